@@ -0,0 +1,95 @@
+// Package bots classifies the clicks into the clicks of the humans and of the
+// bots, such as the link previews of the chat apps, the crawlers and the
+// prefetches of the browsers.
+package bots
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/chutommy/url-shortener/useragent"
+)
+
+// Reasons of the click being made by a bot.
+const (
+	ReasonHead      = "head"
+	ReasonPrefetch  = "prefetch"
+	ReasonEmptyUA   = "empty_user_agent"
+	ReasonKnownBot  = "known_bot"
+	ReasonAdminRule = "rule"
+)
+
+// purposeHeaders announce the prefetches and the previews of the browsers.
+var purposeHeaders = []string{"Sec-Purpose", "Purpose", "X-Purpose", "X-Moz"}
+
+// Request holds the details of the click the classification is based on.
+type Request struct {
+	Method    string
+	UserAgent string
+	Purpose   string
+}
+
+// Purpose returns the first prefetch or preview header of the request.
+func Purpose(h http.Header) string {
+	for _, name := range purposeHeaders {
+		if v := h.Get(name); v != "" {
+			return v
+		}
+	}
+
+	return ""
+}
+
+// Classifier matches the clicks against the known bots and the rules, which
+// are the lowercase substrings of the user agents of the bots. The Classifier
+// is immutable, it is replaced as a whole when the rules change.
+type Classifier struct {
+	rules []string
+}
+
+// New is a constructor of the Classifier with the rules added to the known bots.
+func New(rules []string) *Classifier {
+	c := &Classifier{rules: make([]string, 0, len(rules))}
+
+	for _, r := range rules {
+		if r = strings.ToLower(strings.TrimSpace(r)); r != "" {
+			c.rules = append(c.rules, r)
+		}
+	}
+
+	return c
+}
+
+// Classify reports whether the click is made by a bot and the reason. The
+// known bots are matched even by a nil Classifier.
+func (c *Classifier) Classify(r Request) (bool, string) {
+	ua := strings.ToLower(strings.TrimSpace(r.UserAgent))
+
+	switch {
+	case strings.EqualFold(r.Method, http.MethodHead):
+		return true, ReasonHead
+	case isPrefetch(r.Purpose):
+		return true, ReasonPrefetch
+	case ua == "":
+		return true, ReasonEmptyUA
+	case useragent.Parse(ua).Device == useragent.DeviceBot:
+		return true, ReasonKnownBot
+	}
+
+	if c != nil {
+		for _, rule := range c.rules {
+			if strings.Contains(ua, rule) {
+				return true, ReasonAdminRule
+			}
+		}
+	}
+
+	return false, ""
+}
+
+// isPrefetch reports whether the purpose header announces a prefetch or a preview.
+func isPrefetch(purpose string) bool {
+	p := strings.ToLower(purpose)
+
+	return strings.Contains(p, "prefetch") || strings.Contains(p, "preview")
+}
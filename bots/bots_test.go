@@ -0,0 +1,72 @@
+package bots_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/chutommy/url-shortener/bots"
+	"github.com/stretchr/testify/assert"
+)
+
+const chrome = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) " +
+	"Chrome/120.0.0.0 Safari/537.36"
+
+func TestClassifier_Classify(t *testing.T) {
+	c := bots.New([]string{" Acme-Monitor ", ""})
+
+	tests := []struct {
+		name   string
+		req    bots.Request
+		bot    bool
+		reason string
+	}{
+		{name: "browser", req: bots.Request{Method: http.MethodGet, UserAgent: chrome}},
+		{name: "head", req: bots.Request{Method: http.MethodHead, UserAgent: chrome}, bot: true, reason: bots.ReasonHead},
+		{
+			name: "prefetch", req: bots.Request{Method: http.MethodGet, UserAgent: chrome, Purpose: "prefetch;prerender"},
+			bot: true, reason: bots.ReasonPrefetch,
+		},
+		{name: "empty user agent", req: bots.Request{Method: http.MethodGet}, bot: true, reason: bots.ReasonEmptyUA},
+		{
+			name: "slack preview", req: bots.Request{Method: http.MethodGet, UserAgent: "Slackbot-LinkExpanding 1.0 (+https://api.slack.com/robots)"},
+			bot: true, reason: bots.ReasonKnownBot,
+		},
+		{
+			name: "whatsapp preview", req: bots.Request{Method: http.MethodGet, UserAgent: "WhatsApp/2.23.20.0 A"},
+			bot: true, reason: bots.ReasonKnownBot,
+		},
+		{
+			name: "admin rule", req: bots.Request{Method: http.MethodGet, UserAgent: chrome + " acme-monitor/2.0"},
+			bot: true, reason: bots.ReasonAdminRule,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			bot, reason := c.Classify(tc.req)
+			assert.Equal(t, tc.bot, bot)
+			assert.Equal(t, tc.reason, reason)
+		})
+	}
+}
+
+func TestClassifier_Nil(t *testing.T) {
+	var c *bots.Classifier
+
+	bot, _ := c.Classify(bots.Request{Method: http.MethodGet, UserAgent: "Googlebot/2.1"})
+	assert.True(t, bot)
+
+	bot, _ = c.Classify(bots.Request{Method: http.MethodGet, UserAgent: chrome})
+	assert.False(t, bot)
+}
+
+func TestPurpose(t *testing.T) {
+	h := http.Header{}
+	assert.Equal(t, "", bots.Purpose(h))
+
+	h.Set("X-Moz", "prefetch")
+	assert.Equal(t, "prefetch", bots.Purpose(h))
+
+	h.Set("Sec-Purpose", "prefetch;prerender")
+	assert.Equal(t, "prefetch;prerender", bots.Purpose(h))
+}
@@ -0,0 +1,84 @@
+package client_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/chutommy/url-shortener/client"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSigner_Sign(t *testing.T) {
+	s := client.NewSigner("AbCdEfGh", "secret")
+	s.Now = func() time.Time {
+		return time.Unix(1600000000, 0)
+	}
+
+	body := `{"full_url":"https://example.com","short_url":"ex"}`
+
+	r, err := http.NewRequest(http.MethodPost, "http://localhost:8080/v1/admin/url?x=1", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Nil(t, s.Sign(r))
+
+	// body is preserved
+	b, err := ioutil.ReadAll(r.Body)
+	assert.Nil(t, err)
+	assert.Equal(t, body, string(b))
+
+	// signature can be verified
+	cred, err := client.ParseAuthorization(r.Header.Get("Authorization"))
+	if assert.Nil(t, err) {
+		assert.Equal(t, "AbCdEfGh", cred.Prefix)
+		assert.Equal(t, int64(1600000000), cred.Timestamp)
+		assert.NotEmpty(t, cred.Nonce)
+
+		sts := client.StringToSign(http.MethodPost, "/v1/admin/url", "x=1", cred.Timestamp, cred.Nonce,
+			client.BodyHash([]byte(body)))
+		assert.Equal(t, client.Signature([]byte("secret"), sts), cred.Signature)
+		assert.NotEqual(t, client.Signature([]byte("other"), sts), cred.Signature)
+	}
+}
+
+var parseAuthorizationTests = []struct {
+	name string
+	h    string
+	cred *client.Credentials
+	err  error
+}{
+	{
+		name: "valid",
+		h:    "HMAC-SHA256 Credential=AbCdEfGh, Timestamp=1600000000, Nonce=abc, Signature=def",
+		cred: &client.Credentials{Prefix: "AbCdEfGh", Timestamp: 1600000000, Nonce: "abc", Signature: "def"},
+	},
+	{
+		name: "bearer scheme",
+		h:    "Bearer token",
+		err:  client.ErrInvalidAuthorization,
+	},
+	{
+		name: "missing nonce",
+		h:    "HMAC-SHA256 Credential=AbCdEfGh, Timestamp=1600000000, Signature=def",
+		err:  client.ErrInvalidAuthorization,
+	},
+	{
+		name: "invalid timestamp",
+		h:    "HMAC-SHA256 Credential=AbCdEfGh, Timestamp=now, Nonce=abc, Signature=def",
+		err:  client.ErrInvalidAuthorization,
+	},
+}
+
+func TestParseAuthorization(t *testing.T) {
+	for _, tc := range parseAuthorizationTests {
+		t.Run(tc.name, func(t *testing.T) {
+			cred, err := client.ParseAuthorization(tc.h)
+			assert.Equal(t, tc.err, err)
+			assert.Equal(t, tc.cred, cred)
+		})
+	}
+}
@@ -0,0 +1,99 @@
+package config
+
+import "time"
+
+const (
+	defaultClickQueueSize     = 10000
+	defaultClickFlushInterval = time.Second
+	defaultDedupWindow        = 30 * time.Second
+	defaultDedupCapacity      = 100000
+)
+
+// Analytics holds settings of the click analytics. The clicks are queued by the
+// redirects and written in batches every FlushInterval by a background writer.
+// The clicks are dropped if more than QueueSize of them wait for the writer.
+// IPHashKey is the secret of the hashed client IPs, it is loaded from the
+// environment, a random key is used if it is not set. The repeated clicks of
+// a visitor within DedupWindow are logged as repeats, at most DedupCapacity
+// recent visitors are remembered, a zero window disables the de-duplication.
+type Analytics struct {
+	QueueSize     int      `json:"queue_size"`
+	FlushInterval string   `json:"flush_interval"`
+	DedupWindow   string   `json:"dedup_window"`
+	DedupCapacity int      `json:"dedup_capacity"`
+	Privacy       *Privacy `json:"privacy,omitempty"`
+	IPHashKey     []byte   `json:"-"`
+}
+
+// Queue returns the capacity of the click queue. A nil Analytics results in the default value.
+func (an *Analytics) Queue() (int, error) {
+	if an == nil || an.QueueSize == 0 {
+		return defaultClickQueueSize, nil
+	}
+
+	if an.QueueSize < 0 {
+		return 0, ErrInvalidAnalytics
+	}
+
+	return an.QueueSize, nil
+}
+
+// Flush returns the parsed interval of the click writes. A nil Analytics results in the default value.
+func (an *Analytics) Flush() (time.Duration, error) {
+	if an == nil || an.FlushInterval == "" {
+		return defaultClickFlushInterval, nil
+	}
+
+	d, err := time.ParseDuration(an.FlushInterval)
+	if err != nil || d <= 0 {
+		return 0, ErrInvalidAnalytics
+	}
+
+	return d, nil
+}
+
+// Dedup returns the parsed de-duplication window and the capacity of the
+// remembered visitors. A nil Analytics results in the default values.
+func (an *Analytics) Dedup() (time.Duration, int, error) {
+	if an == nil {
+		return defaultDedupWindow, defaultDedupCapacity, nil
+	}
+
+	window, capacity := defaultDedupWindow, an.DedupCapacity
+
+	if an.DedupWindow != "" {
+		d, err := time.ParseDuration(an.DedupWindow)
+		if err != nil || d < 0 {
+			return 0, 0, ErrInvalidAnalytics
+		}
+
+		window = d
+	}
+
+	switch {
+	case capacity < 0:
+		return 0, 0, ErrInvalidAnalytics
+	case capacity == 0:
+		capacity = defaultDedupCapacity
+	}
+
+	return window, capacity, nil
+}
+
+// PrivacySettings returns the privacy settings, nil if they are not set.
+func (an *Analytics) PrivacySettings() *Privacy {
+	if an == nil {
+		return nil
+	}
+
+	return an.Privacy
+}
+
+// HashKey returns the secret of the hashed client IPs, nil if it is not set.
+func (an *Analytics) HashKey() []byte {
+	if an == nil {
+		return nil
+	}
+
+	return an.IPHashKey
+}
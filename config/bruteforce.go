@@ -0,0 +1,105 @@
+package config
+
+import (
+	"net"
+	"strings"
+	"time"
+)
+
+// Default values of the brute-force protection.
+const (
+	defaultFreeAttempts = 5
+	defaultBaseDelay    = time.Second
+	defaultMaxDelay     = 15 * time.Minute
+	defaultResetAfter   = time.Hour
+)
+
+// BruteForce holds settings of the protection against brute-force attacks on
+// the admin authentication. Each failed attempt over FreeAttempts doubles the
+// lockout duration starting at BaseDelay up to MaxDelay. Failure counters are
+// forgotten after ResetAfter without any new failure. The X-Forwarded-For header
+// is only trusted if the request comes from one of the TrustedProxies.
+type BruteForce struct {
+	FreeAttempts   int      `json:"free_attempts"`
+	BaseDelay      string   `json:"base_delay"`
+	MaxDelay       string   `json:"max_delay"`
+	ResetAfter     string   `json:"reset_after"`
+	TrustedProxies []string `json:"trusted_proxies"`
+}
+
+// Delays returns parsed durations of the brute-force protection. Unset values
+// are replaced by the defaults, so a nil BruteForce is valid as well.
+func (bf *BruteForce) Delays() (base, max, reset time.Duration, err error) {
+	base, max, reset = defaultBaseDelay, defaultMaxDelay, defaultResetAfter
+	if bf == nil {
+		return base, max, reset, nil
+	}
+
+	for _, d := range []struct {
+		s   string
+		dst *time.Duration
+	}{
+		{bf.BaseDelay, &base},
+		{bf.MaxDelay, &max},
+		{bf.ResetAfter, &reset},
+	} {
+		if d.s == "" {
+			continue
+		}
+
+		if *d.dst, err = time.ParseDuration(d.s); err != nil || *d.dst <= 0 {
+			return 0, 0, 0, ErrInvalidBruteForce
+		}
+	}
+
+	if base > max {
+		return 0, 0, 0, ErrInvalidBruteForce
+	}
+
+	return base, max, reset, nil
+}
+
+// Attempts returns the number of failed attempts which are tolerated without a lockout.
+func (bf *BruteForce) Attempts() int {
+	if bf == nil || bf.FreeAttempts <= 0 {
+		return defaultFreeAttempts
+	}
+
+	return bf.FreeAttempts
+}
+
+// Proxies returns parsed networks of the trusted proxies. Both single IP
+// addresses and CIDR notations are accepted.
+func (bf *BruteForce) Proxies() ([]*net.IPNet, error) {
+	if bf == nil {
+		return nil, nil
+	}
+
+	nets := make([]*net.IPNet, 0, len(bf.TrustedProxies))
+
+	for _, p := range bf.TrustedProxies {
+		if !strings.Contains(p, "/") {
+			ip := net.ParseIP(p)
+			if ip == nil {
+				return nil, ErrInvalidBruteForce
+			}
+
+			if ip4 := ip.To4(); ip4 != nil {
+				ip = ip4
+			}
+
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(len(ip)*8, len(ip)*8)})
+
+			continue
+		}
+
+		_, n, err := net.ParseCIDR(p)
+		if err != nil {
+			return nil, ErrInvalidBruteForce
+		}
+
+		nets = append(nets, n)
+	}
+
+	return nets, nil
+}
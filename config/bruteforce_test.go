@@ -0,0 +1,113 @@
+package config_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/chutommy/url-shortener/config"
+	"github.com/stretchr/testify/assert"
+)
+
+var bruteForceDelaysTests = []struct {
+	name  string
+	bf    *config.BruteForce
+	base  time.Duration
+	max   time.Duration
+	reset time.Duration
+	err   error
+}{
+	{
+		name:  "nil settings",
+		bf:    nil,
+		base:  time.Second,
+		max:   15 * time.Minute,
+		reset: time.Hour,
+	},
+	{
+		name: "custom delays",
+		bf: &config.BruteForce{
+			BaseDelay:  "2s",
+			MaxDelay:   "1m",
+			ResetAfter: "10m",
+		},
+		base:  2 * time.Second,
+		max:   time.Minute,
+		reset: 10 * time.Minute,
+	},
+	{
+		name: "partial settings",
+		bf: &config.BruteForce{
+			MaxDelay: "1h",
+		},
+		base:  time.Second,
+		max:   time.Hour,
+		reset: time.Hour,
+	},
+	{
+		name: "invalid duration",
+		bf: &config.BruteForce{
+			BaseDelay: "1km",
+		},
+		err: config.ErrInvalidBruteForce,
+	},
+	{
+		name: "negative duration",
+		bf: &config.BruteForce{
+			ResetAfter: "-1m",
+		},
+		err: config.ErrInvalidBruteForce,
+	},
+	{
+		name: "base over max",
+		bf: &config.BruteForce{
+			BaseDelay: "1h",
+			MaxDelay:  "1m",
+		},
+		err: config.ErrInvalidBruteForce,
+	},
+}
+
+func TestBruteForce_Delays(t *testing.T) {
+	for _, tc := range bruteForceDelaysTests {
+		t.Run(tc.name, func(t *testing.T) {
+			base, max, reset, err := tc.bf.Delays()
+			if tc.err != nil {
+				assert.Equal(t, tc.err, err)
+
+				return
+			}
+
+			assert.Nil(t, err)
+			assert.Equal(t, tc.base, base)
+			assert.Equal(t, tc.max, max)
+			assert.Equal(t, tc.reset, reset)
+		})
+	}
+}
+
+func TestBruteForce_Attempts(t *testing.T) {
+	var bf *config.BruteForce
+	assert.Equal(t, 5, bf.Attempts())
+	assert.Equal(t, 5, (&config.BruteForce{}).Attempts())
+	assert.Equal(t, 3, (&config.BruteForce{FreeAttempts: 3}).Attempts())
+}
+
+func TestBruteForce_Proxies(t *testing.T) {
+	var bf *config.BruteForce
+	nets, err := bf.Proxies()
+	assert.Nil(t, err)
+	assert.Empty(t, nets)
+
+	bf = &config.BruteForce{TrustedProxies: []string{"10.0.0.1", "192.168.0.0/16", "::1"}}
+	nets, err = bf.Proxies()
+	if assert.Nil(t, err) && assert.Len(t, nets, 3) {
+		assert.Equal(t, "10.0.0.1/32", nets[0].String())
+		assert.Equal(t, "192.168.0.0/16", nets[1].String())
+		assert.Equal(t, "::1/128", nets[2].String())
+	}
+
+	for _, p := range []string{"localhost", "10.0.0.0/33", ""} {
+		_, err = (&config.BruteForce{TrustedProxies: []string{p}}).Proxies()
+		assert.Equal(t, config.ErrInvalidBruteForce, err, p)
+	}
+}
@@ -19,6 +19,43 @@ var (
 	ErrDriverNotSupported = errors.New("only postgres sql driver is supported")
 	// ErrInvalidTimeFormat is returned if given time can not be correctly formatted.
 	ErrInvalidTimeFormat = errors.New("invalid server timeout duration")
+	// ErrInvalidBruteForce is returned if the brute-force protection settings are invalid.
+	ErrInvalidBruteForce = errors.New("invalid brute-force protection settings")
+	// ErrInvalidSession is returned if the lifetimes of the admin sessions are invalid.
+	ErrInvalidSession = errors.New("invalid admin session lifetimes")
+	// ErrInvalidOIDC is returned if the OpenID Connect settings are incomplete.
+	ErrInvalidOIDC = errors.New(
+		"invalid oidc settings: issuer, client_id, redirect_url and role_mapping to admin or viewer roles are required")
+	// ErrInvalidTLS is returned if the TLS settings are incomplete or invalid.
+	ErrInvalidTLS = errors.New(
+		"invalid tls settings: cert_file and key_file are required, client_ca_file is required for client certificates")
+	// ErrInvalidSigning is returned if the maximal clock skew of signed requests is invalid.
+	ErrInvalidSigning = errors.New("invalid max clock skew of signed requests")
+	// ErrInvalidLog is returned if the log level or format is not supported.
+	ErrInvalidLog = errors.New("invalid log settings: level must be debug, info, warn or error and format text or json")
+	// ErrInvalidReporting is returned if an unknown error reporter is enabled.
+	ErrInvalidReporting = errors.New("invalid error reporting: reporters must be db, file or stderr, file requires a file")
+	// ErrInvalidMetrics is returned if the path of the metrics endpoint is not absolute.
+	ErrInvalidMetrics = errors.New("invalid metrics path: it must start with '/'")
+	// ErrInvalidTracing is returned if the tracing exporter or sample ratio is invalid.
+	ErrInvalidTracing = errors.New(
+		"invalid tracing settings: exporter must be otlp, stdout or file (with a file) and sample_ratio between 0 and 1")
+	// ErrInvalidHealth is returned if the drain delay of the shutdown is invalid.
+	ErrInvalidHealth = errors.New("invalid health settings: drain_delay must be a non-negative duration")
+	// ErrInvalidAnalytics is returned if the click queue size, flush interval or de-duplication is invalid.
+	ErrInvalidAnalytics = errors.New("invalid analytics settings: queue_size and dedup_capacity must be " +
+		"non-negative, flush_interval a positive duration and dedup_window a non-negative duration")
+	// ErrInvalidGeoIP is returned if the reload interval of the geoip database is invalid.
+	ErrInvalidGeoIP = errors.New("invalid geoip settings: reload_interval must be a positive duration")
+	// ErrInvalidRollup is returned if the rollup interval or a retention is invalid.
+	ErrInvalidRollup = errors.New(
+		"invalid rollup settings: interval must be a positive duration, raw_retention at least 1h and hourly_retention at least 24h")
+	// ErrInvalidLive is returned if the heartbeat interval or the limits of the live click stream are invalid.
+	ErrInvalidLive = errors.New(
+		"invalid live settings: heartbeat must be a positive duration, buffer and max_subscribers non-negative")
+	// ErrInvalidSigningKeys is returned if the session signing keys can not be parsed.
+	ErrInvalidSigningKeys = errors.New(
+		"invalid session signing keys (URL_SHORTENER_SESSION_KEYS): expected comma-separated 'id:secret' pairs")
 	// ErrDBCONNEnvVarNotSet is returned if environment variable of the database connection is not set.
 	ErrDBCONNEnvVarNotSet = errors.New(
 		"environment variable of url (URL_SHORTENER_DBCONN) for database connection is not set")
@@ -26,9 +63,24 @@ var (
 
 // Config represents the server's settings and the configuration of the database.
 type Config struct {
-	SrvPort    int    `json:"server_port"`
-	SrvTimeOut string `json:"server_timeout"`
-	DB         *DB    `json:"db"`
+	SrvPort    int         `json:"server_port"`
+	SrvTimeOut string      `json:"server_timeout"`
+	DB         *DB         `json:"db"`
+	BruteForce *BruteForce `json:"brute_force,omitempty"`
+	Session    *Session    `json:"session,omitempty"`
+	OIDC       *OIDC       `json:"oidc,omitempty"`
+	TLS        *TLS        `json:"tls,omitempty"`
+	Signing    *Signing    `json:"request_signing,omitempty"`
+	Log        *Log        `json:"log,omitempty"`
+	Metrics    *Metrics    `json:"metrics,omitempty"`
+	Tracing    *Tracing    `json:"tracing,omitempty"`
+	Health     *Health     `json:"health,omitempty"`
+	Analytics  *Analytics  `json:"analytics,omitempty"`
+	GeoIP      *GeoIP      `json:"geoip,omitempty"`
+	Rollup     *Rollup     `json:"rollup,omitempty"`
+	Live       *Live       `json:"live,omitempty"`
+
+	ErrorReporting *ErrorReporting `json:"error_reporting,omitempty"`
 }
 
 // GetConfig returns configuration based on the given file.
@@ -49,6 +101,34 @@ func GetConfig(file string) (*Config, error) {
 
 	cfg.DB.DBConn = dbConn
 
+	// load optional session signing keys
+	if sesKeys := os.Getenv("URL_SHORTENER_SESSION_KEYS"); sesKeys != "" {
+		keys, err := ParseSigningKeys(sesKeys)
+		if err != nil {
+			return nil, err
+		}
+
+		if cfg.Session == nil {
+			cfg.Session = &Session{}
+		}
+
+		cfg.Session.Keys = keys
+	}
+
+	// load optional secret of the hashed client IPs
+	if ipKey := os.Getenv("URL_SHORTENER_IP_HASH_KEY"); ipKey != "" {
+		if cfg.Analytics == nil {
+			cfg.Analytics = &Analytics{}
+		}
+
+		cfg.Analytics.IPHashKey = []byte(ipKey)
+	}
+
+	// load oidc client secret
+	if cfg.OIDC != nil {
+		cfg.OIDC.ClientSecret = os.Getenv("URL_SHORTENER_OIDC_CLIENT_SECRET")
+	}
+
 	return &cfg, nil
 }
 
@@ -69,7 +149,10 @@ func OpenConfig(file string) (cfg Config, err error) { // open config file
 	}
 
 	defer func() {
-		err = f.Close()
+		// do not override the decoding and validation errors
+		if cErr := f.Close(); err == nil {
+			err = cErr
+		}
 	}()
 
 	// validate file extension
@@ -96,5 +179,99 @@ func OpenConfig(file string) (cfg Config, err error) { // open config file
 		return Config{}, ErrInvalidTimeFormat
 	}
 
+	// validate brute-force protection
+	if _, _, _, err = cfg.BruteForce.Delays(); err != nil {
+		return Config{}, err
+	}
+
+	if _, err = cfg.BruteForce.Proxies(); err != nil {
+		return Config{}, err
+	}
+
+	// validate admin sessions
+	if _, _, err = cfg.Session.Lifetimes(); err != nil {
+		return Config{}, err
+	}
+
+	// validate oidc login
+	if err = cfg.OIDC.Validate(); err != nil {
+		return Config{}, err
+	}
+
+	// validate tls
+	if err = cfg.TLS.Validate(); err != nil {
+		return Config{}, err
+	}
+
+	// validate request signing
+	if _, err = cfg.Signing.ClockSkew(); err != nil {
+		return Config{}, err
+	}
+
+	// validate logging
+	if _, err = cfg.Log.Leveler(); err != nil {
+		return Config{}, err
+	}
+
+	if _, err = cfg.Log.OutputFormat(); err != nil {
+		return Config{}, err
+	}
+
+	// validate error reporting
+	if err = cfg.ErrorReporting.Validate(); err != nil {
+		return Config{}, err
+	}
+
+	// validate metrics
+	if _, err = cfg.Metrics.Endpoint(); err != nil {
+		return Config{}, err
+	}
+
+	// validate tracing
+	if err = cfg.Tracing.Validate(); err != nil {
+		return Config{}, err
+	}
+
+	// validate health probes
+	if _, err = cfg.Health.Drain(); err != nil {
+		return Config{}, err
+	}
+
+	// validate click analytics
+	if _, err = cfg.Analytics.Queue(); err != nil {
+		return Config{}, err
+	}
+
+	if _, err = cfg.Analytics.Flush(); err != nil {
+		return Config{}, err
+	}
+
+	if _, _, err = cfg.Analytics.Dedup(); err != nil {
+		return Config{}, err
+	}
+
+	// validate geolocation
+	if _, err = cfg.GeoIP.Reload(); err != nil {
+		return Config{}, err
+	}
+
+	// validate rollups
+	if _, err = cfg.Rollup.Every(); err != nil {
+		return Config{}, err
+	}
+
+	if _, _, err = cfg.Rollup.Retentions(); err != nil {
+		return Config{}, err
+	}
+
+	// validate live click stream
+	if _, err = cfg.Live.HeartbeatInterval(); err != nil {
+		return Config{}, err
+	}
+
+	if _, _, err = cfg.Live.Limits(); err != nil {
+		return Config{}, err
+	}
+
 	return cfg, nil
 }
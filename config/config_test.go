@@ -60,6 +60,96 @@ var openConfigTests = []struct {
 		cfg:  config.Config{},
 		err:  config.ErrFileNotFound,
 	},
+	{
+		name: "invalid brute-force delays",
+		file: "settings_7.json",
+		cfg:  config.Config{},
+		err:  config.ErrInvalidBruteForce,
+	},
+	{
+		name: "invalid session lifetimes",
+		file: "settings_8.json",
+		cfg:  config.Config{},
+		err:  config.ErrInvalidSession,
+	},
+	{
+		name: "invalid oidc role mapping",
+		file: "settings_9.json",
+		cfg:  config.Config{},
+		err:  config.ErrInvalidOIDC,
+	},
+	{
+		name: "invalid tls client identity",
+		file: "settings_10.json",
+		cfg:  config.Config{},
+		err:  config.ErrInvalidTLS,
+	},
+	{
+		name: "invalid log level",
+		file: "settings_11.json",
+		cfg:  config.Config{},
+		err:  config.ErrInvalidLog,
+	},
+	{
+		name: "file reporter without file",
+		file: "settings_12.json",
+		cfg:  config.Config{},
+		err:  config.ErrInvalidReporting,
+	},
+	{
+		name: "relative metrics path",
+		file: "settings_13.json",
+		cfg:  config.Config{},
+		err:  config.ErrInvalidMetrics,
+	},
+	{
+		name: "invalid sample ratio",
+		file: "settings_14.json",
+		cfg:  config.Config{},
+		err:  config.ErrInvalidTracing,
+	},
+	{
+		name: "negative drain delay",
+		file: "settings_15.json",
+		cfg:  config.Config{},
+		err:  config.ErrInvalidHealth,
+	},
+	{
+		name: "zero click flush interval",
+		file: "settings_16.json",
+		cfg:  config.Config{},
+		err:  config.ErrInvalidAnalytics,
+	},
+	{
+		name: "invalid geoip reload interval",
+		file: "settings_17.json",
+		cfg:  config.Config{},
+		err:  config.ErrInvalidGeoIP,
+	},
+	{
+		name: "too short hourly retention",
+		file: "settings_18.json",
+		cfg:  config.Config{},
+		err:  config.ErrInvalidRollup,
+	},
+	{
+		name: "negative dedup window",
+		file: "settings_19.json",
+		cfg:  config.Config{},
+		err:  config.ErrInvalidAnalytics,
+	},
+	{
+		name: "zero live heartbeat",
+		file: "settings_20.json",
+		cfg:  config.Config{},
+		err:  config.ErrInvalidLive,
+	},
+	{
+		name: "invalid trusted proxy",
+		file: "settings_21.json",
+		cfg:  config.Config{},
+		err:  config.ErrInvalidBruteForce,
+	},
 }
 
 func TestOpenConfig(t *testing.T) {
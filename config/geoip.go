@@ -0,0 +1,34 @@
+package config
+
+import "time"
+
+// defaultGeoIPReload is the default interval of the checks of the database file changes.
+const defaultGeoIPReload = time.Minute
+
+// GeoIP holds settings of the geolocation of the clicks. Database is the path of
+// a local MaxMind DB file (such as GeoLite2-City.mmdb) which is reloaded when it
+// changes. The clicks are not geolocated if the Database is not set or missing.
+type GeoIP struct {
+	Database       string `json:"database"`
+	ReloadInterval string `json:"reload_interval"`
+}
+
+// Enabled reports whether the clicks are geolocated.
+func (g *GeoIP) Enabled() bool {
+	return g != nil && g.Database != ""
+}
+
+// Reload returns the parsed interval of the checks of the file changes.
+// A nil GeoIP results in the default value.
+func (g *GeoIP) Reload() (time.Duration, error) {
+	if g == nil || g.ReloadInterval == "" {
+		return defaultGeoIPReload, nil
+	}
+
+	d, err := time.ParseDuration(g.ReloadInterval)
+	if err != nil || d <= 0 {
+		return 0, ErrInvalidGeoIP
+	}
+
+	return d, nil
+}
@@ -0,0 +1,28 @@
+package config
+
+import "time"
+
+// defaultDrainDelay is the default time between failing the readiness probe and
+// the shutdown of the listener.
+const defaultDrainDelay = 5 * time.Second
+
+// Health holds settings of the health probes. When the server is stopping, the
+// readiness probe fails for DrainDelay before the listener is shut down, so the
+// load balancers stop routing new requests to the server.
+type Health struct {
+	DrainDelay string `json:"drain_delay"`
+}
+
+// Drain returns the parsed drain delay. A nil Health results in the default value.
+func (hl *Health) Drain() (time.Duration, error) {
+	if hl == nil || hl.DrainDelay == "" {
+		return defaultDrainDelay, nil
+	}
+
+	d, err := time.ParseDuration(hl.DrainDelay)
+	if err != nil || d < 0 {
+		return 0, ErrInvalidHealth
+	}
+
+	return d, nil
+}
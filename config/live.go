@@ -0,0 +1,56 @@
+package config
+
+import "time"
+
+const (
+	defaultLiveHeartbeat      = 15 * time.Second
+	defaultLiveBuffer         = 256
+	defaultLiveMaxSubscribers = 100
+)
+
+// Live holds settings of the live stream of the clicks. Every subscriber has a
+// buffer of Buffer clicks, the clicks which do not fit are dropped and the
+// subscriber is told how many it missed. At most MaxSubscribers streams are
+// served at once and a heartbeat is sent after every Heartbeat.
+type Live struct {
+	Heartbeat      string `json:"heartbeat"`
+	Buffer         int    `json:"buffer"`
+	MaxSubscribers int    `json:"max_subscribers"`
+}
+
+// HeartbeatInterval returns the parsed interval of the heartbeats. A nil Live results in the default value.
+func (l *Live) HeartbeatInterval() (time.Duration, error) {
+	if l == nil || l.Heartbeat == "" {
+		return defaultLiveHeartbeat, nil
+	}
+
+	d, err := time.ParseDuration(l.Heartbeat)
+	if err != nil || d <= 0 {
+		return 0, ErrInvalidLive
+	}
+
+	return d, nil
+}
+
+// Limits returns the buffer size of a subscriber and the maximal number of the
+// subscribers. A nil Live results in the default values.
+func (l *Live) Limits() (buffer int, subscribers int, err error) {
+	buffer, subscribers = defaultLiveBuffer, defaultLiveMaxSubscribers
+	if l == nil {
+		return buffer, subscribers, nil
+	}
+
+	if l.Buffer < 0 || l.MaxSubscribers < 0 {
+		return 0, 0, ErrInvalidLive
+	}
+
+	if l.Buffer > 0 {
+		buffer = l.Buffer
+	}
+
+	if l.MaxSubscribers > 0 {
+		subscribers = l.MaxSubscribers
+	}
+
+	return buffer, subscribers, nil
+}
@@ -0,0 +1,43 @@
+package config
+
+import "log/slog"
+
+// Formats of the log output.
+const (
+	LogFormatText = "text"
+	LogFormatJSON = "json"
+)
+
+// Log holds settings of the structured logger. Level is one of debug, info,
+// warn or error and Format is either text or json.
+type Log struct {
+	Level  string `json:"level"`
+	Format string `json:"format"`
+}
+
+// Leveler returns the parsed minimal log level. A nil Log results in the info level.
+func (lg *Log) Leveler() (slog.Level, error) {
+	if lg == nil || lg.Level == "" {
+		return slog.LevelInfo, nil
+	}
+
+	var lvl slog.Level
+	if err := lvl.UnmarshalText([]byte(lg.Level)); err != nil {
+		return 0, ErrInvalidLog
+	}
+
+	return lvl, nil
+}
+
+// OutputFormat returns the validated log format. A nil Log results in the text format.
+func (lg *Log) OutputFormat() (string, error) {
+	if lg == nil || lg.Format == "" {
+		return LogFormatText, nil
+	}
+
+	if lg.Format != LogFormatText && lg.Format != LogFormatJSON {
+		return "", ErrInvalidLog
+	}
+
+	return lg.Format, nil
+}
@@ -0,0 +1,43 @@
+package config
+
+import "strings"
+
+// defaultMetricsPath is the default path of the metrics endpoint.
+const defaultMetricsPath = "/metrics"
+
+// Metrics holds settings of the Prometheus metrics endpoint. The metrics are
+// served by the API server unless Addr of a separate listener is set.
+type Metrics struct {
+	Disabled bool   `json:"disabled"`
+	Path     string `json:"path"`
+	Addr     string `json:"addr"`
+}
+
+// Enabled reports whether the metrics are served. A nil Metrics results in enabled metrics.
+func (m *Metrics) Enabled() bool {
+	return m == nil || !m.Disabled
+}
+
+// Endpoint returns the validated path of the metrics endpoint. A nil Metrics
+// results in the default path.
+func (m *Metrics) Endpoint() (string, error) {
+	if m == nil || m.Path == "" {
+		return defaultMetricsPath, nil
+	}
+
+	if !strings.HasPrefix(m.Path, "/") {
+		return "", ErrInvalidMetrics
+	}
+
+	return m.Path, nil
+}
+
+// Listener returns the address of the separate metrics listener. It is empty
+// if the metrics are served by the API server.
+func (m *Metrics) Listener() string {
+	if m == nil {
+		return ""
+	}
+
+	return m.Addr
+}
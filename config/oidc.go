@@ -0,0 +1,47 @@
+package config
+
+// Admin roles which can be granted by the identity provider.
+const (
+	roleAdmin  = "admin"
+	roleViewer = "viewer"
+)
+
+// OIDC holds settings of the OpenID Connect login of the admin users. The client
+// secret is loaded from the URL_SHORTENER_OIDC_CLIENT_SECRET environment variable.
+// Values of the RolesClaim (e.g. groups) are mapped to the admin roles by RoleMapping.
+type OIDC struct {
+	Issuer            string            `json:"issuer"`
+	ClientID          string            `json:"client_id"`
+	ClientSecret      string            `json:"-"`
+	RedirectURL       string            `json:"redirect_url"`
+	Scopes            []string          `json:"scopes"`
+	UsernameClaim     string            `json:"username_claim"`
+	RolesClaim        string            `json:"roles_claim"`
+	RoleMapping       map[string]string `json:"role_mapping"`
+	DisableLocalLogin bool              `json:"disable_local_login"`
+}
+
+// Validate checks that all required values of the OIDC settings are set.
+// A nil OIDC is valid and means that the OIDC login is disabled.
+func (o *OIDC) Validate() error {
+	if o == nil {
+		return nil
+	}
+
+	if o.Issuer == "" || o.ClientID == "" || o.RedirectURL == "" || len(o.RoleMapping) == 0 {
+		return ErrInvalidOIDC
+	}
+
+	for _, role := range o.RoleMapping {
+		if role != roleAdmin && role != roleViewer {
+			return ErrInvalidOIDC
+		}
+	}
+
+	return nil
+}
+
+// LocalLogin reports whether the admin users can log in with the local credentials.
+func (o *OIDC) LocalLogin() bool {
+	return o == nil || !o.DisableLocalLogin
+}
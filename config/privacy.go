@@ -0,0 +1,29 @@
+package config
+
+// Privacy holds settings of the privacy of the click analytics. HonorDNT stores
+// the clicks with the DNT or Sec-GPC header without the details of the visitor,
+// they are only counted. TruncateIP zeroes the host part of the client IPs
+// (/24 of IPv4, /48 of IPv6) before they are geolocated and hashed.
+// RotateSaltDaily salts the hashed client IPs by a random salt of the UTC day
+// which is deleted after the next day, so the visitors can not be linked across
+// the days and the hashes of the past days can not be recomputed.
+type Privacy struct {
+	HonorDNT        bool `json:"honor_dnt"`
+	TruncateIP      bool `json:"truncate_ip"`
+	RotateSaltDaily bool `json:"rotate_salt_daily"`
+}
+
+// DNT reports whether the DNT and Sec-GPC headers are honored.
+func (p *Privacy) DNT() bool {
+	return p != nil && p.HonorDNT
+}
+
+// Truncate reports whether the client IPs are truncated.
+func (p *Privacy) Truncate() bool {
+	return p != nil && p.TruncateIP
+}
+
+// Rotate reports whether the hashed client IPs are salted by a daily salt.
+func (p *Privacy) Rotate() bool {
+	return p != nil && p.RotateSaltDaily
+}
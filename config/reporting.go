@@ -0,0 +1,42 @@
+package config
+
+// Names of the error reporters.
+const (
+	ReporterDB     = "db"
+	ReporterFile   = "file"
+	ReporterStderr = "stderr"
+)
+
+// ErrorReporting holds settings of the error reporters. The file reporter
+// appends the errors as JSON lines to File.
+type ErrorReporting struct {
+	Reporters []string `json:"reporters"`
+	File      string   `json:"file"`
+}
+
+// Names returns the enabled reporters. A nil ErrorReporting results in the db reporter only.
+func (er *ErrorReporting) Names() []string {
+	if er == nil || len(er.Reporters) == 0 {
+		return []string{ReporterDB}
+	}
+
+	return er.Reporters
+}
+
+// Validate checks that only known reporters are enabled and that the file
+// reporter has a file.
+func (er *ErrorReporting) Validate() error {
+	for _, name := range er.Names() {
+		switch name {
+		case ReporterDB, ReporterStderr:
+		case ReporterFile:
+			if er.File == "" {
+				return ErrInvalidReporting
+			}
+		default:
+			return ErrInvalidReporting
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,65 @@
+package config
+
+import "time"
+
+// defaultRollupInterval is the default interval of the rollup job.
+const defaultRollupInterval = 5 * time.Minute
+
+// Rollup holds settings of the rollups of the raw clicks into the hourly and
+// daily aggregates. The raw clicks older than RawRetention and the hourly
+// aggregates older than HourlyRetention are deleted once they are rolled up.
+// An empty retention keeps the rows forever, the daily aggregates are kept forever.
+// The monthly partitions of the raw clicks which expire as a whole are dropped.
+type Rollup struct {
+	Interval        string `json:"interval"`
+	RawRetention    string `json:"raw_retention"`
+	HourlyRetention string `json:"hourly_retention"`
+}
+
+// Every returns the parsed interval of the rollup job. A nil Rollup results in the default value.
+func (ro *Rollup) Every() (time.Duration, error) {
+	if ro == nil || ro.Interval == "" {
+		return defaultRollupInterval, nil
+	}
+
+	d, err := time.ParseDuration(ro.Interval)
+	if err != nil || d <= 0 {
+		return 0, ErrInvalidRollup
+	}
+
+	return d, nil
+}
+
+// Retentions returns the parsed retentions of the raw clicks and the hourly
+// aggregates, zero means the rows are kept forever. The raw clicks must be
+// kept for at least an hour and the hourly aggregates for at least a day, so
+// they are rolled up before they are deleted.
+func (ro *Rollup) Retentions() (raw time.Duration, hourly time.Duration, err error) {
+	if ro == nil {
+		return 0, 0, nil
+	}
+
+	if raw, err = parseRetention(ro.RawRetention, time.Hour); err != nil {
+		return 0, 0, err
+	}
+
+	if hourly, err = parseRetention(ro.HourlyRetention, 24*time.Hour); err != nil {
+		return 0, 0, err
+	}
+
+	return raw, hourly, nil
+}
+
+// parseRetention parses the retention which is either empty or at least min.
+func parseRetention(s string, min time.Duration) (time.Duration, error) {
+	if s == "" {
+		return 0, nil
+	}
+
+	d, err := time.ParseDuration(s)
+	if err != nil || d < min {
+		return 0, ErrInvalidRollup
+	}
+
+	return d, nil
+}
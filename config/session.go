@@ -0,0 +1,92 @@
+package config
+
+import (
+	"strings"
+	"time"
+)
+
+// Default lifetimes of the admin sessions.
+const (
+	defaultSessionTTL = 15 * time.Minute
+	defaultRefreshTTL = 24 * time.Hour
+)
+
+// Session holds settings of the interactive admin sessions. Access tokens are
+// valid for TTL, the sessions can be refreshed until RefreshTTL elapses.
+type Session struct {
+	TTL        string       `json:"ttl"`
+	RefreshTTL string       `json:"refresh_ttl"`
+	Keys       []SigningKey `json:"-"`
+}
+
+// SigningKey is a secret used to sign session tokens. The ID is embedded into
+// each token so the keys can be rotated without invalidating active sessions.
+type SigningKey struct {
+	ID     string
+	Secret []byte
+}
+
+// Lifetimes returns parsed lifetimes of the access and refresh tokens. Unset
+// values are replaced by the defaults, so a nil Session is valid as well.
+func (ses *Session) Lifetimes() (ttl, refreshTTL time.Duration, err error) {
+	ttl, refreshTTL = defaultSessionTTL, defaultRefreshTTL
+	if ses == nil {
+		return ttl, refreshTTL, nil
+	}
+
+	if ses.TTL != "" {
+		if ttl, err = time.ParseDuration(ses.TTL); err != nil || ttl <= 0 {
+			return 0, 0, ErrInvalidSession
+		}
+	}
+
+	if ses.RefreshTTL != "" {
+		if refreshTTL, err = time.ParseDuration(ses.RefreshTTL); err != nil || refreshTTL <= 0 {
+			return 0, 0, ErrInvalidSession
+		}
+	}
+
+	if ttl > refreshTTL {
+		return 0, 0, ErrInvalidSession
+	}
+
+	return ttl, refreshTTL, nil
+}
+
+// ActiveKey returns the key which signs new tokens. It is the first one of the keys.
+func (ses *Session) ActiveKey() (SigningKey, bool) {
+	if ses == nil || len(ses.Keys) == 0 {
+		return SigningKey{}, false
+	}
+
+	return ses.Keys[0], true
+}
+
+// ParseSigningKeys parses comma-separated signing keys in the "id:secret" format.
+// The first key is the active one, the rest are accepted only for verification.
+func ParseSigningKeys(s string) ([]SigningKey, error) {
+	var keys []SigningKey
+
+	seen := make(map[string]bool)
+
+	for _, pair := range strings.Split(s, ",") {
+		i := strings.Index(pair, ":")
+		if i <= 0 || i == len(pair)-1 {
+			return nil, ErrInvalidSigningKeys
+		}
+
+		id, secret := strings.TrimSpace(pair[:i]), pair[i+1:]
+		if id == "" || seen[id] {
+			return nil, ErrInvalidSigningKeys
+		}
+
+		seen[id] = true
+
+		keys = append(keys, SigningKey{
+			ID:     id,
+			Secret: []byte(secret),
+		})
+	}
+
+	return keys, nil
+}
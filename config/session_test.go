@@ -0,0 +1,77 @@
+package config_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/chutommy/url-shortener/config"
+	"github.com/stretchr/testify/assert"
+)
+
+var parseSigningKeysTests = []struct {
+	name string
+	s    string
+	keys []config.SigningKey
+	err  error
+}{
+	{
+		name: "single key",
+		s:    "k1:secret",
+		keys: []config.SigningKey{
+			{ID: "k1", Secret: []byte("secret")},
+		},
+	},
+	{
+		name: "rotated keys",
+		s:    "k2:new:secret,k1:old",
+		keys: []config.SigningKey{
+			{ID: "k2", Secret: []byte("new:secret")},
+			{ID: "k1", Secret: []byte("old")},
+		},
+	},
+	{
+		name: "missing secret",
+		s:    "k1:",
+		err:  config.ErrInvalidSigningKeys,
+	},
+	{
+		name: "missing id",
+		s:    ":secret",
+		err:  config.ErrInvalidSigningKeys,
+	},
+	{
+		name: "duplicate id",
+		s:    "k1:a,k1:b",
+		err:  config.ErrInvalidSigningKeys,
+	},
+}
+
+func TestParseSigningKeys(t *testing.T) {
+	for _, tc := range parseSigningKeysTests {
+		t.Run(tc.name, func(t *testing.T) {
+			keys, err := config.ParseSigningKeys(tc.s)
+			assert.Equal(t, tc.err, err)
+			assert.Equal(t, tc.keys, keys)
+		})
+	}
+}
+
+func TestSession_Lifetimes(t *testing.T) {
+	var ses *config.Session
+
+	ttl, refreshTTL, err := ses.Lifetimes()
+	assert.Nil(t, err)
+	assert.Equal(t, 15*time.Minute, ttl)
+	assert.Equal(t, 24*time.Hour, refreshTTL)
+
+	ttl, refreshTTL, err = (&config.Session{TTL: "5m", RefreshTTL: "1h"}).Lifetimes()
+	assert.Nil(t, err)
+	assert.Equal(t, 5*time.Minute, ttl)
+	assert.Equal(t, time.Hour, refreshTTL)
+
+	_, _, err = (&config.Session{TTL: "5 minutes"}).Lifetimes()
+	assert.Equal(t, config.ErrInvalidSession, err)
+
+	_, ok := ses.ActiveKey()
+	assert.False(t, ok)
+}
@@ -0,0 +1,26 @@
+package config
+
+import "time"
+
+// defaultMaxClockSkew is the default tolerance of signed requests' timestamps.
+const defaultMaxClockSkew = 5 * time.Minute
+
+// Signing holds settings of the HMAC signed admin requests. Requests whose
+// timestamp differs from the server's clock by more than MaxClockSkew are rejected.
+type Signing struct {
+	MaxClockSkew string `json:"max_clock_skew"`
+}
+
+// ClockSkew returns the parsed maximal clock skew. A nil Signing results in the default value.
+func (sg *Signing) ClockSkew() (time.Duration, error) {
+	if sg == nil || sg.MaxClockSkew == "" {
+		return defaultMaxClockSkew, nil
+	}
+
+	d, err := time.ParseDuration(sg.MaxClockSkew)
+	if err != nil || d <= 0 {
+		return 0, ErrInvalidSigning
+	}
+
+	return d, nil
+}
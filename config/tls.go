@@ -0,0 +1,59 @@
+package config
+
+import "strings"
+
+// Prefixes of the client certificate identities.
+var certIdentityPrefixes = []string{"cn:", "dns:", "uri:", "email:"}
+
+// TLS holds settings of the HTTPS listener. If ClientCAFile is set, client
+// certificates signed by one of its CAs are verified and can authorize admin
+// requests. ClientScopes maps certificate identities to the granted scopes.
+// An identity is the subject's common name or a SAN with one of the
+// "cn:", "dns:", "uri:" or "email:" prefixes (e.g. "uri:spiffe://corp/billing").
+type TLS struct {
+	CertFile          string              `json:"cert_file"`
+	KeyFile           string              `json:"key_file"`
+	ClientCAFile      string              `json:"client_ca_file"`
+	RequireClientCert bool                `json:"require_client_cert"`
+	ClientScopes      map[string][]string `json:"client_scopes"`
+}
+
+// Validate checks the TLS settings. A nil TLS is valid and means plain HTTP.
+func (t *TLS) Validate() error {
+	if t == nil {
+		return nil
+	}
+
+	if t.CertFile == "" || t.KeyFile == "" {
+		return ErrInvalidTLS
+	}
+
+	if (t.RequireClientCert || len(t.ClientScopes) > 0) && t.ClientCAFile == "" {
+		return ErrInvalidTLS
+	}
+
+	for id, scopes := range t.ClientScopes {
+		if !hasIdentityPrefix(id) {
+			return ErrInvalidTLS
+		}
+
+		for _, s := range scopes {
+			if s != "read" && s != "write" {
+				return ErrInvalidTLS
+			}
+		}
+	}
+
+	return nil
+}
+
+// hasIdentityPrefix reports whether the certificate identity has a known prefix.
+func hasIdentityPrefix(id string) bool {
+	for _, p := range certIdentityPrefixes {
+		if strings.HasPrefix(id, p) && len(id) > len(p) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,80 @@
+package config
+
+// Exporters of the traces.
+const (
+	TraceExporterOTLP   = "otlp"
+	TraceExporterStdout = "stdout"
+	TraceExporterFile   = "file"
+)
+
+const (
+	defaultOTLPEndpoint = "http://localhost:4318/v1/traces"
+	defaultServiceName  = "url-shortener"
+)
+
+// Tracing holds settings of the tracing. The traces are exported by OTLP/HTTP
+// to Endpoint, written to stdout or appended to File. An empty Exporter disables
+// the tracing. SampleRatio is the ratio of the sampled new traces, all traces
+// are sampled if it is not set.
+type Tracing struct {
+	Exporter    string   `json:"exporter"`
+	Endpoint    string   `json:"endpoint"`
+	File        string   `json:"file"`
+	SampleRatio *float64 `json:"sample_ratio,omitempty"`
+	ServiceName string   `json:"service_name"`
+}
+
+// Enabled reports whether the traces are exported.
+func (tr *Tracing) Enabled() bool {
+	return tr != nil && tr.Exporter != ""
+}
+
+// Validate checks the exporter and its destination.
+func (tr *Tracing) Validate() error {
+	if !tr.Enabled() {
+		return nil
+	}
+
+	switch tr.Exporter {
+	case TraceExporterOTLP, TraceExporterStdout:
+	case TraceExporterFile:
+		if tr.File == "" {
+			return ErrInvalidTracing
+		}
+	default:
+		return ErrInvalidTracing
+	}
+
+	if tr.SampleRatio != nil && (*tr.SampleRatio < 0 || *tr.SampleRatio > 1) {
+		return ErrInvalidTracing
+	}
+
+	return nil
+}
+
+// OTLPEndpoint returns the URL of the OTLP/HTTP traces endpoint.
+func (tr *Tracing) OTLPEndpoint() string {
+	if tr.Endpoint == "" {
+		return defaultOTLPEndpoint
+	}
+
+	return tr.Endpoint
+}
+
+// Ratio returns the sample ratio of the new traces.
+func (tr *Tracing) Ratio() float64 {
+	if tr.SampleRatio == nil {
+		return 1
+	}
+
+	return *tr.SampleRatio
+}
+
+// Service returns the name of the service in the traces.
+func (tr *Tracing) Service() string {
+	if tr.ServiceName == "" {
+		return defaultServiceName
+	}
+
+	return tr.ServiceName
+}
@@ -3,6 +3,7 @@ package controller
 import (
 	"errors"
 	"net/http"
+	"strings"
 
 	"github.com/chutommy/url-shortener/data"
 	"github.com/gin-gonic/gin"
@@ -21,6 +22,9 @@ func (h *handler) GenerateAdminKey(c *gin.Context) {
 		return
 	}
 
+	prefix := strings.SplitN(key, ".", 2)[0]
+	h.audit(c, data.AuditKeyGenerate, prefix, nil, gin.H{"prefix": prefix})
+
 	// success
 	c.JSON(http.StatusOK, gin.H{
 		"admin_key": key,
@@ -57,7 +61,48 @@ func (h *handler) RevokeAdminKey(c *gin.Context) {
 		return
 	}
 
+	h.audit(c, data.AuditKeyRevoke, prefix, nil, nil)
+
 	c.JSON(http.StatusOK, gin.H{
 		"revoked_prefix": prefix,
 	})
 }
+
+// GenerateSigningSecret handles a generation of a request signing secret of an admin_key.
+func (h *handler) GenerateSigningSecret(c *gin.Context) {
+	// load prefix
+	prefix := c.PostForm("prefix")
+	if prefix == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "missing prefix form field",
+		})
+
+		return
+	}
+
+	// generate
+	secret, err := h.ds.GenerateSigningSecret(c, prefix)
+	if err != nil {
+		if errors.Is(err, data.ErrPrefixNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": err.Error(),
+			})
+
+			return
+		}
+
+		h.ds.LogError(c, err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": data.ErrUnexpectedError,
+		})
+
+		return
+	}
+
+	h.audit(c, data.AuditSigningSecret, prefix, nil, nil)
+
+	c.JSON(http.StatusOK, gin.H{
+		"prefix":         prefix,
+		"signing_secret": secret,
+	})
+}
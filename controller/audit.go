@@ -0,0 +1,168 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/chutommy/url-shortener/data"
+	"github.com/chutommy/url-shortener/middleware"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultAuditLimit = 50
+	maxAuditLimit     = 500
+)
+
+// errInvalidAuditFilter is returned if the query parameters of the audit filter are invalid.
+var errInvalidAuditFilter = errors.New("invalid audit filter, times must be in RFC 3339 and numbers positive")
+
+// audit records an administrative action of the request's actor. The snapshots
+// of the target are stored as JSON. A failure is logged but does not fail the request.
+func (h *handler) audit(c *gin.Context, action, target string, before, after interface{}) {
+	actorType, actor := middleware.Actor(c)
+
+	e := &data.AuditEvent{
+		ActorType: actorType,
+		Actor:     actor,
+		ClientIP:  c.ClientIP(),
+		Action:    action,
+		Target:    target,
+	}
+
+	var err error
+	if e.Before, err = snapshot(before); err != nil {
+		h.ds.LogError(c, err)
+	}
+
+	if e.After, err = snapshot(after); err != nil {
+		h.ds.LogError(c, err)
+	}
+
+	if err = h.ds.LogAuditEvent(c, e); err != nil {
+		h.ds.LogError(c, err)
+	}
+}
+
+// snapshot encodes the value into JSON. Nil values have no snapshot.
+func snapshot(v interface{}) (json.RawMessage, error) {
+	if v == nil {
+		return nil, nil
+	}
+
+	if r, ok := v.(*data.Record); ok && r == nil {
+		return nil, nil
+	}
+
+	return json.Marshal(v)
+}
+
+// auditFilter loads the audit filter from the query parameters.
+func auditFilter(c *gin.Context) (*data.AuditFilter, error) {
+	f := &data.AuditFilter{
+		ActorType: c.Query("actor_type"),
+		Actor:     c.Query("actor"),
+		Action:    c.Query("action"),
+		Target:    c.Query("target"),
+	}
+
+	var err error
+
+	if v := c.Query("since"); v != "" {
+		if f.Since, err = time.Parse(time.RFC3339, v); err != nil {
+			return nil, errInvalidAuditFilter
+		}
+	}
+
+	if v := c.Query("until"); v != "" {
+		if f.Until, err = time.Parse(time.RFC3339, v); err != nil {
+			return nil, errInvalidAuditFilter
+		}
+	}
+
+	if v := c.Query("before_id"); v != "" {
+		if f.BeforeID, err = strconv.ParseInt(v, 10, 64); err != nil || f.BeforeID < 1 {
+			return nil, errInvalidAuditFilter
+		}
+	}
+
+	return f, nil
+}
+
+// GetAuditEvents serves a page of the audit events, newest first. The next page
+// is requested with the returned next_before_id.
+func (h *handler) GetAuditEvents(c *gin.Context) {
+	// load filter
+	f, err := auditFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+
+		return
+	}
+
+	f.Limit = defaultAuditLimit
+	if v := c.Query("limit"); v != "" {
+		if f.Limit, err = strconv.Atoi(v); err != nil || f.Limit < 1 || f.Limit > maxAuditLimit {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "limit must be a number between 1 and " + strconv.Itoa(maxAuditLimit),
+			})
+
+			return
+		}
+	}
+
+	// get events
+	events, err := h.ds.GetAuditEvents(c, f)
+	if err != nil {
+		h.ds.LogError(c, err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": data.ErrUnexpectedError,
+		})
+
+		return
+	}
+
+	resp := gin.H{
+		"events": events,
+	}
+	if len(events) == f.Limit {
+		resp["next_before_id"] = events[len(events)-1].ID
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+// ExportAuditEvents streams all audit events matching the filter as JSON Lines.
+// The events are flushed in batches, so a large export is not cut by the write
+// timeout of the server.
+func (h *handler) ExportAuditEvents(c *gin.Context) {
+	// load filter
+	f, err := auditFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+
+		return
+	}
+
+	// stream events
+	es := newExportStream(c, "audit", formatJSONL)
+
+	err = h.ds.ExportAuditEvents(c, f, func(e *data.AuditEvent) error {
+		return es.write(nil, e)
+	})
+	if err == nil {
+		err = es.close()
+	}
+
+	if err != nil {
+		// the response has already started
+		h.ds.LogError(c, err)
+	}
+}
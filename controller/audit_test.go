@@ -0,0 +1,205 @@
+package controller
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/chutommy/url-shortener/data"
+	"github.com/chutommy/url-shortener/middleware"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeAuditService serves the audit events from memory, newest first.
+type fakeAuditService struct {
+	data.Service
+
+	events []*data.AuditEvent
+	filter *data.AuditFilter
+	delay  time.Duration
+}
+
+func (s *fakeAuditService) LogError(context.Context, error) {}
+
+func (s *fakeAuditService) GetAuditEvents(ctx context.Context, f *data.AuditFilter) ([]*data.AuditEvent, error) {
+	var events []*data.AuditEvent
+
+	err := s.ExportAuditEvents(ctx, f, func(e *data.AuditEvent) error {
+		events = append(events, e)
+
+		return nil
+	})
+
+	return events, err
+}
+
+func (s *fakeAuditService) ExportAuditEvents(_ context.Context, f *data.AuditFilter, fn func(*data.AuditEvent) error) error {
+	s.filter = f
+
+	n := 0
+
+	for _, e := range s.events {
+		if (f.BeforeID > 0 && e.ID >= f.BeforeID) || (f.Action != "" && e.Action != f.Action) {
+			continue
+		}
+
+		if f.Limit > 0 && n == f.Limit {
+			break
+		}
+
+		time.Sleep(s.delay)
+
+		if err := fn(e); err != nil {
+			return err
+		}
+
+		n++
+	}
+
+	return nil
+}
+
+func newAuditServer(t *testing.T, ds *fakeAuditService) *httptest.Server {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	for id := int64(5); id > 0; id-- {
+		ds.events = append(ds.events, &data.AuditEvent{
+			ID:        id,
+			ActorType: "user",
+			Actor:     "admin",
+			Action:    data.AuditRecordCreate,
+			Target:    "target",
+			LoggedAt:  time.Date(2024, time.March, 1, 0, 0, int(id), 0, time.UTC),
+		})
+	}
+
+	h := &handler{ds: ds}
+
+	r := gin.New()
+	r.GET("/events", h.GetAuditEvents)
+	r.GET("/export", h.ExportAuditEvents)
+
+	srv := httptest.NewUnstartedServer(middleware.ResponseControllers(r))
+	srv.Config.WriteTimeout = 100 * time.Millisecond
+	srv.Start()
+	t.Cleanup(srv.Close)
+
+	return srv
+}
+
+func TestGetAuditEvents(t *testing.T) {
+	tests := []struct {
+		name   string
+		query  string
+		status int
+		ids    []int64
+		next   int64
+	}{
+		{name: "first page", query: "?limit=2", status: http.StatusOK, ids: []int64{5, 4}, next: 4},
+		{name: "next page", query: "?limit=2&before_id=4", status: http.StatusOK, ids: []int64{3, 2}, next: 2},
+		{name: "last page", query: "?limit=2&before_id=2", status: http.StatusOK, ids: []int64{1}},
+		{name: "default limit", query: "", status: http.StatusOK, ids: []int64{5, 4, 3, 2, 1}},
+		{name: "filtered", query: "?action=" + data.AuditKeyRevoke, status: http.StatusOK, ids: []int64{}},
+		{name: "zero limit", query: "?limit=0", status: http.StatusBadRequest},
+		{name: "large limit", query: "?limit=501", status: http.StatusBadRequest},
+		{name: "invalid since", query: "?since=yesterday", status: http.StatusBadRequest},
+		{name: "invalid until", query: "?until=2024-03-01", status: http.StatusBadRequest},
+		{name: "invalid before_id", query: "?before_id=-1", status: http.StatusBadRequest},
+	}
+
+	srv := newAuditServer(t, &fakeAuditService{})
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			resp, err := http.Get(srv.URL + "/events" + tc.query)
+			if !assert.NoError(t, err) {
+				return
+			}
+
+			defer func() {
+				_ = resp.Body.Close()
+			}()
+
+			assert.Equal(t, tc.status, resp.StatusCode)
+
+			if tc.status != http.StatusOK {
+				return
+			}
+
+			var body struct {
+				Events []*data.AuditEvent `json:"events"`
+				Next   int64              `json:"next_before_id"`
+			}
+
+			assert.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+
+			ids := []int64{}
+			for _, e := range body.Events {
+				ids = append(ids, e.ID)
+			}
+
+			assert.Equal(t, tc.ids, ids)
+			assert.Equal(t, tc.next, body.Next)
+		})
+	}
+}
+
+func TestExportAuditEvents(t *testing.T) {
+	// the export outlasts the write timeout of the server
+	ds := &fakeAuditService{delay: 50 * time.Millisecond}
+	srv := newAuditServer(t, ds)
+
+	for _, gzipped := range []bool{false, true} {
+		req, _ := http.NewRequest(http.MethodGet, srv.URL+"/export?since=2024-03-01T00:00:02Z", nil)
+		if gzipped {
+			req.Header.Set("Accept-Encoding", "gzip")
+		}
+
+		resp, err := http.DefaultTransport.RoundTrip(req)
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, "application/x-ndjson", resp.Header.Get("Content-Type"))
+		assert.Equal(t, `attachment; filename="audit.jsonl"`, resp.Header.Get("Content-Disposition"))
+		assert.Equal(t, 0, ds.filter.Limit)
+		assert.Equal(t, time.Date(2024, time.March, 1, 0, 0, 2, 0, time.UTC), ds.filter.Since)
+
+		var body io.Reader = resp.Body
+		if gzipped {
+			assert.Equal(t, "gzip", resp.Header.Get("Content-Encoding"))
+
+			gz, err := gzip.NewReader(resp.Body)
+			if !assert.NoError(t, err) {
+				return
+			}
+
+			body = gz
+		}
+
+		// every line is one event
+		var ids []int64
+
+		sc := bufio.NewScanner(body)
+		for sc.Scan() {
+			var e data.AuditEvent
+			if assert.NoError(t, json.Unmarshal(sc.Bytes(), &e)) {
+				ids = append(ids, e.ID)
+			}
+		}
+
+		assert.NoError(t, sc.Err())
+		assert.Equal(t, []int64{5, 4, 3, 2, 1}, ids)
+
+		_ = resp.Body.Close()
+	}
+}
@@ -0,0 +1,115 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/chutommy/url-shortener/bots"
+	"github.com/chutommy/url-shortener/data"
+	"github.com/gin-gonic/gin"
+)
+
+// botRuleRequest is the body of a new bot rule.
+type botRuleRequest struct {
+	Pattern string `json:"pattern" binding:"required"`
+}
+
+// GetBotRules serves the rules of the bots added by the admins. The clicks are
+// also matched against the known bots, the HEAD requests and the prefetches.
+func (h *handler) GetBotRules(c *gin.Context) {
+	rules, err := h.ds.GetBotRules(c)
+	if err != nil {
+		h.ds.LogError(c, err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": data.ErrUnexpectedError,
+		})
+
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"rules": rules,
+		"reasons": []string{
+			bots.ReasonHead, bots.ReasonPrefetch, bots.ReasonEmptyUA, bots.ReasonKnownBot, bots.ReasonAdminRule,
+		},
+	})
+}
+
+// AddBotRule adds a rule, the clicks whose user agent contains the pattern are
+// classified as the clicks of a bot from now on.
+func (h *handler) AddBotRule(c *gin.Context) {
+	// bind rule
+	var req botRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+
+		return
+	}
+
+	// add rule
+	r, err := h.ds.AddBotRule(c, req.Pattern)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrInvalidBotRule):
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": err.Error(),
+			})
+
+		case errors.Is(err, data.ErrBotRuleExists):
+			c.JSON(http.StatusConflict, gin.H{
+				"error": err.Error(),
+			})
+
+		default:
+			h.ds.LogError(c, err)
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": data.ErrUnexpectedError,
+			})
+		}
+
+		return
+	}
+
+	h.audit(c, data.AuditBotRuleCreate, strconv.FormatInt(r.ID, 10), nil, r)
+
+	c.JSON(http.StatusOK, r)
+}
+
+// DeleteBotRule removes the rule with the certain ID. The clicks classified
+// by the rule before are kept as the clicks of a bot.
+func (h *handler) DeleteBotRule(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("rule_id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "rule_id must be a number",
+		})
+
+		return
+	}
+
+	if err = h.ds.DeleteBotRule(c, id); err != nil {
+		if errors.Is(err, data.ErrBotRuleNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": err.Error(),
+			})
+
+			return
+		}
+
+		h.ds.LogError(c, err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": data.ErrUnexpectedError,
+		})
+
+		return
+	}
+
+	h.audit(c, data.AuditBotRuleDelete, c.Param("rule_id"), nil, nil)
+
+	c.JSON(http.StatusOK, gin.H{
+		"deleted_rule_id": id,
+	})
+}
@@ -0,0 +1,127 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/chutommy/url-shortener/data"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultErrorGroupsLimit = 50
+	maxErrorGroupsLimit     = 500
+)
+
+// GetErrorGroups serves the error groups, the most recently seen first. The groups
+// can be filtered by the status query parameter (open or resolved) and paginated
+// by the limit and offset query parameters.
+func (h *handler) GetErrorGroups(c *gin.Context) {
+	// load filter
+	f := &data.ErrorGroupFilter{
+		Status: c.Query("status"),
+		Limit:  defaultErrorGroupsLimit,
+	}
+
+	if f.Status != "" && f.Status != data.ErrorStatusOpen && f.Status != data.ErrorStatusResolved {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "status must be either open or resolved",
+		})
+
+		return
+	}
+
+	var err error
+
+	if v := c.Query("limit"); v != "" {
+		if f.Limit, err = strconv.Atoi(v); err != nil || f.Limit < 1 || f.Limit > maxErrorGroupsLimit {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "limit must be a number between 1 and " + strconv.Itoa(maxErrorGroupsLimit),
+			})
+
+			return
+		}
+	}
+
+	if v := c.Query("offset"); v != "" {
+		if f.Offset, err = strconv.Atoi(v); err != nil || f.Offset < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "offset must be a non-negative number",
+			})
+
+			return
+		}
+	}
+
+	// get groups
+	groups, err := h.ds.GetErrorGroups(c, f)
+	if err != nil {
+		h.ds.LogError(c, err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": data.ErrUnexpectedError,
+		})
+
+		return
+	}
+
+	c.JSON(http.StatusOK, groups)
+}
+
+// GetErrorGroup serves the error group with its latest occurrences.
+func (h *handler) GetErrorGroup(c *gin.Context) {
+	fingerprint := c.Param("fingerprint")
+
+	// get group
+	g, occurrences, err := h.ds.GetErrorGroup(c, fingerprint)
+	if err != nil {
+		if errors.Is(err, data.ErrErrorGroupNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": err.Error(),
+			})
+
+			return
+		}
+
+		h.ds.LogError(c, err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": data.ErrUnexpectedError,
+		})
+
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"group":       g,
+		"occurrences": occurrences,
+	})
+}
+
+// ResolveErrorGroup marks the error group as resolved.
+func (h *handler) ResolveErrorGroup(c *gin.Context) {
+	fingerprint := c.Param("fingerprint")
+
+	// resolve
+	if err := h.ds.ResolveErrorGroup(c, fingerprint); err != nil {
+		if errors.Is(err, data.ErrErrorGroupNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": err.Error(),
+			})
+
+			return
+		}
+
+		h.ds.LogError(c, err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": data.ErrUnexpectedError,
+		})
+
+		return
+	}
+
+	h.audit(c, data.AuditErrorResolve, fingerprint, nil, nil)
+
+	c.JSON(http.StatusOK, gin.H{
+		"resolved_fingerprint": fingerprint,
+	})
+}
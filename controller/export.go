@@ -0,0 +1,305 @@
+package controller
+
+import (
+	"compress/gzip"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/chutommy/url-shortener/data"
+	"github.com/chutommy/url-shortener/middleware"
+	"github.com/gin-gonic/gin"
+)
+
+// Formats of the exports.
+const (
+	formatCSV   = "csv"
+	formatJSONL = "jsonl"
+)
+
+const (
+	// defaultExportRange is the default length of the range of the exported clicks.
+	defaultExportRange = 24 * time.Hour
+	// exportFlushRows is the number of the rows after which the export is flushed to the client.
+	exportFlushRows = 1000
+	// exportWriteTimeout is the time the client has to receive the next flushed rows.
+	exportWriteTimeout = 30 * time.Second
+)
+
+var (
+	clickEventHeader = []string{
+		"id", "shortcut_id", "short_url", "time", "referrer", "referrer_host", "user_agent", "browser", "os",
+		"device", "language", "country", "region", "city", "query", "is_bot", "bot_reason", "is_repeat", "cursor",
+	}
+	seriesPointHeader = []string{"shortcut_id", "short_url", "bucket", "clicks", "cursor"}
+)
+
+// exportStream writes the rows of an export as CSV or JSON Lines, gzipped if
+// the client accepts it. The rows are flushed in batches and the write
+// deadline of the response is extended with every batch, so a large export is
+// not cut by the write timeout of the server as long as the client reads it.
+type exportStream struct {
+	c    *gin.Context
+	gz   *gzip.Writer
+	csv  *csv.Writer
+	json *json.Encoder
+	rows int
+}
+
+// newExportStream starts the response of the export named by the name.
+func newExportStream(c *gin.Context, name, format string) *exportStream {
+	es := &exportStream{c: c}
+	es.extendDeadline()
+
+	var w io.Writer = c.Writer
+
+	c.Header("Vary", "Accept-Encoding")
+
+	if strings.Contains(c.GetHeader("Accept-Encoding"), "gzip") {
+		c.Header("Content-Encoding", "gzip")
+		es.gz = gzip.NewWriter(c.Writer)
+		w = es.gz
+	}
+
+	if format == formatCSV {
+		c.Header("Content-Type", "text/csv; charset=utf-8")
+		es.csv = csv.NewWriter(w)
+	} else {
+		c.Header("Content-Type", "application/x-ndjson")
+		es.json = json.NewEncoder(w)
+	}
+
+	c.Header("Content-Disposition", `attachment; filename="`+name+"."+format+`"`)
+	c.Status(http.StatusOK)
+
+	return es
+}
+
+// header writes the header row of the CSV.
+func (es *exportStream) header(record []string) error {
+	if es.csv == nil {
+		return nil
+	}
+
+	return es.csv.Write(record)
+}
+
+// write writes the row either as the CSV record or as the JSON encoded value.
+func (es *exportStream) write(record []string, v interface{}) error {
+	var err error
+	if es.csv != nil {
+		err = es.csv.Write(record)
+	} else {
+		err = es.json.Encode(v)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if es.rows++; es.rows%exportFlushRows == 0 {
+		return es.flush()
+	}
+
+	return nil
+}
+
+// flush sends the buffered rows to the client.
+func (es *exportStream) flush() error {
+	if es.csv != nil {
+		es.csv.Flush()
+
+		if err := es.csv.Error(); err != nil {
+			return err
+		}
+	}
+
+	if es.gz != nil {
+		if err := es.gz.Flush(); err != nil {
+			return err
+		}
+	}
+
+	es.c.Writer.Flush()
+	es.extendDeadline()
+
+	return nil
+}
+
+// close flushes the remaining rows and ends the gzip stream.
+func (es *exportStream) close() error {
+	if err := es.flush(); err != nil {
+		return err
+	}
+
+	if es.gz != nil {
+		return es.gz.Close()
+	}
+
+	return nil
+}
+
+// extendDeadline extends the write deadline of the response. The servers
+// without the response controllers keep their write timeout.
+func (es *exportStream) extendDeadline() {
+	_ = middleware.ExtendWriteDeadline(es.c, exportWriteTimeout)
+}
+
+// exportQuery loads the export query from the query parameters. The range
+// defaults to the given length ending now.
+func exportQuery(c *gin.Context, defaultRange time.Duration) (*data.ExportQuery, error) {
+	q := &data.ExportQuery{
+		ShortcutID: strings.ToLower(c.Query("shortcut_id")),
+		Tag:        c.Query("tag"),
+		Bots:       c.DefaultQuery("bots", data.BotsExclude),
+	}
+
+	var err error
+
+	q.To = time.Now()
+	if v := c.Query("to"); v != "" {
+		if q.To, err = time.Parse(time.RFC3339, v); err != nil {
+			return nil, errors.New("to must be an RFC 3339 timestamp")
+		}
+	}
+
+	q.From = q.To.Add(-defaultRange)
+	if v := c.Query("from"); v != "" {
+		if q.From, err = time.Parse(time.RFC3339, v); err != nil {
+			return nil, errors.New("from must be an RFC 3339 timestamp")
+		}
+	}
+
+	if v := c.Query("cursor"); v != "" {
+		if q.Cursor, err = data.ParseExportCursor(v); err != nil {
+			return nil, err
+		}
+	}
+
+	if v := c.Query("limit"); v != "" {
+		if q.Limit, err = strconv.Atoi(v); err != nil || q.Limit < 1 {
+			return nil, errors.New("limit must be a positive number")
+		}
+	}
+
+	return q, nil
+}
+
+// exportFormat returns the format query parameter, JSON Lines by default.
+func exportFormat(c *gin.Context) (string, error) {
+	switch f := c.DefaultQuery("format", formatJSONL); f {
+	case formatCSV, formatJSONL:
+		return f, nil
+	default:
+		return "", errors.New("format must be csv or jsonl")
+	}
+}
+
+// ExportClicks streams the raw clicks of the shortcut given by the shortcut_id
+// query parameter, of the shortcuts with the tag or of all shortcuts in the
+// range given by the from and to query parameters (RFC 3339) as CSV or JSON
+// Lines by the format query parameter. Every row carries its cursor, an export
+// is resumed after the row by the cursor query parameter.
+func (h *handler) ExportClicks(c *gin.Context) {
+	format, err := exportFormat(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+
+		return
+	}
+
+	q, err := exportQuery(c, defaultExportRange)
+	if err == nil {
+		err = q.Validate()
+	}
+
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+
+		return
+	}
+
+	// stream clicks
+	es := newExportStream(c, "clicks", format)
+
+	err = es.header(clickEventHeader)
+	if err == nil {
+		err = h.ds.ExportClicks(c, q, func(e *data.ClickEvent) error {
+			return es.write([]string{
+				strconv.FormatInt(e.ID, 10), e.ShortcutID, e.ShortURL, e.Time.Format(time.RFC3339Nano), e.Referrer,
+				e.ReferrerHost, e.UserAgent, e.Browser, e.OS, e.Device, e.Language, e.Country, e.Region, e.City,
+				e.Query, strconv.FormatBool(e.IsBot), e.BotReason, strconv.FormatBool(e.IsRepeat), e.Cursor,
+			}, e)
+		})
+	}
+
+	if err == nil {
+		err = es.close()
+	}
+
+	if err != nil {
+		// the response has already started
+		h.ds.LogError(c, err)
+	}
+}
+
+// ExportSeries streams the clicks bucketed by the interval query parameter
+// (minute, hour, day or week) in UTC per shortcut, selected like the exported
+// clicks. The empty buckets are skipped. The rolled up clicks are counted
+// also beyond the retention of the raw clicks.
+func (h *handler) ExportSeries(c *gin.Context) {
+	format, err := exportFormat(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+
+		return
+	}
+
+	interval := c.DefaultQuery("interval", data.IntervalDay)
+
+	q, err := exportQuery(c, defaultStatsRanges[interval])
+	if err == nil {
+		q.Interval = interval
+		err = q.Validate()
+	}
+
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+
+		return
+	}
+
+	// stream series
+	es := newExportStream(c, "series", format)
+
+	err = es.header(seriesPointHeader)
+	if err == nil {
+		err = h.ds.ExportSeries(c, q, func(p *data.SeriesPoint) error {
+			return es.write([]string{
+				p.ShortcutID, p.ShortURL, p.Bucket.Format(time.RFC3339), strconv.Itoa(p.Clicks), p.Cursor,
+			}, p)
+		})
+	}
+
+	if err == nil {
+		err = es.close()
+	}
+
+	if err != nil {
+		// the response has already started
+		h.ds.LogError(c, err)
+	}
+}
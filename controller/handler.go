@@ -3,10 +3,16 @@ package controller
 import (
 	"context"
 	"fmt"
+	"log/slog"
 	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/chutommy/url-shortener/config"
 	"github.com/chutommy/url-shortener/data"
+	"github.com/chutommy/url-shortener/middleware"
+	"github.com/chutommy/url-shortener/oidc"
 )
 
 // Handler is a handler interface of the controller.
@@ -14,22 +20,56 @@ type Handler interface {
 	GetHTTPHandler() http.Handler
 	CloseHandler() error
 	InitDataService(context.Context, *config.DB) error
+	InitLimiter(*config.BruteForce)
+	InitSessions(*config.Session) error
+	InitOIDC(*config.OIDC)
+	InitCertScopes(*config.TLS)
+	InitSignatures(*config.Signing)
+	InitReporters(*config.ErrorReporting) error
+	InitMetrics(*config.Metrics)
+	InitClicks(*config.Analytics) error
+	InitGeoIP(*config.GeoIP) error
+	InitRollups(*config.Rollup) error
+	InitLive(*config.Live) error
+	SetReady(bool)
 }
 
 // handler is the controller of the data service actions.
 type handler struct {
-	ds data.Service
+	log *slog.Logger
+	ds  data.Service
+	lim *middleware.Limiter
+	idp *oidc.Provider
+
+	localLogin bool
+	certScopes middleware.CertScopes
+	sigs       *middleware.SignatureVerifier
+
+	// ready is false until the server is running and again once it is stopping
+	ready atomic.Bool
+
+	// streamsDone is closed once the server is stopping, it ends the live streams
+	streamsDone   chan struct{}
+	stopStreams   sync.Once
+	liveHeartbeat time.Duration
+
+	// metricsPath is the path of the metrics endpoint, empty if it is not served by the API
+	metricsPath string
 }
 
-// NewHandler returns an empty handler.
-func NewHandler() Handler {
-	return &handler{}
+// NewHandler returns an empty handler which logs with the given logger.
+func NewHandler(log *slog.Logger) Handler {
+	return &handler{
+		log:         log,
+		localLogin:  true,
+		streamsDone: make(chan struct{}),
+	}
 }
 
 // InitDataService initializes handler's data service.
 func (h *handler) InitDataService(ctx context.Context, dbCfg *config.DB) error {
 	// create new data service
-	h.ds = data.NewService()
+	h.ds = data.Instrument(data.NewService(h.log))
 
 	// initialize data service
 	err := h.ds.InitDB(ctx, dbCfg)
@@ -40,6 +80,107 @@ func (h *handler) InitDataService(ctx context.Context, dbCfg *config.DB) error {
 	return nil
 }
 
+// InitLimiter initializes handler's brute-force protection of the admin authentication.
+func (h *handler) InitLimiter(bfCfg *config.BruteForce) {
+	h.lim = middleware.NewLimiter(bfCfg)
+}
+
+// InitSessions initializes signing of the interactive admin sessions.
+func (h *handler) InitSessions(sesCfg *config.Session) error {
+	err := h.ds.InitSessions(sesCfg)
+	if err != nil {
+		return fmt.Errorf("failed to initialize admin sessions: %w", err)
+	}
+
+	return nil
+}
+
+// InitOIDC initializes the OpenID Connect login of the admin users.
+// A nil configuration disables the OIDC login.
+func (h *handler) InitOIDC(oidcCfg *config.OIDC) {
+	h.localLogin = oidcCfg.LocalLogin()
+	if oidcCfg != nil {
+		h.idp = oidc.NewProvider(oidcCfg)
+	}
+}
+
+// InitCertScopes sets the scopes granted to the client certificates.
+func (h *handler) InitCertScopes(tlsCfg *config.TLS) {
+	if tlsCfg != nil {
+		h.certScopes = tlsCfg.ClientScopes
+	}
+}
+
+// InitSignatures initializes the verification of HMAC signed admin requests.
+func (h *handler) InitSignatures(sigCfg *config.Signing) {
+	h.sigs = middleware.NewSignatureVerifier(sigCfg)
+}
+
+// InitReporters initializes the reporters of the unexpected errors.
+func (h *handler) InitReporters(erCfg *config.ErrorReporting) error {
+	err := h.ds.InitReporters(erCfg)
+	if err != nil {
+		return fmt.Errorf("failed to initialize error reporters: %w", err)
+	}
+
+	return nil
+}
+
+// InitClicks starts the background writer of the click analytics.
+func (h *handler) InitClicks(anCfg *config.Analytics) error {
+	err := h.ds.InitClicks(anCfg)
+	if err != nil {
+		return fmt.Errorf("failed to initialize click analytics: %w", err)
+	}
+
+	return nil
+}
+
+// InitGeoIP initializes the geolocation of the clicks.
+func (h *handler) InitGeoIP(geoCfg *config.GeoIP) error {
+	err := h.ds.InitGeoIP(geoCfg)
+	if err != nil {
+		return fmt.Errorf("failed to initialize geoip: %w", err)
+	}
+
+	return nil
+}
+
+// InitRollups starts the rollups and the retention of the raw clicks.
+func (h *handler) InitRollups(roCfg *config.Rollup) error {
+	err := h.ds.InitRollups(roCfg)
+	if err != nil {
+		return fmt.Errorf("failed to initialize rollups: %w", err)
+	}
+
+	return nil
+}
+
+// InitLive starts the live click stream. It must be initialized before the click analytics.
+func (h *handler) InitLive(liveCfg *config.Live) error {
+	heartbeat, err := liveCfg.HeartbeatInterval()
+	if err != nil {
+		return fmt.Errorf("failed to initialize live click stream: %w", err)
+	}
+
+	if err = h.ds.InitLive(liveCfg); err != nil {
+		return fmt.Errorf("failed to initialize live click stream: %w", err)
+	}
+
+	h.liveHeartbeat = heartbeat
+
+	return nil
+}
+
+// InitMetrics sets the metrics endpoint unless the metrics are disabled or
+// served by a separate listener.
+func (h *handler) InitMetrics(mCfg *config.Metrics) {
+	h.metricsPath = ""
+	if mCfg.Enabled() && mCfg.Listener() == "" {
+		h.metricsPath, _ = mCfg.Endpoint()
+	}
+}
+
 // CloseHandler stops all active connections. closeHandler closes the data service.
 // This function should not be called often (meant to be used only when the server is shutting down).
 func (h *handler) CloseHandler() error {
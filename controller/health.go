@@ -0,0 +1,96 @@
+package controller
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/chutommy/url-shortener/health"
+	"github.com/gin-gonic/gin"
+)
+
+// readyCheckTimeout bounds the database checks of the readiness probe.
+const readyCheckTimeout = 2 * time.Second
+
+// Results of the readiness checks.
+const (
+	checkOK     = "ok"
+	checkFailed = "failed"
+)
+
+// SetReady sets whether the server accepts new requests. The readiness probe
+// fails while the handler is not ready. The live streams end once the handler
+// is not ready anymore, so the shutdown does not wait for them.
+func (h *handler) SetReady(ready bool) {
+	h.ready.Store(ready)
+
+	if !ready {
+		h.stopStreams.Do(func() {
+			close(h.streamsDone)
+		})
+	}
+}
+
+// Healthz reports the process is alive.
+func (h *handler) Healthz(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"status": checkOK,
+	})
+}
+
+// Readyz reports whether the server can serve requests: it is not shutting
+// down, the database is reachable, its schema is at the expected version and
+// all background workers are running.
+func (h *handler) Readyz(c *gin.Context) {
+	checks := gin.H{}
+	ready := true
+
+	fail := func(name, msg string) {
+		checks[name] = msg
+		ready = false
+	}
+
+	// shutdown
+	if h.ready.Load() {
+		checks["server"] = checkOK
+	} else {
+		fail("server", "shutting down")
+	}
+
+	// database
+	ctx, cancel := context.WithTimeout(c, readyCheckTimeout)
+	defer cancel()
+
+	if err := h.ds.Ping(ctx); err != nil {
+		h.log.Warn("readiness check failed", slog.String("check", "database"), slog.Any("error", err))
+		fail("database", checkFailed)
+	} else {
+		checks["database"] = checkOK
+	}
+
+	if err := h.ds.CheckSchema(ctx); err != nil {
+		h.log.Warn("readiness check failed", slog.String("check", "migrations"), slog.Any("error", err))
+		fail("migrations", checkFailed)
+	} else {
+		checks["migrations"] = checkOK
+	}
+
+	// workers
+	if stopped := health.Default.Stopped(); len(stopped) > 0 {
+		fail("workers", "stopped: "+strings.Join(stopped, ", "))
+	} else {
+		checks["workers"] = checkOK
+	}
+
+	status, code := checkOK, http.StatusOK
+	if !ready {
+		status, code = "unavailable", http.StatusServiceUnavailable
+	}
+
+	c.JSON(code, gin.H{
+		"status": status,
+		"checks": checks,
+	})
+}
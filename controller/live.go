@@ -0,0 +1,159 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/chutommy/url-shortener/data"
+	"github.com/chutommy/url-shortener/live"
+	"github.com/chutommy/url-shortener/middleware"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// Events of the live click stream.
+const (
+	eventClick     = "click"
+	eventDropped   = "dropped"
+	eventHeartbeat = "heartbeat"
+)
+
+// liveWriteTimeout is the time the client has to receive an event beyond the heartbeat interval.
+const liveWriteTimeout = 10 * time.Second
+
+// writeEvent writes the value encoded into JSON as a server-sent event.
+func writeEvent(w io.Writer, event string, v interface{}) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, b)
+
+	return err
+}
+
+// StreamClicks streams the clicks as they arrive as server-sent events,
+// optionally only the clicks of the shortcut given by the shortcut_id query
+// parameter or of the shortcuts with the tag. Each click is a click event,
+// a heartbeat event is sent whenever no click arrives within the heartbeat
+// interval. The clicks are dropped while the client does not keep up, the
+// number of the missed clicks is sent in a dropped event.
+func (h *handler) StreamClicks(c *gin.Context) {
+	f := live.Filter{
+		ShortcutID: strings.ToLower(c.Query("shortcut_id")),
+		Tag:        strings.ToLower(c.Query("tag")),
+	}
+
+	if f.ShortcutID != "" {
+		if _, err := uuid.Parse(f.ShortcutID); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": data.ErrInvalidID.Error(),
+			})
+
+			return
+		}
+	}
+
+	// subscribe
+	sub, err := h.ds.SubscribeClicks(f)
+	if err != nil {
+		switch {
+		case errors.Is(err, live.ErrTooManySubscribers), errors.Is(err, live.ErrClosed):
+			c.JSON(http.StatusServiceUnavailable, gin.H{
+				"error": err.Error(),
+			})
+
+		default:
+			h.ds.LogError(c, err)
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": data.ErrUnexpectedError,
+			})
+		}
+
+		return
+	}
+
+	defer sub.Close()
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+
+	// flush sends the written events, the client has the heartbeat interval
+	// to receive them before the next heartbeat
+	flush := func() {
+		c.Writer.Flush()
+		_ = middleware.ExtendWriteDeadline(c, h.liveHeartbeat+liveWriteTimeout)
+	}
+
+	// dropped reports the clicks missed by the client
+	dropped := func() error {
+		if n := sub.Dropped(); n > 0 {
+			return writeEvent(c.Writer, eventDropped, gin.H{"dropped": n})
+		}
+
+		return nil
+	}
+
+	heartbeat := func() error {
+		if err := dropped(); err != nil {
+			return err
+		}
+
+		return writeEvent(c.Writer, eventHeartbeat, gin.H{"time": time.Now().UTC()})
+	}
+
+	// the first heartbeat confirms the subscription
+	if err = heartbeat(); err != nil {
+		return
+	}
+
+	flush()
+
+	ticker := time.NewTicker(h.liveHeartbeat)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case cl, ok := <-sub.Clicks():
+			if !ok {
+				return
+			}
+
+			if err = dropped(); err == nil {
+				err = writeEvent(c.Writer, eventClick, cl)
+			}
+
+			if err != nil {
+				return
+			}
+
+			// the buffered clicks are flushed at once
+			if len(sub.Clicks()) == 0 {
+				flush()
+			}
+
+			ticker.Reset(h.liveHeartbeat)
+
+		case <-ticker.C:
+			if err = heartbeat(); err != nil {
+				return
+			}
+
+			flush()
+
+		case <-c.Request.Context().Done():
+			return
+
+		case <-h.streamsDone:
+			return
+		}
+	}
+}
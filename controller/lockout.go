@@ -0,0 +1,45 @@
+package controller
+
+import (
+	"net/http"
+
+	"github.com/chutommy/url-shortener/data"
+	"github.com/chutommy/url-shortener/middleware"
+	"github.com/gin-gonic/gin"
+)
+
+// GetLockouts serves all active lockouts of the admin authentication.
+func (h *handler) GetLockouts(c *gin.Context) {
+	c.JSON(http.StatusOK, h.lim.Lockouts())
+}
+
+// ClearLockout removes a lockout and the failure counter of the given kind and subject.
+func (h *handler) ClearLockout(c *gin.Context) {
+	// load lockout
+	kind := c.Query("kind")
+	subject := c.Query("subject")
+
+	if kind == "" || subject == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "missing kind or subject query parameter",
+		})
+
+		return
+	}
+
+	// clear
+	if !h.lim.Clear(middleware.LockoutKey(kind, subject)) {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "lockout with the given kind and subject was not found",
+		})
+
+		return
+	}
+
+	h.audit(c, data.AuditLockoutClear, middleware.LockoutKey(kind, subject), nil, nil)
+
+	c.JSON(http.StatusOK, gin.H{
+		"cleared_kind":    kind,
+		"cleared_subject": subject,
+	})
+}
@@ -0,0 +1,12 @@
+package controller
+
+import "github.com/chutommy/url-shortener/metrics"
+
+// Results of the clicks in the metrics.
+const (
+	clickFound    = "found"
+	clickNotFound = "not_found"
+)
+
+var clicks = metrics.Default.Counter("clicks_total",
+	"Resolved clicks of the shortcuts by the result (found or not_found).", "result")
@@ -0,0 +1,133 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/chutommy/url-shortener/data"
+	"github.com/chutommy/url-shortener/oidc"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// oidcCookie keeps the binding of the login, it ties the callback to the browser which started the login.
+	oidcCookie = "oidc_login"
+	// oidcCookieTTL is the time within which the user must finish the login.
+	oidcCookieTTL = 10 * time.Minute
+)
+
+// setOIDCCookie sets the cookie with the binding of the login, an empty binding deletes it.
+// The identity provider redirects the browser back to the callback, so the cookie is lax.
+func setOIDCCookie(c *gin.Context, binding string) {
+	maxAge := int(oidcCookieTTL.Seconds())
+	if binding == "" {
+		maxAge = -1
+	}
+
+	http.SetCookie(c.Writer, &http.Cookie{
+		Name:     oidcCookie,
+		Value:    binding,
+		Path:     "/v1/oidc",
+		MaxAge:   maxAge,
+		Secure:   c.Request.TLS != nil || c.GetHeader("X-Forwarded-Proto") == "https",
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// OIDCLogin redirects the admin user to the identity provider.
+func (h *handler) OIDCLogin(c *gin.Context) {
+	if h.idp == nil {
+		c.JSON(http.StatusNotImplemented, gin.H{
+			"error": "oidc login is not configured",
+		})
+
+		return
+	}
+
+	// start login
+	u, binding, err := h.idp.Begin(c)
+	if err != nil {
+		h.ds.LogError(c, err)
+		c.JSON(http.StatusBadGateway, gin.H{
+			"error": "identity provider is unavailable",
+		})
+
+		return
+	}
+
+	setOIDCCookie(c, binding)
+	c.Redirect(http.StatusFound, u)
+}
+
+// OIDCCallback finishes the login at the identity provider and starts a new admin
+// session with the role mapped from the identity's claims. The login must be
+// finished by the browser which started it.
+func (h *handler) OIDCCallback(c *gin.Context) {
+	if h.idp == nil {
+		c.JSON(http.StatusNotImplemented, gin.H{
+			"error": "oidc login is not configured",
+		})
+
+		return
+	}
+
+	// login rejected by the provider
+	if e := c.Query("error"); e != "" {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "identity provider error: " + e,
+		})
+
+		return
+	}
+
+	// the login is finished only by the browser which started it
+	binding, _ := c.Cookie(oidcCookie)
+	setOIDCCookie(c, "")
+
+	// verify identity
+	id, err := h.idp.Complete(c, c.Query("state"), binding, c.Query("code"))
+	if err != nil {
+		switch {
+		case errors.Is(err, oidc.ErrInvalidState), errors.Is(err, oidc.ErrInvalidToken):
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"error": err.Error(),
+			})
+
+		case errors.Is(err, oidc.ErrNoRole):
+			c.JSON(http.StatusForbidden, gin.H{
+				"error": err.Error(),
+			})
+
+		default:
+			h.ds.LogError(c, err)
+			c.JSON(http.StatusBadGateway, gin.H{
+				"error": "identity provider is unavailable",
+			})
+		}
+
+		return
+	}
+
+	// start session
+	ses, err := h.ds.CreateSession(c, id.Username, id.Role)
+	if err != nil {
+		if errors.Is(err, data.ErrSessionsDisabled) {
+			c.JSON(http.StatusNotImplemented, gin.H{
+				"error": err.Error(),
+			})
+
+			return
+		}
+
+		h.ds.LogError(c, err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": data.ErrUnexpectedError,
+		})
+
+		return
+	}
+
+	c.JSON(http.StatusOK, ses)
+}
@@ -0,0 +1,131 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/chutommy/url-shortener/data"
+	"github.com/gin-gonic/gin"
+)
+
+// analyticsOptRequest is the body of the analytics opt-out of a record.
+type analyticsOptRequest struct {
+	Disabled *bool `json:"disabled" binding:"required"`
+}
+
+// doNotTrack reports whether the request asks not to be tracked by the DNT or
+// Sec-GPC header.
+func doNotTrack(h http.Header) bool {
+	return strings.TrimSpace(h.Get("DNT")) == "1" || strings.TrimSpace(h.Get("Sec-GPC")) == "1"
+}
+
+// SetAnalyticsDisabled opts the record with the certain ID out of the click
+// analytics or back in. The clicks of an opted out record are only counted.
+func (h *handler) SetAnalyticsDisabled(c *gin.Context) {
+	id := c.Param("record_id")
+
+	// bind request
+	var req analyticsOptRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+
+		return
+	}
+
+	if err := h.ds.SetAnalyticsDisabled(c, id, *req.Disabled); err != nil {
+		h.recordAnalyticsError(c, err)
+
+		return
+	}
+
+	h.audit(c, data.AuditAnalyticsOpt, strings.ToLower(id), nil, gin.H{"analytics_disabled": *req.Disabled})
+
+	c.JSON(http.StatusOK, gin.H{
+		"shortcut_id":        strings.ToLower(id),
+		"analytics_disabled": *req.Disabled,
+	})
+}
+
+// EraseRecordAnalytics deletes all stored clicks and aggregates of the record
+// with the certain ID. The usage of the record is kept.
+func (h *handler) EraseRecordAnalytics(c *gin.Context) {
+	id := c.Param("record_id")
+
+	n, err := h.ds.EraseRecordAnalytics(c, id)
+	if err != nil {
+		h.recordAnalyticsError(c, err)
+
+		return
+	}
+
+	h.audit(c, data.AuditEraseRecord, strings.ToLower(id), nil, gin.H{"deleted_clicks": n})
+
+	c.JSON(http.StatusOK, gin.H{
+		"shortcut_id":    strings.ToLower(id),
+		"deleted_clicks": n,
+	})
+}
+
+// EraseVisitorAnalytics deletes the stored clicks of the visitor given by the
+// client IP or its hash in the body, optionally narrowed by the user agent.
+// The body is used, so the client IP is not logged with the request URL.
+func (h *handler) EraseVisitorAnalytics(c *gin.Context) {
+	// bind visitor
+	var v data.VisitorErasure
+	if err := c.ShouldBindJSON(&v); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+
+		return
+	}
+
+	n, err := h.ds.EraseVisitorAnalytics(c, &v)
+	if err != nil {
+		if errors.Is(err, data.ErrInvalidErasure) {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": err.Error(),
+			})
+
+			return
+		}
+
+		h.ds.LogError(c, err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": data.ErrUnexpectedError,
+		})
+
+		return
+	}
+
+	// the visitor is not identified in the audit log
+	h.audit(c, data.AuditEraseVisitor, "visitor", nil, gin.H{"deleted_clicks": n})
+
+	c.JSON(http.StatusOK, gin.H{
+		"deleted_clicks": n,
+	})
+}
+
+// recordAnalyticsError responds with the error of the analytics of a record.
+func (h *handler) recordAnalyticsError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, data.ErrIDNotFound):
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": err.Error(),
+		})
+
+	case errors.Is(err, data.ErrInvalidID):
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+
+	default:
+		h.ds.LogError(c, err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": data.ErrUnexpectedError,
+		})
+	}
+}
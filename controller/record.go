@@ -4,6 +4,7 @@ import (
 	"errors"
 	"net/http"
 
+	"github.com/chutommy/url-shortener/bots"
 	"github.com/chutommy/url-shortener/data"
 	"github.com/gin-gonic/gin"
 )
@@ -26,7 +27,7 @@ func (h *handler) AddRecord(c *gin.Context) {
 	r, err := h.ds.AddRecord(c, &newRecord)
 	if err != nil {
 		switch {
-		case errors.Is(err, data.ErrInvalidRecord):
+		case errors.Is(err, data.ErrInvalidRecord), errors.Is(err, data.ErrInvalidTags):
 			c.JSON(http.StatusBadRequest, gin.H{
 				"error": err.Error(),
 			})
@@ -46,6 +47,8 @@ func (h *handler) AddRecord(c *gin.Context) {
 		return
 	}
 
+	h.audit(c, data.AuditRecordCreate, r.ID, nil, r)
+
 	// record successfully added
 	c.JSON(http.StatusOK, r)
 }
@@ -66,6 +69,9 @@ func (h *handler) UpdateRecord(c *gin.Context) { // get record's ID
 		return
 	}
 
+	// snapshot before, errors are reported by the update
+	before, _ := h.ds.GetRecordByID(c, id)
+
 	// update record
 	r, err := h.ds.UpdateRecord(c, id, &newRecord)
 	if err != nil {
@@ -80,7 +86,7 @@ func (h *handler) UpdateRecord(c *gin.Context) { // get record's ID
 				"error": err.Error(),
 			})
 
-		case errors.Is(err, data.ErrInvalidID):
+		case errors.Is(err, data.ErrInvalidID), errors.Is(err, data.ErrInvalidTags):
 			c.JSON(http.StatusBadRequest, gin.H{
 				"error": err.Error(),
 			})
@@ -96,6 +102,9 @@ func (h *handler) UpdateRecord(c *gin.Context) { // get record's ID
 		return
 	}
 
+	after, _ := h.ds.GetRecordByID(c, r.ID)
+	h.audit(c, data.AuditRecordUpdate, r.ID, before, after)
+
 	// record successfully updated
 	c.JSON(http.StatusOK, r)
 }
@@ -104,6 +113,9 @@ func (h *handler) UpdateRecord(c *gin.Context) { // get record's ID
 func (h *handler) DeleteRecord(c *gin.Context) { // get record's ID
 	id := c.Param("record_id")
 
+	// snapshot before, errors are reported by the deletion
+	before, _ := h.ds.GetRecordByID(c, id)
+
 	// delete record
 	did, err := h.ds.DeleteRecord(c, id)
 	if err != nil {
@@ -128,6 +140,8 @@ func (h *handler) DeleteRecord(c *gin.Context) { // get record's ID
 		return
 	}
 
+	h.audit(c, data.AuditRecordDelete, did, before, nil)
+
 	// record successfully deleted
 	c.JSON(http.StatusOK, gin.H{
 		"delete_record_id": did,
@@ -198,10 +212,11 @@ func (h *handler) GetRecordByShortPeek(c *gin.Context) { // get short
 	short := c.Param("record_short")
 
 	// get full url
-	full, err := h.ds.GetRecordByShortPeek(c, short)
+	r, err := h.ds.GetRecordByShortPeek(c, short)
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrShortNotFound):
+			clicks.Inc(clickNotFound)
 			c.JSON(http.StatusNotFound, gin.H{
 				"error": err.Error(),
 			})
@@ -216,9 +231,25 @@ func (h *handler) GetRecordByShortPeek(c *gin.Context) { // get short
 		return
 	}
 
-	// found
+	// found, the click is stored in the background and classified as a bot or a human
+	clicks.Inc(clickFound)
+	h.ds.RecordClick(&data.Click{
+		ShortcutID:        r.ID,
+		ShortURL:          r.Short,
+		Tags:              r.Tags,
+		AnalyticsDisabled: r.AnalyticsDisabled,
+		ClientIP:          c.ClientIP(),
+		Referrer:          c.Request.Referer(),
+		UserAgent:         c.Request.UserAgent(),
+		AcceptLanguage:    c.GetHeader("Accept-Language"),
+		Query:             c.Request.URL.RawQuery,
+		Method:            c.Request.Method,
+		Purpose:           bots.Purpose(c.Request.Header),
+		DoNotTrack:        doNotTrack(c.Request.Header),
+	})
+
 	c.JSON(http.StatusOK, gin.H{
-		"url": full,
+		"url": r.Full,
 	})
 }
 
@@ -285,6 +316,9 @@ func (h *handler) RecordRecovery(c *gin.Context) { // load id
 		return
 	}
 
+	after, _ := h.ds.GetRecordByID(c, rid)
+	h.audit(c, data.AuditRecordRecover, rid, nil, after)
+
 	// record successfully recovered
 	c.JSON(http.StatusOK, gin.H{
 		"recovered_id": rid,
@@ -3,6 +3,7 @@ package controller
 import (
 	"net/http"
 
+	"github.com/chutommy/url-shortener/metrics"
 	"github.com/chutommy/url-shortener/middleware"
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
@@ -11,35 +12,110 @@ import (
 // GetHTTPHandler returns http.Handler with set routing.
 func (h *handler) GetHTTPHandler() http.Handler { // set router
 	r := gin.New()
-	r.Use(gin.Logger())
-	r.Use(gin.Recovery())
+
+	// probes are registered before the middlewares, so they are not logged, traced or measured
+	r.GET("/healthz", h.Healthz)
+	r.GET("/readyz", h.Readyz)
+
+	r.Use(middleware.RequestID(h.log))
+	r.Use(middleware.Tracing())
+	r.Use(middleware.Metrics())
+	r.Use(middleware.AccessLog())
+	r.Use(middleware.Recovery(h.ds))
 	r.Use(cors.Default())
 
+	// metrics
+	if h.metricsPath != "" {
+		r.GET(h.metricsPath, gin.WrapH(metrics.Default.Handler()))
+	}
+
+	adminAuth := middleware.Traced("ValidateAdminKey", middleware.ValidateAdminKey(h.ds, h.lim, h.certScopes, h.sigs))
+	requireTOTP := middleware.Traced("RequireTOTP", middleware.RequireTOTP())
+	sessionAuth := middleware.Traced("SessionAuth", middleware.SessionAuth(h.ds, h.lim))
+
 	// V1
 	v1 := r.Group("/v1")
 	{
 		v1.GET("/url/i/:record_short", h.GetRecordByShortPeek)
+		v1.HEAD("/url/i/:record_short", h.GetRecordByShortPeek)
+
+		// the share tokens grant the read-only access to the analytics of a shortcut
+		v1.GET("/share", h.GetSharedStatsPage)
+		v1.GET("/share/stats", h.GetSharedStats)
 
-		authorized := v1.Group("/admin", middleware.ValidateAdminKey(h.ds))
+		authorized := v1.Group("/admin", adminAuth)
 		{
 			authorized.GET("/url/short/:record_short", h.GetRecordByShort)
 			authorized.GET("/url/id/:record_id", h.GetRecordByID)
+			authorized.GET("/url/id/:record_id/stats", h.GetClickStats)
 
 			authorized.GET("/urls/l", h.GetRecordsLen)
 			authorized.GET("/urls", h.GetAllRecords)
+			authorized.GET("/urls/top", h.GetTopRecords)
 
 			authorized.POST("/url", h.AddRecord)
 			authorized.PUT("/url/:record_id", h.UpdateRecord)
 			authorized.DELETE("/url/:record_id", h.DeleteRecord)
 			authorized.POST("/url/recovery/:record_id", h.RecordRecovery)
+
+			authorized.GET("/lockouts", h.GetLockouts)
+			authorized.DELETE("/lockouts", h.ClearLockout)
+
+			authorized.GET("/audit", h.GetAuditEvents)
+			authorized.GET("/audit/export", h.ExportAuditEvents)
+
+			authorized.GET("/errors", h.GetErrorGroups)
+			authorized.GET("/errors/:fingerprint", h.GetErrorGroup)
+			authorized.POST("/errors/:fingerprint/resolve", h.ResolveErrorGroup)
+
+			authorized.GET("/bot-rules", h.GetBotRules)
+			authorized.POST("/bot-rules", h.AddBotRule)
+			authorized.DELETE("/bot-rules/:rule_id", h.DeleteBotRule)
+
+			authorized.PUT("/analytics/url/:record_id", h.SetAnalyticsDisabled)
+			authorized.DELETE("/analytics/url/:record_id", h.EraseRecordAnalytics)
+			authorized.POST("/analytics/erase", h.EraseVisitorAnalytics)
+
+			authorized.GET("/export/clicks", h.ExportClicks)
+			authorized.GET("/export/series", h.ExportSeries)
+
+			authorized.GET("/live/clicks", h.StreamClicks)
+
+			authorized.GET("/share-tokens", h.GetShareTokens)
+			authorized.POST("/share-tokens", h.CreateShareToken)
+			authorized.DELETE("/share-tokens/:token_id", h.RevokeShareToken)
+		}
+
+		loginAuth := middleware.AdminLogin(h.ds, h.lim)
+		if !h.localLogin {
+			loginAuth = middleware.LocalLoginDisabled()
+		}
+
+		login := v1.Group("/login", middleware.Traced("AdminLogin", loginAuth))
+		{
+			login.POST("/gen", requireTOTP, h.GenerateAdminKey)
+			login.POST("/revoke", requireTOTP, h.RevokeAdminKey)
+			login.POST("/signing-secret", requireTOTP, h.GenerateSigningSecret)
+			login.POST("/session", h.CreateSession)
+
+			login.POST("/totp/enroll", h.EnrollTOTP)
+			login.POST("/totp/confirm", h.ConfirmTOTP)
+			login.POST("/totp/disable", requireTOTP, h.DisableTOTP)
+		}
+
+		oidcLogin := v1.Group("/oidc")
+		{
+			oidcLogin.GET("/login", h.OIDCLogin)
+			oidcLogin.GET("/callback", h.OIDCCallback)
 		}
 
-		login := v1.Group("/login", middleware.AdminLogin(h.ds))
+		session := v1.Group("/session")
 		{
-			login.POST("/gen", h.GenerateAdminKey)
-			login.POST("/revoke", h.RevokeAdminKey)
+			session.POST("/refresh", h.RefreshSession)
+			session.POST("/logout", sessionAuth, h.Logout)
 		}
 	}
 
-	return r
+	// the streamed exports and the live streams extend the write deadline of the server
+	return middleware.ResponseControllers(r)
 }
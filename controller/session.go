@@ -0,0 +1,104 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/chutommy/url-shortener/data"
+	"github.com/chutommy/url-shortener/middleware"
+	"github.com/gin-gonic/gin"
+)
+
+// CreateSession handles a login of an admin user into a new session.
+func (h *handler) CreateSession(c *gin.Context) {
+	// start session
+	ses, err := h.ds.CreateSession(c, c.PostForm("username"), data.RoleAdmin)
+	if err != nil {
+		if errors.Is(err, data.ErrSessionsDisabled) {
+			c.JSON(http.StatusNotImplemented, gin.H{
+				"error": err.Error(),
+			})
+
+			return
+		}
+
+		h.ds.LogError(c, err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": data.ErrUnexpectedError,
+		})
+
+		return
+	}
+
+	// success
+	c.JSON(http.StatusOK, ses)
+}
+
+// RefreshSession exchanges a refresh token for new session tokens.
+func (h *handler) RefreshSession(c *gin.Context) {
+	// load refresh token
+	refresh, ok := c.GetPostForm("refresh_token")
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "missing refresh_token form field",
+		})
+
+		return
+	}
+
+	// refresh
+	ses, err := h.ds.RefreshSession(c, refresh)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrUnauthorized):
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"error": "invalid or expired refresh token",
+			})
+
+		case errors.Is(err, data.ErrSessionsDisabled):
+			c.JSON(http.StatusNotImplemented, gin.H{
+				"error": err.Error(),
+			})
+
+		default:
+			h.ds.LogError(c, err)
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": data.ErrUnexpectedError,
+			})
+		}
+
+		return
+	}
+
+	// success
+	c.JSON(http.StatusOK, ses)
+}
+
+// Logout revokes the session of the request's access token.
+func (h *handler) Logout(c *gin.Context) {
+	// load session
+	v, ok := c.Get(middleware.SessionKey)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "request is not authorized by a session token",
+		})
+
+		return
+	}
+
+	claims := v.(*data.SessionClaims)
+
+	// revoke
+	if err := h.ds.RevokeSession(c, claims.SessionID); err != nil && !errors.Is(err, data.ErrUnauthorized) {
+		h.ds.LogError(c, err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": data.ErrUnexpectedError,
+		})
+
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"revoked_session_id": claims.SessionID,
+	})
+}
@@ -0,0 +1,384 @@
+package controller
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"html/template"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/chutommy/url-shortener/data"
+	"github.com/gin-gonic/gin"
+)
+
+// sharePath is the path of the public stats page, the token is passed in the
+// query, so it is not logged with the path of the request.
+const sharePath = "/v1/share"
+
+// defaultShareBreakdowns are the dimensions of the stats page if none are requested.
+var defaultShareBreakdowns = []string{
+	data.BreakdownReferrer, data.BreakdownCountry, data.BreakdownDevice, data.BreakdownBrowser,
+}
+
+// shareTokenRequest is the body of a new share token.
+type shareTokenRequest struct {
+	ShortcutID string     `json:"shortcut_id" binding:"required"`
+	Label      string     `json:"label"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+}
+
+// GetShareTokens serves the share tokens of the shortcut given by the
+// shortcut_id query parameter, including the revoked and expired ones.
+func (h *handler) GetShareTokens(c *gin.Context) {
+	tokens, err := h.ds.GetShareTokens(c, c.Query("shortcut_id"))
+	if err != nil {
+		if errors.Is(err, data.ErrInvalidID) {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": err.Error(),
+			})
+
+			return
+		}
+
+		h.ds.LogError(c, err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": data.ErrUnexpectedError,
+		})
+
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"tokens": tokens,
+	})
+}
+
+// CreateShareToken creates a token which grants the read-only access to the
+// analytics of the shortcut through the public stats page and its JSON
+// endpoint. The token is returned only once.
+func (h *handler) CreateShareToken(c *gin.Context) {
+	// bind request
+	var req shareTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+
+		return
+	}
+
+	t, err := h.ds.CreateShareToken(c, &data.ShareToken{
+		ShortcutID: req.ShortcutID,
+		Label:      req.Label,
+		ExpiresAt:  req.ExpiresAt,
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrInvalidID), errors.Is(err, data.ErrInvalidShareToken):
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": err.Error(),
+			})
+
+		case errors.Is(err, data.ErrIDNotFound):
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": err.Error(),
+			})
+
+		default:
+			h.ds.LogError(c, err)
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": data.ErrUnexpectedError,
+			})
+		}
+
+		return
+	}
+
+	// the token itself is never audited
+	audited := *t
+	audited.Token = ""
+	h.audit(c, data.AuditShareCreate, strconv.FormatInt(t.ID, 10), nil, &audited)
+
+	c.JSON(http.StatusOK, gin.H{
+		"share_token": t,
+		"page":        sharePath + "?token=" + t.Token,
+		"stats":       sharePath + "/stats?token=" + t.Token,
+	})
+}
+
+// RevokeShareToken revokes the share token with the certain ID.
+func (h *handler) RevokeShareToken(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("token_id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "token_id must be a number",
+		})
+
+		return
+	}
+
+	if err = h.ds.RevokeShareToken(c, id); err != nil {
+		if errors.Is(err, data.ErrShareTokenNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": err.Error(),
+			})
+
+			return
+		}
+
+		h.ds.LogError(c, err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": data.ErrUnexpectedError,
+		})
+
+		return
+	}
+
+	h.audit(c, data.AuditShareRevoke, c.Param("token_id"), nil, nil)
+
+	c.JSON(http.StatusOK, gin.H{
+		"revoked_token_id": id,
+	})
+}
+
+// sharedRecord returns the shortcut of the token query parameter. The
+// responses with the shared stats are not cached, indexed or leaked by the
+// referrer. A nil record is returned if the response is already written.
+func (h *handler) sharedRecord(c *gin.Context, fail func(int, string)) *data.ShortRecord {
+	c.Header("Cache-Control", "no-store")
+	c.Header("Referrer-Policy", "no-referrer")
+	c.Header("X-Robots-Tag", "noindex, nofollow")
+
+	r, err := h.ds.ValidateShareToken(c, c.Query("token"))
+	if err != nil {
+		if errors.Is(err, data.ErrShareUnauthorized) {
+			fail(http.StatusNotFound, err.Error())
+
+			return nil
+		}
+
+		h.ds.LogError(c, err)
+		fail(http.StatusInternalServerError, data.ErrUnexpectedError.Error())
+
+		return nil
+	}
+
+	return r
+}
+
+// GetSharedStats serves the click stats of the shortcut of the share token
+// given by the token query parameter, the stats are queried like GetClickStats.
+func (h *handler) GetSharedStats(c *gin.Context) {
+	r := h.sharedRecord(c, func(code int, msg string) {
+		c.JSON(code, gin.H{
+			"error": msg,
+		})
+	})
+	if r == nil {
+		return
+	}
+
+	// load query
+	q, err := clickStatsQuery(c, r.ID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+
+		return
+	}
+
+	// get stats
+	stats, err := h.ds.GetClickStats(c, q)
+	if err != nil {
+		h.clickStatsError(c, err)
+
+		return
+	}
+
+	resp := clickStatsResponse(q, stats)
+	resp["short_url"] = r.Short
+
+	c.JSON(http.StatusOK, resp)
+}
+
+// sharePageBar is a bucket of the click series of the stats page.
+type sharePageBar struct {
+	Label   string
+	Clicks  int
+	Percent float64
+}
+
+// sharePageBreakdown is a breakdown of the clicks of the stats page.
+type sharePageBreakdown struct {
+	Name  string
+	Items []*data.BreakdownItem
+}
+
+// sharePageView is the content of the stats page.
+type sharePageView struct {
+	Short         string
+	Full          string
+	From          string
+	To            string
+	Interval      string
+	Timezone      string
+	Total         int
+	PreviousTotal int
+	Change        string
+	Visitors      uint64
+	Series        []sharePageBar
+	Breakdowns    []sharePageBreakdown
+}
+
+var sharePage = template.Must(template.New("share").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<meta name="robots" content="noindex, nofollow">
+<title>Stats of {{.Short}}</title>
+<style>
+body { font-family: sans-serif; margin: 2em auto; max-width: 48em; padding: 0 1em; color: #222; }
+h1 { font-size: 1.5em; margin-bottom: 0; }
+.target { color: #666; overflow-wrap: anywhere; }
+.totals { display: flex; gap: 2em; margin: 1.5em 0; }
+.totals div { font-size: 0.9em; color: #666; }
+.totals strong { display: block; font-size: 1.8em; color: #222; }
+table { border-collapse: collapse; width: 100%; margin-bottom: 1.5em; }
+td, th { padding: 0.2em 0.4em; text-align: left; font-size: 0.9em; }
+td.n { text-align: right; white-space: nowrap; width: 5em; }
+td.t { white-space: nowrap; width: 10em; }
+.bar { background: #4a7bd0; height: 0.8em; min-width: 1px; }
+</style>
+</head>
+<body>
+<h1>{{.Short}}</h1>
+<p class="target">{{.Full}}</p>
+<p>{{.From}} &ndash; {{.To}} ({{.Timezone}}), by {{.Interval}}</p>
+<div class="totals">
+<div><strong>{{.Total}}</strong>clicks</div>
+<div><strong>{{with .Change}}{{.}}{{else}}&ndash;{{end}}</strong>vs previous {{.PreviousTotal}}</div>
+<div><strong>{{.Visitors}}</strong>unique visitors</div>
+</div>
+<h2>Clicks</h2>
+<table>
+{{range .Series}}<tr><td class="t">{{.Label}}</td><td><div class="bar" style="width: {{.Percent}}%"></div></td><td class="n">{{.Clicks}}</td></tr>
+{{end}}</table>
+{{range .Breakdowns}}<h2>{{.Name}}</h2>
+<table>
+{{range .Items}}<tr><td>{{.Value}}</td><td class="n">{{.Clicks}}</td></tr>
+{{else}}<tr><td>no clicks</td></tr>
+{{end}}</table>
+{{end}}</body>
+</html>
+`))
+
+// newSharePageView returns the content of the stats page of the shortcut.
+func newSharePageView(r *data.ShortRecord, q *data.ClickStatsQuery, stats *data.ClickStats) *sharePageView {
+	layout := "2006-01-02"
+	if q.Interval == data.IntervalMinute || q.Interval == data.IntervalHour {
+		layout = "2006-01-02 15:04"
+	}
+
+	v := &sharePageView{
+		Short:         r.Short,
+		Full:          r.Full,
+		From:          q.From.In(q.Location).Format(layout),
+		To:            q.To.In(q.Location).Format(layout),
+		Interval:      q.Interval,
+		Timezone:      q.Location.String(),
+		Total:         stats.Total,
+		PreviousTotal: stats.PreviousTotal,
+		Visitors:      stats.Visitors,
+	}
+
+	if pct := change(stats.Total, stats.PreviousTotal); pct != nil {
+		v.Change = fmt.Sprintf("%+.2f%%", *pct)
+	}
+
+	// the bars are relative to the busiest bucket
+	var maxClicks int
+
+	for _, b := range stats.Series {
+		if b.Clicks > maxClicks {
+			maxClicks = b.Clicks
+		}
+	}
+
+	for _, b := range stats.Series {
+		bar := sharePageBar{Label: b.Time.Format(layout), Clicks: b.Clicks}
+		if maxClicks > 0 {
+			bar.Percent = math.Round(float64(b.Clicks*1000)/float64(maxClicks)) / 10
+		}
+
+		v.Series = append(v.Series, bar)
+	}
+
+	for _, name := range q.Breakdowns {
+		v.Breakdowns = append(v.Breakdowns, sharePageBreakdown{Name: name, Items: stats.Breakdowns[name]})
+	}
+
+	return v
+}
+
+// GetSharedStatsPage serves the server-rendered stats page of the shortcut of
+// the share token given by the token query parameter. The stats are queried
+// like GetClickStats, the referrers, countries, devices and browsers are
+// broken down by default.
+func (h *handler) GetSharedStatsPage(c *gin.Context) {
+	fail := func(code int, msg string) {
+		c.String(code, msg)
+	}
+
+	r := h.sharedRecord(c, fail)
+	if r == nil {
+		return
+	}
+
+	// load query
+	q, err := clickStatsQuery(c, r.ID)
+	if err != nil {
+		fail(http.StatusBadRequest, err.Error())
+
+		return
+	}
+
+	if len(q.Breakdowns) == 0 {
+		q.Breakdowns = defaultShareBreakdowns
+	}
+
+	// get stats
+	stats, err := h.ds.GetClickStats(c, q)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrIDNotFound):
+			fail(http.StatusNotFound, err.Error())
+
+		case errors.Is(err, data.ErrInvalidInterval), errors.Is(err, data.ErrInvalidBreakdown),
+			errors.Is(err, data.ErrInvalidStatsRange), errors.Is(err, data.ErrInvalidBots):
+			fail(http.StatusBadRequest, err.Error())
+
+		default:
+			h.ds.LogError(c, err)
+			fail(http.StatusInternalServerError, data.ErrUnexpectedError.Error())
+		}
+
+		return
+	}
+
+	// render page
+	var buf bytes.Buffer
+	if err = sharePage.Execute(&buf, newSharePageView(r, q, stats)); err != nil {
+		h.ds.LogError(c, err)
+		fail(http.StatusInternalServerError, data.ErrUnexpectedError.Error())
+
+		return
+	}
+
+	c.Header("Content-Security-Policy", "default-src 'none'; style-src 'unsafe-inline'")
+	c.Data(http.StatusOK, "text/html; charset=utf-8", buf.Bytes())
+}
@@ -0,0 +1,161 @@
+package controller
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/chutommy/url-shortener/data"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultBreakdownLimit = 10
+	maxBreakdownLimit     = 100
+)
+
+// defaultStatsRanges are the default lengths of the range by the interval.
+var defaultStatsRanges = map[string]time.Duration{
+	data.IntervalMinute: time.Hour,
+	data.IntervalHour:   24 * time.Hour,
+	data.IntervalDay:    30 * 24 * time.Hour,
+	data.IntervalWeek:   12 * 7 * 24 * time.Hour,
+}
+
+// GetClickStats serves the clicks of the shortcut bucketed by the interval query
+// parameter (minute, hour, day or week) in the range given by the from and to
+// query parameters (RFC 3339) and compared with the previous period of the same
+// length, including the estimated unique visitors. The buckets start at the
+// interval boundaries in the tz time zone. The breakdown query parameter lists
+// comma-separated dimensions (referrer, device, country, region, city, browser)
+// whose top values are returned. The bots query parameter (exclude, include or
+// only) filters the clicks of the bots, the visitors are always the humans.
+func (h *handler) GetClickStats(c *gin.Context) {
+	// load query
+	q, err := clickStatsQuery(c, c.Param("record_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+
+		return
+	}
+
+	// get stats
+	stats, err := h.ds.GetClickStats(c, q)
+	if err != nil {
+		h.clickStatsError(c, err)
+
+		return
+	}
+
+	c.JSON(http.StatusOK, clickStatsResponse(q, stats))
+}
+
+// clickStatsQuery loads the query of the click stats of the shortcut with the
+// certain ID from the query parameters.
+func clickStatsQuery(c *gin.Context, id string) (*data.ClickStatsQuery, error) {
+	q := &data.ClickStatsQuery{
+		ShortcutID: strings.ToLower(id),
+		Interval:   c.DefaultQuery("interval", data.IntervalDay),
+		Location:   time.UTC,
+		Limit:      defaultBreakdownLimit,
+		Bots:       c.DefaultQuery("bots", data.BotsExclude),
+	}
+
+	if tz := c.Query("tz"); tz != "" {
+		loc, err := time.LoadLocation(tz)
+		if err != nil || tz == "Local" {
+			return nil, errors.New("tz must be an IANA time zone name")
+		}
+
+		q.Location = loc
+	}
+
+	var err error
+
+	q.To = time.Now()
+	if v := c.Query("to"); v != "" {
+		if q.To, err = time.Parse(time.RFC3339, v); err != nil {
+			return nil, errors.New("to must be an RFC 3339 timestamp")
+		}
+	}
+
+	q.From = q.To.Add(-defaultStatsRanges[q.Interval])
+	if v := c.Query("from"); v != "" {
+		if q.From, err = time.Parse(time.RFC3339, v); err != nil {
+			return nil, errors.New("from must be an RFC 3339 timestamp")
+		}
+	}
+
+	if v := c.Query("breakdown"); v != "" {
+		q.Breakdowns = strings.Split(v, ",")
+	}
+
+	if v := c.Query("limit"); v != "" {
+		if q.Limit, err = strconv.Atoi(v); err != nil || q.Limit < 1 || q.Limit > maxBreakdownLimit {
+			return nil, errors.New("limit must be a number between 1 and " + strconv.Itoa(maxBreakdownLimit))
+		}
+	}
+
+	return q, nil
+}
+
+// clickStatsError responds with the status of the error of the click stats.
+func (h *handler) clickStatsError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, data.ErrIDNotFound):
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": err.Error(),
+		})
+
+	case errors.Is(err, data.ErrInvalidID), errors.Is(err, data.ErrInvalidInterval),
+		errors.Is(err, data.ErrInvalidBreakdown), errors.Is(err, data.ErrInvalidStatsRange),
+		errors.Is(err, data.ErrInvalidBots):
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+
+	default:
+		h.ds.LogError(c, err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": data.ErrUnexpectedError,
+		})
+	}
+}
+
+// clickStatsResponse returns the body of the click stats of the query.
+func clickStatsResponse(q *data.ClickStatsQuery, stats *data.ClickStats) gin.H {
+	return gin.H{
+		"shortcut_id":       q.ShortcutID,
+		"from":              q.From.In(q.Location),
+		"to":                q.To.In(q.Location),
+		"interval":          q.Interval,
+		"timezone":          q.Location.String(),
+		"bots":              q.Bots,
+		"total":             stats.Total,
+		"previous_total":    stats.PreviousTotal,
+		"change":            change(stats.Total, stats.PreviousTotal),
+		"visitors":          stats.Visitors,
+		"previous_visitors": stats.PreviousVisitors,
+		"visitors_change":   change(int(stats.Visitors), int(stats.PreviousVisitors)),
+		"series":            stats.Series,
+		"previous_series":   stats.PreviousSeries,
+		"breakdowns":        stats.Breakdowns,
+	}
+}
+
+// change returns the relative change of the clicks against the previous period
+// in percents, nil if there were no clicks in the previous period.
+func change(cur, prev int) *float64 {
+	if prev == 0 {
+		return nil
+	}
+
+	pct := math.Round(float64(cur-prev)/float64(prev)*10000) / 100
+
+	return &pct
+}
@@ -0,0 +1,70 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/chutommy/url-shortener/data"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultTopLimit = 10
+	maxTopLimit     = 100
+)
+
+// GetTopRecords serves the leaderboard of the shortcuts with the most clicks
+// in the window query parameter (hour, day or week) ending now. The sort query
+// parameter orders them by the clicks or by the trending score against the
+// preceding windows. The shortcuts can be filtered by the tag and domain query
+// parameters.
+func (h *handler) GetTopRecords(c *gin.Context) {
+	q := &data.TopQuery{
+		Window: c.DefaultQuery("window", data.WindowDay),
+		Sort:   c.DefaultQuery("sort", data.SortClicks),
+		Tag:    c.Query("tag"),
+		Domain: c.Query("domain"),
+		Limit:  defaultTopLimit,
+		Now:    time.Now(),
+	}
+
+	var err error
+
+	if v := c.Query("limit"); v != "" {
+		if q.Limit, err = strconv.Atoi(v); err != nil || q.Limit < 1 || q.Limit > maxTopLimit {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "limit must be a number between 1 and " + strconv.Itoa(maxTopLimit),
+			})
+
+			return
+		}
+	}
+
+	// get leaderboard
+	top, err := h.ds.GetTopRecords(c, q)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrInvalidWindow), errors.Is(err, data.ErrInvalidSort):
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": err.Error(),
+			})
+
+		default:
+			h.ds.LogError(c, err)
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": data.ErrUnexpectedError,
+			})
+		}
+
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"window": q.Window,
+		"sort":   q.Sort,
+		"to":     q.Now.UTC(),
+		"top":    top,
+	})
+}
@@ -0,0 +1,99 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/chutommy/url-shortener/data"
+	"github.com/gin-gonic/gin"
+)
+
+// EnrollTOTP handles a provisioning of a new TOTP secret for the logged admin user.
+func (h *handler) EnrollTOTP(c *gin.Context) {
+	// enroll
+	enr, err := h.ds.EnrollTOTP(c, c.PostForm("username"))
+	if err != nil {
+		if errors.Is(err, data.ErrTOTPEnabled) {
+			c.JSON(http.StatusConflict, gin.H{
+				"error": err.Error(),
+			})
+
+			return
+		}
+
+		h.ds.LogError(c, err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": data.ErrUnexpectedError,
+		})
+
+		return
+	}
+
+	// success
+	c.JSON(http.StatusOK, enr)
+}
+
+// ConfirmTOTP enables the pending TOTP enrolment of the logged admin user.
+func (h *handler) ConfirmTOTP(c *gin.Context) {
+	// load code
+	code, ok := c.GetPostForm("code")
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "missing code form field",
+		})
+
+		return
+	}
+
+	// confirm
+	if err := h.ds.ConfirmTOTP(c, c.PostForm("username"), code); err != nil {
+		switch {
+		case errors.Is(err, data.ErrUnauthorized):
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"error": "invalid code",
+			})
+
+		case errors.Is(err, data.ErrTOTPNotEnrolled):
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": err.Error(),
+			})
+
+		default:
+			h.ds.LogError(c, err)
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": data.ErrUnexpectedError,
+			})
+		}
+
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"totp_enabled": true,
+	})
+}
+
+// DisableTOTP removes the TOTP of the logged admin user.
+func (h *handler) DisableTOTP(c *gin.Context) {
+	// disable
+	if err := h.ds.DisableTOTP(c, c.PostForm("username")); err != nil {
+		if errors.Is(err, data.ErrTOTPNotEnrolled) {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": err.Error(),
+			})
+
+			return
+		}
+
+		h.ds.LogError(c, err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": data.ErrUnexpectedError,
+		})
+
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"totp_enabled": false,
+	})
+}
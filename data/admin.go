@@ -8,6 +8,7 @@ import (
 	"strings"
 
 	"github.com/chutommy/rand"
+	"github.com/chutommy/url-shortener/tracing"
 	"github.com/lib/pq"
 	"golang.org/x/crypto/bcrypt"
 )
@@ -87,10 +88,11 @@ WHERE
 	}
 
 	// compare
-	if err := bcrypt.CompareHashAndPassword(
-		[]byte(hashKey),
-		[]byte(key),
-	); errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+	_, span := tracing.Start(ctx, "bcrypt.CompareHashAndPassword")
+	err := bcrypt.CompareHashAndPassword([]byte(hashKey), []byte(key))
+	span.End()
+
+	if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
 		return ErrUnauthorized
 	} else if err != nil {
 		return fmt.Errorf("unexpected validation failure: %w", err)
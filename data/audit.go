@@ -0,0 +1,155 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Actions of the audit events.
+const (
+	AuditRecordCreate  = "record.create"
+	AuditRecordUpdate  = "record.update"
+	AuditRecordDelete  = "record.delete"
+	AuditRecordRecover = "record.recover"
+	AuditKeyGenerate   = "key.generate"
+	AuditKeyRevoke     = "key.revoke"
+	AuditSigningSecret = "key.signing_secret"
+	AuditLockoutClear  = "lockout.clear"
+	AuditErrorResolve  = "error.resolve"
+	AuditBotRuleCreate = "bot_rule.create"
+	AuditBotRuleDelete = "bot_rule.delete"
+	AuditAnalyticsOpt  = "analytics.opt"
+	AuditEraseRecord   = "analytics.erase_record"
+	AuditEraseVisitor  = "analytics.erase_visitor"
+	AuditShareCreate   = "share_token.create"
+	AuditShareRevoke   = "share_token.revoke"
+)
+
+// AuditEvent is a record of an administrative action. Before and After hold
+// JSON snapshots of the target, if there are any.
+type AuditEvent struct {
+	ID        int64           `json:"event_id"`
+	ActorType string          `json:"actor_type"`
+	Actor     string          `json:"actor"`
+	ClientIP  string          `json:"client_ip"`
+	Action    string          `json:"action"`
+	Target    string          `json:"target"`
+	Before    json.RawMessage `json:"before,omitempty"`
+	After     json.RawMessage `json:"after,omitempty"`
+	LoggedAt  time.Time       `json:"logged_at"`
+}
+
+// AuditFilter selects the audit events. Zero values match all events.
+// Events are ordered from the newest, BeforeID continues a previous page.
+type AuditFilter struct {
+	ActorType string
+	Actor     string
+	Action    string
+	Target    string
+	Since     time.Time
+	Until     time.Time
+	BeforeID  int64
+	Limit     int
+}
+
+// LogAuditEvent stores the audit event into the audit_events table.
+func (s *service) LogAuditEvent(ctx context.Context, e *AuditEvent) error {
+	// insert
+	_, err := s.DB.ExecContext(ctx, `
+INSERT INTO
+  audit_events (actor_type, actor, client_ip, action, target, before, after)
+VALUES
+  ($1, $2, $3, $4, $5, $6, $7);
+  `, e.ActorType, e.Actor, e.ClientIP, e.Action, e.Target,
+		newNullString(string(e.Before)), newNullString(string(e.After)))
+	if err != nil {
+		return fmt.Errorf("insert failure: %w", err)
+	}
+
+	return nil
+}
+
+// GetAuditEvents returns a page of the audit events matching the filter.
+func (s *service) GetAuditEvents(ctx context.Context, f *AuditFilter) ([]*AuditEvent, error) {
+	events := []*AuditEvent{}
+
+	err := s.ExportAuditEvents(ctx, f, func(e *AuditEvent) error {
+		events = append(events, e)
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return events, nil
+}
+
+// ExportAuditEvents streams the audit events matching the filter into fn
+// one by one. A zero Limit of the filter exports all matching events.
+func (s *service) ExportAuditEvents(ctx context.Context, f *AuditFilter, fn func(*AuditEvent) error) error {
+	limit := sql.NullInt64{Int64: int64(f.Limit), Valid: f.Limit > 0}
+
+	// query db
+	rows, err := s.DB.QueryContext(ctx, `
+SELECT
+  event_id,
+  actor_type,
+  actor,
+  client_ip,
+  action,
+  target,
+  before,
+  after,
+  logged_at
+FROM
+  audit_events
+WHERE
+  ($1 = '' OR actor_type = $1)
+  AND ($2 = '' OR actor = $2)
+  AND ($3 = '' OR action = $3)
+  AND ($4 = '' OR target = $4)
+  AND ($5::TIMESTAMP IS NULL OR logged_at >= $5)
+  AND ($6::TIMESTAMP IS NULL OR logged_at < $6)
+  AND ($7::BIGINT = 0 OR event_id < $7)
+ORDER BY
+  event_id DESC
+LIMIT
+  $8;
+  `, f.ActorType, f.Actor, f.Action, f.Target, newNullTime(f.Since), newNullTime(f.Until), f.BeforeID, limit)
+	if err != nil {
+		return fmt.Errorf("could not query audit events: %w", err)
+	}
+
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	// scan rows
+	for rows.Next() {
+		var (
+			e             AuditEvent
+			before, after []byte
+		)
+
+		err = rows.Scan(&e.ID, &e.ActorType, &e.Actor, &e.ClientIP, &e.Action, &e.Target, &before, &after, &e.LoggedAt)
+		if err != nil {
+			return fmt.Errorf("retrieved sql row scan error: %w", err)
+		}
+
+		e.Before, e.After = before, after
+
+		if err = fn(&e); err != nil {
+			return err
+		}
+	}
+
+	if err = rows.Err(); err != nil {
+		return fmt.Errorf("failed to iterate audit events: %w", err)
+	}
+
+	return nil
+}
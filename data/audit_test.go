@@ -0,0 +1,82 @@
+package data
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var auditColumns = []string{
+	"event_id", "actor_type", "actor", "client_ip", "action", "target", "before", "after", "logged_at",
+}
+
+func TestGetAuditEvents(t *testing.T) {
+	since := time.Date(2024, time.March, 1, 12, 0, 0, 0, time.FixedZone("CET", 3600))
+	logged := time.Date(2024, time.March, 2, 8, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name string
+		f    AuditFilter
+		args []driver.Value
+	}{
+		{
+			name: "all events of the first page",
+			f:    AuditFilter{Limit: 50},
+			args: []driver.Value{"", "", "", "", nil, nil, int64(0), int64(50)},
+		},
+		{
+			name: "filtered next page",
+			f: AuditFilter{
+				ActorType: "key", Actor: "abcd1234", Action: AuditRecordDelete, Target: "id",
+				Since: since, BeforeID: 42, Limit: 2,
+			},
+			args: []driver.Value{"key", "abcd1234", AuditRecordDelete, "id", since.UTC(), nil, int64(42), int64(2)},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			s, db := newMockDB(t)
+			db.ExpectQuery(`FROM\s+audit_events`).WithArgs(tc.args...).WillReturnRows(auditColumns,
+				[]driver.Value{int64(41), "key", "abcd1234", "10.0.0.1", AuditRecordDelete, "id",
+					[]byte(`{"a":1}`), nil, logged},
+				[]driver.Value{int64(40), "key", "abcd1234", "10.0.0.1", AuditRecordDelete, "id", nil, nil, logged},
+			)
+
+			events, err := s.GetAuditEvents(context.Background(), &tc.f)
+			assert.NoError(t, err)
+
+			if assert.Len(t, events, 2) {
+				assert.Equal(t, int64(41), events[0].ID)
+				assert.JSONEq(t, `{"a":1}`, string(events[0].Before))
+				assert.Nil(t, events[0].After)
+				assert.Equal(t, logged, events[1].LoggedAt)
+			}
+		})
+	}
+}
+
+func TestExportAuditEvents(t *testing.T) {
+	s, db := newMockDB(t)
+	q := db.ExpectQuery(`FROM\s+audit_events`).WillReturnRows(auditColumns,
+		[]driver.Value{int64(2), "user", "admin", "", AuditKeyRevoke, "k", nil, nil, time.Now()},
+		[]driver.Value{int64(1), "user", "admin", "", AuditKeyGenerate, "k", nil, nil, time.Now()},
+	)
+
+	// the export is not limited and stops at the first failure of fn
+	errStop := errors.New("stop")
+	n := 0
+
+	err := s.ExportAuditEvents(context.Background(), &AuditFilter{}, func(e *AuditEvent) error {
+		n++
+
+		return errStop
+	})
+	assert.True(t, errors.Is(err, errStop))
+	assert.Equal(t, 1, n)
+	assert.Nil(t, q.Args()[7])
+}
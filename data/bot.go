@@ -0,0 +1,165 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/chutommy/url-shortener/bots"
+	"github.com/lib/pq"
+)
+
+const (
+	// botRulesRefresh is the interval of the reloads of the rules, so the
+	// rules changed by the other instances are applied.
+	botRulesRefresh = time.Minute
+
+	minBotRuleLen = 3
+	maxBotRuleLen = 128
+)
+
+var (
+	// ErrInvalidBotRule is returned if the pattern of the rule is too short, too long or contains control characters.
+	ErrInvalidBotRule = errors.New("pattern must have 3 to 128 printable characters")
+	// ErrBotRuleExists is returned if a rule with the same pattern already exists.
+	ErrBotRuleExists = errors.New("rule with the given pattern already exists")
+	// ErrBotRuleNotFound is returned if the rule does not exist.
+	ErrBotRuleNotFound = errors.New("rule with the given id does not exist")
+)
+
+// BotRule is a lowercase substring of the user agents of the bots added by an admin.
+type BotRule struct {
+	ID        int64     `json:"rule_id"`
+	Pattern   string    `json:"pattern"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// GetBotRules returns the rules ordered by their creation.
+func (s *service) GetBotRules(ctx context.Context) (rules []*BotRule, err error) {
+	rows, err := s.DB.QueryxContext(ctx, `
+SELECT
+  rule_id,
+  pattern,
+  created_at
+FROM
+  bot_rules
+ORDER BY
+  rule_id;
+  `)
+	if err != nil {
+		return nil, fmt.Errorf("unexpected query error: %w", err)
+	}
+
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	rules = []*BotRule{}
+
+	for rows.Next() {
+		var r BotRule
+		if err = rows.Scan(&r.ID, &r.Pattern, &r.CreatedAt); err != nil {
+			return nil, fmt.Errorf("retrieved sql row scan error: %w", err)
+		}
+
+		rules = append(rules, &r)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("unexpected rows error: %w", err)
+	}
+
+	return rules, nil
+}
+
+// AddBotRule adds the rule with the pattern, which is matched case-insensitively.
+// The rule is applied to the clicks recorded by this instance immediately and by
+// the other instances within a minute.
+func (s *service) AddBotRule(ctx context.Context, pattern string) (*BotRule, error) {
+	pattern, err := normalizeBotRule(pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	r := BotRule{Pattern: pattern}
+
+	err = s.DB.QueryRowxContext(ctx, `
+INSERT INTO
+  bot_rules (pattern)
+VALUES
+  ($1)
+RETURNING
+  rule_id,
+  created_at;
+  `, pattern).Scan(&r.ID, &r.CreatedAt)
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == ErrPQUniqueKeyViolation {
+			return nil, ErrBotRuleExists
+		}
+
+		return nil, fmt.Errorf("could not execute sql insert: %w", err)
+	}
+
+	if err = s.loadBotRules(ctx); err != nil {
+		return nil, err
+	}
+
+	return &r, nil
+}
+
+// DeleteBotRule removes the rule with the given id.
+func (s *service) DeleteBotRule(ctx context.Context, id int64) error {
+	result, err := s.DB.ExecContext(ctx, `
+DELETE FROM
+  bot_rules
+WHERE
+  rule_id = $1;
+  `, id)
+	if err != nil {
+		return fmt.Errorf("delete failure: %w", err)
+	}
+
+	if n, _ := result.RowsAffected(); n != 1 {
+		return ErrBotRuleNotFound
+	}
+
+	return s.loadBotRules(ctx)
+}
+
+// loadBotRules replaces the classifier of the clicks by the one with the current rules.
+func (s *service) loadBotRules(ctx context.Context) error {
+	rules, err := s.GetBotRules(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to load bot rules: %w", err)
+	}
+
+	patterns := make([]string, len(rules))
+	for i, r := range rules {
+		patterns[i] = r.Pattern
+	}
+
+	s.botClassifier.Store(bots.New(patterns))
+
+	return nil
+}
+
+// normalizeBotRule returns the lowercase trimmed pattern.
+func normalizeBotRule(pattern string) (string, error) {
+	pattern = strings.ToLower(strings.TrimSpace(pattern))
+
+	if n := len([]rune(pattern)); n < minBotRuleLen || n > maxBotRuleLen {
+		return "", ErrInvalidBotRule
+	}
+
+	for _, r := range pattern {
+		if !unicode.IsPrint(r) {
+			return "", ErrInvalidBotRule
+		}
+	}
+
+	return pattern, nil
+}
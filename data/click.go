@@ -0,0 +1,408 @@
+package data
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/chutommy/url-shortener/bots"
+	"github.com/chutommy/url-shortener/config"
+	"github.com/chutommy/url-shortener/geoip"
+	"github.com/chutommy/url-shortener/health"
+	"github.com/chutommy/url-shortener/metrics"
+	"github.com/chutommy/url-shortener/useragent"
+)
+
+const (
+	clickBatchSize    = 500
+	clickWriteTimeout = 10 * time.Second
+	clickWorkerName   = "click_writer"
+	geoipWorkerName   = "geoip_reloader"
+
+	// limits of the stored click details
+	maxReferrerLen  = 2048
+	maxUserAgentLen = 512
+	maxLanguageLen  = 35
+	maxQueryLen     = 2048
+	maxPlaceLen     = 255
+
+	ipHashKeyLen = 32
+	ipHashLen    = 32
+)
+
+var (
+	clicksDropped = metrics.Default.Counter("clicks_dropped_total",
+		"Clicks dropped because the queue of the click writer was full or its write failed.", "reason")
+	clicksWritten = metrics.Default.Counter("clicks_written_total",
+		"Clicks stored by the click writer.")
+	clicksRepeated = metrics.Default.Counter("clicks_repeated_total",
+		"Clicks logged as repeats of a click of the same visitor within the de-duplication window.")
+)
+
+// clickWriter queues the clicks and stores them in batches in the background,
+// so the redirects do not wait for the database.
+type clickWriter struct {
+	queue    chan *Click
+	done     chan struct{}
+	once     sync.Once
+	interval time.Duration
+	ipKey    []byte
+	dedup    *dedupWindow
+
+	// partitionsUntil is the end of the last created partition of the usages
+	partitionsUntil time.Time
+
+	// privacy
+	honorDNT   bool
+	truncateIP bool
+	rotateSalt bool
+	salts      map[string][]byte
+}
+
+// InitClicks starts the background writer of the clicks.
+func (s *service) InitClicks(anCfg *config.Analytics) error {
+	size, err := anCfg.Queue()
+	if err != nil {
+		return err
+	}
+
+	interval, err := anCfg.Flush()
+	if err != nil {
+		return err
+	}
+
+	window, capacity, err := anCfg.Dedup()
+	if err != nil {
+		return err
+	}
+
+	// load ip hash key
+	key := anCfg.HashKey()
+	if key == nil {
+		key = make([]byte, ipHashKeyLen)
+		if _, err = rand.Read(key); err != nil {
+			return fmt.Errorf("failed to generate ip hash key: %w", err)
+		}
+
+		s.log.Warn("URL_SHORTENER_IP_HASH_KEY is not set, hashed client IPs change with every restart")
+	}
+
+	s.clicks = &clickWriter{
+		queue:    make(chan *Click, size),
+		done:     make(chan struct{}),
+		interval: interval,
+		ipKey:    key,
+		dedup:    newDedupWindow(window, capacity),
+
+		honorDNT:   anCfg.PrivacySettings().DNT(),
+		truncateIP: anCfg.PrivacySettings().Truncate(),
+		rotateSalt: anCfg.PrivacySettings().Rotate(),
+		salts:      make(map[string][]byte),
+	}
+
+	metrics.Default.GaugeFunc("click_queue_length", "Clicks waiting for the click writer.", func() float64 {
+		return float64(len(s.clicks.queue))
+	})
+	health.Default.Register(clickWorkerName, s.clicks.running)
+
+	// the known bots are recognized until the rules are loaded
+	ctx, cancel := context.WithTimeout(context.Background(), clickWriteTimeout)
+	defer cancel()
+
+	if err = s.loadBotRules(ctx); err != nil {
+		s.LogError(ctx, err)
+	}
+
+	// the clicks outside of the partitions are kept in the default partition until the next try
+	if s.clicks.partitionsUntil, err = s.createPartitions(ctx, time.Now()); err != nil {
+		s.LogError(ctx, err)
+	}
+
+	go s.runClicks()
+
+	return nil
+}
+
+// InitGeoIP opens the geoip database which locates the clicks. A disabled
+// geolocation leaves the location of the clicks empty.
+func (s *service) InitGeoIP(geoCfg *config.GeoIP) error {
+	if !geoCfg.Enabled() {
+		return nil
+	}
+
+	interval, err := geoCfg.Reload()
+	if err != nil {
+		return err
+	}
+
+	s.geo = geoip.Open(geoCfg.Database, interval, s.log)
+	health.Default.Register(geoipWorkerName, s.geo.Running)
+
+	return nil
+}
+
+// stopGeoIP stops the reloads of the geoip database.
+func (s *service) stopGeoIP() {
+	if s.geo == nil {
+		return
+	}
+
+	health.Default.Unregister(geoipWorkerName)
+	s.geo.Close()
+}
+
+// RecordClick queues the click to be stored. It never blocks, the click is
+// dropped if the queue is full.
+func (s *service) RecordClick(c *Click) {
+	if s.clicks == nil {
+		return
+	}
+
+	if c.LoggedAt.IsZero() {
+		c.LoggedAt = time.Now()
+	}
+
+	select {
+	case s.clicks.queue <- c:
+	default:
+		clicksDropped.Inc("queue_full")
+	}
+}
+
+// running reports whether the writer still runs.
+func (w *clickWriter) running() bool {
+	select {
+	case <-w.done:
+		return false
+	default:
+		return true
+	}
+}
+
+// runClicks stores the queued clicks when the batch is full or periodically.
+func (s *service) runClicks() {
+	w := s.clicks
+	defer close(w.done)
+
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	// the rules changed by the other instances
+	rulesTicker := time.NewTicker(botRulesRefresh)
+	defer rulesTicker.Stop()
+
+	batch := make([]*usage, 0, clickBatchSize)
+
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+
+		s.ensureClickPartitions(batch)
+
+		ctx, cancel := context.WithTimeout(context.Background(), clickWriteTimeout)
+		defer cancel()
+
+		if err := s.logUsages(ctx, batch); err != nil {
+			clicksDropped.Add(float64(len(batch)), "write_failed")
+			s.LogError(ctx, fmt.Errorf("failed to store %d clicks: %w", len(batch), err))
+		} else {
+			clicksWritten.Add(float64(len(batch)))
+		}
+
+		batch = make([]*usage, 0, clickBatchSize)
+	}
+
+	for {
+		select {
+		case c, ok := <-w.queue:
+			if !ok {
+				flush()
+
+				return
+			}
+
+			salt, err := s.clickSalt(c.LoggedAt)
+			u := w.parse(c, s.geo, s.botClassifier.Load(), salt)
+
+			// the client IP is not hashed without the salt of the day
+			if err != nil {
+				u.IPHash = ""
+			}
+
+			if u.IsRepeat = w.dedup.repeat(u); u.IsRepeat {
+				clicksRepeated.Inc()
+			}
+
+			// the clicks of the records opted out of the analytics are not streamed
+			if !c.AnalyticsDisabled {
+				s.publishClick(c, u)
+			}
+
+			batch = append(batch, u)
+			if len(batch) == clickBatchSize {
+				flush()
+			}
+
+		case <-ticker.C:
+			flush()
+
+		case <-rulesTicker.C:
+			ctx, cancel := context.WithTimeout(context.Background(), clickWriteTimeout)
+			if err := s.loadBotRules(ctx); err != nil {
+				s.LogError(ctx, err)
+			}
+
+			cancel()
+		}
+	}
+}
+
+// ensureClickPartitions creates the partitions of the upcoming months once a
+// click of the batch is logged after the last created partition. The clicks
+// are stored in the default partition if it fails.
+func (s *service) ensureClickPartitions(batch []*usage) {
+	w := s.clicks
+
+	for _, u := range batch {
+		if !u.LoggedAt.Before(w.partitionsUntil) {
+			ctx, cancel := context.WithTimeout(context.Background(), partitionTimeout)
+			defer cancel()
+
+			until, err := s.createPartitions(ctx, u.LoggedAt)
+			if err != nil {
+				s.LogError(ctx, err)
+
+				return
+			}
+
+			w.partitionsUntil = until
+
+			return
+		}
+	}
+}
+
+// clickSalt returns the salt of the hashed client IP of the click logged at t.
+func (s *service) clickSalt(t time.Time) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), clickWriteTimeout)
+	defer cancel()
+
+	salt, err := s.ipSalt(ctx, t)
+	if err != nil && !errors.Is(err, errSaltExpired) {
+		s.LogError(ctx, err)
+	}
+
+	return salt, err
+}
+
+// stopClicks stores the queued clicks and stops the writer.
+func (s *service) stopClicks() {
+	if s.clicks == nil {
+		return
+	}
+
+	health.Default.Unregister(clickWorkerName)
+
+	s.clicks.once.Do(func() {
+		close(s.clicks.queue)
+	})
+	<-s.clicks.done
+
+	s.log.Info("click writer stopped")
+}
+
+// parse extracts the stored details of the click. The client IP is geolocated
+// by the geo reader, the location is empty if the reader is nil. The bots are
+// recognized by the classifier. The client IP is hashed with the salt, the
+// details of the visitor are removed if the click is not to be tracked.
+func (w *clickWriter) parse(c *Click, geo *geoip.Reader, cl *bots.Classifier, salt []byte) *usage {
+	ip := c.ClientIP
+	if w.truncateIP {
+		ip = truncateIP(ip)
+	}
+
+	agent := useragent.Parse(c.UserAgent)
+	loc := geo.Lookup(ip)
+	isBot, reason := cl.Classify(bots.Request{Method: c.Method, UserAgent: c.UserAgent, Purpose: c.Purpose})
+
+	u := &usage{
+		ShortcutID: c.ShortcutID,
+		LoggedAt:   c.LoggedAt,
+		Referrer:   truncate(c.Referrer, maxReferrerLen),
+		UserAgent:  truncate(c.UserAgent, maxUserAgentLen),
+		Browser:    agent.Browser,
+		OS:         agent.OS,
+		Device:     agent.Device,
+		Language:   truncate(primaryLanguage(c.AcceptLanguage), maxLanguageLen),
+		IPHash:     w.hashIP(ip, salt),
+		Query:      truncate(c.Query, maxQueryLen),
+		Country:    loc.Country,
+		Region:     truncate(loc.Region, maxPlaceLen),
+		City:       truncate(loc.City, maxPlaceLen),
+		IsBot:      isBot,
+		BotReason:  reason,
+	}
+
+	if ref, err := url.Parse(c.Referrer); err == nil {
+		u.ReferrerHost = strings.ToLower(ref.Hostname())
+	}
+
+	if w.honorDNT && c.DoNotTrack {
+		u.anonymize()
+	}
+
+	return u
+}
+
+// hashIP returns the keyed hash of the salted client IP, so the visitors can
+// be distinguished without storing their addresses. A nil salt is not used.
+func (w *clickWriter) hashIP(ip string, salt []byte) string {
+	if ip == "" {
+		return ""
+	}
+
+	mac := hmac.New(sha256.New, w.ipKey)
+	mac.Write(salt)
+	mac.Write([]byte(ip))
+
+	return hex.EncodeToString(mac.Sum(nil))[:ipHashLen]
+}
+
+// primaryLanguage returns the lowercase first language tag of the
+// Accept-Language header, such as "en-us".
+func primaryLanguage(header string) string {
+	tag := header
+	if i := strings.IndexByte(tag, ','); i >= 0 {
+		tag = tag[:i]
+	}
+
+	if i := strings.IndexByte(tag, ';'); i >= 0 {
+		tag = tag[:i]
+	}
+
+	tag = strings.ToLower(strings.TrimSpace(tag))
+	if tag == "*" {
+		return ""
+	}
+
+	return tag
+}
+
+// truncate shortens the string to at most n bytes and removes the invalid
+// UTF-8 sequences and NUL bytes which the database rejects.
+func truncate(s string, n int) string {
+	if len(s) > n {
+		s = s[:n]
+	}
+
+	return strings.ToValidUTF8(strings.ReplaceAll(s, "\x00", ""), "")
+}
@@ -0,0 +1,152 @@
+package data
+
+import (
+	"context"
+	"database/sql/driver"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/chutommy/url-shortener/bots"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPrimaryLanguage(t *testing.T) {
+	tests := []struct {
+		header string
+		exp    string
+	}{
+		{header: "", exp: ""},
+		{header: "en", exp: "en"},
+		{header: "en-US,en;q=0.9,cs;q=0.8", exp: "en-us"},
+		{header: " de-CH ; q=0.9 , fr", exp: "de-ch"},
+		{header: "cs;q=0.5", exp: "cs"},
+		{header: "*", exp: ""},
+		{header: "*;q=0.1, en", exp: ""},
+		{header: ",en", exp: ""},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.header, func(t *testing.T) {
+			assert.Equal(t, tc.exp, primaryLanguage(tc.header))
+		})
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	tests := []struct {
+		name string
+		s    string
+		n    int
+		exp  string
+	}{
+		{name: "short", s: "abc", n: 5, exp: "abc"},
+		{name: "exact", s: "abcde", n: 5, exp: "abcde"},
+		{name: "long", s: "abcdef", n: 5, exp: "abcde"},
+		{name: "empty", s: "", n: 5, exp: ""},
+		{name: "cut rune", s: "abč", n: 3, exp: "ab"},
+		{name: "whole rune", s: "abč", n: 4, exp: "abč"},
+		{name: "invalid utf-8", s: "a\xffb", n: 5, exp: "ab"},
+		{name: "nul bytes", s: "a\x00b\x00", n: 5, exp: "ab"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.exp, truncate(tc.s, tc.n))
+		})
+	}
+}
+
+func TestClickWriter_Parse(t *testing.T) {
+	logged := time.Date(2024, time.March, 1, 12, 0, 0, 0, time.UTC)
+	firefox := "Mozilla/5.0 (X11; Linux x86_64; rv:120.0) Gecko/20100101 Firefox/120.0"
+
+	tests := []struct {
+		name  string
+		click Click
+		check func(t *testing.T, u *usage)
+	}{
+		{
+			name: "browser",
+			click: Click{
+				ShortcutID:     "a",
+				ClientIP:       "203.0.113.195",
+				Referrer:       "https://News.Example.com:8443/item?id=1",
+				UserAgent:      firefox,
+				AcceptLanguage: "cs-CZ,cs;q=0.9",
+				Query:          "utm_source=news",
+				Method:         "GET",
+				LoggedAt:       logged,
+			},
+			check: func(t *testing.T, u *usage) {
+				assert.Equal(t, "a", u.ShortcutID)
+				assert.Equal(t, logged, u.LoggedAt)
+				assert.Equal(t, "https://News.Example.com:8443/item?id=1", u.Referrer)
+				assert.Equal(t, "news.example.com", u.ReferrerHost)
+				assert.Equal(t, "Firefox", u.Browser)
+				assert.Equal(t, "Linux", u.OS)
+				assert.Equal(t, "cs-cz", u.Language)
+				assert.Equal(t, "utm_source=news", u.Query)
+				assert.Len(t, u.IPHash, ipHashLen)
+				assert.False(t, u.IsBot)
+				assert.Empty(t, u.BotReason)
+			},
+		},
+		{
+			name:  "bot",
+			click: Click{ShortcutID: "a", Method: "HEAD", UserAgent: firefox},
+			check: func(t *testing.T, u *usage) {
+				assert.True(t, u.IsBot)
+				assert.Equal(t, bots.ReasonHead, u.BotReason)
+				assert.Empty(t, u.IPHash)
+			},
+		},
+		{
+			name: "long details",
+			click: Click{
+				ShortcutID:     "a",
+				Referrer:       "https://example.com/" + strings.Repeat("r", maxReferrerLen),
+				UserAgent:      strings.Repeat("u", maxUserAgentLen+1),
+				AcceptLanguage: strings.Repeat("l", maxLanguageLen+1),
+				Query:          strings.Repeat("q", maxQueryLen+1),
+				Method:         "GET",
+			},
+			check: func(t *testing.T, u *usage) {
+				assert.Len(t, u.Referrer, maxReferrerLen)
+				assert.Equal(t, "example.com", u.ReferrerHost)
+				assert.Len(t, u.UserAgent, maxUserAgentLen)
+				assert.Len(t, u.Language, maxLanguageLen)
+				assert.Len(t, u.Query, maxQueryLen)
+			},
+		},
+		{
+			name:  "invalid referrer",
+			click: Click{ShortcutID: "a", Referrer: "://\x00bad", UserAgent: firefox, Method: "GET"},
+			check: func(t *testing.T, u *usage) {
+				assert.Equal(t, "://bad", u.Referrer)
+				assert.Empty(t, u.ReferrerHost)
+			},
+		},
+	}
+
+	w := &clickWriter{ipKey: []byte("secret")}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			c := tc.click
+			tc.check(t, w.parse(&c, nil, nil, nil))
+		})
+	}
+}
+
+func TestLogUsages_DeletedShortcuts(t *testing.T) {
+	s, db := newMockDB(t)
+
+	// the deleted shortcuts are not counted
+	db.ExpectBegin()
+	db.ExpectExec(`SET\s+usage = usage \+ c\.clicks[\s\S]+AND deleted_at IS NULL`)
+	db.ExpectQuery(`AND analytics_disabled`).WillReturnRows([]string{"shortcut_id"}, []driver.Value{"a"})
+	db.ExpectCommit()
+
+	assert.NoError(t, s.logUsages(context.Background(), []*usage{{ShortcutID: "a"}}))
+}
@@ -3,7 +3,11 @@ package data
 import (
 	"context"
 	"database/sql"
-	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/chutommy/url-shortener/logging"
+	"github.com/chutommy/url-shortener/report"
 )
 
 // newNullString returns a passed string in sql's NullString type.
@@ -18,16 +22,40 @@ func newNullString(s string) sql.NullString {
 	}
 }
 
-// LogError logs the error into error_logs table.
+// newNullTime returns a passed time in sql's NullTime type.
+func newNullTime(t time.Time) sql.NullTime {
+	if t.IsZero() {
+		return sql.NullTime{}
+	}
+
+	return sql.NullTime{
+		Time:  t.UTC(),
+		Valid: true,
+	}
+}
+
+// logger returns the request scoped logger of the context or the logger of the service.
+func (s *service) logger(ctx context.Context) *slog.Logger {
+	if l, ok := ctx.Value(logging.LoggerKey).(*slog.Logger); ok {
+		return l
+	}
+
+	return s.log
+}
+
+// LogError logs the error and delivers it with the context of its request
+// to the error reporters. Failures of the reporters are logged.
 func (s *service) LogError(ctx context.Context, logErr error) {
-	// insert
-	_, err := s.DB.ExecContext(ctx, `
-INSERT INTO
-  error_logs (err_msg)
-VALUES
-  ($1);
-  `, logErr)
-	if err != nil {
-		fmt.Printf("[ERROR] unalbe to log error (%v): %v", logErr, err)
+	e := report.NewEvent(ctx, logErr, 1)
+
+	log := s.logger(ctx)
+	log.ErrorContext(ctx, "unexpected error", slog.Any("error", logErr), slog.String("fingerprint", e.Fingerprint))
+
+	// report
+	for _, r := range s.reporters {
+		if err := r.Report(ctx, e); err != nil {
+			log.ErrorContext(ctx, "unable to report error", slog.Any("error", err),
+				slog.String("fingerprint", e.Fingerprint))
+		}
 	}
 }
@@ -2,11 +2,21 @@ package data
 
 import (
 	"context"
+	"database/sql"
 	"errors"
 	"fmt"
+	"log/slog"
+	"sync/atomic"
+	"time"
 
+	"github.com/chutommy/url-shortener/bots"
 	"github.com/chutommy/url-shortener/config"
+	"github.com/chutommy/url-shortener/geoip"
+	"github.com/chutommy/url-shortener/live"
+	"github.com/chutommy/url-shortener/report"
+	"github.com/chutommy/url-shortener/tracing"
 	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
 )
 
 // ErrUnexpectedError is returned if something internal went wrong.
@@ -17,12 +27,14 @@ var ErrUnexpectedError = errors.New("unexpected internal server error")
 type Service interface {
 	InitDB(context.Context, *config.DB) error
 	StopDB() error
+	Ping(context.Context) error
+	CheckSchema(context.Context) error
 	AddRecord(context.Context, *Record) (*ShortRecord, error)
 	UpdateRecord(context.Context, string, *ShortRecord) (*ShortRecord, error)
 	DeleteRecord(context.Context, string) (string, error)
 	GetRecordByID(context.Context, string) (*Record, error)
 	GetRecordByShort(context.Context, string) (*Record, error)
-	GetRecordByShortPeek(context.Context, string) (string, error)
+	GetRecordByShortPeek(context.Context, string) (*Record, error)
 	GetRecordsLen(context.Context) (int, error)
 	GetAllRecords(context.Context) ([]*ShortRecord, error)
 	RecordRecovery(context.Context, string) (string, error)
@@ -31,16 +43,68 @@ type Service interface {
 	GenerateAdminKey(context.Context) (string, error)
 	RevokeAdminKey(context.Context, string) error
 	LogError(context.Context, error)
+	LogLockout(context.Context, string, string, string, int, time.Time) error
+	InitSessions(*config.Session) error
+	CreateSession(context.Context, string, string) (*Session, error)
+	RefreshSession(context.Context, string) (*Session, error)
+	ValidateSession(context.Context, string) (*SessionClaims, error)
+	RevokeSession(context.Context, string) error
+	EnrollTOTP(context.Context, string) (*TOTPEnrolment, error)
+	ConfirmTOTP(context.Context, string, string) error
+	TOTPEnabled(context.Context, string) (bool, error)
+	VerifyTOTP(context.Context, string, string) error
+	DisableTOTP(context.Context, string) error
+	GenerateSigningSecret(context.Context, string) (string, error)
+	GetSigningSecret(context.Context, string) (string, error)
+	LogAuditEvent(context.Context, *AuditEvent) error
+	GetAuditEvents(context.Context, *AuditFilter) ([]*AuditEvent, error)
+	ExportAuditEvents(context.Context, *AuditFilter, func(*AuditEvent) error) error
+	InitReporters(*config.ErrorReporting) error
+	GetErrorGroups(context.Context, *ErrorGroupFilter) ([]*ErrorGroup, error)
+	GetErrorGroup(context.Context, string) (*ErrorGroup, []*ErrorOccurrence, error)
+	ResolveErrorGroup(context.Context, string) error
+	InitClicks(*config.Analytics) error
+	InitGeoIP(*config.GeoIP) error
+	InitRollups(*config.Rollup) error
+	RecordClick(*Click)
+	GetClickStats(context.Context, *ClickStatsQuery) (*ClickStats, error)
+	GetTopRecords(context.Context, *TopQuery) ([]*TopRecord, error)
+	GetBotRules(context.Context) ([]*BotRule, error)
+	AddBotRule(context.Context, string) (*BotRule, error)
+	DeleteBotRule(context.Context, int64) error
+	SetAnalyticsDisabled(context.Context, string, bool) error
+	EraseRecordAnalytics(context.Context, string) (int64, error)
+	EraseVisitorAnalytics(context.Context, *VisitorErasure) (int64, error)
+	ExportClicks(context.Context, *ExportQuery, func(*ClickEvent) error) error
+	ExportSeries(context.Context, *ExportQuery, func(*SeriesPoint) error) error
+	InitLive(*config.Live) error
+	SubscribeClicks(live.Filter) (*live.Subscription, error)
+	CreateShareToken(context.Context, *ShareToken) (*ShareToken, error)
+	GetShareTokens(context.Context, string) ([]*ShareToken, error)
+	RevokeShareToken(context.Context, int64) error
+	ValidateShareToken(context.Context, string) (*ShortRecord, error)
 }
 
 // service implements Service interface.
 type service struct {
-	DB *sqlx.DB
+	DB     *sqlx.DB
+	tokens *tokenSigner
+	log    *slog.Logger
+
+	reporters []report.Reporter
+	clicks    *clickWriter
+	geo       *geoip.Reader
+	rollups   *rollupJob
+	live      *live.Broadcaster
+
+	botClassifier atomic.Pointer[bots.Classifier]
 }
 
 // NewService is the constructor of the Service controller.
-func NewService() Service {
-	return &service{}
+func NewService(log *slog.Logger) Service {
+	return &service{
+		log: log,
+	}
 }
 
 // InitDB initializes the database connection for the data server.
@@ -49,30 +113,44 @@ func (s *service) InitDB(ctx context.Context, dbCfg *config.DB) error {
 	// retrieve db connection string
 	driver, connStr := dbCfg.ConnStr()
 
-	// open connection to db
-	var err error
-	s.DB, err = sqlx.ConnectContext(ctx, driver, connStr)
-
+	// open connection to db, statements are traced
+	connector, err := pq.NewConnector(connStr)
 	if err != nil {
 		return fmt.Errorf("failed to open db conn: %w", err)
 	}
 
+	s.DB = sqlx.NewDb(sql.OpenDB(tracing.WrapConnector(connector, driver)), driver)
+
 	// test connection
-	err = s.DB.Ping()
+	err = s.DB.PingContext(ctx)
 	if err != nil {
 		return fmt.Errorf("failed to make a database connection: %w", err)
 	}
 
+	registerDBStats(s.DB)
+	s.log.Info("database connection established", slog.String("driver", driver))
+
 	return nil
 }
 
 // StopDB closes database connection of the service.
 func (s *service) StopDB() error {
+	// store queued clicks
+	s.stopClicks()
+	s.stopLive()
+	s.stopGeoIP()
+	s.stopRollups()
+
 	// close db connection
 	err := s.DB.Close()
 	if err != nil {
 		return fmt.Errorf("failed to successfully close database connection: %w", err)
 	}
 
+	// close reporters
+	if err = s.closeReporters(); err != nil {
+		return fmt.Errorf("failed to close error reporters: %w", err)
+	}
+
 	return nil
 }
@@ -0,0 +1,26 @@
+package data
+
+import (
+	"github.com/chutommy/url-shortener/metrics"
+	"github.com/jmoiron/sqlx"
+)
+
+// registerDBStats exposes the statistics of the connection pool in the metrics.
+func registerDBStats(db *sqlx.DB) {
+	metrics.Default.GaugeFunc("db_max_open_connections", "Maximal number of open database connections.",
+		func() float64 { return float64(db.Stats().MaxOpenConnections) })
+	metrics.Default.GaugeFunc("db_open_connections", "Open database connections.",
+		func() float64 { return float64(db.Stats().OpenConnections) })
+	metrics.Default.GaugeFunc("db_in_use_connections", "Database connections in use.",
+		func() float64 { return float64(db.Stats().InUse) })
+	metrics.Default.GaugeFunc("db_idle_connections", "Idle database connections.",
+		func() float64 { return float64(db.Stats().Idle) })
+	metrics.Default.CounterFunc("db_wait_count_total", "Waits for a database connection.",
+		func() float64 { return float64(db.Stats().WaitCount) })
+	metrics.Default.CounterFunc("db_wait_duration_seconds_total", "Time spent waiting for a database connection.",
+		func() float64 { return db.Stats().WaitDuration.Seconds() })
+	metrics.Default.CounterFunc("db_max_idle_closed_total", "Connections closed because of the maximal idle connections.",
+		func() float64 { return float64(db.Stats().MaxIdleClosed) })
+	metrics.Default.CounterFunc("db_max_lifetime_closed_total", "Connections closed because of the maximal lifetime.",
+		func() float64 { return float64(db.Stats().MaxLifetimeClosed) })
+}
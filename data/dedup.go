@@ -0,0 +1,102 @@
+package data
+
+import (
+	"container/list"
+	"time"
+
+	"github.com/chutommy/url-shortener/hll"
+)
+
+// dedupKey identifies a visitor of a shortcut.
+type dedupKey struct {
+	shortcutID string
+	visitor    uint64
+}
+
+// dedupEntry is the last counted click of the visitor.
+type dedupEntry struct {
+	key dedupKey
+	at  time.Time
+}
+
+// dedupWindow remembers the last counted clicks of the recent visitors, so the
+// repeated clicks within the window, such as double-clicks and reloads, are not
+// counted again. The entries are ordered by the time of the counted click and
+// the oldest ones are evicted when the capacity is reached, so the memory is
+// bounded. It is used only by the click writer and is not safe for the
+// concurrent use. Each instance de-duplicates the clicks it receives.
+type dedupWindow struct {
+	window   time.Duration
+	capacity int
+	order    *list.List
+	entries  map[dedupKey]*list.Element
+}
+
+// newDedupWindow is a constructor of the dedupWindow, a zero window disables
+// the de-duplication and nil is returned.
+func newDedupWindow(window time.Duration, capacity int) *dedupWindow {
+	if window <= 0 || capacity <= 0 {
+		return nil
+	}
+
+	return &dedupWindow{
+		window:   window,
+		capacity: capacity,
+		order:    list.New(),
+		entries:  make(map[dedupKey]*list.Element),
+	}
+}
+
+// repeat reports whether the visitor of the usage clicked the shortcut within
+// the window before the usage. Otherwise the usage is remembered as the counted
+// click. The clicks of the unknown visitors are never repeats.
+func (d *dedupWindow) repeat(u *usage) bool {
+	if d == nil || u.IPHash == "" {
+		return false
+	}
+
+	k := dedupKey{shortcutID: u.ShortcutID, visitor: visitorFingerprint(u)}
+	d.evict(u.LoggedAt)
+
+	if el, ok := d.entries[k]; ok {
+		e := el.Value.(*dedupEntry)
+		if u.LoggedAt.Sub(e.at) < d.window {
+			return true
+		}
+
+		e.at = u.LoggedAt
+		d.order.MoveToFront(el)
+
+		return false
+	}
+
+	if d.order.Len() >= d.capacity {
+		d.remove(d.order.Back())
+	}
+
+	d.entries[k] = d.order.PushFront(&dedupEntry{key: k, at: u.LoggedAt})
+
+	return false
+}
+
+// evict removes the entries whose window ended before t.
+func (d *dedupWindow) evict(t time.Time) {
+	for el := d.order.Back(); el != nil; el = d.order.Back() {
+		if t.Sub(el.Value.(*dedupEntry).at) < d.window {
+			return
+		}
+
+		d.remove(el)
+	}
+}
+
+// remove removes the entry of the element.
+func (d *dedupWindow) remove(el *list.Element) {
+	delete(d.entries, el.Value.(*dedupEntry).key)
+	d.order.Remove(el)
+}
+
+// visitorFingerprint returns the hash of the hashed client IP and the user agent of the usage.
+func visitorFingerprint(u *usage) uint64 {
+	return hll.Hash([]byte(u.IPHash + "\x00" + u.UserAgent))
+}
@@ -0,0 +1,44 @@
+package data
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDedupWindow_Repeat(t *testing.T) {
+	start := time.Date(2024, time.March, 14, 12, 0, 0, 0, time.UTC)
+
+	click := func(id, ip string, after time.Duration) *usage {
+		return &usage{ShortcutID: id, IPHash: ip, UserAgent: "Firefox", LoggedAt: start.Add(after)}
+	}
+
+	d := newDedupWindow(30*time.Second, 2)
+
+	assert.False(t, d.repeat(click("a", "ip1", 0)), "first click")
+	assert.True(t, d.repeat(click("a", "ip1", 10*time.Second)), "reload")
+	assert.False(t, d.repeat(click("b", "ip1", 10*time.Second)), "other shortcut")
+	assert.False(t, d.repeat(click("a", "", 10*time.Second)), "unknown visitor")
+	assert.True(t, d.repeat(click("a", "ip1", 29*time.Second)), "window of the counted click")
+	assert.False(t, d.repeat(click("a", "ip1", 30*time.Second)), "window ended")
+
+	// the capacity evicts the oldest visitor
+	assert.False(t, d.repeat(click("c", "ip2", 31*time.Second)))
+	assert.False(t, d.repeat(click("d", "ip3", 32*time.Second)))
+	assert.Equal(t, 2, d.order.Len())
+	assert.False(t, d.repeat(click("a", "ip1", 33*time.Second)), "evicted by the capacity")
+
+	// expired visitors are evicted
+	assert.False(t, d.repeat(click("e", "ip4", 10*time.Minute)))
+	assert.Equal(t, 1, d.order.Len())
+}
+
+func TestDedupWindow_Disabled(t *testing.T) {
+	d := newDedupWindow(0, 100)
+	assert.Nil(t, d)
+
+	u := &usage{ShortcutID: "a", IPHash: "ip1", LoggedAt: time.Now()}
+	assert.False(t, d.repeat(u))
+	assert.False(t, d.repeat(u))
+}
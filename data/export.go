@@ -0,0 +1,337 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var (
+	// ErrInvalidExportRange is returned if the range of the export is empty.
+	ErrInvalidExportRange = errors.New("range of the export must be non-empty")
+	// ErrInvalidCursor is returned if the cursor of the export is malformed.
+	ErrInvalidCursor = errors.New("cursor is malformed")
+)
+
+// ExportQuery selects the clicks in the range [From, To) of a shortcut, of the
+// shortcuts with the Tag or of all shortcuts. Bots filters the clicks of the
+// bots, they are excluded by default. The series are bucketed by the Interval
+// in UTC. The export resumes after the Cursor and a zero Limit exports all rows.
+type ExportQuery struct {
+	ShortcutID string
+	Tag        string
+	From       time.Time
+	To         time.Time
+	Bots       string
+	Interval   string
+	Cursor     *ExportCursor
+	Limit      int
+}
+
+// ExportCursor is the position of an exported row, the rows are ordered by the
+// Time and the ID. The ID is the id of the click or of the shortcut of a bucket.
+type ExportCursor struct {
+	Time time.Time
+	ID   string
+}
+
+// ClickEvent is an exported click. The hash of the visitor's IP is never exported.
+type ClickEvent struct {
+	ID           int64     `json:"id"`
+	ShortcutID   string    `json:"shortcut_id"`
+	ShortURL     string    `json:"short_url"`
+	Time         time.Time `json:"time"`
+	Referrer     string    `json:"referrer"`
+	ReferrerHost string    `json:"referrer_host"`
+	UserAgent    string    `json:"user_agent"`
+	Browser      string    `json:"browser"`
+	OS           string    `json:"os"`
+	Device       string    `json:"device"`
+	Language     string    `json:"language"`
+	Country      string    `json:"country"`
+	Region       string    `json:"region"`
+	City         string    `json:"city"`
+	Query        string    `json:"query"`
+	IsBot        bool      `json:"is_bot"`
+	BotReason    string    `json:"bot_reason"`
+	IsRepeat     bool      `json:"is_repeat"`
+	Cursor       string    `json:"cursor"`
+}
+
+// SeriesPoint is the number of the clicks of a shortcut in the bucket starting at Bucket.
+type SeriesPoint struct {
+	ShortcutID string    `json:"shortcut_id"`
+	ShortURL   string    `json:"short_url"`
+	Bucket     time.Time `json:"bucket"`
+	Clicks     int       `json:"clicks"`
+	Cursor     string    `json:"cursor"`
+}
+
+// String encodes the cursor into an opaque URL safe token.
+func (cur *ExportCursor) String() string {
+	raw := strconv.FormatInt(cur.Time.UnixNano(), 10) + ":" + cur.ID
+
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// ParseExportCursor decodes the cursor from its token.
+func ParseExportCursor(s string) (*ExportCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	parts := strings.SplitN(string(raw), ":", 2)
+	if len(parts) != 2 || parts[1] == "" {
+		return nil, ErrInvalidCursor
+	}
+
+	ns, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	return &ExportCursor{Time: time.Unix(0, ns).UTC(), ID: parts[1]}, nil
+}
+
+// Validate checks the shortcut, the range, the cursor and the filter of the
+// bots of the query. An empty filter of the bots is set to exclude them. The
+// interval is checked only if it is set, it is required by the series whose
+// cursors point to a shortcut, the cursors of the clicks point to a click.
+func (q *ExportQuery) Validate() error {
+	if q.ShortcutID != "" {
+		if _, err := uuid.Parse(q.ShortcutID); err != nil {
+			return ErrInvalidID
+		}
+	}
+
+	if q.Interval != "" {
+		if _, ok := map[string]bool{IntervalMinute: true, IntervalHour: true, IntervalDay: true, IntervalWeek: true}[q.Interval]; !ok {
+			return ErrInvalidInterval
+		}
+	}
+
+	if q.Bots == "" {
+		q.Bots = BotsExclude
+	}
+
+	if _, ok := botFilters[q.Bots]; !ok {
+		return ErrInvalidBots
+	}
+
+	if !q.From.Before(q.To) {
+		return ErrInvalidExportRange
+	}
+
+	if q.Cursor != nil {
+		var err error
+		if q.Interval != "" {
+			_, err = uuid.Parse(q.Cursor.ID)
+		} else {
+			_, err = strconv.ParseInt(q.Cursor.ID, 10, 64)
+		}
+
+		if err != nil {
+			return ErrInvalidCursor
+		}
+	}
+
+	return nil
+}
+
+// ExportClicks streams the raw clicks matching the query into fn one by one,
+// ordered by their time and id. Only the clicks kept by the raw retention are
+// exported, the repeats are exported and flagged.
+func (s *service) ExportClicks(ctx context.Context, q *ExportQuery, fn func(*ClickEvent) error) error {
+	if err := q.Validate(); err != nil {
+		return err
+	}
+
+	var (
+		afterTime sql.NullTime
+		afterID   int64
+	)
+
+	if q.Cursor != nil {
+		// the id of the cursor is validated
+		afterID, _ = strconv.ParseInt(q.Cursor.ID, 10, 64)
+		afterTime = sql.NullTime{Time: q.Cursor.Time.UTC(), Valid: true}
+	}
+
+	limit := sql.NullInt64{Int64: int64(q.Limit), Valid: q.Limit > 0}
+
+	// the filter of the bots is one of the known conditions, never the user input
+	rows, err := s.DB.QueryContext(ctx, fmt.Sprintf(`
+SELECT
+  c.usage_id,
+  c.shortcut_id,
+  s.short_url,
+  c.logged_at,
+  COALESCE(c.referrer, ''),
+  COALESCE(c.referrer_host, ''),
+  COALESCE(c.user_agent, ''),
+  COALESCE(c.browser, ''),
+  COALESCE(c.os, ''),
+  COALESCE(c.device, ''),
+  COALESCE(c.language, ''),
+  COALESCE(c.country::TEXT, ''),
+  COALESCE(c.region, ''),
+  COALESCE(c.city, ''),
+  COALESCE(c.query, ''),
+  c.is_bot,
+  COALESCE(c.bot_reason, ''),
+  c.is_repeat
+FROM
+  usages AS c
+  JOIN shortcuts AS s ON s.shortcut_id = c.shortcut_id
+WHERE
+  c.logged_at >= $1
+  AND c.logged_at < $2
+  AND ($3::UUID IS NULL OR c.shortcut_id = $3)
+  AND ($4 = '' OR $4 = ANY (s.tags))
+  AND ($5::TIMESTAMP IS NULL OR (c.logged_at, c.usage_id) > ($5, $6))
+  AND %s
+ORDER BY
+  c.logged_at,
+  c.usage_id
+LIMIT
+  $7;
+  `, botFilters[q.Bots]), q.From.UTC(), q.To.UTC(), newNullString(q.ShortcutID), strings.ToLower(q.Tag),
+		afterTime, afterID, limit)
+	if err != nil {
+		return fmt.Errorf("could not query clicks: %w", err)
+	}
+
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	// scan rows
+	for rows.Next() {
+		var e ClickEvent
+
+		err = rows.Scan(&e.ID, &e.ShortcutID, &e.ShortURL, &e.Time, &e.Referrer, &e.ReferrerHost, &e.UserAgent,
+			&e.Browser, &e.OS, &e.Device, &e.Language, &e.Country, &e.Region, &e.City, &e.Query, &e.IsBot,
+			&e.BotReason, &e.IsRepeat)
+		if err != nil {
+			return fmt.Errorf("retrieved sql row scan error: %w", err)
+		}
+
+		e.Time = e.Time.UTC()
+		e.Cursor = (&ExportCursor{Time: e.Time, ID: strconv.FormatInt(e.ID, 10)}).String()
+
+		if err = fn(&e); err != nil {
+			return err
+		}
+	}
+
+	if err = rows.Err(); err != nil {
+		return fmt.Errorf("failed to iterate clicks: %w", err)
+	}
+
+	return nil
+}
+
+// ExportSeries streams the counted clicks matching the query bucketed by the
+// interval into fn one by one, ordered by the bucket and the shortcut. The
+// buckets with no clicks are skipped. The rolled up clicks are counted in the
+// buckets of their hour or day, whichever is kept.
+func (s *service) ExportSeries(ctx context.Context, q *ExportQuery, fn func(*SeriesPoint) error) error {
+	if err := q.Validate(); err != nil {
+		return err
+	}
+
+	if q.Interval == "" {
+		return ErrInvalidInterval
+	}
+
+	var (
+		afterTime sql.NullTime
+		afterID   sql.NullString
+	)
+
+	if q.Cursor != nil {
+		afterTime, afterID = sql.NullTime{Time: q.Cursor.Time.UTC(), Valid: true}, newNullString(q.Cursor.ID)
+	}
+
+	segs, err := s.segments(ctx, q.From, q.To)
+	if err != nil {
+		return err
+	}
+
+	limit := sql.NullInt64{Int64: int64(q.Limit), Valid: q.Limit > 0}
+	source, sourceArgs := clickSource(segs, 7)
+
+	// the filter of the bots is one of the known conditions, never the user input
+	rows, err := s.DB.QueryContext(ctx, fmt.Sprintf(`
+SELECT
+  g.shortcut_id,
+  g.short_url,
+  g.bucket,
+  g.clicks
+FROM
+  (
+    SELECT
+      c.shortcut_id,
+      s.short_url,
+      DATE_TRUNC($1, c.t) AS bucket,
+      SUM(c.clicks) AS clicks
+    FROM
+      (%s) AS c
+      JOIN shortcuts AS s ON s.shortcut_id = c.shortcut_id
+    WHERE
+      ($2::UUID IS NULL OR c.shortcut_id = $2)
+      AND ($3 = '' OR $3 = ANY (s.tags))
+      AND %s
+    GROUP BY
+      c.shortcut_id,
+      s.short_url,
+      bucket
+  ) AS g
+WHERE
+  $4::TIMESTAMP IS NULL
+  OR (g.bucket, g.shortcut_id) > ($4, $5::UUID)
+ORDER BY
+  g.bucket,
+  g.shortcut_id
+LIMIT
+  $6;
+  `, source, botFilters[q.Bots]), append([]interface{}{q.Interval, newNullString(q.ShortcutID),
+		strings.ToLower(q.Tag), afterTime, afterID, limit}, sourceArgs...)...)
+	if err != nil {
+		return fmt.Errorf("could not query click series: %w", err)
+	}
+
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	// scan rows
+	for rows.Next() {
+		var p SeriesPoint
+
+		if err = rows.Scan(&p.ShortcutID, &p.ShortURL, &p.Bucket, &p.Clicks); err != nil {
+			return fmt.Errorf("retrieved sql row scan error: %w", err)
+		}
+
+		p.Bucket = p.Bucket.UTC()
+		p.Cursor = (&ExportCursor{Time: p.Bucket, ID: p.ShortcutID}).String()
+
+		if err = fn(&p); err != nil {
+			return err
+		}
+	}
+
+	if err = rows.Err(); err != nil {
+		return fmt.Errorf("failed to iterate click series: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,62 @@
+package data
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExportCursor(t *testing.T) {
+	cur := &ExportCursor{Time: time.Date(2024, time.March, 1, 12, 30, 0, 123456000, time.UTC), ID: "42"}
+
+	parsed, err := ParseExportCursor(cur.String())
+	assert.NoError(t, err)
+	assert.Equal(t, cur, parsed)
+
+	for _, s := range []string{"", "not base64!", "NDI", "eDo0Mg"} {
+		_, err = ParseExportCursor(s)
+		assert.True(t, errors.Is(err, ErrInvalidCursor), s)
+	}
+}
+
+func TestExportQuery_Validate(t *testing.T) {
+	from := time.Date(2024, time.March, 1, 0, 0, 0, 0, time.UTC)
+	to := from.Add(24 * time.Hour)
+	id := "6ba7b810-9dad-11d1-80b4-00c04fd430c8"
+
+	tests := []struct {
+		name string
+		q    ExportQuery
+		exp  error
+	}{
+		{name: "clicks", q: ExportQuery{From: from, To: to}},
+		{name: "series", q: ExportQuery{ShortcutID: id, From: from, To: to, Interval: IntervalHour, Bots: BotsOnly}},
+		{name: "invalid shortcut", q: ExportQuery{ShortcutID: "abc", From: from, To: to}, exp: ErrInvalidID},
+		{name: "invalid interval", q: ExportQuery{From: from, To: to, Interval: "month"}, exp: ErrInvalidInterval},
+		{name: "invalid bots", q: ExportQuery{From: from, To: to, Bots: "some"}, exp: ErrInvalidBots},
+		{name: "empty range", q: ExportQuery{From: to, To: to}, exp: ErrInvalidExportRange},
+		{name: "click cursor", q: ExportQuery{From: from, To: to, Cursor: &ExportCursor{Time: from, ID: "42"}}},
+		{
+			name: "series cursor of a click", exp: ErrInvalidCursor,
+			q: ExportQuery{From: from, To: to, Interval: IntervalDay, Cursor: &ExportCursor{Time: from, ID: "42"}},
+		},
+		{
+			name: "click cursor of a series", exp: ErrInvalidCursor,
+			q: ExportQuery{From: from, To: to, Cursor: &ExportCursor{Time: from, ID: id}},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.q.Validate()
+			if tc.exp == nil {
+				assert.NoError(t, err)
+				assert.NotEmpty(t, tc.q.Bots)
+			} else {
+				assert.True(t, errors.Is(err, tc.exp), err)
+			}
+		})
+	}
+}
@@ -0,0 +1,66 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// SchemaVersion is the version of the latest migration in the schema directory
+// which the service expects to be applied.
+const SchemaVersion = 26
+
+var (
+	// ErrSchemaVersion is returned if the applied migrations do not match the expected version.
+	ErrSchemaVersion = errors.New("unexpected version of the database schema")
+	// ErrSchemaDirty is returned if the last migration failed and the schema needs to be fixed manually.
+	ErrSchemaDirty = errors.New("database schema is dirty")
+)
+
+// Ping verifies the database connection is alive.
+func (s *service) Ping(ctx context.Context) error {
+	if err := s.DB.PingContext(ctx); err != nil {
+		return fmt.Errorf("failed to ping the database: %w", err)
+	}
+
+	return nil
+}
+
+// CheckSchema verifies the migrations applied by the migrate tool are at the
+// SchemaVersion and none of them failed.
+func (s *service) CheckSchema(ctx context.Context) error {
+	// query db
+	row := s.DB.QueryRowxContext(ctx, `
+SELECT
+  version,
+  dirty
+FROM
+  schema_migrations
+LIMIT
+  1;
+  `)
+
+	// scan row
+	var (
+		version int
+		dirty   bool
+	)
+
+	if err := row.Scan(&version, &dirty); errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("%w: no migration is applied", ErrSchemaVersion)
+	} else if err != nil {
+		return fmt.Errorf("retrieved sql row scan error: %w", err)
+	}
+
+	// check version
+	if dirty {
+		return fmt.Errorf("%w: migration %d failed", ErrSchemaDirty, version)
+	}
+
+	if version != SchemaVersion {
+		return fmt.Errorf("%w: expected %d, got %d", ErrSchemaVersion, SchemaVersion, version)
+	}
+
+	return nil
+}
@@ -0,0 +1,434 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/chutommy/url-shortener/metrics"
+	"github.com/chutommy/url-shortener/tracing"
+)
+
+// Results of the data operations in the metrics.
+const (
+	resultOK       = "ok"
+	resultRejected = "rejected"
+	resultError    = "error"
+)
+
+var (
+	opDuration = metrics.Default.Histogram("data_operation_duration_seconds",
+		"Latency of the data service operations.", metrics.DefBuckets, "operation")
+	opResults = metrics.Default.Counter("data_operations_total",
+		"Data service operations by the result: ok, rejected (an expected error such as not found) or error.",
+		"operation", "result")
+)
+
+// rejections are the expected errors of the operations which are not failures of the service.
+var rejections = []error{
+	ErrUnauthorized, ErrPrefixNotFound, ErrInvalidRecord, ErrIDNotFound, ErrShortNotFound,
+	ErrUnavailableShort, ErrInvalidID, ErrNotDeleted, ErrSessionsDisabled, ErrTOTPEnabled,
+	ErrTOTPNotEnrolled, ErrErrorGroupNotFound, ErrInvalidInterval, ErrInvalidBreakdown,
+	ErrInvalidStatsRange, ErrInvalidTags, ErrInvalidWindow, ErrInvalidSort, ErrInvalidBots,
+	ErrInvalidBotRule, ErrBotRuleExists, ErrBotRuleNotFound, ErrInvalidErasure,
+	ErrInvalidExportRange, ErrInvalidCursor, ErrInvalidShareToken, ErrShareTokenNotFound, ErrShareUnauthorized,
+}
+
+// instrumented records the latency and the result of every operation of the
+// wrapped Service and traces it by a span.
+type instrumented struct {
+	Service
+}
+
+// Instrument wraps the service, so the latencies and results of its operations are measured and traced.
+func Instrument(s Service) Service {
+	return &instrumented{Service: s}
+}
+
+// observe starts the span of the operation. The returned function records the
+// operation's latency and the result of the returned error.
+func observe(ctx context.Context, op string) (context.Context, func(*error)) {
+	start := time.Now()
+	ctx, span := tracing.Start(ctx, "data."+op)
+
+	return ctx, func(err *error) {
+		res := result(*err)
+
+		opDuration.Observe(time.Since(start).Seconds(), op)
+		opResults.Inc(op, res)
+
+		span.SetAttrs(tracing.String("data.result", res))
+		if res == resultError {
+			span.SetError(*err)
+		}
+
+		span.End()
+	}
+}
+
+// result classifies the error of an operation.
+func result(err error) string {
+	if err == nil {
+		return resultOK
+	}
+
+	for _, r := range rejections {
+		if errors.Is(err, r) {
+			return resultRejected
+		}
+	}
+
+	return resultError
+}
+
+// AddRecord instruments the AddRecord operation.
+func (i *instrumented) AddRecord(ctx context.Context, r *Record) (_ *ShortRecord, err error) {
+	ctx, done := observe(ctx, "AddRecord")
+	defer done(&err)
+
+	return i.Service.AddRecord(ctx, r)
+}
+
+// UpdateRecord instruments the UpdateRecord operation.
+func (i *instrumented) UpdateRecord(ctx context.Context, id string, r *ShortRecord) (_ *ShortRecord, err error) {
+	ctx, done := observe(ctx, "UpdateRecord")
+	defer done(&err)
+
+	return i.Service.UpdateRecord(ctx, id, r)
+}
+
+// DeleteRecord instruments the DeleteRecord operation.
+func (i *instrumented) DeleteRecord(ctx context.Context, id string) (_ string, err error) {
+	ctx, done := observe(ctx, "DeleteRecord")
+	defer done(&err)
+
+	return i.Service.DeleteRecord(ctx, id)
+}
+
+// GetRecordByID instruments the GetRecordByID operation.
+func (i *instrumented) GetRecordByID(ctx context.Context, id string) (_ *Record, err error) {
+	ctx, done := observe(ctx, "GetRecordByID")
+	defer done(&err)
+
+	return i.Service.GetRecordByID(ctx, id)
+}
+
+// GetRecordByShort instruments the GetRecordByShort operation.
+func (i *instrumented) GetRecordByShort(ctx context.Context, short string) (_ *Record, err error) {
+	ctx, done := observe(ctx, "GetRecordByShort")
+	defer done(&err)
+
+	return i.Service.GetRecordByShort(ctx, short)
+}
+
+// GetRecordByShortPeek instruments the GetRecordByShortPeek operation.
+func (i *instrumented) GetRecordByShortPeek(ctx context.Context, short string) (_ *Record, err error) {
+	ctx, done := observe(ctx, "GetRecordByShortPeek")
+	defer done(&err)
+
+	return i.Service.GetRecordByShortPeek(ctx, short)
+}
+
+// GetRecordsLen instruments the GetRecordsLen operation.
+func (i *instrumented) GetRecordsLen(ctx context.Context) (_ int, err error) {
+	ctx, done := observe(ctx, "GetRecordsLen")
+	defer done(&err)
+
+	return i.Service.GetRecordsLen(ctx)
+}
+
+// GetAllRecords instruments the GetAllRecords operation.
+func (i *instrumented) GetAllRecords(ctx context.Context) (_ []*ShortRecord, err error) {
+	ctx, done := observe(ctx, "GetAllRecords")
+	defer done(&err)
+
+	return i.Service.GetAllRecords(ctx)
+}
+
+// RecordRecovery instruments the RecordRecovery operation.
+func (i *instrumented) RecordRecovery(ctx context.Context, id string) (_ string, err error) {
+	ctx, done := observe(ctx, "RecordRecovery")
+	defer done(&err)
+
+	return i.Service.RecordRecovery(ctx, id)
+}
+
+// ValidateAdminKey instruments the ValidateAdminKey operation.
+func (i *instrumented) ValidateAdminKey(ctx context.Context, key string) (err error) {
+	ctx, done := observe(ctx, "ValidateAdminKey")
+	defer done(&err)
+
+	return i.Service.ValidateAdminKey(ctx, key)
+}
+
+// GenerateAdminKey instruments the GenerateAdminKey operation.
+func (i *instrumented) GenerateAdminKey(ctx context.Context) (_ string, err error) {
+	ctx, done := observe(ctx, "GenerateAdminKey")
+	defer done(&err)
+
+	return i.Service.GenerateAdminKey(ctx)
+}
+
+// RevokeAdminKey instruments the RevokeAdminKey operation.
+func (i *instrumented) RevokeAdminKey(ctx context.Context, prefix string) (err error) {
+	ctx, done := observe(ctx, "RevokeAdminKey")
+	defer done(&err)
+
+	return i.Service.RevokeAdminKey(ctx, prefix)
+}
+
+// LogLockout instruments the LogLockout operation.
+func (i *instrumented) LogLockout(ctx context.Context, kind string, subject string, ip string, failures int, until time.Time) (err error) {
+	ctx, done := observe(ctx, "LogLockout")
+	defer done(&err)
+
+	return i.Service.LogLockout(ctx, kind, subject, ip, failures, until)
+}
+
+// CreateSession instruments the CreateSession operation.
+func (i *instrumented) CreateSession(ctx context.Context, username string, role string) (_ *Session, err error) {
+	ctx, done := observe(ctx, "CreateSession")
+	defer done(&err)
+
+	return i.Service.CreateSession(ctx, username, role)
+}
+
+// RefreshSession instruments the RefreshSession operation.
+func (i *instrumented) RefreshSession(ctx context.Context, refreshToken string) (_ *Session, err error) {
+	ctx, done := observe(ctx, "RefreshSession")
+	defer done(&err)
+
+	return i.Service.RefreshSession(ctx, refreshToken)
+}
+
+// ValidateSession instruments the ValidateSession operation.
+func (i *instrumented) ValidateSession(ctx context.Context, accessToken string) (_ *SessionClaims, err error) {
+	ctx, done := observe(ctx, "ValidateSession")
+	defer done(&err)
+
+	return i.Service.ValidateSession(ctx, accessToken)
+}
+
+// RevokeSession instruments the RevokeSession operation.
+func (i *instrumented) RevokeSession(ctx context.Context, sessionID string) (err error) {
+	ctx, done := observe(ctx, "RevokeSession")
+	defer done(&err)
+
+	return i.Service.RevokeSession(ctx, sessionID)
+}
+
+// EnrollTOTP instruments the EnrollTOTP operation.
+func (i *instrumented) EnrollTOTP(ctx context.Context, username string) (_ *TOTPEnrolment, err error) {
+	ctx, done := observe(ctx, "EnrollTOTP")
+	defer done(&err)
+
+	return i.Service.EnrollTOTP(ctx, username)
+}
+
+// ConfirmTOTP instruments the ConfirmTOTP operation.
+func (i *instrumented) ConfirmTOTP(ctx context.Context, username string, code string) (err error) {
+	ctx, done := observe(ctx, "ConfirmTOTP")
+	defer done(&err)
+
+	return i.Service.ConfirmTOTP(ctx, username, code)
+}
+
+// TOTPEnabled instruments the TOTPEnabled operation.
+func (i *instrumented) TOTPEnabled(ctx context.Context, username string) (_ bool, err error) {
+	ctx, done := observe(ctx, "TOTPEnabled")
+	defer done(&err)
+
+	return i.Service.TOTPEnabled(ctx, username)
+}
+
+// VerifyTOTP instruments the VerifyTOTP operation.
+func (i *instrumented) VerifyTOTP(ctx context.Context, username string, code string) (err error) {
+	ctx, done := observe(ctx, "VerifyTOTP")
+	defer done(&err)
+
+	return i.Service.VerifyTOTP(ctx, username, code)
+}
+
+// DisableTOTP instruments the DisableTOTP operation.
+func (i *instrumented) DisableTOTP(ctx context.Context, username string) (err error) {
+	ctx, done := observe(ctx, "DisableTOTP")
+	defer done(&err)
+
+	return i.Service.DisableTOTP(ctx, username)
+}
+
+// GenerateSigningSecret instruments the GenerateSigningSecret operation.
+func (i *instrumented) GenerateSigningSecret(ctx context.Context, prefix string) (_ string, err error) {
+	ctx, done := observe(ctx, "GenerateSigningSecret")
+	defer done(&err)
+
+	return i.Service.GenerateSigningSecret(ctx, prefix)
+}
+
+// GetSigningSecret instruments the GetSigningSecret operation.
+func (i *instrumented) GetSigningSecret(ctx context.Context, prefix string) (_ string, err error) {
+	ctx, done := observe(ctx, "GetSigningSecret")
+	defer done(&err)
+
+	return i.Service.GetSigningSecret(ctx, prefix)
+}
+
+// LogAuditEvent instruments the LogAuditEvent operation.
+func (i *instrumented) LogAuditEvent(ctx context.Context, e *AuditEvent) (err error) {
+	ctx, done := observe(ctx, "LogAuditEvent")
+	defer done(&err)
+
+	return i.Service.LogAuditEvent(ctx, e)
+}
+
+// GetAuditEvents instruments the GetAuditEvents operation.
+func (i *instrumented) GetAuditEvents(ctx context.Context, f *AuditFilter) (_ []*AuditEvent, err error) {
+	ctx, done := observe(ctx, "GetAuditEvents")
+	defer done(&err)
+
+	return i.Service.GetAuditEvents(ctx, f)
+}
+
+// ExportAuditEvents instruments the ExportAuditEvents operation.
+func (i *instrumented) ExportAuditEvents(ctx context.Context, f *AuditFilter, fn func(*AuditEvent) error) (err error) {
+	ctx, done := observe(ctx, "ExportAuditEvents")
+	defer done(&err)
+
+	return i.Service.ExportAuditEvents(ctx, f, fn)
+}
+
+// GetErrorGroups instruments the GetErrorGroups operation.
+func (i *instrumented) GetErrorGroups(ctx context.Context, f *ErrorGroupFilter) (_ []*ErrorGroup, err error) {
+	ctx, done := observe(ctx, "GetErrorGroups")
+	defer done(&err)
+
+	return i.Service.GetErrorGroups(ctx, f)
+}
+
+// GetErrorGroup instruments the GetErrorGroup operation.
+func (i *instrumented) GetErrorGroup(ctx context.Context, fingerprint string) (_ *ErrorGroup, _ []*ErrorOccurrence, err error) {
+	ctx, done := observe(ctx, "GetErrorGroup")
+	defer done(&err)
+
+	return i.Service.GetErrorGroup(ctx, fingerprint)
+}
+
+// ResolveErrorGroup instruments the ResolveErrorGroup operation.
+func (i *instrumented) ResolveErrorGroup(ctx context.Context, fingerprint string) (err error) {
+	ctx, done := observe(ctx, "ResolveErrorGroup")
+	defer done(&err)
+
+	return i.Service.ResolveErrorGroup(ctx, fingerprint)
+}
+
+// GetClickStats instruments the GetClickStats operation.
+func (i *instrumented) GetClickStats(ctx context.Context, q *ClickStatsQuery) (_ *ClickStats, err error) {
+	ctx, done := observe(ctx, "GetClickStats")
+	defer done(&err)
+
+	return i.Service.GetClickStats(ctx, q)
+}
+
+// GetTopRecords instruments the GetTopRecords operation.
+func (i *instrumented) GetTopRecords(ctx context.Context, q *TopQuery) (_ []*TopRecord, err error) {
+	ctx, done := observe(ctx, "GetTopRecords")
+	defer done(&err)
+
+	return i.Service.GetTopRecords(ctx, q)
+}
+
+// GetBotRules instruments the GetBotRules operation.
+func (i *instrumented) GetBotRules(ctx context.Context) (_ []*BotRule, err error) {
+	ctx, done := observe(ctx, "GetBotRules")
+	defer done(&err)
+
+	return i.Service.GetBotRules(ctx)
+}
+
+// AddBotRule instruments the AddBotRule operation.
+func (i *instrumented) AddBotRule(ctx context.Context, pattern string) (_ *BotRule, err error) {
+	ctx, done := observe(ctx, "AddBotRule")
+	defer done(&err)
+
+	return i.Service.AddBotRule(ctx, pattern)
+}
+
+// DeleteBotRule instruments the DeleteBotRule operation.
+func (i *instrumented) DeleteBotRule(ctx context.Context, id int64) (err error) {
+	ctx, done := observe(ctx, "DeleteBotRule")
+	defer done(&err)
+
+	return i.Service.DeleteBotRule(ctx, id)
+}
+
+// SetAnalyticsDisabled instruments the SetAnalyticsDisabled operation.
+func (i *instrumented) SetAnalyticsDisabled(ctx context.Context, id string, disabled bool) (err error) {
+	ctx, done := observe(ctx, "SetAnalyticsDisabled")
+	defer done(&err)
+
+	return i.Service.SetAnalyticsDisabled(ctx, id, disabled)
+}
+
+// EraseRecordAnalytics instruments the EraseRecordAnalytics operation.
+func (i *instrumented) EraseRecordAnalytics(ctx context.Context, id string) (_ int64, err error) {
+	ctx, done := observe(ctx, "EraseRecordAnalytics")
+	defer done(&err)
+
+	return i.Service.EraseRecordAnalytics(ctx, id)
+}
+
+// EraseVisitorAnalytics instruments the EraseVisitorAnalytics operation.
+func (i *instrumented) EraseVisitorAnalytics(ctx context.Context, v *VisitorErasure) (_ int64, err error) {
+	ctx, done := observe(ctx, "EraseVisitorAnalytics")
+	defer done(&err)
+
+	return i.Service.EraseVisitorAnalytics(ctx, v)
+}
+
+// ExportClicks instruments the ExportClicks operation.
+func (i *instrumented) ExportClicks(ctx context.Context, q *ExportQuery, fn func(*ClickEvent) error) (err error) {
+	ctx, done := observe(ctx, "ExportClicks")
+	defer done(&err)
+
+	return i.Service.ExportClicks(ctx, q, fn)
+}
+
+// ExportSeries instruments the ExportSeries operation.
+func (i *instrumented) ExportSeries(ctx context.Context, q *ExportQuery, fn func(*SeriesPoint) error) (err error) {
+	ctx, done := observe(ctx, "ExportSeries")
+	defer done(&err)
+
+	return i.Service.ExportSeries(ctx, q, fn)
+}
+
+// CreateShareToken instruments the CreateShareToken operation.
+func (i *instrumented) CreateShareToken(ctx context.Context, t *ShareToken) (_ *ShareToken, err error) {
+	ctx, done := observe(ctx, "CreateShareToken")
+	defer done(&err)
+
+	return i.Service.CreateShareToken(ctx, t)
+}
+
+// GetShareTokens instruments the GetShareTokens operation.
+func (i *instrumented) GetShareTokens(ctx context.Context, id string) (_ []*ShareToken, err error) {
+	ctx, done := observe(ctx, "GetShareTokens")
+	defer done(&err)
+
+	return i.Service.GetShareTokens(ctx, id)
+}
+
+// RevokeShareToken instruments the RevokeShareToken operation.
+func (i *instrumented) RevokeShareToken(ctx context.Context, id int64) (err error) {
+	ctx, done := observe(ctx, "RevokeShareToken")
+	defer done(&err)
+
+	return i.Service.RevokeShareToken(ctx, id)
+}
+
+// ValidateShareToken instruments the ValidateShareToken operation.
+func (i *instrumented) ValidateShareToken(ctx context.Context, token string) (_ *ShortRecord, err error) {
+	ctx, done := observe(ctx, "ValidateShareToken")
+	defer done(&err)
+
+	return i.Service.ValidateShareToken(ctx, token)
+}
@@ -0,0 +1,61 @@
+package data
+
+import (
+	"github.com/chutommy/url-shortener/config"
+	"github.com/chutommy/url-shortener/live"
+	"github.com/chutommy/url-shortener/metrics"
+)
+
+var liveClicksDropped = metrics.Default.Counter("live_clicks_dropped_total",
+	"Clicks not delivered to the subscribers of the live click stream because their buffer was full.")
+
+// InitLive starts the broadcaster of the live click stream. It must be
+// initialized before the click writer which feeds it.
+func (s *service) InitLive(liveCfg *config.Live) error {
+	buffer, subscribers, err := liveCfg.Limits()
+	if err != nil {
+		return err
+	}
+
+	s.live = live.New(buffer, subscribers)
+
+	metrics.Default.GaugeFunc("live_subscribers", "Subscribers of the live click stream.", func() float64 {
+		return float64(s.live.Len())
+	})
+
+	return nil
+}
+
+// SubscribeClicks subscribes to the live stream of the clicks matching the filter.
+func (s *service) SubscribeClicks(f live.Filter) (*live.Subscription, error) {
+	return s.live.Subscribe(f)
+}
+
+// publishClick streams the parsed click to the subscribers of the live click stream.
+func (s *service) publishClick(c *Click, u *usage) {
+	dropped := s.live.Publish(&live.Click{
+		ShortcutID:   u.ShortcutID,
+		ShortURL:     c.ShortURL,
+		Tags:         c.Tags,
+		Time:         u.LoggedAt.UTC(),
+		ReferrerHost: u.ReferrerHost,
+		Browser:      u.Browser,
+		OS:           u.OS,
+		Device:       u.Device,
+		Country:      u.Country,
+		Region:       u.Region,
+		City:         u.City,
+		IsBot:        u.IsBot,
+		BotReason:    u.BotReason,
+		IsRepeat:     u.IsRepeat,
+	})
+
+	if dropped > 0 {
+		liveClicksDropped.Add(float64(dropped))
+	}
+}
+
+// stopLive ends the subscriptions of the live click stream.
+func (s *service) stopLive() {
+	s.live.Close()
+}
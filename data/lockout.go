@@ -0,0 +1,23 @@
+package data
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// LogLockout stores an audit entry of a started lockout into the auth_lockouts table.
+func (s *service) LogLockout(ctx context.Context, kind, subject, ip string, failures int, until time.Time) error {
+	// insert
+	_, err := s.DB.ExecContext(ctx, `
+INSERT INTO
+  auth_lockouts (kind, subject, client_ip, failures, locked_until)
+VALUES
+  ($1, $2, $3, $4, $5);
+  `, kind, subject, ip, failures, until.UTC())
+	if err != nil {
+		return fmt.Errorf("insert failure: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,245 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"reflect"
+	"regexp"
+	"sync"
+	"testing"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// anyArg matches any argument of an expected statement.
+type anyArg struct{}
+
+// expectation is a statement the tested code is expected to run next. A nil
+// args matches any arguments.
+type expectation struct {
+	kind     string
+	pattern  *regexp.Regexp
+	args     []driver.Value
+	columns  []string
+	rows     [][]driver.Value
+	affected int64
+	err      error
+	seen     []driver.Value
+}
+
+// WithArgs sets the expected arguments of the statement.
+func (e *expectation) WithArgs(args ...driver.Value) *expectation {
+	e.args = args
+
+	return e
+}
+
+// WillReturnRows sets the rows returned by the query.
+func (e *expectation) WillReturnRows(columns []string, rows ...[]driver.Value) *expectation {
+	e.columns, e.rows = columns, rows
+
+	return e
+}
+
+// WillReturnResult sets the number of the rows affected by the statement.
+func (e *expectation) WillReturnResult(affected int64) *expectation {
+	e.affected = affected
+
+	return e
+}
+
+// WillReturnError makes the statement fail.
+func (e *expectation) WillReturnError(err error) *expectation {
+	e.err = err
+
+	return e
+}
+
+// Args returns the arguments the statement was run with.
+func (e *expectation) Args() []driver.Value {
+	return e.seen
+}
+
+// mockDB is a scripted database driver, the statements must be run in the
+// order of the expectations.
+type mockDB struct {
+	t        *testing.T
+	mu       sync.Mutex
+	expected []*expectation
+	next     int
+}
+
+// newMockDB returns the service using the scripted database. The test fails
+// if not all expectations are met.
+func newMockDB(t *testing.T) (*service, *mockDB) {
+	t.Helper()
+
+	m := &mockDB{t: t}
+
+	db := sql.OpenDB(m)
+	db.SetMaxOpenConns(1)
+
+	t.Cleanup(func() {
+		_ = db.Close()
+
+		m.mu.Lock()
+		defer m.mu.Unlock()
+
+		if m.next != len(m.expected) {
+			t.Errorf("%d of %d expected statements were run, next: %s", m.next, len(m.expected),
+				m.expected[m.next].pattern)
+		}
+	})
+
+	return &service{DB: sqlx.NewDb(db, "postgres")}, m
+}
+
+func (m *mockDB) expect(kind, pattern string) *expectation {
+	e := &expectation{kind: kind}
+	if pattern != "" {
+		e.pattern = regexp.MustCompile(pattern)
+	}
+
+	m.expected = append(m.expected, e)
+
+	return e
+}
+
+// ExpectQuery expects a query matching the pattern.
+func (m *mockDB) ExpectQuery(pattern string) *expectation { return m.expect("query", pattern) }
+
+// ExpectExec expects a statement matching the pattern.
+func (m *mockDB) ExpectExec(pattern string) *expectation { return m.expect("exec", pattern) }
+
+// ExpectBegin expects a start of a transaction.
+func (m *mockDB) ExpectBegin() *expectation { return m.expect("begin", "") }
+
+// ExpectCommit expects a commit of the transaction.
+func (m *mockDB) ExpectCommit() *expectation { return m.expect("commit", "") }
+
+// ExpectRollback expects a rollback of the transaction.
+func (m *mockDB) ExpectRollback() *expectation { return m.expect("rollback", "") }
+
+// match returns the next expectation if the statement matches it.
+func (m *mockDB) match(kind, query string, args []driver.NamedValue) (*expectation, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.next == len(m.expected) {
+		m.t.Errorf("unexpected %s: %s", kind, query)
+
+		return nil, fmt.Errorf("unexpected %s", kind)
+	}
+
+	e := m.expected[m.next]
+	if e.kind != kind || (e.pattern != nil && !e.pattern.MatchString(query)) {
+		m.t.Errorf("expected %s matching %v, got %s: %s", e.kind, e.pattern, kind, query)
+
+		return nil, fmt.Errorf("unexpected %s", kind)
+	}
+
+	m.next++
+
+	for _, a := range args {
+		e.seen = append(e.seen, a.Value)
+	}
+
+	if e.args != nil {
+		if len(e.args) != len(e.seen) {
+			m.t.Errorf("expected %d arguments of %v, got %d", len(e.args), e.pattern, len(e.seen))
+
+			return nil, fmt.Errorf("unexpected arguments")
+		}
+
+		for i, exp := range e.args {
+			if _, ok := exp.(anyArg); !ok && !reflect.DeepEqual(exp, e.seen[i]) {
+				m.t.Errorf("argument $%d of %v: expected %#v, got %#v", i+1, e.pattern, exp, e.seen[i])
+			}
+		}
+	}
+
+	return e, e.err
+}
+
+// Connect implements driver.Connector.
+func (m *mockDB) Connect(context.Context) (driver.Conn, error) { return &mockConn{db: m}, nil }
+
+// Driver implements driver.Connector.
+func (m *mockDB) Driver() driver.Driver { return nil }
+
+// mockConn is the connection of the scripted database.
+type mockConn struct {
+	db *mockDB
+}
+
+func (c *mockConn) Prepare(string) (driver.Stmt, error) {
+	return nil, fmt.Errorf("prepared statements are not supported")
+}
+
+func (c *mockConn) Close() error { return nil }
+
+func (c *mockConn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+func (c *mockConn) BeginTx(context.Context, driver.TxOptions) (driver.Tx, error) {
+	if _, err := c.db.match("begin", "", nil); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+func (c *mockConn) Commit() error {
+	_, err := c.db.match("commit", "", nil)
+
+	return err
+}
+
+func (c *mockConn) Rollback() error {
+	_, err := c.db.match("rollback", "", nil)
+
+	return err
+}
+
+func (c *mockConn) ExecContext(_ context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	e, err := c.db.match("exec", query, args)
+	if err != nil {
+		return nil, err
+	}
+
+	return driver.RowsAffected(e.affected), nil
+}
+
+func (c *mockConn) QueryContext(_ context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	e, err := c.db.match("query", query, args)
+	if err != nil {
+		return nil, err
+	}
+
+	return &mockRows{columns: e.columns, rows: e.rows}, nil
+}
+
+// mockRows are the scripted rows of a query.
+type mockRows struct {
+	columns []string
+	rows    [][]driver.Value
+}
+
+func (r *mockRows) Columns() []string { return r.columns }
+
+func (r *mockRows) Close() error { return nil }
+
+func (r *mockRows) Next(dest []driver.Value) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+
+	copy(dest, r.rows[0])
+	r.rows = r.rows[1:]
+
+	return nil
+}
@@ -0,0 +1,231 @@
+package data
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+const (
+	// partitionsAhead is the number of the months following the current month
+	// whose partitions are created in advance.
+	partitionsAhead = 3
+	// partitionLayout is the layout of the names of the monthly partitions of the usages.
+	partitionLayout = "usages_y2006m01"
+	// partitionLockKey is the key of the advisory lock, so only one instance creates the partitions at a time.
+	partitionLockKey = 0x75726c70
+	// partitionTimeout bounds the creation of the partitions outside of the rollups.
+	partitionTimeout = 30 * time.Second
+)
+
+// partitionName returns the name of the partition of the month which starts at t.
+func partitionName(month time.Time) string {
+	return month.Format(partitionLayout)
+}
+
+// truncateMonth returns the start of the UTC month of t.
+func truncateMonth(t time.Time) time.Time {
+	y, m, _ := t.UTC().Date()
+
+	return time.Date(y, m, 1, 0, 0, 0, 0, time.UTC)
+}
+
+// partitionsToCreate returns the months from the month of now up to
+// partitionsAhead months ahead which have no partition, oldest first.
+func partitionsToCreate(existing map[time.Time]bool, now time.Time) []time.Time {
+	var months []time.Time
+
+	month := truncateMonth(now)
+	for i := 0; i <= partitionsAhead; i++ {
+		if !existing[month] {
+			months = append(months, month)
+		}
+
+		month = month.AddDate(0, 1, 0)
+	}
+
+	return months
+}
+
+// partitionsToDrop returns the months of the existing partitions which end
+// before or at the cutoff, oldest first.
+func partitionsToDrop(existing map[time.Time]bool, cutoff time.Time) []time.Time {
+	var months []time.Time
+
+	for month := range existing {
+		if !month.AddDate(0, 1, 0).After(cutoff) {
+			months = append(months, month)
+		}
+	}
+
+	sort.Slice(months, func(i, j int) bool {
+		return months[i].Before(months[j])
+	})
+
+	return months
+}
+
+// usagePartitions returns the starts of the months of the existing partitions.
+// The default partition and the tables attached by hand which do not follow
+// the naming are ignored.
+func usagePartitions(ctx context.Context, tx *sqlx.Tx) (map[time.Time]bool, error) {
+	rows, err := tx.QueryxContext(ctx, `
+SELECT
+  c.relname
+FROM
+  pg_inherits AS i
+  JOIN pg_class AS c ON c.oid = i.inhrelid
+WHERE
+  i.inhparent = 'usages'::REGCLASS;
+  `)
+	if err != nil {
+		return nil, fmt.Errorf("unexpected query error: %w", err)
+	}
+
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	months := make(map[time.Time]bool)
+
+	for rows.Next() {
+		var name string
+		if err = rows.Scan(&name); err != nil {
+			return nil, fmt.Errorf("retrieved sql row scan error: %w", err)
+		}
+
+		if month, err := time.Parse(partitionLayout, name); err == nil {
+			months[month] = true
+		}
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("unexpected rows error: %w", err)
+	}
+
+	return months, nil
+}
+
+// ensurePartitions creates the missing partitions of the current and the
+// following months and returns all existing partitions. The clicks of a new
+// partition's month are moved to it from the default partition.
+func ensurePartitions(ctx context.Context, tx *sqlx.Tx, now time.Time) (map[time.Time]bool, error) {
+	if _, err := tx.ExecContext(ctx, `SELECT PG_ADVISORY_XACT_LOCK($1);`, partitionLockKey); err != nil {
+		return nil, fmt.Errorf("failed to lock partitions: %w", err)
+	}
+
+	existing, err := usagePartitions(ctx, tx)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, month := range partitionsToCreate(existing, now) {
+		if err = createPartition(ctx, tx, month); err != nil {
+			return nil, err
+		}
+
+		existing[month] = true
+	}
+
+	return existing, nil
+}
+
+// createPartition creates the partition of the month. The partition is filled
+// with the clicks of its month from the default partition before it is attached.
+func createPartition(ctx context.Context, tx *sqlx.Tx, month time.Time) error {
+	name, next := partitionName(month), month.AddDate(0, 1, 0)
+
+	// the name and the bounds are formatted times, never the user input
+	if _, err := tx.ExecContext(ctx, fmt.Sprintf(`
+CREATE TABLE
+  %s (LIKE usages INCLUDING DEFAULTS);
+  `, name)); err != nil {
+		return fmt.Errorf("failed to create partition: %w", err)
+	}
+
+	if _, err := tx.ExecContext(ctx, fmt.Sprintf(`
+WITH moved AS (
+  DELETE FROM
+    usages_default
+  WHERE
+    logged_at >= $1
+    AND logged_at < $2
+  RETURNING
+    *
+)
+INSERT INTO
+  %s
+SELECT
+  *
+FROM
+  moved;
+  `, name), month, next); err != nil {
+		return fmt.Errorf("failed to move clicks of the default partition: %w", err)
+	}
+
+	if _, err := tx.ExecContext(ctx, fmt.Sprintf(`
+ALTER TABLE
+  usages
+ATTACH PARTITION
+  %s
+FOR VALUES FROM ('%s') TO ('%s');
+  `, name, month.Format(usageTimeLayout), next.Format(usageTimeLayout))); err != nil {
+		return fmt.Errorf("failed to attach partition: %w", err)
+	}
+
+	return nil
+}
+
+// createPartitions creates the missing partitions of the current and the
+// following months in its own transaction and returns the end of the last one.
+func (s *service) createPartitions(ctx context.Context, now time.Time) (until time.Time, err error) {
+	tx, err := s.DB.BeginTxx(ctx, nil)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	if _, err = ensurePartitions(ctx, tx, now); err != nil {
+		return time.Time{}, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return time.Time{}, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return truncateMonth(now).AddDate(0, partitionsAhead+1, 0), nil
+}
+
+// purgeUsages deletes the raw clicks logged before the cutoff. The partitions
+// of the months which end before the cutoff are dropped as a whole, only the
+// clicks of the partially expired month and of the default partition are
+// deleted row by row.
+func purgeUsages(ctx context.Context, tx *sqlx.Tx, existing map[time.Time]bool, cutoff time.Time) error {
+	for _, month := range partitionsToDrop(existing, cutoff) {
+		// the name is a formatted time, never the user input
+		if _, err := tx.ExecContext(ctx, fmt.Sprintf(`DROP TABLE IF EXISTS %s;`, partitionName(month))); err != nil {
+			return fmt.Errorf("failed to drop partition: %w", err)
+		}
+
+		delete(existing, month)
+	}
+
+	if _, err := tx.ExecContext(ctx, `
+DELETE FROM
+  usages
+WHERE
+  logged_at < $1;
+  `, cutoff); err != nil {
+		return fmt.Errorf("delete failure: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,126 @@
+package data
+
+import (
+	"context"
+	"database/sql/driver"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPartitionName(t *testing.T) {
+	month := truncateMonth(time.Date(2024, time.December, 31, 23, 30, 0, 0, time.FixedZone("", -2*3600)))
+
+	assert.Equal(t, time.Date(2025, time.January, 1, 0, 0, 0, 0, time.UTC), month)
+	assert.Equal(t, "usages_y2025m01", partitionName(month))
+
+	parsed, err := time.Parse(partitionLayout, partitionName(month))
+	assert.NoError(t, err)
+	assert.Equal(t, month, parsed)
+}
+
+func TestPartitionsToCreate(t *testing.T) {
+	month := func(y int, m time.Month) time.Time {
+		return time.Date(y, m, 1, 0, 0, 0, 0, time.UTC)
+	}
+
+	now := time.Date(2024, time.November, 30, 23, 59, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		existing []time.Time
+		now      time.Time
+		exp      []time.Time
+	}{
+		{
+			name: "no partitions",
+			now:  now,
+			exp:  []time.Time{month(2024, 11), month(2024, 12), month(2025, 1), month(2025, 2)},
+		},
+		{
+			name:     "missing months",
+			existing: []time.Time{month(2024, 10), month(2024, 11), month(2025, 1)},
+			now:      now,
+			exp:      []time.Time{month(2024, 12), month(2025, 2)},
+		},
+		{
+			name:     "all months",
+			existing: []time.Time{month(2024, 11), month(2024, 12), month(2025, 1), month(2025, 2)},
+			now:      now,
+		},
+		{
+			name:     "local time of the next month",
+			existing: []time.Time{month(2024, 11), month(2024, 12), month(2025, 1), month(2025, 2)},
+			now:      time.Date(2024, time.December, 1, 0, 30, 0, 0, time.FixedZone("", 3600)),
+		},
+		{
+			name:     "next month",
+			existing: []time.Time{month(2024, 11), month(2024, 12), month(2025, 1), month(2025, 2)},
+			now:      now.Add(time.Minute),
+			exp:      []time.Time{month(2025, 3)},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			existing := make(map[time.Time]bool)
+			for _, m := range tc.existing {
+				existing[m] = true
+			}
+
+			assert.Equal(t, tc.exp, partitionsToCreate(existing, tc.now))
+		})
+	}
+}
+
+func TestPartitionsToDrop(t *testing.T) {
+	month := func(m time.Month) time.Time {
+		return time.Date(2024, m, 1, 0, 0, 0, 0, time.UTC)
+	}
+
+	existing := map[time.Time]bool{month(3): true, month(1): true, month(2): true, month(4): true}
+
+	tests := []struct {
+		name   string
+		cutoff time.Time
+		exp    []time.Time
+	}{
+		{name: "before all", cutoff: month(1).Add(time.Hour)},
+		{name: "end of a month", cutoff: month(3), exp: []time.Time{month(1), month(2)}},
+		{name: "within a month", cutoff: month(3).Add(-time.Hour), exp: []time.Time{month(1)}},
+		{name: "after all", cutoff: month(6), exp: []time.Time{month(1), month(2), month(3), month(4)}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.exp, partitionsToDrop(existing, tc.cutoff))
+		})
+	}
+}
+
+func TestEnsurePartitions(t *testing.T) {
+	s, db := newMockDB(t)
+	now := time.Date(2024, time.March, 15, 0, 0, 0, 0, time.UTC)
+
+	db.ExpectBegin()
+	db.ExpectExec(`PG_ADVISORY_XACT_LOCK`).WithArgs(int64(partitionLockKey))
+	db.ExpectQuery(`pg_inherits`).WillReturnRows([]string{"relname"},
+		[]driver.Value{"usages_default"},
+		[]driver.Value{"usages_y2024m02"},
+		[]driver.Value{"usages_y2024m03"},
+		[]driver.Value{"usages_y2024m05"},
+		[]driver.Value{"usages_y2024m06"},
+	)
+
+	// the clicks of the new month are moved from the default partition
+	db.ExpectExec(`CREATE TABLE\s+usages_y2024m04 \(LIKE usages`)
+	db.ExpectExec(`DELETE FROM\s+usages_default[\s\S]+INSERT INTO\s+usages_y2024m04`).WithArgs(
+		time.Date(2024, time.April, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, time.May, 1, 0, 0, 0, 0, time.UTC))
+	db.ExpectExec(`ATTACH PARTITION\s+usages_y2024m04\s+FOR VALUES FROM \('2024-04-01 00:00:00'\) TO \('2024-05-01 00:00:00'\)`)
+	db.ExpectCommit()
+
+	until, err := s.createPartitions(context.Background(), now)
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(2024, time.July, 1, 0, 0, 0, 0, time.UTC), until)
+}
@@ -0,0 +1,321 @@
+package data
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+const (
+	ipSaltLen = 32
+
+	// widths of the kept network prefixes of the truncated client IPs
+	truncatedIPv4Bits = 24
+	truncatedIPv6Bits = 48
+)
+
+var (
+	// ErrInvalidErasure is returned if neither the client IP nor its hash of the visitor is given.
+	ErrInvalidErasure = errors.New("valid ip or ip_hash of the visitor must be given")
+	// errSaltExpired is returned if the salt of the day was already deleted.
+	errSaltExpired = errors.New("salt of the day has expired")
+)
+
+// VisitorErasure selects the clicks of a visitor by the client IP or its
+// hash, optionally narrowed by the user agent.
+type VisitorErasure struct {
+	IP        string `json:"ip"`
+	IPHash    string `json:"ip_hash"`
+	UserAgent string `json:"user_agent"`
+}
+
+// truncateIP zeroes the host part of the IP, an invalid IP results in an empty string.
+func truncateIP(ip string) string {
+	parsed := net.ParseIP(ip)
+
+	switch {
+	case parsed == nil:
+		return ""
+	case parsed.To4() != nil:
+		return parsed.Mask(net.CIDRMask(truncatedIPv4Bits, 8*net.IPv4len)).String()
+	default:
+		return parsed.Mask(net.CIDRMask(truncatedIPv6Bits, 8*net.IPv6len)).String()
+	}
+}
+
+// anonymize removes the details of the visitor from the usage, the usage is
+// still counted by its device, browser, operating system and country.
+func (u *usage) anonymize() {
+	u.Referrer, u.ReferrerHost, u.Query = "", "", ""
+	u.UserAgent, u.Language, u.IPHash = "", "", ""
+	u.Region, u.City = "", ""
+}
+
+// ipSalt returns the salt of the UTC day of t, nil if the salts are not
+// rotated. The salt is created by the first instance which needs it, the salts
+// of the days before the previous day are deleted.
+func (s *service) ipSalt(ctx context.Context, t time.Time) ([]byte, error) {
+	w := s.clicks
+	if !w.rotateSalt {
+		return nil, nil
+	}
+
+	day := t.UTC().Format(dayLayout)
+	if salt, ok := w.salts[day]; ok {
+		return salt, nil
+	}
+
+	// the deleted salts are not created again
+	oldest := time.Now().UTC().AddDate(0, 0, -1).Format(dayLayout)
+	if day < oldest {
+		return nil, errSaltExpired
+	}
+
+	fresh := make([]byte, ipSaltLen)
+	if _, err := rand.Read(fresh); err != nil {
+		return nil, fmt.Errorf("failed to generate ip salt: %w", err)
+	}
+
+	var salt []byte
+
+	err := s.DB.QueryRowxContext(ctx, `
+WITH created AS (
+  INSERT INTO
+    ip_salts (day, salt)
+  VALUES
+    ($1, $2)
+  ON CONFLICT (day)
+    DO NOTHING
+  RETURNING
+    salt
+)
+SELECT
+  salt
+FROM
+  created
+UNION ALL
+SELECT
+  salt
+FROM
+  ip_salts
+WHERE
+  day = $1
+LIMIT 1;
+  `, day, fresh).Scan(&salt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load ip salt: %w", err)
+	}
+
+	// forget the salts of the past days
+	if _, err = s.DB.ExecContext(ctx, `
+DELETE FROM
+  ip_salts
+WHERE
+  day < $1;
+  `, oldest); err != nil {
+		return nil, fmt.Errorf("delete failure: %w", err)
+	}
+
+	for d := range w.salts {
+		if d < oldest {
+			delete(w.salts, d)
+		}
+	}
+
+	w.salts[day] = salt
+
+	return salt, nil
+}
+
+// visitorIPHashes returns the hashes the client IP could be stored with: the
+// hashes of the full and the truncated IP, unsalted and salted by each kept salt.
+func (s *service) visitorIPHashes(ctx context.Context, ip string) ([]string, error) {
+	if s.clicks == nil || ip == "" {
+		return nil, nil
+	}
+
+	salts := [][]byte{nil}
+
+	rows, err := s.DB.QueryxContext(ctx, `
+SELECT
+  salt
+FROM
+  ip_salts;
+  `)
+	if err != nil {
+		return nil, fmt.Errorf("unexpected query error: %w", err)
+	}
+
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	for rows.Next() {
+		var salt []byte
+		if err = rows.Scan(&salt); err != nil {
+			return nil, fmt.Errorf("retrieved sql row scan error: %w", err)
+		}
+
+		salts = append(salts, salt)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("unexpected rows error: %w", err)
+	}
+
+	var hashes []string
+
+	for _, v := range []string{ip, truncateIP(ip)} {
+		for _, salt := range salts {
+			hashes = append(hashes, s.clicks.hashIP(v, salt))
+		}
+	}
+
+	return hashes, nil
+}
+
+// SetAnalyticsDisabled opts the record out of the click analytics or back in.
+// The clicks of an opted out record are counted, but not stored.
+func (s *service) SetAnalyticsDisabled(ctx context.Context, id string, disabled bool) error {
+	result, err := s.DB.ExecContext(ctx, `
+UPDATE
+  shortcuts
+SET
+  analytics_disabled = $2
+WHERE
+  shortcut_id = $1
+  AND deleted_at IS NULL;
+  `, strings.ToLower(id), disabled)
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == ErrPQInvalidTextRepresentation {
+			return ErrInvalidID
+		}
+
+		return fmt.Errorf("could not execute sql update; %w", err)
+	}
+
+	if n, _ := result.RowsAffected(); n != 1 {
+		return ErrIDNotFound
+	}
+
+	return nil
+}
+
+// EraseRecordAnalytics deletes the stored clicks, their aggregates and the
+// visitor sketches of the record, including a softly deleted one, and returns
+// the number of the deleted clicks. The usage of the record is kept.
+func (s *service) EraseRecordAnalytics(ctx context.Context, id string) (n int64, err error) {
+	id = strings.ToLower(id)
+
+	tx, err := s.DB.BeginTxx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	// check record
+	var exists bool
+
+	err = tx.QueryRowxContext(ctx, `
+SELECT
+  TRUE
+FROM
+  shortcuts
+WHERE
+  shortcut_id = $1;
+  `, id).Scan(&exists)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, ErrIDNotFound
+	} else if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == ErrPQInvalidTextRepresentation {
+			return 0, ErrInvalidID
+		}
+
+		return 0, fmt.Errorf("unexpected query error: %w", err)
+	}
+
+	// erase clicks
+	result, err := tx.ExecContext(ctx, `
+DELETE FROM
+  usages
+WHERE
+  shortcut_id = $1;
+  `, id)
+	if err != nil {
+		return 0, fmt.Errorf("delete failure: %w", err)
+	}
+
+	n, _ = result.RowsAffected()
+
+	for _, table := range []string{"usage_rollups_hourly", "usage_rollups_daily", "visitor_sketches"} {
+		// the table is one of the known tables, never the user input
+		if _, err = tx.ExecContext(ctx, fmt.Sprintf(`
+DELETE FROM
+  %s
+WHERE
+  shortcut_id = $1;
+  `, table), id); err != nil {
+			return 0, fmt.Errorf("delete failure: %w", err)
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return n, nil
+}
+
+// EraseVisitorAnalytics deletes the stored clicks of the visitor and returns
+// their number. The client IP is hashed in every way it could be stored, the
+// clicks hashed by the deleted daily salts can not be linked to the visitor
+// anymore. The aggregates and the visitor sketches do not identify the visitors
+// and are kept.
+func (s *service) EraseVisitorAnalytics(ctx context.Context, v *VisitorErasure) (int64, error) {
+	ip, hash := strings.TrimSpace(v.IP), strings.ToLower(strings.TrimSpace(v.IPHash))
+	if (ip == "" && hash == "") || (ip != "" && net.ParseIP(ip) == nil) {
+		return 0, ErrInvalidErasure
+	}
+
+	hashes, err := s.visitorIPHashes(ctx, ip)
+	if err != nil {
+		return 0, err
+	}
+
+	if hash != "" {
+		hashes = append(hashes, hash)
+	}
+
+	if len(hashes) == 0 {
+		return 0, nil
+	}
+
+	result, err := s.DB.ExecContext(ctx, `
+DELETE FROM
+  usages
+WHERE
+  ip_hash = ANY ($1)
+  AND ($2 = '' OR user_agent = $2);
+  `, pq.Array(hashes), v.UserAgent)
+	if err != nil {
+		return 0, fmt.Errorf("delete failure: %w", err)
+	}
+
+	n, _ := result.RowsAffected()
+
+	return n, nil
+}
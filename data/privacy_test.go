@@ -0,0 +1,61 @@
+package data
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTruncateIP(t *testing.T) {
+	tests := []struct {
+		ip  string
+		exp string
+	}{
+		{ip: "203.0.113.195", exp: "203.0.113.0"},
+		{ip: "::ffff:203.0.113.195", exp: "203.0.113.0"},
+		{ip: "2001:db8:85a3:8d3:1319:8a2e:370:7348", exp: "2001:db8:85a3::"},
+		{ip: "not an ip", exp: ""},
+		{ip: "", exp: ""},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.ip, func(t *testing.T) {
+			assert.Equal(t, tc.exp, truncateIP(tc.ip))
+		})
+	}
+}
+
+func TestClickWriter_HashIP(t *testing.T) {
+	w := &clickWriter{ipKey: []byte("secret")}
+
+	plain := w.hashIP("203.0.113.195", nil)
+	assert.Len(t, plain, ipHashLen)
+	assert.Equal(t, plain, w.hashIP("203.0.113.195", nil))
+	assert.NotEqual(t, plain, w.hashIP("203.0.113.195", []byte("salt of the day")))
+	assert.Equal(t, "", w.hashIP("", []byte("salt of the day")))
+}
+
+func TestClickWriter_ParseDoNotTrack(t *testing.T) {
+	c := &Click{
+		ShortcutID: "a",
+		ClientIP:   "203.0.113.195",
+		Referrer:   "https://news.example.com/item",
+		UserAgent:  "Mozilla/5.0 (X11; Linux x86_64; rv:120.0) Gecko/20100101 Firefox/120.0",
+		Query:      "utm_source=news",
+		Method:     "GET",
+		DoNotTrack: true,
+	}
+
+	tracked := (&clickWriter{ipKey: []byte("secret")}).parse(c, nil, nil, nil)
+	assert.NotEmpty(t, tracked.IPHash)
+	assert.Equal(t, "news.example.com", tracked.ReferrerHost)
+
+	u := (&clickWriter{ipKey: []byte("secret"), honorDNT: true}).parse(c, nil, nil, nil)
+	assert.Empty(t, u.IPHash)
+	assert.Empty(t, u.UserAgent)
+	assert.Empty(t, u.Referrer)
+	assert.Empty(t, u.ReferrerHost)
+	assert.Empty(t, u.Query)
+	assert.Equal(t, "Firefox", u.Browser)
+	assert.False(t, u.IsBot)
+}
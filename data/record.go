@@ -20,22 +20,26 @@ const ErrPQUniqueKeyViolation = "23505"
 
 // Record is the unit of each shorten URL.  Record stores the time of its creation,
 // update and deletion. All Short attributes must be unique. Full can have duplicates.
+// The clicks of a record with AnalyticsDisabled are counted, but not stored.
 type Record struct {
-	ID        string       `json:"shortcut_id"`
-	Full      string       `json:"full_url"`
-	Short     string       `json:"short_url"`
-	Usage     int32        `json:"usage"`
-	CreatedAt time.Time    `json:"created_at"`
-	UpdatedAt time.Time    `json:"updated_at"`
-	DeletedAt sql.NullTime `json:"-"`
+	ID                string       `json:"shortcut_id"`
+	Full              string       `json:"full_url"`
+	Short             string       `json:"short_url"`
+	Usage             int32        `json:"usage"`
+	Tags              []string     `json:"tags,omitempty"`
+	AnalyticsDisabled bool         `json:"analytics_disabled"`
+	CreatedAt         time.Time    `json:"created_at"`
+	UpdatedAt         time.Time    `json:"updated_at"`
+	DeletedAt         sql.NullTime `json:"-"`
 }
 
 // ShortRecord represents shorter version of the Record.
 type ShortRecord struct {
-	ID    string `json:"shortcut_id"`
-	Full  string `json:"full_url"`
-	Short string `json:"short_url"`
-	Usage int32  `json:"usage"`
+	ID    string   `json:"shortcut_id"`
+	Full  string   `json:"full_url"`
+	Short string   `json:"short_url"`
+	Usage int32    `json:"usage"`
+	Tags  []string `json:"tags,omitempty"`
 }
 
 var (
@@ -63,20 +67,26 @@ func (s *service) AddRecord(ctx context.Context, r *Record) (*ShortRecord, error
 		return nil, ErrInvalidRecord
 	}
 
+	tags, err := normalizeTags(r.Tags)
+	if err != nil {
+		return nil, err
+	}
+
 	// create a record
 	newRec := &ShortRecord{
 		ID:    uuid.New().String(),
 		Full:  strings.ToLower(r.Full),
 		Short: strings.ToLower(r.Short),
+		Tags:  tags,
 	}
 
 	// insert record
-	_, err := s.DB.ExecContext(ctx, `
+	_, err = s.DB.ExecContext(ctx, `
 INSERT INTO
-  shortcuts (shortcut_id, full_url, short_url)
+  shortcuts (shortcut_id, full_url, short_url, tags, analytics_disabled)
 VALUES
-  ($1, $2, $3);
-  `, newRec.ID, newRec.Full, newRec.Short)
+  ($1, $2, $3, $4, $5);
+  `, newRec.ID, newRec.Full, newRec.Short, pq.Array(newRec.Tags), r.AnalyticsDisabled)
 	if err != nil {
 		// postgres errors
 		var pqErr *pq.Error
@@ -93,17 +103,29 @@ VALUES
 	return newRec, nil
 }
 
-// UpdateRecord updates a record with the given id.
+// UpdateRecord updates a record with the given id. Nil tags are kept.
 // If the record is not found ErrIDNotFound is returned.
 // If the record r has a short which is already in use,
 // ErrUnavailableShort is returned. Any other errors
 // are server internal.
 func (s *service) UpdateRecord(ctx context.Context, id string, r *ShortRecord) (*ShortRecord, error) {
+	tags, err := normalizeTags(r.Tags)
+	if err != nil {
+		return nil, err
+	}
+
 	// create record
 	updRecord := &ShortRecord{
 		ID:    strings.ToLower(id),
 		Full:  strings.ToLower(r.Full),
 		Short: strings.ToLower(r.Short),
+		Tags:  tags,
+	}
+
+	// nil tags are not updated
+	var tagsParam interface{}
+	if r.Tags != nil {
+		tagsParam = pq.Array(tags)
 	}
 
 	// update record
@@ -112,11 +134,12 @@ UPDATE
   shortcuts
 SET
   full_url = COALESCE($2, full_url),
-  short_url = COALESCE($3, short_url)
+  short_url = COALESCE($3, short_url),
+  tags = COALESCE($4, tags)
 WHERE
   shortcut_id = $1
   AND deleted_at IS NULL;
-  `, updRecord.ID, newNullString(updRecord.Full), newNullString(updRecord.Short))
+  `, updRecord.ID, newNullString(updRecord.Full), newNullString(updRecord.Short), tagsParam)
 	if err != nil {
 		// postgres errors
 		var pqErr *pq.Error
@@ -196,6 +219,8 @@ SELECT
   full_url,
   short_url,
   usage,
+  tags,
+  analytics_disabled,
   created_at,
   updated_at
 FROM
@@ -208,7 +233,7 @@ LIMIT 1;
 
 	// scan row into new record
 	var r Record
-	err := row.Scan(&r.ID, &r.Full, &r.Short, &r.Usage, &r.CreatedAt, &r.UpdatedAt)
+	err := row.Scan(&r.ID, &r.Full, &r.Short, &r.Usage, pq.Array(&r.Tags), &r.AnalyticsDisabled, &r.CreatedAt, &r.UpdatedAt)
 
 	if errors.Is(err, sql.ErrNoRows) {
 		// nothing returned
@@ -240,6 +265,8 @@ SELECT
   full_url,
   short_url,
   usage,
+  tags,
+  analytics_disabled,
   created_at,
   updated_at
 FROM
@@ -252,7 +279,7 @@ LIMIT 1;
 
 	// scan row into a new record
 	var r Record
-	err := row.Scan(&r.ID, &r.Full, &r.Short, &r.Usage, &r.CreatedAt, &r.UpdatedAt)
+	err := row.Scan(&r.ID, &r.Full, &r.Short, &r.Usage, pq.Array(&r.Tags), &r.AnalyticsDisabled, &r.CreatedAt, &r.UpdatedAt)
 
 	if errors.Is(err, sql.ErrNoRows) {
 		// nothing returned
@@ -264,16 +291,20 @@ LIMIT 1;
 	return &r, nil
 }
 
-// GetRecordByShortPeek finds and returns the full version which corresponds
-// to the given short url.
-func (s *service) GetRecordByShortPeek(ctx context.Context, short string) (string, error) { // short to lowercase
+// GetRecordByShortPeek finds the active record which corresponds to the given
+// short url. The click itself is recorded by RecordClick.
+func (s *service) GetRecordByShortPeek(ctx context.Context, short string) (*Record, error) { // short to lowercase
 	short = strings.ToLower(short)
 
 	// get full url
 	row := s.DB.QueryRowContext(ctx, `
 SELECT
   shortcut_id,
-  full_url
+  full_url,
+  short_url,
+  usage,
+  tags,
+  analytics_disabled
 FROM
   shortcuts
 WHERE
@@ -282,26 +313,17 @@ WHERE
 LIMIT 1;
   `, short)
 
-	// scan full url
-	var id, full string
-	err := row.Scan(&id, &full)
+	// scan record
+	var r Record
+	err := row.Scan(&r.ID, &r.Full, &r.Short, &r.Usage, pq.Array(&r.Tags), &r.AnalyticsDisabled)
 
 	if errors.Is(err, sql.ErrNoRows) {
-		return "", ErrShortNotFound
+		return nil, ErrShortNotFound
 	} else if err != nil {
-		return "", fmt.Errorf("unexpected sql query error: %w", err)
-	}
-	// increment record's usage
-	if err = s.incrementUsage(ctx, id); err != nil {
-		return "", err
-	}
-
-	// log record's usage
-	if err = s.logUsage(ctx, id); err != nil {
-		return "", err
+		return nil, fmt.Errorf("unexpected sql query error: %w", err)
 	}
 
-	return full, nil
+	return &r, nil
 }
 
 // GetRecordsLen returns the number of active urls.
@@ -336,7 +358,8 @@ SELECT
   shortcut_id,
   full_url,
   short_url,
-  usage
+  usage,
+  tags
 FROM
   shortcuts
 WHERE
@@ -356,7 +379,7 @@ ORDER BY
 		// create new record
 		var r ShortRecord
 
-		if err := rows.Scan(&r.ID, &r.Full, &r.Short, &r.Usage); err != nil {
+		if err := rows.Scan(&r.ID, &r.Full, &r.Short, &r.Usage, pq.Array(&r.Tags)); err != nil {
 			return nil, fmt.Errorf("unexpected server error while scanning racords: %w", err)
 		}
 
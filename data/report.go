@@ -0,0 +1,330 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/chutommy/url-shortener/config"
+	"github.com/chutommy/url-shortener/report"
+)
+
+// Statuses of the error groups.
+const (
+	ErrorStatusOpen     = "open"
+	ErrorStatusResolved = "resolved"
+)
+
+const maxGroupOccurrences = 20
+
+// ErrErrorGroupNotFound is returned if an error group with the given fingerprint does not exist.
+var ErrErrorGroupNotFound = errors.New("error group with the given fingerprint was not found")
+
+// ErrorGroup aggregates the occurrences of errors with the same fingerprint.
+type ErrorGroup struct {
+	Fingerprint string     `json:"fingerprint"`
+	Type        string     `json:"type"`
+	Message     string     `json:"message"`
+	Method      string     `json:"method"`
+	Route       string     `json:"route"`
+	Occurrences int64      `json:"occurrences"`
+	FirstSeen   time.Time  `json:"first_seen"`
+	LastSeen    time.Time  `json:"last_seen"`
+	ResolvedAt  *time.Time `json:"resolved_at,omitempty"`
+}
+
+// ErrorOccurrence is a single occurrence of an error group.
+type ErrorOccurrence struct {
+	ID         int64     `json:"occurrence_id"`
+	Message    string    `json:"message"`
+	RequestID  string    `json:"request_id"`
+	Actor      string    `json:"actor"`
+	ClientIP   string    `json:"client_ip"`
+	Stack      string    `json:"stack"`
+	OccurredAt time.Time `json:"occurred_at"`
+}
+
+// ErrorGroupFilter selects the error groups. An empty Status matches all groups.
+type ErrorGroupFilter struct {
+	Status string
+	Limit  int
+	Offset int
+}
+
+// dbReporter stores the error events into the error_groups and error_occurrences tables.
+type dbReporter struct {
+	s *service
+}
+
+// InitReporters sets up the configured error reporters.
+func (s *service) InitReporters(erCfg *config.ErrorReporting) error {
+	var reporters []report.Reporter
+
+	for _, name := range erCfg.Names() {
+		switch name {
+		case config.ReporterDB:
+			reporters = append(reporters, &dbReporter{s: s})
+
+		case config.ReporterStderr:
+			reporters = append(reporters, report.NewWriterReporter(os.Stderr))
+
+		case config.ReporterFile:
+			fr, err := report.NewFileReporter(erCfg.File)
+			if err != nil {
+				return err
+			}
+
+			reporters = append(reporters, fr)
+
+		default:
+			return config.ErrInvalidReporting
+		}
+	}
+
+	s.reporters = reporters
+
+	return nil
+}
+
+// closeReporters releases the resources of the reporters.
+func (s *service) closeReporters() error {
+	var err error
+
+	for _, r := range s.reporters {
+		if c, ok := r.(io.Closer); ok {
+			if cErr := c.Close(); cErr != nil && err == nil {
+				err = cErr
+			}
+		}
+	}
+
+	return err
+}
+
+// Report adds an occurrence to the error group of the event. A resolved group
+// which occurs again is reopened.
+func (r *dbReporter) Report(ctx context.Context, e *report.Event) error {
+	tx, err := r.s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	// upsert group
+	_, err = tx.ExecContext(ctx, `
+INSERT INTO
+  error_groups (fingerprint, error_type, message, method, route, first_seen, last_seen)
+VALUES
+  ($1, $2, $3, $4, $5, $6, $6)
+ON CONFLICT (fingerprint) DO UPDATE
+SET
+  occurrences = error_groups.occurrences + 1,
+  last_seen = EXCLUDED.last_seen,
+  resolved_at = NULL;
+  `, e.Fingerprint, e.Type, e.Message, e.Method, e.Route, e.OccurredAt)
+	if err != nil {
+		return fmt.Errorf("upsert failure: %w", err)
+	}
+
+	// insert occurrence
+	_, err = tx.ExecContext(ctx, `
+INSERT INTO
+  error_occurrences (fingerprint, message, request_id, actor, client_ip, stack, occurred_at)
+VALUES
+  ($1, $2, $3, $4, $5, $6, $7);
+  `, e.Fingerprint, e.Message, e.RequestID, e.Actor, e.ClientIP, e.Stack, e.OccurredAt)
+	if err != nil {
+		return fmt.Errorf("insert failure: %w", err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// GetErrorGroups returns the error groups matching the filter, the most recently seen first.
+func (s *service) GetErrorGroups(ctx context.Context, f *ErrorGroupFilter) ([]*ErrorGroup, error) {
+	// query db
+	rows, err := s.DB.QueryContext(ctx, `
+SELECT
+  fingerprint,
+  error_type,
+  message,
+  method,
+  route,
+  occurrences,
+  first_seen,
+  last_seen,
+  resolved_at
+FROM
+  error_groups
+WHERE
+  $1 = ''
+  OR ($1 = 'open' AND resolved_at IS NULL)
+  OR ($1 = 'resolved' AND resolved_at IS NOT NULL)
+ORDER BY
+  last_seen DESC
+LIMIT
+  $2
+OFFSET
+  $3;
+  `, f.Status, f.Limit, f.Offset)
+	if err != nil {
+		return nil, fmt.Errorf("could not query error groups: %w", err)
+	}
+
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	// scan rows
+	groups := []*ErrorGroup{}
+
+	for rows.Next() {
+		g, err := scanErrorGroup(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		groups = append(groups, g)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate error groups: %w", err)
+	}
+
+	return groups, nil
+}
+
+// GetErrorGroup returns the error group with the given fingerprint together with its latest occurrences.
+func (s *service) GetErrorGroup(ctx context.Context, fingerprint string) (*ErrorGroup, []*ErrorOccurrence, error) {
+	// query group
+	row := s.DB.QueryRowContext(ctx, `
+SELECT
+  fingerprint,
+  error_type,
+  message,
+  method,
+  route,
+  occurrences,
+  first_seen,
+  last_seen,
+  resolved_at
+FROM
+  error_groups
+WHERE
+  fingerprint = $1;
+  `, fingerprint)
+
+	g, err := scanErrorGroup(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil, ErrErrorGroupNotFound
+	} else if err != nil {
+		return nil, nil, err
+	}
+
+	// query occurrences
+	rows, err := s.DB.QueryContext(ctx, `
+SELECT
+  occurrence_id,
+  message,
+  request_id,
+  actor,
+  client_ip,
+  stack,
+  occurred_at
+FROM
+  error_occurrences
+WHERE
+  fingerprint = $1
+ORDER BY
+  occurred_at DESC
+LIMIT
+  $2;
+  `, fingerprint, maxGroupOccurrences)
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not query error occurrences: %w", err)
+	}
+
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	// scan rows
+	occurrences := []*ErrorOccurrence{}
+
+	for rows.Next() {
+		var o ErrorOccurrence
+		if err = rows.Scan(&o.ID, &o.Message, &o.RequestID, &o.Actor, &o.ClientIP, &o.Stack, &o.OccurredAt); err != nil {
+			return nil, nil, fmt.Errorf("retrieved sql row scan error: %w", err)
+		}
+
+		occurrences = append(occurrences, &o)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, nil, fmt.Errorf("failed to iterate error occurrences: %w", err)
+	}
+
+	return g, occurrences, nil
+}
+
+// ResolveErrorGroup marks the error group as resolved. The group is reopened
+// by its next occurrence.
+func (s *service) ResolveErrorGroup(ctx context.Context, fingerprint string) error {
+	// resolve
+	res, err := s.DB.ExecContext(ctx, `
+UPDATE
+  error_groups
+SET
+  resolved_at = $2
+WHERE
+  fingerprint = $1;
+  `, fingerprint, time.Now().UTC())
+	if err != nil {
+		return fmt.Errorf("update failure: %w", err)
+	}
+
+	// check result
+	if i, _ := res.RowsAffected(); i == 0 {
+		return ErrErrorGroupNotFound
+	}
+
+	return nil
+}
+
+// scanner is implemented by both sql.Row and sql.Rows.
+type scanner interface {
+	Scan(...interface{}) error
+}
+
+// scanErrorGroup scans a single error group.
+func scanErrorGroup(sc scanner) (*ErrorGroup, error) {
+	var (
+		g          ErrorGroup
+		resolvedAt sql.NullTime
+	)
+
+	err := sc.Scan(&g.Fingerprint, &g.Type, &g.Message, &g.Method, &g.Route, &g.Occurrences,
+		&g.FirstSeen, &g.LastSeen, &resolvedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	} else if err != nil {
+		return nil, fmt.Errorf("retrieved sql row scan error: %w", err)
+	}
+
+	if resolvedAt.Valid {
+		g.ResolvedAt = &resolvedAt.Time
+	}
+
+	return &g, nil
+}
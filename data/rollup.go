@@ -0,0 +1,537 @@
+package data
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/chutommy/url-shortener/config"
+	"github.com/chutommy/url-shortener/health"
+	"github.com/jmoiron/sqlx"
+)
+
+const (
+	// rollupLateness delays the rollup of an hour, so the clicks queued by the
+	// click writer are stored before their hour is rolled up.
+	rollupLateness = 5 * time.Minute
+	// rollupRecheck is the time before the hourly watermark whose hours are
+	// rolled up again, so the clicks stored even later are counted. The raw
+	// clicks of these hours are kept and their days are not rolled up yet.
+	rollupRecheck = 2 * time.Hour
+	// rollupChunk bounds the raw clicks rolled up in a single transaction.
+	rollupChunk = 24 * time.Hour
+	// rollupDailyChunk bounds the hourly aggregates rolled up in a single transaction.
+	rollupDailyChunk = 31 * 24 * time.Hour
+	// rollupLockKey is the key of the advisory lock, so only one instance rolls up at a time.
+	rollupLockKey = 0x75726c72
+
+	rollupTimeout    = 5 * time.Minute
+	rollupWorkerName = "rollup_job"
+)
+
+// Watermarks of the rollups and the retention in the rollup_state table.
+const (
+	// stateHourly is the end of the raw clicks rolled up into the hourly aggregates.
+	stateHourly = "hourly"
+	// stateDaily is the end of the hourly aggregates rolled up into the daily aggregates.
+	stateDaily = "daily"
+	// stateRawPurged is the start of the raw clicks which are kept.
+	stateRawPurged = "raw_purged"
+	// stateHourlyPurged is the start of the hourly aggregates which are kept.
+	stateHourlyPurged = "hourly_purged"
+)
+
+// rollupJob periodically rolls up the raw clicks, deletes the expired rows and
+// creates the partitions of the upcoming months.
+type rollupJob struct {
+	interval        time.Duration
+	rawRetention    time.Duration
+	hourlyRetention time.Duration
+
+	stop chan struct{}
+	done chan struct{}
+	once sync.Once
+}
+
+// InitRollups starts the background job of the rollups, the retention and the
+// partitions of the raw clicks. The job runs even with no retention set, so
+// the partitions of the upcoming clicks exist.
+func (s *service) InitRollups(roCfg *config.Rollup) error {
+	interval, err := roCfg.Every()
+	if err != nil {
+		return err
+	}
+
+	raw, hourly, err := roCfg.Retentions()
+	if err != nil {
+		return err
+	}
+
+	s.rollups = &rollupJob{
+		interval:        interval,
+		rawRetention:    raw,
+		hourlyRetention: hourly,
+		stop:            make(chan struct{}),
+		done:            make(chan struct{}),
+	}
+
+	health.Default.Register(rollupWorkerName, s.rollups.running)
+
+	go s.runRollups()
+
+	return nil
+}
+
+// running reports whether the job still runs.
+func (j *rollupJob) running() bool {
+	select {
+	case <-j.done:
+		return false
+	default:
+		return true
+	}
+}
+
+// runRollups runs the rollups every interval until the job is stopped.
+func (s *service) runRollups() {
+	j := s.rollups
+	defer close(j.done)
+
+	ticker := time.NewTicker(j.interval)
+	defer ticker.Stop()
+
+	for {
+		ctx, cancel := context.WithTimeout(context.Background(), rollupTimeout)
+		if err := s.rollup(ctx, time.Now()); err != nil {
+			s.LogError(ctx, fmt.Errorf("failed to roll up clicks: %w", err))
+		}
+
+		cancel()
+
+		select {
+		case <-j.stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// stopRollups stops the job, a running rollup is finished first.
+func (s *service) stopRollups() {
+	if s.rollups == nil {
+		return
+	}
+
+	health.Default.Unregister(rollupWorkerName)
+
+	s.rollups.once.Do(func() {
+		close(s.rollups.stop)
+	})
+	<-s.rollups.done
+}
+
+// rollup rolls up the clicks until it catches up with the time or the job is stopped.
+func (s *service) rollup(ctx context.Context, now time.Time) error {
+	for {
+		more, err := s.rollupChunk(ctx, now)
+		if err != nil || !more {
+			return err
+		}
+
+		select {
+		case <-s.rollups.stop:
+			return nil
+		default:
+		}
+	}
+}
+
+// rollupChunk rolls up a chunk of the raw clicks and the hourly aggregates and
+// deletes the expired rows in a single transaction. True is returned if there
+// is more to roll up.
+func (s *service) rollupChunk(ctx context.Context, now time.Time) (more bool, err error) {
+	tx, err := s.DB.BeginTxx(ctx, nil)
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	// another instance rolls up
+	var locked bool
+	if err = tx.QueryRowxContext(ctx, `SELECT PG_TRY_ADVISORY_XACT_LOCK($1);`, rollupLockKey).Scan(&locked); err != nil {
+		return false, fmt.Errorf("failed to lock rollups: %w", err)
+	}
+
+	if !locked {
+		return false, tx.Rollback()
+	}
+
+	state, err := rollupState(ctx, tx)
+	if err != nil {
+		return false, err
+	}
+
+	// partitions of the upcoming clicks
+	partitions, err := ensurePartitions(ctx, tx, now)
+	if err != nil {
+		return false, err
+	}
+
+	// hourly
+	target := now.Add(-rollupLateness).UTC().Truncate(time.Hour)
+
+	hourly, ok := state[stateHourly]
+	if !ok {
+		if hourly, err = firstUsageHour(ctx, tx, target); err != nil {
+			return false, err
+		}
+	}
+
+	if end := minTime(hourly.Add(rollupChunk), target); hourly.Before(end) {
+		// the recent hours are rolled up again with their late clicks
+		from := maxTime(hourly.Add(-rollupRecheck), state[stateRawPurged])
+		if err = rollupHourly(ctx, tx, from, end); err != nil {
+			return false, err
+		}
+
+		hourly = end
+		more = end.Before(target)
+	}
+
+	state[stateHourly] = hourly
+
+	// daily, only the days whose hours are not rolled up again
+	dayTarget := truncateDay(hourly.Add(-rollupRecheck))
+
+	daily, ok := state[stateDaily]
+	if !ok {
+		daily = dayTarget
+		if first, ok := state[stateHourlyPurged]; ok {
+			daily = truncateDay(first)
+		}
+	}
+
+	if end := minTime(daily.Add(rollupDailyChunk), dayTarget); daily.Before(end) {
+		if err = rollupDaily(ctx, tx, daily, end); err != nil {
+			return false, err
+		}
+
+		daily = end
+		more = more || end.Before(dayTarget)
+	}
+
+	state[stateDaily] = daily
+
+	// retention, only rolled up rows are deleted
+	if s.rollups.rawRetention > 0 {
+		cutoff := minTime(now.Add(-s.rollups.rawRetention).UTC().Truncate(time.Hour), hourly.Add(-rollupRecheck))
+		if cutoff.After(state[stateRawPurged]) {
+			if err = purgeUsages(ctx, tx, partitions, cutoff); err != nil {
+				return false, err
+			}
+
+			state[stateRawPurged] = cutoff
+		}
+	}
+
+	if s.rollups.hourlyRetention > 0 {
+		cutoff := minTime(truncateDay(now.Add(-s.rollups.hourlyRetention)), daily)
+		if cutoff.After(state[stateHourlyPurged]) {
+			if _, err = tx.ExecContext(ctx, `
+DELETE FROM
+  usage_rollups_hourly
+WHERE
+  bucket < $1;
+  `, cutoff); err != nil {
+				return false, fmt.Errorf("delete failure: %w", err)
+			}
+
+			state[stateHourlyPurged] = cutoff
+		}
+	}
+
+	// store watermarks
+	if err = storeRollupState(ctx, tx, state); err != nil {
+		return false, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return false, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return more, nil
+}
+
+// rollupState loads the watermarks.
+func rollupState(ctx context.Context, q sqlx.QueryerContext) (map[string]time.Time, error) {
+	rows, err := q.QueryxContext(ctx, `
+SELECT
+  name,
+  until
+FROM
+  rollup_state;
+  `)
+	if err != nil {
+		return nil, fmt.Errorf("unexpected query error: %w", err)
+	}
+
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	state := make(map[string]time.Time)
+
+	for rows.Next() {
+		var (
+			name  string
+			until time.Time
+		)
+
+		if err = rows.Scan(&name, &until); err != nil {
+			return nil, fmt.Errorf("retrieved sql row scan error: %w", err)
+		}
+
+		state[name] = until.UTC()
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("unexpected rows error: %w", err)
+	}
+
+	return state, nil
+}
+
+// storeRollupState stores the watermarks.
+func storeRollupState(ctx context.Context, tx *sqlx.Tx, state map[string]time.Time) error {
+	for name, until := range state {
+		_, err := tx.ExecContext(ctx, `
+INSERT INTO
+  rollup_state (name, until)
+VALUES
+  ($1, $2)
+ON CONFLICT (name)
+  DO UPDATE SET until = EXCLUDED.until;
+  `, name, until)
+		if err != nil {
+			return fmt.Errorf("insert failure: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// firstUsageHour returns the hour of the oldest raw click, the fallback if there is none.
+func firstUsageHour(ctx context.Context, tx *sqlx.Tx, fallback time.Time) (time.Time, error) {
+	var first *time.Time
+
+	if err := tx.QueryRowxContext(ctx, `
+SELECT
+  DATE_TRUNC('hour', MIN(logged_at))
+FROM
+  usages;
+  `).Scan(&first); err != nil {
+		return time.Time{}, fmt.Errorf("retrieved sql row scan error: %w", err)
+	}
+
+	if first == nil {
+		return fallback, nil
+	}
+
+	return first.UTC(), nil
+}
+
+// rollupHourly counts the raw clicks of the range into the hourly aggregates,
+// the repeated clicks are not counted. The aggregates of the range are
+// replaced, so the range can be rolled up again.
+func rollupHourly(ctx context.Context, tx *sqlx.Tx, from, to time.Time) error {
+	_, err := tx.ExecContext(ctx, `
+INSERT INTO
+  usage_rollups_hourly (shortcut_id, bucket, referrer_host, device, country, region, city, browser, is_bot, clicks)
+SELECT
+  shortcut_id,
+  DATE_TRUNC('hour', logged_at),
+  COALESCE(referrer_host, ''),
+  COALESCE(device, ''),
+  COALESCE(country::TEXT, ''),
+  COALESCE(region, ''),
+  COALESCE(city, ''),
+  COALESCE(browser, ''),
+  is_bot,
+  COUNT(*)
+FROM
+  usages
+WHERE
+  logged_at >= $1
+  AND logged_at < $2
+  AND NOT is_repeat
+GROUP BY
+  1, 2, 3, 4, 5, 6, 7, 8, 9
+ON CONFLICT (shortcut_id, bucket, referrer_host, device, country, region, city, browser, is_bot)
+  DO UPDATE SET clicks = EXCLUDED.clicks;
+  `, from, to)
+	if err != nil {
+		return fmt.Errorf("insert failure: %w", err)
+	}
+
+	return nil
+}
+
+// rollupDaily adds the hourly aggregates of the range to the daily aggregates.
+func rollupDaily(ctx context.Context, tx *sqlx.Tx, from, to time.Time) error {
+	_, err := tx.ExecContext(ctx, `
+INSERT INTO
+  usage_rollups_daily (shortcut_id, bucket, referrer_host, device, country, region, city, browser, is_bot, clicks)
+SELECT
+  shortcut_id,
+  DATE_TRUNC('day', bucket),
+  referrer_host,
+  device,
+  country,
+  region,
+  city,
+  browser,
+  is_bot,
+  SUM(clicks)
+FROM
+  usage_rollups_hourly
+WHERE
+  bucket >= $1
+  AND bucket < $2
+GROUP BY
+  1, 2, 3, 4, 5, 6, 7, 8, 9
+ON CONFLICT (shortcut_id, bucket, referrer_host, device, country, region, city, browser, is_bot)
+  DO UPDATE SET clicks = usage_rollups_daily.clicks + EXCLUDED.clicks;
+  `, from, to)
+	if err != nil {
+		return fmt.Errorf("insert failure: %w", err)
+	}
+
+	return nil
+}
+
+// segment is a range of the clicks stored in a single table. The filter
+// excludes the rows which are not counted as clicks.
+type segment struct {
+	table  string
+	time   string
+	clicks string
+	filter string
+	from   time.Time
+	to     time.Time
+}
+
+// Tables of the clicks from the oldest to the most recent.
+var (
+	dailySegment  = segment{table: "usage_rollups_daily", time: "bucket", clicks: "clicks", filter: "TRUE"}
+	hourlySegment = segment{table: "usage_rollups_hourly", time: "bucket", clicks: "clicks", filter: "TRUE"}
+	rawSegment    = segment{table: "usages", time: "logged_at", clicks: "1::BIGINT", filter: "NOT is_repeat"}
+)
+
+// segments splits the range [from, to) by the tables holding its clicks: the
+// daily aggregates before the kept hourly aggregates, the hourly aggregates
+// before the kept raw clicks and the raw clicks. The raw clicks are used
+// wherever they are kept, the aggregates count the whole hours and days which
+// start in the range.
+func (s *service) segments(ctx context.Context, from, to time.Time) ([]segment, error) {
+	state, err := rollupState(ctx, s.DB)
+	if err != nil {
+		return nil, err
+	}
+
+	return splitSegments(from, to, state[stateRawPurged], state[stateHourlyPurged]), nil
+}
+
+// splitSegments splits the range by the starts of the kept raw clicks and
+// hourly aggregates, at least a single segment is returned.
+func splitSegments(from, to, rawFrom, hourlyFrom time.Time) []segment {
+	var segs []segment
+
+	for _, seg := range []segment{dailySegment, hourlySegment, rawSegment} {
+		switch seg {
+		case dailySegment:
+			seg.from, seg.to = from, minTime(to, hourlyFrom)
+		case hourlySegment:
+			seg.from, seg.to = maxTime(from, hourlyFrom), minTime(to, rawFrom)
+		default:
+			seg.from, seg.to = maxTime(from, rawFrom), to
+		}
+
+		if seg.from.Before(seg.to) {
+			segs = append(segs, seg)
+		}
+	}
+
+	if len(segs) == 0 {
+		raw := rawSegment
+		raw.from, raw.to = from, to
+		segs = append(segs, raw)
+	}
+
+	return segs
+}
+
+// clickSource returns the query of the counted clicks of the segments with the
+// columns shortcut_id, t, clicks, is_bot and the breakdown columns, and its
+// arguments which are numbered from the firstArg. The repeats are excluded.
+func clickSource(segs []segment, firstArg int) (string, []interface{}) {
+	var (
+		parts []string
+		args  []interface{}
+	)
+
+	for i, seg := range segs {
+		n := firstArg + 2*i
+		parts = append(parts, fmt.Sprintf(`
+SELECT
+  shortcut_id,
+  %[2]s AS t,
+  %[3]s AS clicks,
+  referrer_host,
+  device,
+  country::TEXT AS country,
+  region,
+  city,
+  browser,
+  is_bot
+FROM
+  %[1]s
+WHERE
+  %[2]s >= $%[4]d
+  AND %[2]s < $%[5]d
+  AND %[6]s`, seg.table, seg.time, seg.clicks, n, n+1, seg.filter))
+
+		args = append(args, seg.from.UTC(), seg.to.UTC())
+	}
+
+	return strings.Join(parts, "\nUNION ALL"), args
+}
+
+// truncateDay returns the start of the UTC day of t.
+func truncateDay(t time.Time) time.Time {
+	y, m, d := t.UTC().Date()
+
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+func minTime(a, b time.Time) time.Time {
+	if b.Before(a) {
+		return b
+	}
+
+	return a
+}
+
+func maxTime(a, b time.Time) time.Time {
+	if b.After(a) {
+		return b
+	}
+
+	return a
+}
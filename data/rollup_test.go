@@ -0,0 +1,179 @@
+package data
+
+import (
+	"context"
+	"database/sql/driver"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSplitSegments(t *testing.T) {
+	day := func(d int) time.Time {
+		return time.Date(2024, time.March, d, 0, 0, 0, 0, time.UTC)
+	}
+
+	type span struct {
+		table    string
+		from, to time.Time
+	}
+
+	tests := []struct {
+		name       string
+		from, to   time.Time
+		rawFrom    time.Time
+		hourlyFrom time.Time
+		exp        []span
+	}{
+		{
+			name: "nothing purged", from: day(1), to: day(10),
+			exp: []span{{"usages", day(1), day(10)}},
+		},
+		{
+			name: "raw purged", from: day(1), to: day(10), rawFrom: day(5),
+			exp: []span{{"usage_rollups_hourly", day(1), day(5)}, {"usages", day(5), day(10)}},
+		},
+		{
+			name: "all tiers", from: day(1), to: day(10), rawFrom: day(7), hourlyFrom: day(3),
+			exp: []span{
+				{"usage_rollups_daily", day(1), day(3)},
+				{"usage_rollups_hourly", day(3), day(7)},
+				{"usages", day(7), day(10)},
+			},
+		},
+		{
+			name: "only raw", from: day(8), to: day(10), rawFrom: day(7), hourlyFrom: day(3),
+			exp: []span{{"usages", day(8), day(10)}},
+		},
+		{
+			name: "only daily", from: day(1), to: day(2), rawFrom: day(7), hourlyFrom: day(3),
+			exp: []span{{"usage_rollups_daily", day(1), day(2)}},
+		},
+		{
+			name: "empty range", from: day(5), to: day(5), rawFrom: day(7), hourlyFrom: day(3),
+			exp: []span{{"usages", day(5), day(5)}},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var got []span
+			for _, seg := range splitSegments(tc.from, tc.to, tc.rawFrom, tc.hourlyFrom) {
+				got = append(got, span{seg.table, seg.from, seg.to})
+			}
+
+			assert.Equal(t, tc.exp, got)
+		})
+	}
+}
+
+func TestClickSource(t *testing.T) {
+	segs := splitSegments(time.Unix(0, 0), time.Unix(7200, 0), time.Unix(3600, 0), time.Time{})
+
+	source, args := clickSource(segs, 3)
+
+	assert.Contains(t, source, "FROM\n  usage_rollups_hourly\nWHERE\n  bucket >= $3\n  AND bucket < $4")
+	assert.Contains(t, source, "FROM\n  usages\nWHERE\n  logged_at >= $5\n  AND logged_at < $6")
+	assert.Contains(t, source, "1::BIGINT AS clicks")
+	assert.Len(t, args, 4)
+}
+
+func TestRollupChunk_LateClicks(t *testing.T) {
+	at := func(d, h int) time.Time {
+		return time.Date(2024, time.March, d, h, 0, 0, 0, time.UTC)
+	}
+
+	tests := []struct {
+		name         string
+		state        map[string]time.Time
+		now          time.Time
+		rawRetention time.Duration
+		hourly       [2]time.Time
+		daily        *[2]time.Time
+		rawPurged    time.Time
+		exp          map[string]time.Time
+	}{
+		{
+			name:   "recent hours rolled up again",
+			state:  map[string]time.Time{stateHourly: at(15, 10), stateDaily: at(15, 0)},
+			now:    at(15, 11).Add(10 * time.Minute),
+			hourly: [2]time.Time{at(15, 8), at(15, 11)},
+			exp:    map[string]time.Time{stateHourly: at(15, 11), stateDaily: at(15, 0)},
+		},
+		{
+			name: "day rolled up once its hours are final",
+			state: map[string]time.Time{
+				stateHourly: at(15, 1), stateDaily: at(14, 0), stateRawPurged: at(14, 0),
+			},
+			now:          at(15, 2).Add(10 * time.Minute),
+			rawRetention: time.Hour,
+			hourly:       [2]time.Time{at(14, 23), at(15, 2)},
+			daily:        &[2]time.Time{at(14, 0), at(15, 0)},
+			rawPurged:    at(15, 0),
+			exp: map[string]time.Time{
+				stateHourly: at(15, 2), stateDaily: at(15, 0), stateRawPurged: at(15, 0),
+			},
+		},
+		{
+			name: "purged raw clicks are not rolled up again",
+			state: map[string]time.Time{
+				stateHourly: at(15, 10), stateDaily: at(15, 0), stateRawPurged: at(15, 9),
+			},
+			now:    at(15, 11).Add(10 * time.Minute),
+			hourly: [2]time.Time{at(15, 9), at(15, 11)},
+			exp:    map[string]time.Time{stateHourly: at(15, 11), stateDaily: at(15, 0), stateRawPurged: at(15, 9)},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			s, db := newMockDB(t)
+			s.rollups = &rollupJob{rawRetention: tc.rawRetention}
+
+			db.ExpectBegin()
+			db.ExpectQuery(`PG_TRY_ADVISORY_XACT_LOCK`).WillReturnRows([]string{"locked"}, []driver.Value{true})
+
+			var rows [][]driver.Value
+			for name, until := range tc.state {
+				rows = append(rows, []driver.Value{name, until})
+			}
+
+			db.ExpectQuery(`FROM\s+rollup_state`).WillReturnRows([]string{"name", "until"}, rows...)
+			db.ExpectExec(`PG_ADVISORY_XACT_LOCK`)
+			db.ExpectQuery(`pg_inherits`).WillReturnRows([]string{"relname"},
+				[]driver.Value{"usages_y2024m03"}, []driver.Value{"usages_y2024m04"},
+				[]driver.Value{"usages_y2024m05"}, []driver.Value{"usages_y2024m06"})
+
+			// the aggregates of the hours are replaced
+			db.ExpectExec(`INSERT INTO\s+usage_rollups_hourly[\s\S]+SET clicks = EXCLUDED\.clicks;`).
+				WithArgs(tc.hourly[0], tc.hourly[1])
+
+			if tc.daily != nil {
+				db.ExpectExec(`INSERT INTO\s+usage_rollups_daily`).WithArgs(tc.daily[0], tc.daily[1])
+			}
+
+			if !tc.rawPurged.IsZero() && !tc.rawPurged.Equal(tc.state[stateRawPurged]) {
+				db.ExpectExec(`DELETE FROM\s+usages`).WithArgs(tc.rawPurged)
+			}
+
+			var stored []*expectation
+			for range tc.exp {
+				stored = append(stored, db.ExpectExec(`INSERT INTO\s+rollup_state`))
+			}
+
+			db.ExpectCommit()
+
+			more, err := s.rollupChunk(context.Background(), tc.now)
+			assert.NoError(t, err)
+			assert.False(t, more)
+
+			state := make(map[string]time.Time)
+			for _, e := range stored {
+				state[e.Args()[0].(string)] = e.Args()[1].(time.Time)
+			}
+
+			assert.Equal(t, tc.exp, state)
+		})
+	}
+}
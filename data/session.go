@@ -0,0 +1,273 @@
+package data
+
+import (
+	"context"
+	"crypto/subtle"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/chutommy/url-shortener/config"
+	"github.com/google/uuid"
+)
+
+// ErrSessionsDisabled is returned if no session signing keys are configured.
+var ErrSessionsDisabled = errors.New("admin sessions are not configured")
+
+// Roles of the admin sessions.
+const (
+	// RoleAdmin grants full access to the admin API.
+	RoleAdmin = "admin"
+	// RoleViewer grants a read-only access to the admin API.
+	RoleViewer = "viewer"
+)
+
+// Session holds the tokens of a newly issued or refreshed admin session.
+type Session struct {
+	AccessToken      string    `json:"access_token"`
+	TokenType        string    `json:"token_type"`
+	ExpiresAt        time.Time `json:"expires_at"`
+	RefreshToken     string    `json:"refresh_token"`
+	RefreshExpiresAt time.Time `json:"refresh_expires_at"`
+}
+
+// SessionClaims describes the holder of a valid access token.
+type SessionClaims struct {
+	SessionID string    `json:"session_id"`
+	Username  string    `json:"username"`
+	Role      string    `json:"role"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// InitSessions sets up signing of the admin session tokens. Sessions stay
+// disabled if the configuration has no signing keys.
+func (s *service) InitSessions(sesCfg *config.Session) error {
+	if _, ok := sesCfg.ActiveKey(); !ok {
+		return nil
+	}
+
+	ts, err := newTokenSigner(sesCfg)
+	if err != nil {
+		return fmt.Errorf("invalid session configuration: %w", err)
+	}
+
+	s.tokens = ts
+
+	return nil
+}
+
+// CreateSession starts a new session of the given admin user with the given role.
+func (s *service) CreateSession(ctx context.Context, username string, role string) (*Session, error) {
+	if s.tokens == nil {
+		return nil, ErrSessionsDisabled
+	}
+
+	// generate refresh token
+	id := uuid.New().String()
+
+	refresh, hash, err := genRefreshToken(id)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	refreshExp := now.Add(s.tokens.refreshTTL)
+
+	// store session
+	_, err = s.DB.ExecContext(ctx, `
+INSERT INTO
+  admin_sessions (session_id, username, role, refresh_hash, created_at, refresh_expires_at)
+VALUES
+  ($1, $2, $3, $4, $5, $6);
+  `, id, username, role, hash, now, refreshExp)
+	if err != nil {
+		return nil, fmt.Errorf("insert failure: %w", err)
+	}
+
+	return s.issueSession(id, username, role, refresh, now, refreshExp)
+}
+
+// RefreshSession rotates the refresh token and issues a new access token.
+// A reuse of an already rotated refresh token revokes the whole session.
+func (s *service) RefreshSession(ctx context.Context, refreshToken string) (*Session, error) {
+	if s.tokens == nil {
+		return nil, ErrSessionsDisabled
+	}
+
+	// separate the refresh token
+	splitToken := strings.Split(refreshToken, ".")
+	if len(splitToken) != keySplitLen {
+		return nil, ErrUnauthorized
+	}
+
+	id, secret := splitToken[0], splitToken[1]
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, ErrUnauthorized
+	}
+
+	// query db
+	row := s.DB.QueryRowxContext(ctx, `
+SELECT
+  username,
+  role,
+  refresh_hash,
+  refresh_expires_at
+FROM
+  admin_sessions
+WHERE
+  session_id = $1
+  AND revoked_at IS NULL;
+  `, id)
+
+	var username, role, hash string
+
+	var refreshExp time.Time
+	if err := row.Scan(&username, &role, &hash, &refreshExp); errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrUnauthorized
+	} else if err != nil {
+		return nil, fmt.Errorf("retrieved sql row scan error: %w", err)
+	}
+
+	now := time.Now().UTC()
+	if !now.Before(refreshExp) {
+		return nil, ErrUnauthorized
+	}
+
+	// detect reuse
+	if subtle.ConstantTimeCompare([]byte(hash), []byte(hashSecret(secret))) != 1 {
+		if err := s.RevokeSession(ctx, id); err != nil && !errors.Is(err, ErrUnauthorized) {
+			return nil, err
+		}
+
+		return nil, ErrUnauthorized
+	}
+
+	// rotate refresh token
+	refresh, newHash, err := genRefreshToken(id)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := s.DB.ExecContext(ctx, `
+UPDATE
+  admin_sessions
+SET
+  refresh_hash = $3
+WHERE
+  session_id = $1
+  AND refresh_hash = $2
+  AND revoked_at IS NULL;
+  `, id, hash, newHash)
+	if err != nil {
+		return nil, fmt.Errorf("update failure: %w", err)
+	}
+
+	// concurrent refresh
+	if i, _ := res.RowsAffected(); i != 1 {
+		return nil, ErrUnauthorized
+	}
+
+	return s.issueSession(id, username, role, refresh, now, refreshExp)
+}
+
+// ValidateSession validates the access token and checks that its session was not revoked.
+// ErrUnauthorized is returned if the token is invalid, expired or revoked.
+func (s *service) ValidateSession(ctx context.Context, accessToken string) (*SessionClaims, error) {
+	if s.tokens == nil {
+		return nil, ErrUnauthorized
+	}
+
+	// verify token
+	claims, err := s.tokens.verify(accessToken, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	// check session
+	row := s.DB.QueryRowxContext(ctx, `
+SELECT
+  role,
+  refresh_expires_at
+FROM
+  admin_sessions
+WHERE
+  session_id = $1
+  AND revoked_at IS NULL;
+  `, claims.SessionID)
+
+	var role string
+
+	var refreshExp time.Time
+	if err := row.Scan(&role, &refreshExp); errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrUnauthorized
+	} else if err != nil {
+		return nil, fmt.Errorf("retrieved sql row scan error: %w", err)
+	}
+
+	if !time.Now().UTC().Before(refreshExp) {
+		return nil, ErrUnauthorized
+	}
+
+	return &SessionClaims{
+		SessionID: claims.SessionID,
+		Username:  claims.Subject,
+		Role:      role,
+		ExpiresAt: time.Unix(claims.ExpiresAt, 0).UTC(),
+	}, nil
+}
+
+// RevokeSession revokes the session with the given id. ErrUnauthorized is
+// returned if the session does not exist or is already revoked.
+func (s *service) RevokeSession(ctx context.Context, sessionID string) error {
+	// revoke
+	res, err := s.DB.ExecContext(ctx, `
+UPDATE
+  admin_sessions
+SET
+  revoked_at = $2
+WHERE
+  session_id = $1
+  AND revoked_at IS NULL;
+  `, sessionID, time.Now().UTC())
+	if err != nil {
+		return fmt.Errorf("update failure: %w", err)
+	}
+
+	// check result
+	if i, _ := res.RowsAffected(); i == 0 {
+		return ErrUnauthorized
+	}
+
+	return nil
+}
+
+// issueSession signs a new access token of the session.
+func (s *service) issueSession(id, username, role, refresh string, now, refreshExp time.Time) (*Session, error) {
+	exp := now.Add(s.tokens.ttl)
+	if exp.After(refreshExp) {
+		exp = refreshExp
+	}
+
+	access, err := s.tokens.sign(&tokenClaims{
+		Issuer:    tokenIssuer,
+		Subject:   username,
+		SessionID: id,
+		Role:      role,
+		IssuedAt:  now.Unix(),
+		NotBefore: now.Unix(),
+		ExpiresAt: exp.Unix(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &Session{
+		AccessToken:      access,
+		TokenType:        "Bearer",
+		ExpiresAt:        exp,
+		RefreshToken:     refresh,
+		RefreshExpiresAt: refreshExp,
+	}, nil
+}
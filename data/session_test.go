@@ -0,0 +1,141 @@
+package data
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRefreshSession(t *testing.T) {
+	const id = "d5e3b3e4-5f4c-4c0e-9d0a-3b1a8a1f2c3d"
+
+	refresh, hash, err := genRefreshToken(id)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	sessionColumns := []string{"username", "role", "refresh_hash", "refresh_expires_at"}
+	future, past := time.Now().Add(time.Hour).UTC(), time.Now().Add(-time.Second).UTC()
+
+	tests := []struct {
+		name   string
+		token  string
+		expect func(db *mockDB)
+		err    error
+	}{
+		{
+			name:  "rotated",
+			token: refresh,
+			expect: func(db *mockDB) {
+				db.ExpectQuery(`FROM\s+admin_sessions`).WithArgs(id).WillReturnRows(sessionColumns,
+					[]driver.Value{"admin", RoleAdmin, hash, future})
+				db.ExpectExec(`SET\s+refresh_hash = \$3`).WithArgs(id, hash, anyArg{}).WillReturnResult(1)
+			},
+		},
+		{
+			name:  "reused",
+			token: id + ".rotated-secret",
+			expect: func(db *mockDB) {
+				db.ExpectQuery(`FROM\s+admin_sessions`).WithArgs(id).WillReturnRows(sessionColumns,
+					[]driver.Value{"admin", RoleAdmin, hash, future})
+				// the whole session is revoked
+				db.ExpectExec(`SET\s+revoked_at = \$2`).WithArgs(id, anyArg{}).WillReturnResult(1)
+			},
+			err: ErrUnauthorized,
+		},
+		{
+			name:  "concurrently rotated",
+			token: refresh,
+			expect: func(db *mockDB) {
+				db.ExpectQuery(`FROM\s+admin_sessions`).WithArgs(id).WillReturnRows(sessionColumns,
+					[]driver.Value{"admin", RoleAdmin, hash, future})
+				db.ExpectExec(`SET\s+refresh_hash = \$3`).WithArgs(id, hash, anyArg{}).WillReturnResult(0)
+			},
+			err: ErrUnauthorized,
+		},
+		{
+			name:  "expired",
+			token: refresh,
+			expect: func(db *mockDB) {
+				db.ExpectQuery(`FROM\s+admin_sessions`).WithArgs(id).WillReturnRows(sessionColumns,
+					[]driver.Value{"admin", RoleAdmin, hash, past})
+			},
+			err: ErrUnauthorized,
+		},
+		{
+			name:  "revoked",
+			token: refresh,
+			expect: func(db *mockDB) {
+				db.ExpectQuery(`FROM\s+admin_sessions`).WithArgs(id).WillReturnRows(sessionColumns)
+			},
+			err: ErrUnauthorized,
+		},
+		{name: "no secret", token: id, expect: func(*mockDB) {}, err: ErrUnauthorized},
+		{name: "invalid session id", token: "session.secret", expect: func(*mockDB) {}, err: ErrUnauthorized},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			s, db := newMockDB(t)
+			s.tokens = testSigner(newKey)
+			s.tokens.ttl, s.tokens.refreshTTL = 15*time.Minute, 24*time.Hour
+			tc.expect(db)
+
+			ses, err := s.RefreshSession(context.Background(), tc.token)
+			if tc.err != nil {
+				assert.True(t, errors.Is(err, tc.err), err)
+
+				return
+			}
+
+			if !assert.NoError(t, err) {
+				return
+			}
+
+			assert.True(t, strings.HasPrefix(ses.RefreshToken, id+"."))
+			assert.NotEqual(t, refresh, ses.RefreshToken)
+			assert.Equal(t, future, ses.RefreshExpiresAt)
+
+			claims, err := s.tokens.verify(ses.AccessToken, time.Now())
+			if assert.NoError(t, err) {
+				assert.Equal(t, id, claims.SessionID)
+				assert.Equal(t, RoleAdmin, claims.Role)
+			}
+
+			// the new hash is of the new refresh token
+			newHash := db.expected[1].Args()[2]
+			assert.Equal(t, hashSecret(strings.TrimPrefix(ses.RefreshToken, id+".")), newHash)
+		})
+	}
+}
+
+func TestRefreshSession_ReuseRevokesAccess(t *testing.T) {
+	const id = "d5e3b3e4-5f4c-4c0e-9d0a-3b1a8a1f2c3d"
+
+	s, db := newMockDB(t)
+	s.tokens = testSigner(newKey)
+	s.tokens.ttl, s.tokens.refreshTTL = 15*time.Minute, 24*time.Hour
+
+	ses, err := s.issueSession(id, "admin", RoleAdmin, id+".secret", time.Now().UTC(), time.Now().Add(time.Hour))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	// the reuse revokes the session, its access tokens are rejected as well
+	db.ExpectQuery(`FROM\s+admin_sessions`).WithArgs(id).WillReturnRows(
+		[]string{"username", "role", "refresh_hash", "refresh_expires_at"},
+		[]driver.Value{"admin", RoleAdmin, hashSecret("rotated"), time.Now().Add(time.Hour)})
+	db.ExpectExec(`SET\s+revoked_at = \$2`).WithArgs(id, anyArg{}).WillReturnResult(1)
+	db.ExpectQuery(`revoked_at IS NULL`).WithArgs(id).WillReturnRows([]string{"role", "refresh_expires_at"})
+
+	_, err = s.RefreshSession(context.Background(), id+".secret")
+	assert.True(t, errors.Is(err, ErrUnauthorized), err)
+
+	_, err = s.ValidateSession(context.Background(), ses.AccessToken)
+	assert.True(t, errors.Is(err, ErrUnauthorized), err)
+}
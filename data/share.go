@@ -0,0 +1,227 @@
+package data
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+)
+
+const (
+	shareTokenLen      = 32
+	maxShareTokenLabel = 128
+)
+
+var (
+	// ErrInvalidShareToken is returned if the label of a new share token is too long or it expires in the past.
+	ErrInvalidShareToken = errors.New("label must have at most 128 characters and expires_at must be in the future")
+	// ErrShareTokenNotFound is returned if the share token does not exist or is already revoked.
+	ErrShareTokenNotFound = errors.New("share token with the given id does not exist or is revoked")
+	// ErrShareUnauthorized is returned if the share token is unknown, revoked or expired, or its shortcut is deleted.
+	ErrShareUnauthorized = errors.New("share token is invalid, revoked or expired")
+)
+
+// ShareToken grants the read-only access to the analytics of the shortcut
+// with the ShortcutID until ExpiresAt, a nil ExpiresAt never expires. The
+// Token itself is returned only once by CreateShareToken, only its hash is stored.
+type ShareToken struct {
+	ID         int64      `json:"token_id"`
+	ShortcutID string     `json:"shortcut_id"`
+	Label      string     `json:"label"`
+	Token      string     `json:"token,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+}
+
+// hashShareToken returns the stored hash of the token. The tokens are random,
+// so a fast hash is enough to protect them.
+func hashShareToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+
+	return hex.EncodeToString(sum[:])
+}
+
+// CreateShareToken creates a random token of the active shortcut with the
+// ShortcutID, the Label and the ExpiresAt of the given token.
+func (s *service) CreateShareToken(ctx context.Context, t *ShareToken) (*ShareToken, error) {
+	id := strings.ToLower(t.ShortcutID)
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, ErrInvalidID
+	}
+
+	label := strings.TrimSpace(t.Label)
+	if utf8.RuneCountInString(label) > maxShareTokenLabel || (t.ExpiresAt != nil && !t.ExpiresAt.After(time.Now())) {
+		return nil, ErrInvalidShareToken
+	}
+
+	// generate token
+	raw := make([]byte, shareTokenLen)
+	if _, err := rand.Read(raw); err != nil {
+		return nil, fmt.Errorf("failed to generate share token: %w", err)
+	}
+
+	created := ShareToken{
+		ShortcutID: id,
+		Label:      label,
+		Token:      hex.EncodeToString(raw),
+	}
+
+	var expiresAt sql.NullTime
+	if t.ExpiresAt != nil {
+		expiresAt = newNullTime(*t.ExpiresAt)
+		utc := expiresAt.Time
+		created.ExpiresAt = &utc
+	}
+
+	// store the hash
+	err := s.DB.QueryRowxContext(ctx, `
+INSERT INTO
+  share_tokens (shortcut_id, token_hash, label, expires_at)
+SELECT
+  shortcut_id,
+  $2,
+  $3,
+  $4
+FROM
+  shortcuts
+WHERE
+  shortcut_id = $1
+  AND deleted_at IS NULL
+RETURNING
+  token_id,
+  created_at;
+  `, id, hashShareToken(created.Token), label, expiresAt).Scan(&created.ID, &created.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrIDNotFound
+	} else if err != nil {
+		return nil, fmt.Errorf("could not execute sql insert: %w", err)
+	}
+
+	return &created, nil
+}
+
+// GetShareTokens returns the share tokens of the shortcut, including the
+// revoked and expired ones, ordered by their creation.
+func (s *service) GetShareTokens(ctx context.Context, shortcutID string) ([]*ShareToken, error) {
+	id := strings.ToLower(shortcutID)
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, ErrInvalidID
+	}
+
+	rows, err := s.DB.QueryxContext(ctx, `
+SELECT
+  token_id,
+  shortcut_id,
+  label,
+  created_at,
+  expires_at,
+  revoked_at
+FROM
+  share_tokens
+WHERE
+  shortcut_id = $1
+ORDER BY
+  token_id;
+  `, id)
+	if err != nil {
+		return nil, fmt.Errorf("unexpected query error: %w", err)
+	}
+
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	// scan rows
+	tokens := []*ShareToken{}
+
+	for rows.Next() {
+		var (
+			t                  ShareToken
+			expires, revokedAt sql.NullTime
+		)
+
+		if err = rows.Scan(&t.ID, &t.ShortcutID, &t.Label, &t.CreatedAt, &expires, &revokedAt); err != nil {
+			return nil, fmt.Errorf("retrieved sql row scan error: %w", err)
+		}
+
+		if expires.Valid {
+			t.ExpiresAt = &expires.Time
+		}
+
+		if revokedAt.Valid {
+			t.RevokedAt = &revokedAt.Time
+		}
+
+		tokens = append(tokens, &t)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("unexpected rows error: %w", err)
+	}
+
+	return tokens, nil
+}
+
+// RevokeShareToken revokes the share token with the given id, its links stop working immediately.
+func (s *service) RevokeShareToken(ctx context.Context, id int64) error {
+	result, err := s.DB.ExecContext(ctx, `
+UPDATE
+  share_tokens
+SET
+  revoked_at = NOW()
+WHERE
+  token_id = $1
+  AND revoked_at IS NULL;
+  `, id)
+	if err != nil {
+		return fmt.Errorf("could not execute sql update: %w", err)
+	}
+
+	if n, _ := result.RowsAffected(); n != 1 {
+		return ErrShareTokenNotFound
+	}
+
+	return nil
+}
+
+// ValidateShareToken returns the active shortcut the token grants the access
+// to. ErrShareUnauthorized is returned if the token is not valid.
+func (s *service) ValidateShareToken(ctx context.Context, token string) (*ShortRecord, error) {
+	if token == "" {
+		return nil, ErrShareUnauthorized
+	}
+
+	row := s.DB.QueryRowxContext(ctx, `
+SELECT
+  s.shortcut_id,
+  s.full_url,
+  s.short_url,
+  s.usage
+FROM
+  share_tokens AS t
+  JOIN shortcuts AS s ON s.shortcut_id = t.shortcut_id
+WHERE
+  t.token_hash = $1
+  AND t.revoked_at IS NULL
+  AND (t.expires_at IS NULL OR t.expires_at > $2)
+  AND s.deleted_at IS NULL;
+  `, hashShareToken(token), time.Now().UTC())
+
+	var r ShortRecord
+	if err := row.Scan(&r.ID, &r.Full, &r.Short, &r.Usage); errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrShareUnauthorized
+	} else if err != nil {
+		return nil, fmt.Errorf("retrieved sql row scan error: %w", err)
+	}
+
+	return &r, nil
+}
@@ -0,0 +1,46 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHashShareToken(t *testing.T) {
+	h := hashShareToken("token")
+	assert.Len(t, h, 64)
+	assert.Equal(t, h, hashShareToken("token"))
+	assert.NotEqual(t, h, hashShareToken("token2"))
+}
+
+func TestCreateShareToken_Invalid(t *testing.T) {
+	s := &service{}
+	id := "6ba7b810-9dad-11d1-80b4-00c04fd430c8"
+	past := time.Now().Add(-time.Minute)
+
+	tests := []struct {
+		name string
+		t    ShareToken
+		exp  error
+	}{
+		{name: "invalid shortcut", t: ShareToken{ShortcutID: "abc"}, exp: ErrInvalidID},
+		{name: "long label", t: ShareToken{ShortcutID: id, Label: strings.Repeat("a", 129)}, exp: ErrInvalidShareToken},
+		{name: "expired", t: ShareToken{ShortcutID: id, ExpiresAt: &past}, exp: ErrInvalidShareToken},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := s.CreateShareToken(context.Background(), &tc.t)
+			assert.True(t, errors.Is(err, tc.exp), err)
+		})
+	}
+}
+
+func TestValidateShareToken_Empty(t *testing.T) {
+	_, err := (&service{}).ValidateShareToken(context.Background(), "")
+	assert.True(t, errors.Is(err, ErrShareUnauthorized))
+}
@@ -0,0 +1,71 @@
+package data
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+)
+
+const signingSecretLen = 32
+
+// GenerateSigningSecret generates a new secret for signing requests of the active
+// admin_key with the given prefix. Any previous secret of the prefix stops working.
+func (s *service) GenerateSigningSecret(ctx context.Context, prefix string) (string, error) {
+	// generate secret
+	raw := make([]byte, signingSecretLen)
+	if _, err := rand.Read(raw); err != nil {
+		return "", fmt.Errorf("failed to generate signing secret: %w", err)
+	}
+
+	secret := hex.EncodeToString(raw)
+
+	// store
+	res, err := s.DB.ExecContext(ctx, `
+UPDATE
+  admin_keys
+SET
+  signing_secret = $2
+WHERE
+  prefix = $1
+  AND revoked_at IS NULL;
+  `, prefix, secret)
+	if err != nil {
+		return "", fmt.Errorf("update failure: %w", err)
+	}
+
+	// check result
+	if i, _ := res.RowsAffected(); i == 0 {
+		return "", ErrPrefixNotFound
+	}
+
+	return secret, nil
+}
+
+// GetSigningSecret returns the signing secret of the active admin_key with the given
+// prefix. ErrUnauthorized is returned if the key is revoked or has no signing secret.
+func (s *service) GetSigningSecret(ctx context.Context, prefix string) (string, error) {
+	// query db
+	row := s.DB.QueryRowxContext(ctx, `
+SELECT
+  signing_secret
+FROM
+  admin_keys
+WHERE
+  prefix = $1
+  AND revoked_at IS NULL
+  AND signing_secret IS NOT NULL;
+  `, prefix)
+
+	// scan row
+	var secret string
+	if err := row.Scan(&secret); errors.Is(err, sql.ErrNoRows) {
+		return "", ErrUnauthorized
+	} else if err != nil {
+		return "", fmt.Errorf("retrieved sql row scan error: %w", err)
+	}
+
+	return secret, nil
+}
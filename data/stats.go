@@ -0,0 +1,408 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Intervals of the click series buckets.
+const (
+	IntervalMinute = "minute"
+	IntervalHour   = "hour"
+	IntervalDay    = "day"
+	IntervalWeek   = "week"
+)
+
+// Dimensions of the click breakdowns.
+const (
+	BreakdownReferrer = "referrer"
+	BreakdownDevice   = "device"
+	BreakdownCountry  = "country"
+	BreakdownRegion   = "region"
+	BreakdownCity     = "city"
+	BreakdownBrowser  = "browser"
+)
+
+// Filters of the clicks of the bots.
+const (
+	BotsExclude = "exclude"
+	BotsInclude = "include"
+	BotsOnly    = "only"
+)
+
+// botFilters maps the filters of the bots to the conditions on the clicks, see clickSource.
+var botFilters = map[string]string{
+	BotsExclude: "NOT c.is_bot",
+	BotsInclude: "TRUE",
+	BotsOnly:    "c.is_bot",
+}
+
+// breakdownColumns maps the dimensions to the columns of the clicks, see clickSource.
+var breakdownColumns = map[string]string{
+	BreakdownReferrer: "referrer_host",
+	BreakdownDevice:   "device",
+	BreakdownCountry:  "country",
+	BreakdownRegion:   "region",
+	BreakdownCity:     "city",
+	BreakdownBrowser:  "browser",
+}
+
+// Values of the clicks with an empty dimension.
+const (
+	breakdownDirect  = "direct"
+	breakdownUnknown = "unknown"
+)
+
+// MaxStatsBuckets is the maximal number of buckets of a click series.
+const MaxStatsBuckets = 2000
+
+var (
+	// ErrInvalidInterval is returned if the interval of the buckets is not supported.
+	ErrInvalidInterval = errors.New("interval must be minute, hour, day or week")
+	// ErrInvalidBreakdown is returned if the dimension of a breakdown is not supported.
+	ErrInvalidBreakdown = errors.New("breakdown must be referrer, device, country, region, city or browser")
+	// ErrInvalidBots is returned if the filter of the bots is not supported.
+	ErrInvalidBots = errors.New("bots must be exclude, include or only")
+	// ErrInvalidStatsRange is returned if the range is empty or has too many buckets.
+	ErrInvalidStatsRange = errors.New("range must be non-empty and span at most 2000 buckets of the interval")
+)
+
+// ClickStatsQuery selects the clicks of a shortcut in the range [From, To).
+// The buckets of the series start at the Interval boundaries in the Location.
+// Bots filters the clicks of the bots, they are excluded by default.
+type ClickStatsQuery struct {
+	ShortcutID string
+	From       time.Time
+	To         time.Time
+	Interval   string
+	Location   *time.Location
+	Breakdowns []string
+	Limit      int
+	Bots       string
+}
+
+// ClickBucket is the number of clicks in the bucket starting at Time. Visitors
+// is the estimate of the unique visitors of the day and week buckets.
+type ClickBucket struct {
+	Time     time.Time `json:"time"`
+	Clicks   int       `json:"clicks"`
+	Visitors *uint64   `json:"visitors,omitempty"`
+}
+
+// BreakdownItem is the number of clicks of a value of the dimension.
+type BreakdownItem struct {
+	Value  string `json:"value"`
+	Clicks int    `json:"clicks"`
+}
+
+// ClickStats are the clicks of a shortcut in the range and in the previous
+// period of the same length. The unique visitors are estimated from the daily
+// sketches, so they cover the whole UTC days overlapping the range.
+type ClickStats struct {
+	Total            int                         `json:"total"`
+	PreviousTotal    int                         `json:"previous_total"`
+	Visitors         uint64                      `json:"visitors"`
+	PreviousVisitors uint64                      `json:"previous_visitors"`
+	Series           []*ClickBucket              `json:"series"`
+	PreviousSeries   []*ClickBucket              `json:"previous_series"`
+	Breakdowns       map[string][]*BreakdownItem `json:"breakdowns,omitempty"`
+}
+
+// Validate checks the interval, the breakdowns, the filter of the bots and
+// the number of the buckets of the query. An empty filter of the bots is set
+// to exclude them.
+func (q *ClickStatsQuery) Validate() error {
+	if _, ok := map[string]bool{IntervalMinute: true, IntervalHour: true, IntervalDay: true, IntervalWeek: true}[q.Interval]; !ok {
+		return ErrInvalidInterval
+	}
+
+	if q.Bots == "" {
+		q.Bots = BotsExclude
+	}
+
+	if _, ok := botFilters[q.Bots]; !ok {
+		return ErrInvalidBots
+	}
+
+	for _, b := range q.Breakdowns {
+		if _, ok := breakdownColumns[b]; !ok {
+			return ErrInvalidBreakdown
+		}
+	}
+
+	if !q.From.Before(q.To) || len(Buckets(q.From, q.To, q.Interval, q.Location)) > MaxStatsBuckets {
+		return ErrInvalidStatsRange
+	}
+
+	return nil
+}
+
+// TruncateTime returns the start of the interval containing t in the location.
+func TruncateTime(t time.Time, interval string, loc *time.Location) time.Time {
+	t = t.In(loc)
+	y, m, d := t.Date()
+
+	switch interval {
+	case IntervalMinute:
+		return time.Date(y, m, d, t.Hour(), t.Minute(), 0, 0, loc)
+	case IntervalHour:
+		return time.Date(y, m, d, t.Hour(), 0, 0, 0, loc)
+	case IntervalWeek:
+		// weeks start on Monday
+		return time.Date(y, m, d-(int(t.Weekday())+6)%7, 0, 0, 0, 0, loc)
+	default:
+		return time.Date(y, m, d, 0, 0, 0, 0, loc)
+	}
+}
+
+// nextBucket returns the start of the interval following the one starting at t.
+// The wall clock of the location is followed, so the days are correct across
+// the daylight saving time changes.
+func nextBucket(t time.Time, interval string) time.Time {
+	y, m, d := t.Date()
+	loc := t.Location()
+
+	switch interval {
+	case IntervalMinute:
+		return time.Date(y, m, d, t.Hour(), t.Minute()+1, 0, 0, loc)
+	case IntervalHour:
+		return time.Date(y, m, d, t.Hour()+1, 0, 0, 0, loc)
+	case IntervalWeek:
+		return time.Date(y, m, d+7, 0, 0, 0, 0, loc)
+	default:
+		return time.Date(y, m, d+1, 0, 0, 0, 0, loc)
+	}
+}
+
+// Buckets returns the starts of the buckets covering the range [from, to).
+// At most MaxStatsBuckets+1 buckets are returned.
+func Buckets(from, to time.Time, interval string, loc *time.Location) []time.Time {
+	var bs []time.Time
+
+	for t := TruncateTime(from, interval, loc); t.Before(to) && len(bs) <= MaxStatsBuckets; t = nextBucket(t, interval) {
+		bs = append(bs, t)
+	}
+
+	return bs
+}
+
+// GetClickStats returns the click series of the shortcut bucketed by the
+// interval, the series of the previous period of the same length and the top
+// values of the requested breakdowns.
+func (s *service) GetClickStats(ctx context.Context, q *ClickStatsQuery) (*ClickStats, error) {
+	if _, err := uuid.Parse(q.ShortcutID); err != nil {
+		return nil, ErrInvalidID
+	}
+
+	if err := q.Validate(); err != nil {
+		return nil, err
+	}
+
+	// check record
+	var exists bool
+
+	row := s.DB.QueryRowContext(ctx, `
+SELECT
+  EXISTS (
+    SELECT
+      1
+    FROM
+      shortcuts
+    WHERE
+      shortcut_id = $1
+      AND deleted_at IS NULL
+  );
+  `, q.ShortcutID)
+	if err := row.Scan(&exists); err != nil {
+		return nil, fmt.Errorf("retrieved sql row scan error: %w", err)
+	} else if !exists {
+		return nil, ErrIDNotFound
+	}
+
+	stats := &ClickStats{}
+
+	// current period
+	var err error
+
+	stats.Series, stats.Total, err = s.clickSeries(ctx, q, q.From, q.To)
+	if err != nil {
+		return nil, err
+	}
+
+	// previous period of the same length
+	prevFrom := q.From.Add(-q.To.Sub(q.From))
+
+	stats.PreviousSeries, stats.PreviousTotal, err = s.clickSeries(ctx, q, prevFrom, q.From)
+	if err != nil {
+		return nil, err
+	}
+
+	// unique visitors
+	sketches, err := s.getSketches(ctx, q.ShortcutID, prevFrom, q.To)
+	if err != nil {
+		return nil, err
+	}
+
+	stats.Visitors = sketches.visitors(q.From, q.To)
+	stats.PreviousVisitors = sketches.visitors(prevFrom, q.From)
+
+	if q.Interval == IntervalDay || q.Interval == IntervalWeek {
+		for _, series := range [][]*ClickBucket{stats.Series, stats.PreviousSeries} {
+			for _, b := range series {
+				v := sketches.visitors(b.Time, nextBucket(b.Time, q.Interval))
+				b.Visitors = &v
+			}
+		}
+	}
+
+	// breakdowns
+	if len(q.Breakdowns) > 0 {
+		stats.Breakdowns = make(map[string][]*BreakdownItem, len(q.Breakdowns))
+	}
+
+	for _, b := range q.Breakdowns {
+		if stats.Breakdowns[b], err = s.clickBreakdown(ctx, q, b); err != nil {
+			return nil, err
+		}
+	}
+
+	return stats, nil
+}
+
+// clickSeries returns the zero-filled buckets of the clicks in the range and
+// their total. The clicks which are rolled up are counted in the bucket of
+// their hour or day, so the minute buckets are exact only within the raw
+// retention.
+func (s *service) clickSeries(ctx context.Context, q *ClickStatsQuery, from, to time.Time) ([]*ClickBucket, int, error) {
+	segs, err := s.segments(ctx, from, to)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	source, sourceArgs := clickSource(segs, 4)
+
+	rows, err := s.DB.QueryxContext(ctx, fmt.Sprintf(`
+SELECT
+  DATE_TRUNC($2, c.t AT TIME ZONE 'UTC' AT TIME ZONE $3) AS bucket,
+  SUM(c.clicks)
+FROM
+  (%s) AS c
+WHERE
+  c.shortcut_id = $1
+  AND %s
+GROUP BY
+  bucket;
+  `, source, botFilters[q.Bots]), append([]interface{}{q.ShortcutID, q.Interval, q.Location.String()}, sourceArgs...)...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("unexpected query error: %w", err)
+	}
+
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	// counts by the wall clock of the bucket start
+	counts := make(map[string]int)
+
+	for rows.Next() {
+		var (
+			bucket time.Time
+			n      int
+		)
+
+		if err = rows.Scan(&bucket, &n); err != nil {
+			return nil, 0, fmt.Errorf("retrieved sql row scan error: %w", err)
+		}
+
+		counts[wallClock(bucket)] += n
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("unexpected rows error: %w", err)
+	}
+
+	// fill the buckets
+	var total int
+
+	starts := Buckets(from, to, q.Interval, q.Location)
+	series := make([]*ClickBucket, len(starts))
+
+	for i, t := range starts {
+		n := counts[wallClock(t)]
+		series[i] = &ClickBucket{Time: t, Clicks: n}
+		total += n
+	}
+
+	return series, total, nil
+}
+
+// wallClock formats the date and time of t without its location.
+func wallClock(t time.Time) string {
+	return t.Format("2006-01-02T15:04")
+}
+
+// clickBreakdown returns the most frequent values of the dimension in the range.
+func (s *service) clickBreakdown(ctx context.Context, q *ClickStatsQuery, dimension string) ([]*BreakdownItem, error) {
+	segs, err := s.segments(ctx, q.From, q.To)
+	if err != nil {
+		return nil, err
+	}
+
+	source, sourceArgs := clickSource(segs, 3)
+
+	// the column and the filter are known expressions, never the user input
+	rows, err := s.DB.QueryxContext(ctx, fmt.Sprintf(`
+SELECT
+  COALESCE(c.%s, '') AS value,
+  SUM(c.clicks) AS clicks
+FROM
+  (%s) AS c
+WHERE
+  c.shortcut_id = $1
+  AND %s
+GROUP BY
+  value
+ORDER BY
+  clicks DESC,
+  value
+LIMIT
+  $2;
+  `, breakdownColumns[dimension], source, botFilters[q.Bots]), append([]interface{}{q.ShortcutID, q.Limit}, sourceArgs...)...)
+	if err != nil {
+		return nil, fmt.Errorf("unexpected query error: %w", err)
+	}
+
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	items := []*BreakdownItem{}
+
+	for rows.Next() {
+		var item BreakdownItem
+		if err = rows.Scan(&item.Value, &item.Clicks); err != nil {
+			return nil, fmt.Errorf("retrieved sql row scan error: %w", err)
+		}
+
+		item.Value = strings.TrimSpace(item.Value)
+		if item.Value == "" {
+			item.Value = breakdownUnknown
+			if dimension == BreakdownReferrer {
+				item.Value = breakdownDirect
+			}
+		}
+
+		items = append(items, &item)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("unexpected rows error: %w", err)
+	}
+
+	return items, nil
+}
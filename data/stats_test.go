@@ -0,0 +1,113 @@
+package data_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/chutommy/url-shortener/data"
+	"github.com/stretchr/testify/assert"
+)
+
+func mustLoadLocation(t *testing.T, name string) *time.Location {
+	t.Helper()
+
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		t.Skipf("time zone %s is not available: %v", name, err)
+	}
+
+	return loc
+}
+
+func TestTruncateTime(t *testing.T) {
+	prague := mustLoadLocation(t, "Europe/Prague")
+	ts := time.Date(2024, 3, 14, 22, 47, 31, 0, time.UTC) // Thursday, 23:47 in Prague
+
+	tests := []struct {
+		interval string
+		loc      *time.Location
+		exp      time.Time
+	}{
+		{data.IntervalMinute, time.UTC, time.Date(2024, 3, 14, 22, 47, 0, 0, time.UTC)},
+		{data.IntervalHour, time.UTC, time.Date(2024, 3, 14, 22, 0, 0, 0, time.UTC)},
+		{data.IntervalDay, time.UTC, time.Date(2024, 3, 14, 0, 0, 0, 0, time.UTC)},
+		{data.IntervalDay, prague, time.Date(2024, 3, 14, 0, 0, 0, 0, prague)},
+		{data.IntervalWeek, time.UTC, time.Date(2024, 3, 11, 0, 0, 0, 0, time.UTC)},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.interval+" "+tc.loc.String(), func(t *testing.T) {
+			assert.True(t, tc.exp.Equal(data.TruncateTime(ts, tc.interval, tc.loc)))
+		})
+	}
+}
+
+func TestBuckets(t *testing.T) {
+	prague := mustLoadLocation(t, "Europe/Prague")
+
+	// days across the daylight saving time change are not 24 hours long
+	from := time.Date(2024, 3, 30, 12, 0, 0, 0, prague)
+	to := time.Date(2024, 4, 1, 12, 0, 0, 0, prague)
+
+	bs := data.Buckets(from, to, data.IntervalDay, prague)
+	if assert.Len(t, bs, 3) {
+		assert.Equal(t, time.Date(2024, 3, 31, 0, 0, 0, 0, prague), bs[1])
+		assert.Equal(t, 23*time.Hour, bs[2].Sub(bs[1]))
+	}
+
+	// hours skip the missing hour
+	from = time.Date(2024, 3, 31, 1, 0, 0, 0, prague)
+	to = time.Date(2024, 3, 31, 4, 0, 0, 0, prague)
+	assert.Len(t, data.Buckets(from, to, data.IntervalHour, prague), 2)
+}
+
+func TestClickStatsQuery_Validate(t *testing.T) {
+	to := time.Date(2024, 3, 14, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name string
+		q    data.ClickStatsQuery
+		err  error
+	}{
+		{
+			name: "valid",
+			q: data.ClickStatsQuery{From: to.AddDate(0, 0, -7), To: to, Interval: data.IntervalDay,
+				Location: time.UTC, Breakdowns: []string{data.BreakdownReferrer, data.BreakdownCountry}},
+		},
+		{
+			name: "unknown interval",
+			q:    data.ClickStatsQuery{From: to.AddDate(0, 0, -7), To: to, Interval: "month", Location: time.UTC},
+			err:  data.ErrInvalidInterval,
+		},
+		{
+			name: "unknown breakdown",
+			q: data.ClickStatsQuery{From: to.AddDate(0, 0, -7), To: to, Interval: data.IntervalDay,
+				Location: time.UTC, Breakdowns: []string{"full_url"}},
+			err: data.ErrInvalidBreakdown,
+		},
+		{
+			name: "unknown bots filter",
+			q: data.ClickStatsQuery{From: to.AddDate(0, 0, -7), To: to, Interval: data.IntervalDay,
+				Location: time.UTC, Bots: "some"},
+			err: data.ErrInvalidBots,
+		},
+		{
+			name: "empty range",
+			q:    data.ClickStatsQuery{From: to, To: to, Interval: data.IntervalDay, Location: time.UTC},
+			err:  data.ErrInvalidStatsRange,
+		},
+		{
+			name: "too many buckets",
+			q:    data.ClickStatsQuery{From: to.AddDate(0, 0, -7), To: to, Interval: data.IntervalMinute, Location: time.UTC},
+			err:  data.ErrInvalidStatsRange,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.q.Validate()
+			assert.True(t, errors.Is(err, tc.err), "expected %v; got %v", tc.err, err)
+		})
+	}
+}
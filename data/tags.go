@@ -0,0 +1,43 @@
+package data
+
+import (
+	"errors"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+const maxTags = 20
+
+// ErrInvalidTags is returned if the record has too many tags or a tag has an invalid format.
+var ErrInvalidTags = errors.New(
+	"tags must be at most 20 lowercase words of letters, digits, '-' or '_' up to 32 characters long")
+
+var tagPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,31}$`)
+
+// normalizeTags lowercases, deduplicates and sorts the tags. The result is
+// never nil, so it can be stored in the non-null tags column.
+func normalizeTags(tags []string) ([]string, error) {
+	seen := make(map[string]bool, len(tags))
+	norm := make([]string, 0, len(tags))
+
+	for _, t := range tags {
+		t = strings.ToLower(strings.TrimSpace(t))
+		if !tagPattern.MatchString(t) {
+			return nil, ErrInvalidTags
+		}
+
+		if !seen[t] {
+			seen[t] = true
+			norm = append(norm, t)
+		}
+	}
+
+	if len(norm) > maxTags {
+		return nil, ErrInvalidTags
+	}
+
+	sort.Strings(norm)
+
+	return norm, nil
+}
@@ -0,0 +1,33 @@
+package data
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalizeTags(t *testing.T) {
+	tests := []struct {
+		name string
+		tags []string
+		exp  []string
+		err  error
+	}{
+		{name: "nil", tags: nil, exp: []string{}},
+		{name: "normalized", tags: []string{" Spring-Sale", "newsletter", "spring-sale"}, exp: []string{"newsletter", "spring-sale"}},
+		{name: "space", tags: []string{"spring sale"}, err: ErrInvalidTags},
+		{name: "empty", tags: []string{""}, err: ErrInvalidTags},
+		{name: "too long", tags: []string{strings.Repeat("a", 33)}, err: ErrInvalidTags},
+		{name: "too many", tags: strings.Split("a,b,c,d,e,f,g,h,i,j,k,l,m,n,o,p,q,r,s,t,u", ","), err: ErrInvalidTags},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			tags, err := normalizeTags(tc.tags)
+			assert.True(t, errors.Is(err, tc.err), "expected %v; got %v", tc.err, err)
+			assert.Equal(t, tc.exp, tags)
+		})
+	}
+}
@@ -0,0 +1,182 @@
+package data
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/chutommy/url-shortener/config"
+)
+
+const (
+	tokenIssuer   = "url-shortener"
+	tokenAlg      = "HS256"
+	tokenType     = "JWT"
+	tokenParts    = 3
+	refreshSecLen = 32
+
+	// tokenLeeway tolerates the clocks of the instances running slightly behind the issuer.
+	tokenLeeway = 30 * time.Second
+)
+
+// tokenHeader is the JOSE header of the session tokens.
+type tokenHeader struct {
+	Alg string `json:"alg"`
+	Typ string `json:"typ"`
+	Kid string `json:"kid"`
+}
+
+// tokenClaims are the claims of the session tokens.
+type tokenClaims struct {
+	Issuer    string `json:"iss"`
+	Subject   string `json:"sub"`
+	SessionID string `json:"sid"`
+	Role      string `json:"role"`
+	IssuedAt  int64  `json:"iat"`
+	NotBefore int64  `json:"nbf"`
+	ExpiresAt int64  `json:"exp"`
+}
+
+// tokenSigner signs and verifies HS256 JSON Web Tokens. New tokens are signed
+// by the active key, all of the keys are accepted for the verification.
+type tokenSigner struct {
+	active config.SigningKey
+	keys   map[string][]byte
+
+	ttl        time.Duration
+	refreshTTL time.Duration
+}
+
+// newTokenSigner constructs a tokenSigner from the session configuration.
+func newTokenSigner(cfg *config.Session) (*tokenSigner, error) {
+	ttl, refreshTTL, err := cfg.Lifetimes()
+	if err != nil {
+		return nil, err
+	}
+
+	active, ok := cfg.ActiveKey()
+	if !ok {
+		return nil, ErrSessionsDisabled
+	}
+
+	keys := make(map[string][]byte, len(cfg.Keys))
+	for _, k := range cfg.Keys {
+		keys[k.ID] = k.Secret
+	}
+
+	return &tokenSigner{
+		active:     active,
+		keys:       keys,
+		ttl:        ttl,
+		refreshTTL: refreshTTL,
+	}, nil
+}
+
+// sign creates a signed token with the given claims.
+func (ts *tokenSigner) sign(claims *tokenClaims) (string, error) {
+	header, err := json.Marshal(tokenHeader{
+		Alg: tokenAlg,
+		Typ: tokenType,
+		Kid: ts.active.ID,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to encode token header: %w", err)
+	}
+
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode token claims: %w", err)
+	}
+
+	unsigned := b64(header) + "." + b64(payload)
+
+	return unsigned + "." + b64(hmacSum(ts.active.Secret, unsigned)), nil
+}
+
+// verify checks the signature and the validity period of the token and returns
+// its claims. ErrUnauthorized is returned for any invalid token.
+func (ts *tokenSigner) verify(token string, now time.Time) (*tokenClaims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != tokenParts {
+		return nil, ErrUnauthorized
+	}
+
+	// decode header
+	var header tokenHeader
+	if err := decodeSegment(parts[0], &header); err != nil || header.Alg != tokenAlg {
+		return nil, ErrUnauthorized
+	}
+
+	// verify signature
+	secret, ok := ts.keys[header.Kid]
+	if !ok {
+		return nil, ErrUnauthorized
+	}
+
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil || !hmac.Equal(sig, hmacSum(secret, parts[0]+"."+parts[1])) {
+		return nil, ErrUnauthorized
+	}
+
+	// validate claims
+	var claims tokenClaims
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, ErrUnauthorized
+	}
+
+	if claims.Issuer != tokenIssuer || claims.SessionID == "" || now.Unix() >= claims.ExpiresAt ||
+		now.Add(tokenLeeway).Unix() < claims.NotBefore {
+		return nil, ErrUnauthorized
+	}
+
+	return &claims, nil
+}
+
+// genRefreshToken generates a refresh token of the session with the given id
+// and returns the token together with a hash of its secret part.
+func genRefreshToken(sessionID string) (string, string, error) {
+	secret := make([]byte, refreshSecLen)
+	if _, err := rand.Read(secret); err != nil {
+		return "", "", fmt.Errorf("failed to generate refresh token: %w", err)
+	}
+
+	s := b64(secret)
+
+	return sessionID + "." + s, hashSecret(s), nil
+}
+
+// hashSecret returns a hex encoded SHA-256 hash of the secret.
+func hashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+
+	return hex.EncodeToString(sum[:])
+}
+
+// hmacSum returns the HMAC-SHA256 of the message.
+func hmacSum(secret []byte, msg string) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(msg))
+
+	return mac.Sum(nil)
+}
+
+// decodeSegment decodes a base64url encoded JSON segment of a token.
+func decodeSegment(seg string, v interface{}) error {
+	raw, err := base64.RawURLEncoding.DecodeString(seg)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(raw, v)
+}
+
+// b64 encodes bytes with unpadded base64url encoding.
+func b64(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
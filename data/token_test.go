@@ -0,0 +1,133 @@
+package data
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/chutommy/url-shortener/config"
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	oldKey = config.SigningKey{ID: "2023", Secret: []byte("old-secret-of-the-session-tokens")}
+	newKey = config.SigningKey{ID: "2024", Secret: []byte("new-secret-of-the-session-tokens")}
+)
+
+// testSigner returns a signer of the active key which accepts all keys.
+func testSigner(active config.SigningKey, keys ...config.SigningKey) *tokenSigner {
+	ts := &tokenSigner{active: active, keys: map[string][]byte{active.ID: active.Secret}}
+	for _, k := range keys {
+		ts.keys[k.ID] = k.Secret
+	}
+
+	return ts
+}
+
+// forge signs the header and the claims with the secret.
+func forge(header tokenHeader, claims *tokenClaims, secret []byte) string {
+	h, _ := json.Marshal(header)
+	c, _ := json.Marshal(claims)
+	unsigned := b64(h) + "." + b64(c)
+
+	return unsigned + "." + b64(hmacSum(secret, unsigned))
+}
+
+func TestTokenSigner(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+
+	claims := func() *tokenClaims {
+		return &tokenClaims{
+			Issuer:    tokenIssuer,
+			Subject:   "admin",
+			SessionID: "d5e3b3e4-5f4c-4c0e-9d0a-3b1a8a1f2c3d",
+			Role:      RoleAdmin,
+			IssuedAt:  now.Unix(),
+			NotBefore: now.Unix(),
+			ExpiresAt: now.Add(15 * time.Minute).Unix(),
+		}
+	}
+
+	header := tokenHeader{Alg: tokenAlg, Typ: tokenType, Kid: newKey.ID}
+	verifier := testSigner(newKey, oldKey)
+
+	signed, err := verifier.sign(claims())
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	parts := strings.Split(signed, ".")
+
+	tests := []struct {
+		name  string
+		token string
+		now   time.Time
+		ok    bool
+	}{
+		{name: "valid", token: signed, now: now, ok: true},
+		{name: "signed by the rotated key", now: now, ok: true,
+			token: forge(tokenHeader{Alg: tokenAlg, Typ: tokenType, Kid: oldKey.ID}, claims(), oldKey.Secret)},
+		{name: "just before the expiration", token: signed, now: now.Add(15*time.Minute - time.Second), ok: true},
+		{name: "at the expiration", token: signed, now: now.Add(15 * time.Minute)},
+		{name: "after the expiration", token: signed, now: now.Add(time.Hour)},
+		{name: "clock behind within leeway", token: signed, now: now.Add(-tokenLeeway), ok: true},
+		{name: "before not before", token: signed, now: now.Add(-tokenLeeway - time.Second)},
+		{name: "tampered signature", now: now,
+			token: parts[0] + "." + parts[1] + "." + b64(hmacSum([]byte("guessed"), parts[0]+"."+parts[1]))},
+		{name: "tampered claims", now: now, token: func() string {
+			c := claims()
+			c.Role = "owner"
+			forged := strings.Split(forge(header, c, []byte("guessed")), ".")
+
+			return parts[0] + "." + forged[1] + "." + parts[2]
+		}()},
+		{name: "no signature", token: parts[0] + "." + parts[1] + ".", now: now},
+		{name: "alg none", now: now, token: func() string {
+			forged := strings.Split(forge(tokenHeader{Alg: "none", Typ: tokenType, Kid: newKey.ID}, claims(), nil), ".")
+
+			return forged[0] + "." + forged[1] + "."
+		}()},
+		{name: "alg HS512", now: now,
+			token: forge(tokenHeader{Alg: "HS512", Typ: tokenType, Kid: newKey.ID}, claims(), newKey.Secret)},
+		{name: "unknown kid", now: now,
+			token: forge(tokenHeader{Alg: tokenAlg, Typ: tokenType, Kid: "2025"}, claims(), newKey.Secret)},
+		{name: "removed key", now: now,
+			token: forge(tokenHeader{Alg: tokenAlg, Typ: tokenType, Kid: "2022"}, claims(), []byte("removed"))},
+		{name: "other issuer", now: now, token: func() string {
+			c := claims()
+			c.Issuer = "other"
+
+			return forge(header, c, newKey.Secret)
+		}()},
+		{name: "no session", now: now, token: func() string {
+			c := claims()
+			c.SessionID = ""
+
+			return forge(header, c, newKey.Secret)
+		}()},
+		{name: "malformed", token: "not.a-token", now: now},
+		{name: "empty", token: "", now: now},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := verifier.verify(tc.token, tc.now)
+			if !tc.ok {
+				assert.True(t, errors.Is(err, ErrUnauthorized), err)
+
+				return
+			}
+
+			if assert.NoError(t, err) {
+				assert.Equal(t, "admin", got.Subject)
+			}
+		})
+	}
+
+	// the tokens of a removed key are rejected
+	_, err = testSigner(newKey).verify(forge(tokenHeader{Alg: tokenAlg, Typ: tokenType, Kid: oldKey.ID}, claims(),
+		oldKey.Secret), now)
+	assert.True(t, errors.Is(err, ErrUnauthorized), err)
+}
@@ -0,0 +1,168 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Windows of the leaderboards.
+const (
+	WindowHour = "hour"
+	WindowDay  = "day"
+	WindowWeek = "week"
+)
+
+// Orders of the leaderboards.
+const (
+	SortClicks   = "clicks"
+	SortTrending = "trending"
+)
+
+// trendingBaselineWindows is the number of the windows preceding the current
+// one whose average clicks are the baseline of the trending score.
+const trendingBaselineWindows = 7
+
+var windowDurations = map[string]time.Duration{
+	WindowHour: time.Hour,
+	WindowDay:  24 * time.Hour,
+	WindowWeek: 7 * 24 * time.Hour,
+}
+
+// sortExpressions maps the orders to the expressions of the leaderboard query.
+var sortExpressions = map[string]string{
+	SortClicks:   "clicks DESC, score DESC",
+	SortTrending: "score DESC, clicks DESC",
+}
+
+var (
+	// ErrInvalidWindow is returned if the window of the leaderboard is not supported.
+	ErrInvalidWindow = errors.New("window must be hour, day or week")
+	// ErrInvalidSort is returned if the order of the leaderboard is not supported.
+	ErrInvalidSort = errors.New("sort must be clicks or trending")
+)
+
+// TopQuery selects the shortcuts with the most clicks in the window ending at Now.
+// The shortcuts can be filtered by a Tag and by the Domain of their full url,
+// which includes its subdomains.
+type TopQuery struct {
+	Window string
+	Sort   string
+	Tag    string
+	Domain string
+	Limit  int
+	Now    time.Time
+}
+
+// TopRecord is a shortcut of the leaderboard. Baseline is the average of the
+// clicks in the preceding windows and TrendingScore the deviation of the
+// clicks from the baseline, (clicks - baseline) / sqrt(baseline + 1), so a
+// sudden rise of a popular shortcut outweighs a few clicks of a new one.
+type TopRecord struct {
+	ShortRecord
+	Clicks        int     `json:"clicks"`
+	Baseline      float64 `json:"baseline"`
+	TrendingScore float64 `json:"trending_score"`
+}
+
+// GetTopRecords returns the leaderboard of the active shortcuts clicked by the
+// humans in the window, the clicks of the bots are excluded.
+func (s *service) GetTopRecords(ctx context.Context, q *TopQuery) ([]*TopRecord, error) {
+	window, ok := windowDurations[q.Window]
+	if !ok {
+		return nil, ErrInvalidWindow
+	}
+
+	order, ok := sortExpressions[q.Sort]
+	if !ok {
+		return nil, ErrInvalidSort
+	}
+
+	start := q.Now.Add(-window)
+	baselineStart := start.Add(-trendingBaselineWindows * window)
+
+	segs, err := s.segments(ctx, baselineStart, q.Now)
+	if err != nil {
+		return nil, err
+	}
+
+	source, sourceArgs := clickSource(segs, 6)
+
+	// the order is one of the known expressions, never the user input
+	rows, err := s.DB.QueryxContext(ctx, fmt.Sprintf(`
+WITH windows AS (
+  SELECT
+    c.shortcut_id,
+    COALESCE(SUM(c.clicks) FILTER (WHERE c.t >= $1), 0) AS clicks,
+    COALESCE(SUM(c.clicks) FILTER (WHERE c.t < $1), 0)::FLOAT8 / $2 AS baseline
+  FROM
+    (%s) AS c
+  WHERE
+    NOT c.is_bot
+  GROUP BY
+    c.shortcut_id
+)
+SELECT
+  s.shortcut_id,
+  s.full_url,
+  s.short_url,
+  s.usage,
+  s.tags,
+  w.clicks,
+  w.baseline,
+  (w.clicks - w.baseline) / SQRT(w.baseline + 1) AS score
+FROM
+  windows AS w
+  JOIN shortcuts AS s ON s.shortcut_id = w.shortcut_id
+  CROSS JOIN LATERAL (
+    SELECT
+      SUBSTRING(LOWER(s.full_url) FROM '^(?:[a-z][a-z0-9+.-]*://)?(?:[^@/]*@)?([^/:?#]+)') AS host
+  ) AS u
+WHERE
+  s.deleted_at IS NULL
+  AND w.clicks > 0
+  AND ($3 = '' OR $3 = ANY (s.tags))
+  AND (
+    $4 = ''
+    OR u.host = $4
+    OR RIGHT(u.host, LENGTH($4) + 1) = '.' || $4
+  )
+ORDER BY
+  %s,
+  s.short_url
+LIMIT
+  $5;
+  `, source, order), append([]interface{}{start.UTC(), trendingBaselineWindows,
+		strings.ToLower(q.Tag), strings.ToLower(strings.TrimSpace(q.Domain)), q.Limit}, sourceArgs...)...)
+	if err != nil {
+		return nil, fmt.Errorf("unexpected query error: %w", err)
+	}
+
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	// scan rows
+	top := []*TopRecord{}
+
+	for rows.Next() {
+		var r TopRecord
+
+		err = rows.Scan(&r.ID, &r.Full, &r.Short, &r.Usage, pq.Array(&r.Tags), &r.Clicks, &r.Baseline, &r.TrendingScore)
+		if err != nil {
+			return nil, fmt.Errorf("retrieved sql row scan error: %w", err)
+		}
+
+		top = append(top, &r)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("unexpected rows error: %w", err)
+	}
+
+	return top, nil
+}
@@ -0,0 +1,35 @@
+package data
+
+import (
+	"context"
+	"database/sql/driver"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetTopRecords_Domain(t *testing.T) {
+	s, db := newMockDB(t)
+	db.ExpectQuery(`FROM\s+rollup_state`).WillReturnRows([]string{"name", "until"})
+
+	// the host is compared case-insensitively and by the whole labels, the
+	// domain is never used as a LIKE pattern
+	db.ExpectQuery(`SUBSTRING\(LOWER\(s\.full_url\) FROM [\s\S]+OR u\.host = \$4\s+`+
+		`OR RIGHT\(u\.host, LENGTH\(\$4\) \+ 1\) = '\.' \|\| \$4\s+\)`).
+		WithArgs(anyArg{}, anyArg{}, "", "example.com", int64(10), anyArg{}, anyArg{}).
+		WillReturnRows([]string{"shortcut_id", "full_url", "short_url", "usage", "tags", "clicks", "baseline", "score"},
+			[]driver.Value{int64(1), "HTTP://WWW.Example.com/a", "a", int64(3), "{}", int64(3), 0.0, 3.0})
+
+	top, err := s.GetTopRecords(context.Background(), &TopQuery{
+		Window: WindowDay,
+		Sort:   SortClicks,
+		Domain: " Example.COM ",
+		Limit:  10,
+		Now:    time.Date(2024, time.March, 15, 12, 0, 0, 0, time.UTC),
+	})
+	if assert.NoError(t, err) && assert.Len(t, top, 1) {
+		assert.Equal(t, "a", top[0].Short)
+		assert.Equal(t, 3, top[0].Clicks)
+	}
+}
@@ -0,0 +1,396 @@
+package data
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1" //nolint:gosec
+	"database/sql"
+	"encoding/base32"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	totpIssuer    = "url-shortener"
+	totpSecretLen = 20
+	totpPeriod    = 30
+	totpDigits    = 6
+	totpSkew      = 1
+
+	recoveryCodes    = 10
+	recoveryCodeLen  = 10
+	recoveryAlphabet = "abcdefghjkmnpqrstuvwxyz23456789"
+)
+
+var (
+	// ErrTOTPEnabled is returned if an enrolment of an already enabled TOTP is requested.
+	ErrTOTPEnabled = errors.New("two-factor authentication is already enabled")
+	// ErrTOTPNotEnrolled is returned if no pending TOTP enrolment can be found.
+	ErrTOTPNotEnrolled = errors.New("two-factor authentication is not enrolled")
+)
+
+var b32 = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// TOTPEnrolment holds a newly provisioned TOTP secret of an admin user.
+// The secret must be confirmed by a valid code before it is enforced.
+type TOTPEnrolment struct {
+	Secret        string   `json:"secret"`
+	URI           string   `json:"otpauth_uri"`
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+// EnrollTOTP provisions a new TOTP secret and recovery codes of the admin user.
+// Any previous pending enrolment is replaced.
+func (s *service) EnrollTOTP(ctx context.Context, username string) (*TOTPEnrolment, error) {
+	// generate secret
+	raw := make([]byte, totpSecretLen)
+	if _, err := rand.Read(raw); err != nil {
+		return nil, fmt.Errorf("failed to generate totp secret: %w", err)
+	}
+
+	secret := b32.EncodeToString(raw)
+
+	codes, err := genRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+
+	// store secret and codes
+	tx, err := s.DB.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	res, err := tx.ExecContext(ctx, `
+INSERT INTO
+  admin_totp (username, secret, created_at)
+VALUES
+  ($1, $2, $3)
+ON CONFLICT (username) DO UPDATE
+SET
+  secret = EXCLUDED.secret,
+  created_at = EXCLUDED.created_at,
+  last_used_step = 0
+WHERE
+  admin_totp.enabled_at IS NULL;
+  `, username, secret, time.Now().UTC())
+	if err != nil {
+		return nil, fmt.Errorf("insert failure: %w", err)
+	}
+
+	if i, _ := res.RowsAffected(); i == 0 {
+		return nil, ErrTOTPEnabled
+	}
+
+	if _, err = tx.ExecContext(ctx, `
+DELETE FROM
+  admin_recovery_codes
+WHERE
+  username = $1;
+  `, username); err != nil {
+		return nil, fmt.Errorf("delete failure: %w", err)
+	}
+
+	for _, code := range codes {
+		if _, err = tx.ExecContext(ctx, `
+INSERT INTO
+  admin_recovery_codes (username, code_hash)
+VALUES
+  ($1, $2);
+  `, username, hashSecret(strings.ReplaceAll(code, "-", ""))); err != nil {
+			return nil, fmt.Errorf("insert failure: %w", err)
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return &TOTPEnrolment{
+		Secret:        secret,
+		URI:           totpURI(username, secret),
+		RecoveryCodes: codes,
+	}, nil
+}
+
+// ConfirmTOTP enables the pending TOTP enrolment of the admin user if the code is valid.
+func (s *service) ConfirmTOTP(ctx context.Context, username string, code string) error {
+	// query pending secret
+	row := s.DB.QueryRowxContext(ctx, `
+SELECT
+  secret
+FROM
+  admin_totp
+WHERE
+  username = $1
+  AND enabled_at IS NULL;
+  `, username)
+
+	var secret string
+	if err := row.Scan(&secret); errors.Is(err, sql.ErrNoRows) {
+		return ErrTOTPNotEnrolled
+	} else if err != nil {
+		return fmt.Errorf("retrieved sql row scan error: %w", err)
+	}
+
+	step, ok := checkTOTP(secret, code, time.Now())
+	if !ok {
+		return ErrUnauthorized
+	}
+
+	// enable
+	res, err := s.DB.ExecContext(ctx, `
+UPDATE
+  admin_totp
+SET
+  enabled_at = $3,
+  last_used_step = $2
+WHERE
+  username = $1
+  AND enabled_at IS NULL;
+  `, username, step, time.Now().UTC())
+	if err != nil {
+		return fmt.Errorf("update failure: %w", err)
+	}
+
+	if i, _ := res.RowsAffected(); i == 0 {
+		return ErrTOTPNotEnrolled
+	}
+
+	return nil
+}
+
+// TOTPEnabled reports whether the admin user has an enabled TOTP.
+func (s *service) TOTPEnabled(ctx context.Context, username string) (bool, error) {
+	row := s.DB.QueryRowxContext(ctx, `
+SELECT
+  COUNT(*)
+FROM
+  admin_totp
+WHERE
+  username = $1
+  AND enabled_at IS NOT NULL;
+  `, username)
+
+	var count int
+	if err := row.Scan(&count); err != nil {
+		return false, fmt.Errorf("retrieved sql row scan error: %w", err)
+	}
+
+	return count > 0, nil
+}
+
+// VerifyTOTP validates a TOTP code or an unused recovery code of the admin user.
+// Every TOTP code is accepted only once. ErrUnauthorized is returned if the code is invalid.
+func (s *service) VerifyTOTP(ctx context.Context, username string, code string) error {
+	code = strings.TrimSpace(code)
+
+	// recovery code
+	if len(code) != totpDigits {
+		return s.useRecoveryCode(ctx, username, code)
+	}
+
+	// query secret
+	row := s.DB.QueryRowxContext(ctx, `
+SELECT
+  secret
+FROM
+  admin_totp
+WHERE
+  username = $1
+  AND enabled_at IS NOT NULL;
+  `, username)
+
+	var secret string
+	if err := row.Scan(&secret); errors.Is(err, sql.ErrNoRows) {
+		return ErrTOTPNotEnrolled
+	} else if err != nil {
+		return fmt.Errorf("retrieved sql row scan error: %w", err)
+	}
+
+	step, ok := checkTOTP(secret, code, time.Now())
+	if !ok {
+		return ErrUnauthorized
+	}
+
+	// prevent a replay of the code
+	res, err := s.DB.ExecContext(ctx, `
+UPDATE
+  admin_totp
+SET
+  last_used_step = $2
+WHERE
+  username = $1
+  AND last_used_step < $2;
+  `, username, step)
+	if err != nil {
+		return fmt.Errorf("update failure: %w", err)
+	}
+
+	if i, _ := res.RowsAffected(); i == 0 {
+		return ErrUnauthorized
+	}
+
+	return nil
+}
+
+// DisableTOTP removes the TOTP secret and recovery codes of the admin user.
+func (s *service) DisableTOTP(ctx context.Context, username string) (err error) {
+	tx, err := s.DB.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	res, err := tx.ExecContext(ctx, `
+DELETE FROM
+  admin_totp
+WHERE
+  username = $1;
+  `, username)
+	if err != nil {
+		return fmt.Errorf("delete failure: %w", err)
+	}
+
+	if i, _ := res.RowsAffected(); i == 0 {
+		return ErrTOTPNotEnrolled
+	}
+
+	if _, err = tx.ExecContext(ctx, `
+DELETE FROM
+  admin_recovery_codes
+WHERE
+  username = $1;
+  `, username); err != nil {
+		return fmt.Errorf("delete failure: %w", err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// useRecoveryCode marks an unused recovery code of the admin user as used.
+func (s *service) useRecoveryCode(ctx context.Context, username string, code string) error {
+	code = strings.ToLower(strings.ReplaceAll(code, "-", ""))
+
+	res, err := s.DB.ExecContext(ctx, `
+UPDATE
+  admin_recovery_codes
+SET
+  used_at = $3
+WHERE
+  username = $1
+  AND code_hash = $2
+  AND used_at IS NULL;
+  `, username, hashSecret(code), time.Now().UTC())
+	if err != nil {
+		return fmt.Errorf("update failure: %w", err)
+	}
+
+	if i, _ := res.RowsAffected(); i == 0 {
+		return ErrUnauthorized
+	}
+
+	return nil
+}
+
+// checkTOTP validates the code against the secret at the given time with a tolerance
+// of totpSkew periods and returns the time step the code belongs to.
+func checkTOTP(secret string, code string, t time.Time) (int64, bool) {
+	key, err := b32.DecodeString(secret)
+	if err != nil || len(code) != totpDigits {
+		return 0, false
+	}
+
+	step := t.Unix() / totpPeriod
+	for i := int64(-totpSkew); i <= totpSkew; i++ {
+		if hmac.Equal([]byte(hotp(key, step+i)), []byte(code)) {
+			return step + i, true
+		}
+	}
+
+	return 0, false
+}
+
+// hotp computes an HOTP value (RFC 4226) of the counter.
+func hotp(key []byte, counter int64) string {
+	var msg [8]byte
+
+	binary.BigEndian.PutUint64(msg[:], uint64(counter))
+
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+
+	// dynamic truncation
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+
+	return fmt.Sprintf("%0*d", totpDigits, value%1000000)
+}
+
+// totpURI returns an otpauth URI of the secret for provisioning of authenticator apps.
+func totpURI(username string, secret string) string {
+	q := url.Values{}
+	q.Set("secret", secret)
+	q.Set("issuer", totpIssuer)
+	q.Set("algorithm", "SHA1")
+	q.Set("digits", fmt.Sprint(totpDigits))
+	q.Set("period", fmt.Sprint(totpPeriod))
+
+	u := url.URL{
+		Scheme:   "otpauth",
+		Host:     "totp",
+		Path:     "/" + totpIssuer + ":" + username,
+		RawQuery: q.Encode(),
+	}
+
+	return u.String()
+}
+
+// genRecoveryCodes generates single-use recovery codes in the xxxxx-xxxxx format.
+func genRecoveryCodes() ([]string, error) {
+	codes := make([]string, recoveryCodes)
+	code := make([]byte, 0, recoveryCodeLen)
+	buf := make([]byte, recoveryCodeLen)
+
+	// bytes over the largest multiple of the alphabet size are rejected to avoid a modulo bias
+	limit := 256 - 256%len(recoveryAlphabet)
+
+	for i := range codes {
+		code = code[:0]
+
+		for len(code) < recoveryCodeLen {
+			if _, err := rand.Read(buf); err != nil {
+				return nil, fmt.Errorf("failed to generate recovery code: %w", err)
+			}
+
+			for _, b := range buf {
+				if int(b) < limit && len(code) < recoveryCodeLen {
+					code = append(code, recoveryAlphabet[int(b)%len(recoveryAlphabet)])
+				}
+			}
+		}
+
+		codes[i] = string(code[:recoveryCodeLen/2]) + "-" + string(code[recoveryCodeLen/2:])
+	}
+
+	return codes, nil
+}
@@ -0,0 +1,229 @@
+package data
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// rfcSecret is the secret of the test vectors of RFC 4226 and RFC 6238.
+var rfcSecret = b32.EncodeToString([]byte("12345678901234567890"))
+
+func TestHOTP(t *testing.T) {
+	// RFC 4226, Appendix D
+	exp := []string{
+		"755224", "287082", "359152", "969429", "338314",
+		"254676", "287922", "162583", "399871", "520489",
+	}
+
+	for counter, code := range exp {
+		assert.Equal(t, code, hotp([]byte("12345678901234567890"), int64(counter)), counter)
+	}
+}
+
+func TestCheckTOTP(t *testing.T) {
+	// RFC 6238, Appendix B, SHA1 truncated to 6 digits
+	vectors := []struct {
+		unix int64
+		code string
+	}{
+		{unix: 59, code: "287082"},
+		{unix: 1111111109, code: "081804"},
+		{unix: 1111111111, code: "050471"},
+		{unix: 1234567890, code: "005924"},
+		{unix: 2000000000, code: "279037"},
+		{unix: 20000000000, code: "353130"},
+	}
+
+	for _, v := range vectors {
+		step, ok := checkTOTP(rfcSecret, v.code, time.Unix(v.unix, 0))
+		assert.True(t, ok, v.unix)
+		assert.Equal(t, v.unix/totpPeriod, step, v.unix)
+	}
+
+	// the code of the step 1 is valid from the step 0 to the step 2
+	const code = "287082"
+
+	tests := []struct {
+		name string
+		unix int64
+		step int64
+		ok   bool
+	}{
+		{name: "previous step", unix: 0, step: 1, ok: true},
+		{name: "end of previous step", unix: 29, step: 1, ok: true},
+		{name: "current step", unix: 30, step: 1, ok: true},
+		{name: "next step", unix: 60, step: 1, ok: true},
+		{name: "end of next step", unix: 89, step: 1, ok: true},
+		{name: "two steps later", unix: 90},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			step, ok := checkTOTP(rfcSecret, code, time.Unix(tc.unix, 0))
+			assert.Equal(t, tc.ok, ok)
+			assert.Equal(t, tc.step, step)
+		})
+	}
+
+	rejections := []struct {
+		name   string
+		secret string
+		code   string
+	}{
+		{name: "wrong code", secret: rfcSecret, code: "287083"},
+		{name: "short code", secret: rfcSecret, code: "28708"},
+		{name: "long code", secret: rfcSecret, code: "2870820"},
+		{name: "invalid secret", secret: "not base32!", code: code},
+	}
+
+	for _, tc := range rejections {
+		t.Run(tc.name, func(t *testing.T) {
+			_, ok := checkTOTP(tc.secret, tc.code, time.Unix(30, 0))
+			assert.False(t, ok)
+		})
+	}
+}
+
+// currentTOTP returns the code of the secret of the current step and the step.
+func currentTOTP(t *testing.T, secret string) (string, int64) {
+	t.Helper()
+
+	key, err := b32.DecodeString(secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	step := time.Now().Unix() / totpPeriod
+
+	return hotp(key, step), step
+}
+
+func TestVerifyTOTP_Replay(t *testing.T) {
+	s, db := newMockDB(t)
+	code, step := currentTOTP(t, rfcSecret)
+
+	for _, replaced := range []int64{1, 0} {
+		db.ExpectQuery(`FROM\s+admin_totp`).WithArgs("admin").WillReturnRows([]string{"secret"},
+			[]driver.Value{rfcSecret})
+		db.ExpectExec(`last_used_step < \$2`).WithArgs("admin", anyArg{}).WillReturnResult(replaced)
+	}
+
+	assert.NoError(t, s.VerifyTOTP(context.Background(), "admin", code))
+
+	// the step of the accepted code is stored, the same code is rejected
+	err := s.VerifyTOTP(context.Background(), "admin", code)
+	assert.True(t, errors.Is(err, ErrUnauthorized), err)
+
+	for _, e := range db.expected[1:] {
+		if e.kind == "exec" {
+			assert.InDelta(t, step, e.Args()[1], 1)
+		}
+	}
+}
+
+func TestVerifyTOTP_RecoveryCode(t *testing.T) {
+	s, db := newMockDB(t)
+
+	// the code is used only while it is unused
+	for _, used := range []int64{1, 0} {
+		db.ExpectExec(`admin_recovery_codes[\s\S]+used_at IS NULL`).
+			WithArgs("admin", hashSecret("abcdefghjk"), anyArg{}).WillReturnResult(used)
+	}
+
+	assert.NoError(t, s.VerifyTOTP(context.Background(), "admin", " ABCDE-fghjk "))
+
+	err := s.VerifyTOTP(context.Background(), "admin", "abcde-fghjk")
+	assert.True(t, errors.Is(err, ErrUnauthorized), err)
+}
+
+func TestConfirmTOTP(t *testing.T) {
+	code, _ := currentTOTP(t, rfcSecret)
+
+	wrong := "000000"
+	if code == wrong {
+		wrong = "111111"
+	}
+
+	t.Run("wrong code", func(t *testing.T) {
+		s, db := newMockDB(t)
+		db.ExpectQuery(`enabled_at IS NULL`).WithArgs("admin").WillReturnRows([]string{"secret"},
+			[]driver.Value{rfcSecret})
+
+		// the enrolment is not enabled
+		err := s.ConfirmTOTP(context.Background(), "admin", wrong)
+		assert.True(t, errors.Is(err, ErrUnauthorized), err)
+	})
+
+	t.Run("valid code", func(t *testing.T) {
+		s, db := newMockDB(t)
+		db.ExpectQuery(`enabled_at IS NULL`).WithArgs("admin").WillReturnRows([]string{"secret"},
+			[]driver.Value{rfcSecret})
+		db.ExpectExec(`SET\s+enabled_at = \$3`).WithArgs("admin", anyArg{}, anyArg{}).WillReturnResult(1)
+
+		assert.NoError(t, s.ConfirmTOTP(context.Background(), "admin", code))
+	})
+
+	t.Run("not enrolled", func(t *testing.T) {
+		s, db := newMockDB(t)
+		db.ExpectQuery(`enabled_at IS NULL`).WithArgs("admin").WillReturnRows([]string{"secret"})
+
+		err := s.ConfirmTOTP(context.Background(), "admin", code)
+		assert.True(t, errors.Is(err, ErrTOTPNotEnrolled), err)
+	})
+}
+
+func TestDisableTOTP(t *testing.T) {
+	t.Run("enrolled", func(t *testing.T) {
+		s, db := newMockDB(t)
+		db.ExpectBegin()
+		db.ExpectExec(`DELETE FROM\s+admin_totp`).WithArgs("admin").WillReturnResult(1)
+		db.ExpectExec(`DELETE FROM\s+admin_recovery_codes`).WithArgs("admin").WillReturnResult(10)
+		db.ExpectCommit()
+
+		assert.NoError(t, s.DisableTOTP(context.Background(), "admin"))
+	})
+
+	t.Run("not enrolled", func(t *testing.T) {
+		s, db := newMockDB(t)
+		db.ExpectBegin()
+		db.ExpectExec(`DELETE FROM\s+admin_totp`).WithArgs("admin").WillReturnResult(0)
+		db.ExpectRollback()
+
+		err := s.DisableTOTP(context.Background(), "admin")
+		assert.True(t, errors.Is(err, ErrTOTPNotEnrolled), err)
+	})
+
+	t.Run("recovery codes failure", func(t *testing.T) {
+		s, db := newMockDB(t)
+		db.ExpectBegin()
+		db.ExpectExec(`DELETE FROM\s+admin_totp`).WithArgs("admin").WillReturnResult(1)
+		db.ExpectExec(`DELETE FROM\s+admin_recovery_codes`).WithArgs("admin").
+			WillReturnError(errors.New("connection reset"))
+		db.ExpectRollback()
+
+		// the secret is not deleted without the recovery codes
+		assert.Error(t, s.DisableTOTP(context.Background(), "admin"))
+	})
+}
+
+func TestGenRecoveryCodes(t *testing.T) {
+	codes, err := genRecoveryCodes()
+	assert.NoError(t, err)
+	assert.Len(t, codes, recoveryCodes)
+
+	for _, code := range codes {
+		if assert.Len(t, code, recoveryCodeLen+1) {
+			assert.Equal(t, byte('-'), code[recoveryCodeLen/2])
+		}
+
+		for _, r := range strings.ReplaceAll(code, "-", "") {
+			assert.Contains(t, recoveryAlphabet, string(r))
+		}
+	}
+}
@@ -3,47 +3,241 @@ package data
 import (
 	"context"
 	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
 )
 
-// incrementUsage increments usage column at record's with the given id.
-func (s *service) incrementUsage(ctx context.Context, id string) error { // increment
-	result, err := s.DB.ExecContext(ctx, `
+// Click is a visit of a shortcut captured by the redirect. Method and Purpose,
+// the prefetch header of the request, are used to recognize the bots.
+// DoNotTrack is set by the DNT and Sec-GPC headers of the request. ShortURL,
+// Tags and AnalyticsDisabled of the shortcut are used by the live click stream.
+type Click struct {
+	ShortcutID        string
+	ShortURL          string
+	Tags              []string
+	AnalyticsDisabled bool
+	ClientIP          string
+	Referrer          string
+	UserAgent         string
+	AcceptLanguage    string
+	Query             string
+	Method            string
+	Purpose           string
+	DoNotTrack        bool
+	LoggedAt          time.Time
+}
+
+// usage is the stored form of a click with the parsed details.
+type usage struct {
+	ShortcutID   string
+	LoggedAt     time.Time
+	Referrer     string
+	ReferrerHost string
+	UserAgent    string
+	Browser      string
+	OS           string
+	Device       string
+	Language     string
+	IPHash       string
+	Query        string
+	Country      string
+	Region       string
+	City         string
+	IsBot        bool
+	BotReason    string
+	IsRepeat     bool
+}
+
+// usageTimeLayout formats the times of the usages in the array parameters.
+const usageTimeLayout = "2006-01-02 15:04:05.999999"
+
+// logUsages stores the usages, increments the usage column of their records and
+// adds their visitors to the visitor sketches in a single transaction. The
+// usages of the records opted out of the analytics are only counted.
+func (s *service) logUsages(ctx context.Context, us []*usage) (err error) {
+	tx, err := s.DB.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	// increment usage of the records by the clicks of the humans, the repeats are not counted
+	countedIDs := make([]string, 0, len(us))
+
+	for _, u := range us {
+		if !u.IsBot && !u.IsRepeat {
+			countedIDs = append(countedIDs, u.ShortcutID)
+		}
+	}
+
+	_, err = tx.ExecContext(ctx, `
 UPDATE
   shortcuts
 SET
-  usage = usage + 1
+  usage = usage + c.clicks
+FROM
+  (
+    SELECT
+      id,
+      COUNT(*) AS clicks
+    FROM
+      UNNEST($1::UUID[]) AS id
+    GROUP BY
+      id
+  ) AS c
 WHERE
-  shortcut_id = $1
+  shortcuts.shortcut_id = c.id
   AND deleted_at IS NULL;
-  `, id)
+  `, pq.Array(countedIDs))
 	if err != nil {
 		return fmt.Errorf("update failure: %w", err)
 	}
 
-	// check changes
-	if i, _ := result.RowsAffected(); i != 1 {
-		return ErrIDNotFound
+	// the clicks of the records opted out of the analytics are not stored
+	if us, err = trackedUsages(ctx, tx, us); err != nil {
+		return err
 	}
 
-	return nil
-}
+	if len(us) == 0 {
+		if err = tx.Commit(); err != nil {
+			return fmt.Errorf("failed to commit transaction: %w", err)
+		}
+
+		return nil
+	}
 
-// logUsage logs the record with the given id into an usages table.
-func (s *service) logUsage(ctx context.Context, id string) error { // store
-	result, err := s.DB.ExecContext(ctx, `
+	n := len(us)
+	ids, times := make([]string, n), make([]string, n)
+	refs, hosts, agents := make([]string, n), make([]string, n), make([]string, n)
+	browsers, systems, devices := make([]string, n), make([]string, n), make([]string, n)
+	langs, hashes, queries := make([]string, n), make([]string, n), make([]string, n)
+	countries, regions, cities := make([]string, n), make([]string, n), make([]string, n)
+	isBots, reasons, repeats := make([]bool, n), make([]string, n), make([]bool, n)
+
+	for i, u := range us {
+		ids[i], times[i] = u.ShortcutID, u.LoggedAt.UTC().Format(usageTimeLayout)
+		refs[i], hosts[i], agents[i] = u.Referrer, u.ReferrerHost, u.UserAgent
+		browsers[i], systems[i], devices[i] = u.Browser, u.OS, u.Device
+		langs[i], hashes[i], queries[i] = u.Language, u.IPHash, u.Query
+		countries[i], regions[i], cities[i] = u.Country, u.Region, u.City
+		isBots[i], reasons[i], repeats[i] = u.IsBot, u.BotReason, u.IsRepeat
+	}
+
+	// store usages
+	_, err = tx.ExecContext(ctx, `
 INSERT INTO
-  usages (shortcut_id)
-VALUES
-  ($1);
-  `, id)
+  usages (
+    shortcut_id, logged_at, referrer, referrer_host, user_agent,
+    browser, os, device, language, ip_hash, query, country, region, city,
+    is_bot, bot_reason, is_repeat
+  )
+SELECT
+  u.shortcut_id,
+  u.logged_at,
+  NULLIF(u.referrer, ''),
+  NULLIF(u.referrer_host, ''),
+  NULLIF(u.user_agent, ''),
+  NULLIF(u.browser, ''),
+  NULLIF(u.os, ''),
+  NULLIF(u.device, ''),
+  NULLIF(u.language, ''),
+  NULLIF(u.ip_hash, ''),
+  NULLIF(u.query, ''),
+  NULLIF(u.country, ''),
+  NULLIF(u.region, ''),
+  NULLIF(u.city, ''),
+  u.is_bot,
+  NULLIF(u.bot_reason, ''),
+  u.is_repeat
+FROM
+  UNNEST(
+    $1::UUID[], $2::TIMESTAMP[], $3::TEXT[], $4::TEXT[], $5::TEXT[], $6::TEXT[],
+    $7::TEXT[], $8::TEXT[], $9::TEXT[], $10::TEXT[], $11::TEXT[], $12::TEXT[],
+    $13::TEXT[], $14::TEXT[], $15::BOOLEAN[], $16::TEXT[],
+    $17::BOOLEAN[]
+  ) AS u (
+    shortcut_id, logged_at, referrer, referrer_host, user_agent,
+    browser, os, device, language, ip_hash, query, country, region, city,
+    is_bot, bot_reason, is_repeat
+  )
+  JOIN shortcuts ON shortcuts.shortcut_id = u.shortcut_id;
+  `, pq.Array(ids), pq.Array(times), pq.Array(refs), pq.Array(hosts), pq.Array(agents),
+		pq.Array(browsers), pq.Array(systems), pq.Array(devices), pq.Array(langs), pq.Array(hashes),
+		pq.Array(queries), pq.Array(countries), pq.Array(regions), pq.Array(cities),
+		pq.Array(isBots), pq.Array(reasons), pq.Array(repeats))
 	if err != nil {
 		return fmt.Errorf("insert failure: %w", err)
 	}
 
-	// check changes
-	if i, _ := result.RowsAffected(); i != 1 {
-		return ErrIDNotFound
+	// count unique visitors
+	if err = s.updateSketches(ctx, tx, us); err != nil {
+		return fmt.Errorf("failed to update visitor sketches: %w", err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
 	}
 
 	return nil
 }
+
+// trackedUsages returns the usages of the records which are not opted out of the analytics.
+func trackedUsages(ctx context.Context, tx *sqlx.Tx, us []*usage) ([]*usage, error) {
+	ids := make([]string, len(us))
+	for i, u := range us {
+		ids[i] = u.ShortcutID
+	}
+
+	rows, err := tx.QueryxContext(ctx, `
+SELECT
+  shortcut_id
+FROM
+  shortcuts
+WHERE
+  shortcut_id = ANY ($1::UUID[])
+  AND analytics_disabled;
+  `, pq.Array(ids))
+	if err != nil {
+		return nil, fmt.Errorf("unexpected query error: %w", err)
+	}
+
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	disabled := make(map[string]bool)
+
+	for rows.Next() {
+		var id string
+		if err = rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("retrieved sql row scan error: %w", err)
+		}
+
+		disabled[id] = true
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("unexpected rows error: %w", err)
+	}
+
+	if len(disabled) == 0 {
+		return us, nil
+	}
+
+	tracked := make([]*usage, 0, len(us))
+
+	for _, u := range us {
+		if !disabled[u.ShortcutID] {
+			tracked = append(tracked, u)
+		}
+	}
+
+	return tracked, nil
+}
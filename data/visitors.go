@@ -0,0 +1,264 @@
+package data
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/chutommy/url-shortener/hll"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// dayLayout formats the days of the visitor sketches.
+const dayLayout = "2006-01-02"
+
+// sketchKey identifies the daily visitor sketch of a shortcut.
+type sketchKey struct {
+	id  string
+	day string
+}
+
+// visitorHash returns the hash of the visitor of the usage, the visitor is
+// identified by the hashed client IP and the user agent. False is returned if
+// the client IP is unknown or the click is made by a bot.
+func visitorHash(u *usage) (uint64, bool) {
+	if u.IPHash == "" || u.IsBot {
+		return 0, false
+	}
+
+	return visitorFingerprint(u), true
+}
+
+// updateSketches adds the visitors of the usages to the daily (UTC) visitor
+// sketches of their shortcuts. The sketches are locked, so the concurrent
+// writers do not lose their visitors.
+func (s *service) updateSketches(ctx context.Context, tx *sqlx.Tx, us []*usage) error {
+	added := make(map[sketchKey]*hll.Sketch)
+
+	for _, u := range us {
+		h, ok := visitorHash(u)
+		if !ok {
+			continue
+		}
+
+		k := sketchKey{id: u.ShortcutID, day: u.LoggedAt.UTC().Format(dayLayout)}
+		if added[k] == nil {
+			added[k] = hll.New()
+		}
+
+		added[k].Add(h)
+	}
+
+	if len(added) == 0 {
+		return nil
+	}
+
+	// lock in a stable order
+	keys := make([]sketchKey, 0, len(added))
+	for k := range added {
+		keys = append(keys, k)
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].id != keys[j].id {
+			return keys[i].id < keys[j].id
+		}
+
+		return keys[i].day < keys[j].day
+	})
+
+	ids, days := make([]string, len(keys)), make([]string, len(keys))
+	for i, k := range keys {
+		ids[i], days[i] = k.id, k.day
+	}
+
+	// create missing sketches
+	_, err := tx.ExecContext(ctx, `
+INSERT INTO
+  visitor_sketches (shortcut_id, day, sketch)
+SELECT
+  k.shortcut_id,
+  k.day,
+  ''
+FROM
+  UNNEST($1::UUID[], $2::DATE[]) AS k (shortcut_id, day)
+  JOIN shortcuts ON shortcuts.shortcut_id = k.shortcut_id
+ON CONFLICT
+  DO NOTHING;
+  `, pq.Array(ids), pq.Array(days))
+	if err != nil {
+		return fmt.Errorf("insert failure: %w", err)
+	}
+
+	// load and lock sketches
+	rows, err := tx.QueryxContext(ctx, `
+SELECT
+  shortcut_id,
+  day,
+  sketch
+FROM
+  visitor_sketches
+WHERE
+  (shortcut_id, day) IN (
+    SELECT
+      *
+    FROM
+      UNNEST($1::UUID[], $2::DATE[])
+  )
+ORDER BY
+  shortcut_id,
+  day
+FOR UPDATE;
+  `, pq.Array(ids), pq.Array(days))
+	if err != nil {
+		return fmt.Errorf("unexpected query error: %w", err)
+	}
+
+	var (
+		updIDs, updDays []string
+		updSketches     [][]byte
+	)
+
+	for rows.Next() {
+		var (
+			id  string
+			day time.Time
+			buf []byte
+		)
+
+		if err = rows.Scan(&id, &day, &buf); err != nil {
+			_ = rows.Close()
+
+			return fmt.Errorf("retrieved sql row scan error: %w", err)
+		}
+
+		// merge
+		k := sketchKey{id: id, day: day.Format(dayLayout)}
+
+		sketch, err := decodeSketch(buf)
+		if err != nil {
+			s.LogError(ctx, fmt.Errorf("visitor sketch of %s on %s is replaced: %w", k.id, k.day, err))
+			sketch = hll.New()
+		}
+
+		if err = sketch.Merge(added[k]); err != nil {
+			_ = rows.Close()
+
+			return fmt.Errorf("failed to merge visitor sketch: %w", err)
+		}
+
+		enc, _ := sketch.MarshalBinary()
+		updIDs, updDays, updSketches = append(updIDs, k.id), append(updDays, k.day), append(updSketches, enc)
+	}
+
+	err = rows.Err()
+	_ = rows.Close()
+
+	if err != nil {
+		return fmt.Errorf("unexpected rows error: %w", err)
+	}
+
+	// store sketches
+	_, err = tx.ExecContext(ctx, `
+UPDATE
+  visitor_sketches AS v
+SET
+  sketch = n.sketch
+FROM
+  UNNEST($1::UUID[], $2::DATE[], $3::BYTEA[]) AS n (shortcut_id, day, sketch)
+WHERE
+  v.shortcut_id = n.shortcut_id
+  AND v.day = n.day;
+  `, pq.Array(updIDs), pq.Array(updDays), pq.Array(updSketches))
+	if err != nil {
+		return fmt.Errorf("update failure: %w", err)
+	}
+
+	return nil
+}
+
+// decodeSketch decodes the stored sketch, an empty one is a new sketch.
+func decodeSketch(buf []byte) (*hll.Sketch, error) {
+	sketch := hll.New()
+	if len(buf) == 0 {
+		return sketch, nil
+	}
+
+	if err := sketch.UnmarshalBinary(buf); err != nil {
+		return nil, err
+	}
+
+	return sketch, nil
+}
+
+// dailySketches are the visitor sketches of a shortcut by the day.
+type dailySketches map[string]*hll.Sketch
+
+// getSketches returns the visitor sketches of the shortcut of the UTC days
+// overlapping the range [from, to).
+func (s *service) getSketches(ctx context.Context, id string, from, to time.Time) (dailySketches, error) {
+	rows, err := s.DB.QueryxContext(ctx, `
+SELECT
+  day,
+  sketch
+FROM
+  visitor_sketches
+WHERE
+  shortcut_id = $1
+  AND day >= $2::DATE
+  AND day <= $3::DATE;
+  `, id, from.UTC().Format(dayLayout), to.Add(-time.Nanosecond).UTC().Format(dayLayout))
+	if err != nil {
+		return nil, fmt.Errorf("unexpected query error: %w", err)
+	}
+
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	sketches := make(dailySketches)
+
+	for rows.Next() {
+		var (
+			day time.Time
+			buf []byte
+		)
+
+		if err = rows.Scan(&day, &buf); err != nil {
+			return nil, fmt.Errorf("retrieved sql row scan error: %w", err)
+		}
+
+		sketch, err := decodeSketch(buf)
+		if err != nil {
+			return nil, fmt.Errorf("invalid visitor sketch of %s: %w", day.Format(dayLayout), err)
+		}
+
+		sketches[day.Format(dayLayout)] = sketch
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("unexpected rows error: %w", err)
+	}
+
+	return sketches, nil
+}
+
+// visitors estimates the unique visitors of the UTC days overlapping the range [from, to).
+func (ds dailySketches) visitors(from, to time.Time) uint64 {
+	merged := hll.New()
+
+	last := to.Add(-time.Nanosecond).UTC()
+	for d := from.UTC(); ; d = d.AddDate(0, 0, 1) {
+		if sketch, ok := ds[d.Format(dayLayout)]; ok {
+			_ = merged.Merge(sketch)
+		}
+
+		if d.Format(dayLayout) >= last.Format(dayLayout) {
+			break
+		}
+	}
+
+	return merged.Estimate()
+}
@@ -0,0 +1,45 @@
+package data
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/chutommy/url-shortener/hll"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDailySketches_Visitors(t *testing.T) {
+	// 100 visitors a day, the same ones on the first two days
+	ds := make(dailySketches)
+
+	for day, first := range map[string]int{"2024-03-01": 0, "2024-03-02": 0, "2024-03-03": 100} {
+		s := hll.New()
+		for i := first; i < first+100; i++ {
+			s.Add(hll.Hash([]byte(strconv.Itoa(i))))
+		}
+
+		ds[day] = s
+	}
+
+	day := func(d int, h int) time.Time {
+		return time.Date(2024, 3, d, h, 0, 0, 0, time.UTC)
+	}
+
+	tests := []struct {
+		name     string
+		from, to time.Time
+		exp      uint64
+	}{
+		{name: "single day", from: day(1, 0), to: day(2, 0), exp: 100},
+		{name: "repeated visitors", from: day(1, 0), to: day(3, 0), exp: 100},
+		{name: "partial days", from: day(2, 12), to: day(3, 1), exp: 200},
+		{name: "no sketches", from: day(5, 0), to: day(7, 0), exp: 0},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.InDelta(t, tc.exp, ds.visitors(tc.from, tc.to), 3)
+		})
+	}
+}
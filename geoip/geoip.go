@@ -0,0 +1,199 @@
+// Package geoip resolves the client IPs to their location by a local MaxMind DB
+// file (such as GeoLite2-City) without any network lookups. The file is
+// reloaded when it changes.
+package geoip
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log/slog"
+	"net"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Location is the geographic location of an IP address. The fields are empty if they are unknown.
+type Location struct {
+	// Country is the ISO 3166-1 alpha-2 code of the country.
+	Country string `json:"country,omitempty"`
+	// Region is the English name of the first level subdivision of the country.
+	Region string `json:"region,omitempty"`
+	// City is the English name of the city.
+	City string `json:"city,omitempty"`
+}
+
+// database is a loaded database file.
+type database struct {
+	db      *mmdb
+	modTime time.Time
+	size    int64
+}
+
+// Reader looks up the locations in the database file. The file is checked for
+// changes periodically and reloaded. A missing or invalid file is logged and
+// the lookups return empty locations until a valid file appears.
+// A nil *Reader returns empty locations.
+type Reader struct {
+	path string
+	log  *slog.Logger
+
+	current atomic.Pointer[database]
+	stop    chan struct{}
+	done    chan struct{}
+	once    sync.Once
+}
+
+// Open loads the database file and starts checking it for changes every interval.
+func Open(path string, interval time.Duration, log *slog.Logger) *Reader {
+	r := &Reader{
+		path: path,
+		log:  log,
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+
+	if err := r.reload(); err != nil {
+		log.Warn("geoip database is not available, clicks are not geolocated", slog.Any("error", err))
+	}
+
+	go r.watch(interval)
+
+	return r
+}
+
+// Lookup returns the location of the ip.
+func (r *Reader) Lookup(ip string) Location {
+	if r == nil {
+		return Location{}
+	}
+
+	d := r.current.Load()
+	if d == nil {
+		return Location{}
+	}
+
+	addr := net.ParseIP(ip)
+	if addr == nil {
+		return Location{}
+	}
+
+	v, err := d.db.lookup(addr)
+	if err != nil {
+		r.log.Warn("geoip lookup failed", slog.Any("error", err))
+
+		return Location{}
+	}
+
+	return location(v)
+}
+
+// Loaded reports whether a database is loaded.
+func (r *Reader) Loaded() bool {
+	return r != nil && r.current.Load() != nil
+}
+
+// Running reports whether the changes of the file are checked.
+func (r *Reader) Running() bool {
+	select {
+	case <-r.done:
+		return false
+	default:
+		return true
+	}
+}
+
+// Close stops checking the file for changes.
+func (r *Reader) Close() {
+	if r == nil {
+		return
+	}
+
+	r.once.Do(func() {
+		close(r.stop)
+	})
+	<-r.done
+}
+
+// watch reloads the file when its modification time or size changes.
+func (r *Reader) watch(interval time.Duration) {
+	defer close(r.done)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.stop:
+			return
+
+		case <-ticker.C:
+			fi, err := os.Stat(r.path)
+			if err != nil {
+				continue
+			}
+
+			if d := r.current.Load(); d != nil && d.modTime.Equal(fi.ModTime()) && d.size == fi.Size() {
+				continue
+			}
+
+			if err = r.reload(); err != nil {
+				r.log.Warn("failed to reload geoip database, the previous one is kept", slog.Any("error", err))
+			}
+		}
+	}
+}
+
+// reload loads the file and replaces the current database.
+func (r *Reader) reload() error {
+	fi, err := os.Stat(r.path)
+	if err != nil {
+		return fmt.Errorf("can not stat geoip database: %w", err)
+	}
+
+	buf, err := ioutil.ReadFile(r.path)
+	if err != nil {
+		return fmt.Errorf("can not read geoip database: %w", err)
+	}
+
+	db, err := parseMMDB(buf)
+	if err != nil {
+		return err
+	}
+
+	r.current.Store(&database{db: db, modTime: fi.ModTime(), size: fi.Size()})
+	r.log.Info("geoip database loaded", slog.String("path", r.path), slog.String("type", db.dbType),
+		slog.Time("built_at", time.Unix(int64(db.buildEpoch), 0).UTC()))
+
+	return nil
+}
+
+// location extracts the location from the decoded record of the GeoIP2 or
+// GeoLite2 Country and City databases.
+func location(v interface{}) Location {
+	rec, _ := v.(map[string]interface{})
+
+	var l Location
+
+	country, _ := rec["country"].(map[string]interface{})
+	l.Country, _ = country["iso_code"].(string)
+
+	if subs, _ := rec["subdivisions"].([]interface{}); len(subs) > 0 {
+		sub, _ := subs[0].(map[string]interface{})
+		l.Region = englishName(sub)
+	}
+
+	city, _ := rec["city"].(map[string]interface{})
+	l.City = englishName(city)
+
+	return l
+}
+
+// englishName returns the English name of the record.
+func englishName(rec map[string]interface{}) string {
+	names, _ := rec["names"].(map[string]interface{})
+	name, _ := names["en"].(string)
+
+	return name
+}
@@ -0,0 +1,259 @@
+package geoip_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"io/ioutil"
+	"log/slog"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/chutommy/url-shortener/geoip"
+	"github.com/stretchr/testify/assert"
+)
+
+// encoder writes the fields of the MaxMind DB data section.
+type encoder struct {
+	bytes.Buffer
+}
+
+func (e *encoder) ctrl(typ int, size int) {
+	if typ > 7 {
+		e.WriteByte(byte(size))
+		e.WriteByte(byte(typ - 7))
+
+		return
+	}
+
+	e.WriteByte(byte(typ<<5 | size))
+}
+
+func (e *encoder) value(v interface{}) {
+	switch v := v.(type) {
+	case string:
+		e.ctrl(2, len(v))
+		e.WriteString(v)
+
+	case uint32:
+		e.ctrl(6, 4)
+		_ = binary.Write(e, binary.BigEndian, v)
+
+	case map[string]interface{}:
+		e.ctrl(7, len(v))
+
+		for k, val := range v {
+			e.value(k)
+			e.value(val)
+		}
+
+	case []interface{}:
+		e.ctrl(11, len(v))
+
+		for _, val := range v {
+			e.value(val)
+		}
+	}
+}
+
+type network struct {
+	cidr   string
+	record map[string]interface{}
+}
+
+// buildDB builds an IPv4 MaxMind DB with 24-bit records of the networks.
+func buildDB(t *testing.T, networks []network) []byte {
+	t.Helper()
+
+	var (
+		nodes [][2]int
+		data  encoder
+	)
+
+	nodes = append(nodes, [2]int{-1, -1})
+
+	type leaf struct {
+		node, bit, off int
+	}
+
+	var leaves []leaf
+
+	for _, n := range networks {
+		_, ipNet, err := net.ParseCIDR(n.cidr)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		ones, _ := ipNet.Mask.Size()
+		ip := ipNet.IP.To4()
+		node := 0
+
+		for i := 0; i < ones; i++ {
+			bit := int(ip[i/8]>>(7-uint(i%8))) & 1
+
+			if i == ones-1 {
+				leaves = append(leaves, leaf{node: node, bit: bit, off: data.Len()})
+
+				break
+			}
+
+			if nodes[node][bit] < 0 {
+				nodes = append(nodes, [2]int{-1, -1})
+				nodes[node][bit] = len(nodes) - 1
+			}
+
+			node = nodes[node][bit]
+		}
+
+		data.value(n.record)
+	}
+
+	count := len(nodes)
+	records := make([][2]int, count)
+
+	for i, n := range nodes {
+		for b := 0; b < 2; b++ {
+			records[i][b] = n[b]
+			if n[b] < 0 {
+				records[i][b] = count
+			}
+		}
+	}
+
+	for _, l := range leaves {
+		records[l.node][l.bit] = count + 16 + l.off
+	}
+
+	var buf bytes.Buffer
+
+	for _, r := range records {
+		for _, v := range r {
+			buf.Write([]byte{byte(v >> 16), byte(v >> 8), byte(v)})
+		}
+	}
+
+	buf.Write(make([]byte, 16))
+	buf.Write(data.Bytes())
+	buf.WriteString("\xAB\xCD\xEFMaxMind.com")
+
+	var meta encoder
+	meta.value(map[string]interface{}{
+		"node_count":    uint32(count),
+		"record_size":   uint32(24),
+		"ip_version":    uint32(4),
+		"database_type": "Test-City",
+		"build_epoch":   uint32(1700000000),
+	})
+	buf.Write(meta.Bytes())
+
+	return buf.Bytes()
+}
+
+func names(en string) map[string]interface{} {
+	return map[string]interface{}{"names": map[string]interface{}{"en": en}}
+}
+
+var testNetworks = []network{
+	{
+		cidr: "1.2.3.0/24",
+		record: map[string]interface{}{
+			"country":      map[string]interface{}{"iso_code": "CZ"},
+			"subdivisions": []interface{}{names("Prague")},
+			"city":         names("Prague"),
+		},
+	},
+	{
+		cidr: "8.8.0.0/16",
+		record: map[string]interface{}{
+			"country": map[string]interface{}{"iso_code": "US"},
+		},
+	},
+}
+
+func writeDB(t *testing.T, path string, networks []network, modTime time.Time) {
+	t.Helper()
+
+	if err := ioutil.WriteFile(path, buildDB(t, networks), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.Chtimes(path, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// waitFor waits until the condition holds, at most a second.
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+
+	for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(5 * time.Millisecond) {
+		if cond() {
+			return
+		}
+	}
+
+	t.Fatal("condition was not met in time")
+}
+
+var discard = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+func TestReader_Lookup(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.mmdb")
+	writeDB(t, path, testNetworks, time.Now())
+
+	r := geoip.Open(path, time.Hour, discard)
+	defer r.Close()
+
+	tests := []struct {
+		ip  string
+		exp geoip.Location
+	}{
+		{"1.2.3.4", geoip.Location{Country: "CZ", Region: "Prague", City: "Prague"}},
+		{"8.8.8.8", geoip.Location{Country: "US"}},
+		{"1.2.4.1", geoip.Location{}},
+		{"2001:db8::1", geoip.Location{}},
+		{"invalid", geoip.Location{}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.ip, func(t *testing.T) {
+			assert.Equal(t, tc.exp, r.Lookup(tc.ip))
+		})
+	}
+}
+
+func TestReader_Reload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.mmdb")
+
+	// missing database degrades to empty locations
+	r := geoip.Open(path, 10*time.Millisecond, discard)
+	defer r.Close()
+
+	assert.False(t, r.Loaded())
+	assert.Equal(t, geoip.Location{}, r.Lookup("1.2.3.4"))
+
+	// database appears
+	writeDB(t, path, testNetworks[:1], time.Now().Add(-time.Hour))
+
+	waitFor(t, func() bool { return r.Lookup("1.2.3.4").Country == "CZ" })
+	assert.Equal(t, geoip.Location{}, r.Lookup("8.8.8.8"))
+
+	// database changes
+	writeDB(t, path, testNetworks, time.Now())
+
+	waitFor(t, func() bool { return r.Lookup("8.8.8.8").Country == "US" })
+
+	// invalid database keeps the previous one
+	if err := ioutil.WriteFile(path, []byte("corrupted"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, "US", r.Lookup("8.8.8.8").Country)
+
+	var nilReader *geoip.Reader
+	assert.Equal(t, geoip.Location{}, nilReader.Lookup("1.2.3.4"))
+}
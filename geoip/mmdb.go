@@ -0,0 +1,425 @@
+package geoip
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+)
+
+// metadataMarker starts the metadata section at the end of a MaxMind DB file.
+var metadataMarker = []byte("\xAB\xCD\xEFMaxMind.com")
+
+// dataSectionSeparator is the size of the zeros between the search tree and the data section.
+const dataSectionSeparator = 16
+
+// maxMetadataSize bounds the search of the metadata marker.
+const maxMetadataSize = 128 * 1024
+
+// maxDecodeDepth bounds the nesting of the decoded values, so corrupted
+// pointers can not loop forever.
+const maxDecodeDepth = 32
+
+// ErrInvalidDatabase is returned if the file is not a valid MaxMind DB.
+var ErrInvalidDatabase = errors.New("invalid MaxMind DB file")
+
+// Types of the data section fields.
+const (
+	typeExtended = iota
+	typePointer
+	typeString
+	typeDouble
+	typeBytes
+	typeUint16
+	typeUint32
+	typeMap
+	typeInt32
+	typeUint64
+	typeUint128
+	typeArray
+	typeContainer
+	typeEndMarker
+	typeBool
+	typeFloat
+)
+
+// mmdb is a parsed MaxMind DB file.
+type mmdb struct {
+	buf        []byte
+	nodeCount  uint
+	recordSize uint
+	ipVersion  uint
+	dataStart  uint
+	ipv4Start  uint
+	dbType     string
+	buildEpoch uint64
+}
+
+// parseMMDB parses the metadata of the MaxMind DB file content.
+func parseMMDB(buf []byte) (*mmdb, error) {
+	// find metadata
+	from := 0
+	if len(buf) > maxMetadataSize {
+		from = len(buf) - maxMetadataSize
+	}
+
+	i := bytes.LastIndex(buf[from:], metadataMarker)
+	if i < 0 {
+		return nil, fmt.Errorf("%w: metadata not found", ErrInvalidDatabase)
+	}
+
+	metaStart := uint(from + i + len(metadataMarker))
+
+	meta := &decoder{buf: buf[metaStart:]}
+
+	v, _, err := meta.decode(0, 0)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidDatabase, err)
+	}
+
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%w: metadata is not a map", ErrInvalidDatabase)
+	}
+
+	db := &mmdb{
+		buf:        buf,
+		nodeCount:  uint(toUint(m["node_count"])),
+		recordSize: uint(toUint(m["record_size"])),
+		ipVersion:  uint(toUint(m["ip_version"])),
+		buildEpoch: toUint(m["build_epoch"]),
+	}
+	db.dbType, _ = m["database_type"].(string)
+
+	// validate tree
+	switch db.recordSize {
+	case 24, 28, 32:
+	default:
+		return nil, fmt.Errorf("%w: unsupported record size %d", ErrInvalidDatabase, db.recordSize)
+	}
+
+	if db.ipVersion != 4 && db.ipVersion != 6 {
+		return nil, fmt.Errorf("%w: unsupported ip version %d", ErrInvalidDatabase, db.ipVersion)
+	}
+
+	treeSize := db.nodeCount * db.recordSize / 4
+	db.dataStart = treeSize + dataSectionSeparator
+
+	if db.dataStart > metaStart-uint(len(metadataMarker)) {
+		return nil, fmt.Errorf("%w: search tree exceeds the file", ErrInvalidDatabase)
+	}
+
+	// IPv4 addresses are looked up under ::/96 of an IPv6 tree
+	if db.ipVersion == 6 {
+		node := uint(0)
+		for i := 0; i < 96 && node < db.nodeCount; i++ {
+			node = db.record(node, 0)
+		}
+
+		db.ipv4Start = node
+	}
+
+	return db, nil
+}
+
+// record returns the left (bit 0) or right (bit 1) record of the node.
+func (db *mmdb) record(node uint, bit uint) uint {
+	b := db.buf
+
+	switch db.recordSize {
+	case 24:
+		off := node*6 + bit*3
+
+		return uint(b[off])<<16 | uint(b[off+1])<<8 | uint(b[off+2])
+
+	case 28:
+		off := node * 7
+		if bit == 0 {
+			return (uint(b[off+3])&0xF0)<<20 | uint(b[off])<<16 | uint(b[off+1])<<8 | uint(b[off+2])
+		}
+
+		return (uint(b[off+3])&0x0F)<<24 | uint(b[off+4])<<16 | uint(b[off+5])<<8 | uint(b[off+6])
+
+	default:
+		off := node*8 + bit*4
+
+		return uint(binary.BigEndian.Uint32(b[off : off+4]))
+	}
+}
+
+// lookup returns the decoded data of the network containing the ip, nil if the
+// ip is not in the database.
+func (db *mmdb) lookup(ip net.IP) (interface{}, error) {
+	var (
+		node uint
+		bits int
+	)
+
+	if ip4 := ip.To4(); ip4 != nil {
+		ip = ip4
+		bits = 32
+
+		if db.ipVersion == 6 {
+			node = db.ipv4Start
+		}
+	} else {
+		if db.ipVersion == 4 {
+			return nil, nil
+		}
+
+		ip = ip.To16()
+		bits = 128
+	}
+
+	// walk the tree
+	for i := 0; i < bits && node < db.nodeCount; i++ {
+		bit := uint(ip[i/8]>>(7-uint(i%8))) & 1
+		node = db.record(node, bit)
+	}
+
+	switch {
+	case node == db.nodeCount:
+		// not found
+		return nil, nil
+	case node < db.nodeCount:
+		return nil, fmt.Errorf("%w: search tree is deeper than the address", ErrInvalidDatabase)
+	}
+
+	// resolve data
+	off := node - db.nodeCount - dataSectionSeparator
+	d := &decoder{buf: db.buf[db.dataStart:]}
+
+	v, _, err := d.decode(off, 0)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidDatabase, err)
+	}
+
+	return v, nil
+}
+
+// decoder decodes the fields of a data section.
+type decoder struct {
+	buf []byte
+}
+
+var errOutOfBounds = errors.New("field exceeds the data section")
+
+// bytes returns n bytes at the offset.
+func (d *decoder) bytes(off, n uint) ([]byte, error) {
+	if off+n > uint(len(d.buf)) || off+n < off {
+		return nil, errOutOfBounds
+	}
+
+	return d.buf[off : off+n], nil
+}
+
+// uintN decodes a big-endian unsigned integer of n bytes.
+func uintN(b []byte) uint64 {
+	var v uint64
+	for _, c := range b {
+		v = v<<8 | uint64(c)
+	}
+
+	return v
+}
+
+// decode decodes the field at the offset and returns it with the offset of the next field.
+func (d *decoder) decode(off uint, depth int) (interface{}, uint, error) {
+	if depth > maxDecodeDepth {
+		return nil, 0, errors.New("fields are nested too deep")
+	}
+
+	ctrl, err := d.bytes(off, 1)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	off++
+	typ := uint(ctrl[0] >> 5)
+
+	// pointer
+	if typ == typePointer {
+		ptr, next, err := d.pointer(ctrl[0], off)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		v, _, err := d.decode(ptr, depth+1)
+
+		return v, next, err
+	}
+
+	// extended type
+	if typ == typeExtended {
+		ext, err := d.bytes(off, 1)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		off++
+		typ = uint(ext[0]) + 7
+	}
+
+	// size
+	size := uint(ctrl[0] & 0x1F)
+	if size >= 29 {
+		n := size - 28
+
+		b, err := d.bytes(off, n)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		off += n
+
+		switch n {
+		case 1:
+			size = 29 + uint(b[0])
+		case 2:
+			size = 285 + uint(uintN(b))
+		default:
+			size = 65821 + uint(uintN(b))
+		}
+	}
+
+	return d.value(typ, size, off, depth)
+}
+
+// pointer decodes the pointer whose control byte is ctrl and value starts at the offset.
+func (d *decoder) pointer(ctrl byte, off uint) (uint, uint, error) {
+	n := uint(ctrl>>3)&0x3 + 1
+
+	b, err := d.bytes(off, n)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	vvv := uint64(ctrl & 0x7)
+
+	var ptr uint64
+
+	switch n {
+	case 1:
+		ptr = vvv<<8 | uintN(b)
+	case 2:
+		ptr = (vvv<<16 | uintN(b)) + 2048
+	case 3:
+		ptr = (vvv<<24 | uintN(b)) + 526336
+	default:
+		ptr = uintN(b)
+	}
+
+	return uint(ptr), off + n, nil
+}
+
+// value decodes the value of the type and size starting at the offset.
+func (d *decoder) value(typ, size, off uint, depth int) (interface{}, uint, error) {
+	switch typ {
+	case typeMap:
+		m := make(map[string]interface{}, size)
+
+		for i := uint(0); i < size; i++ {
+			k, next, err := d.decode(off, depth+1)
+			if err != nil {
+				return nil, 0, err
+			}
+
+			key, ok := k.(string)
+			if !ok {
+				return nil, 0, errors.New("map key is not a string")
+			}
+
+			v, next, err := d.decode(next, depth+1)
+			if err != nil {
+				return nil, 0, err
+			}
+
+			m[key] = v
+			off = next
+		}
+
+		return m, off, nil
+
+	case typeArray:
+		a := make([]interface{}, 0, size)
+
+		for i := uint(0); i < size; i++ {
+			v, next, err := d.decode(off, depth+1)
+			if err != nil {
+				return nil, 0, err
+			}
+
+			a = append(a, v)
+			off = next
+		}
+
+		return a, off, nil
+
+	case typeBool:
+		return size != 0, off, nil
+
+	case typeContainer, typeEndMarker:
+		return nil, off, nil
+	}
+
+	b, err := d.bytes(off, size)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	off += size
+
+	switch typ {
+	case typeString:
+		return string(b), off, nil
+	case typeBytes:
+		return append([]byte(nil), b...), off, nil
+	case typeDouble:
+		if size != 8 {
+			return nil, 0, errors.New("invalid double size")
+		}
+
+		return math.Float64frombits(binary.BigEndian.Uint64(b)), off, nil
+	case typeFloat:
+		if size != 4 {
+			return nil, 0, errors.New("invalid float size")
+		}
+
+		return float64(math.Float32frombits(binary.BigEndian.Uint32(b))), off, nil
+	case typeUint16, typeUint32, typeUint64:
+		if size > 8 {
+			return nil, 0, errors.New("invalid unsigned integer size")
+		}
+
+		return uintN(b), off, nil
+	case typeInt32:
+		if size > 4 {
+			return nil, 0, errors.New("invalid int32 size")
+		}
+
+		v := uintN(b)
+		if size == 4 {
+			return int64(int32(uint32(v))), off, nil
+		}
+
+		return int64(v), off, nil
+	case typeUint128:
+		// only the lower 64 bits are kept, the values are not used
+		if size > 8 {
+			b = b[size-8:]
+		}
+
+		return uintN(b), off, nil
+	default:
+		return nil, 0, fmt.Errorf("unknown field type %d", typ)
+	}
+}
+
+// toUint converts a decoded unsigned integer.
+func toUint(v interface{}) uint64 {
+	u, _ := v.(uint64)
+
+	return u
+}
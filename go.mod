@@ -1,6 +1,6 @@
 module github.com/chutommy/url-shortener
 
-go 1.15
+go 1.21
 
 require (
 	github.com/chutommy/rand v0.0.0-20210104105047-c062bd934c5a
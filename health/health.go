@@ -0,0 +1,65 @@
+// Package health tracks the background workers of the service, so the
+// readiness probe can report a worker which stopped running.
+package health
+
+import (
+	"sort"
+	"sync"
+)
+
+// Default is the registry of the service's background workers.
+var Default = NewRegistry()
+
+// Registry holds the liveness checks of the registered workers.
+type Registry struct {
+	mu      sync.Mutex
+	workers map[string]func() bool
+}
+
+// NewRegistry is a constructor of the Registry.
+func NewRegistry() *Registry {
+	return &Registry{
+		workers: make(map[string]func() bool),
+	}
+}
+
+// Register adds the worker whose running reports whether it is still running.
+// A worker with the same name is replaced.
+func (r *Registry) Register(name string, running func() bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.workers[name] = running
+}
+
+// Unregister removes the worker, it is used when the worker is stopped on purpose.
+func (r *Registry) Unregister(name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.workers, name)
+}
+
+// Stopped returns the sorted names of the registered workers which are not running.
+func (r *Registry) Stopped() []string {
+	r.mu.Lock()
+
+	checks := make(map[string]func() bool, len(r.workers))
+	for name, running := range r.workers {
+		checks[name] = running
+	}
+
+	r.mu.Unlock()
+
+	var stopped []string
+
+	for name, running := range checks {
+		if !running() {
+			stopped = append(stopped, name)
+		}
+	}
+
+	sort.Strings(stopped)
+
+	return stopped
+}
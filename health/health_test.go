@@ -0,0 +1,25 @@
+package health_test
+
+import (
+	"testing"
+
+	"github.com/chutommy/url-shortener/health"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegistry_Stopped(t *testing.T) {
+	r := health.NewRegistry()
+	assert.Empty(t, r.Stopped())
+
+	r.Register("clicks", func() bool { return false })
+	r.Register("exporter", func() bool { return true })
+	r.Register("alerts", func() bool { return false })
+	assert.Equal(t, []string{"alerts", "clicks"}, r.Stopped())
+
+	// replace
+	r.Register("clicks", func() bool { return true })
+	assert.Equal(t, []string{"alerts"}, r.Stopped())
+
+	r.Unregister("alerts")
+	assert.Empty(t, r.Stopped())
+}
@@ -0,0 +1,187 @@
+// Package hll implements HyperLogLog sketches which estimate the number of
+// distinct items in a constant space. Sketches of the same precision can be
+// merged, so the daily sketches give the estimate of any range of days.
+package hll
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"math"
+	"math/bits"
+)
+
+// Precision is the number of the index bits of the sketches. The 4096
+// registers give the standard error of about 1.6 %.
+const Precision = 12
+
+const (
+	version = 1
+
+	encodingDense  = 0
+	encodingSparse = 1
+
+	headerLen = 3
+)
+
+// ErrInvalidSketch is returned if a serialized sketch can not be decoded or
+// the sketches to merge have different precisions.
+var ErrInvalidSketch = errors.New("invalid HyperLogLog sketch")
+
+// Sketch is a HyperLogLog sketch.
+type Sketch struct {
+	p    uint8
+	regs []uint8
+}
+
+// New returns an empty sketch of the Precision.
+func New() *Sketch {
+	return &Sketch{
+		p:    Precision,
+		regs: make([]uint8, 1<<Precision),
+	}
+}
+
+// Hash returns the 64-bit hash of the item.
+func Hash(item []byte) uint64 {
+	sum := sha256.Sum256(item)
+
+	return binary.BigEndian.Uint64(sum[:8])
+}
+
+// Add adds the item with the given hash.
+func (s *Sketch) Add(hash uint64) {
+	idx := hash >> (64 - s.p)
+
+	// the guard bit bounds the rank when the remaining bits are zeros
+	w := hash<<s.p | 1<<(s.p-1)
+	rank := uint8(bits.LeadingZeros64(w) + 1)
+
+	if rank > s.regs[idx] {
+		s.regs[idx] = rank
+	}
+}
+
+// Merge adds the items of the other sketch.
+func (s *Sketch) Merge(o *Sketch) error {
+	if o.p != s.p {
+		return ErrInvalidSketch
+	}
+
+	for i, r := range o.regs {
+		if r > s.regs[i] {
+			s.regs[i] = r
+		}
+	}
+
+	return nil
+}
+
+// Estimate returns the estimated number of the distinct items.
+func (s *Sketch) Estimate() uint64 {
+	m := float64(len(s.regs))
+
+	var (
+		sum   float64
+		zeros int
+	)
+
+	for _, r := range s.regs {
+		sum += math.Ldexp(1, -int(r))
+		if r == 0 {
+			zeros++
+		}
+	}
+
+	alpha := 0.7213 / (1 + 1.079/m)
+	est := alpha * m * m / sum
+
+	// linear counting of the small cardinalities
+	if est <= 2.5*m && zeros > 0 {
+		est = m * math.Log(m/float64(zeros))
+	}
+
+	return uint64(est + 0.5)
+}
+
+// MarshalBinary encodes the sketch. Sketches with few non-empty registers are
+// encoded sparsely as the index and value pairs of the registers.
+func (s *Sketch) MarshalBinary() ([]byte, error) {
+	var nonZero int
+
+	for _, r := range s.regs {
+		if r != 0 {
+			nonZero++
+		}
+	}
+
+	// dense
+	if 2+3*nonZero >= len(s.regs) {
+		buf := make([]byte, headerLen, headerLen+len(s.regs))
+		buf[0], buf[1], buf[2] = version, s.p, encodingDense
+
+		return append(buf, s.regs...), nil
+	}
+
+	// sparse
+	buf := make([]byte, headerLen+2, headerLen+2+3*nonZero)
+	buf[0], buf[1], buf[2] = version, s.p, encodingSparse
+	binary.BigEndian.PutUint16(buf[headerLen:], uint16(nonZero))
+
+	for i, r := range s.regs {
+		if r != 0 {
+			buf = append(buf, byte(i>>8), byte(i), r)
+		}
+	}
+
+	return buf, nil
+}
+
+// UnmarshalBinary decodes the sketch encoded by MarshalBinary.
+func (s *Sketch) UnmarshalBinary(buf []byte) error {
+	if len(buf) < headerLen || buf[0] != version || buf[1] < 4 || buf[1] > 16 {
+		return ErrInvalidSketch
+	}
+
+	p := buf[1]
+	m := 1 << p
+	regs := make([]uint8, m)
+	payload := buf[headerLen:]
+
+	switch buf[2] {
+	case encodingDense:
+		if len(payload) != m {
+			return ErrInvalidSketch
+		}
+
+		copy(regs, payload)
+
+	case encodingSparse:
+		if len(payload) < 2 {
+			return ErrInvalidSketch
+		}
+
+		n := int(binary.BigEndian.Uint16(payload))
+		pairs := payload[2:]
+
+		if len(pairs) != 3*n {
+			return ErrInvalidSketch
+		}
+
+		for i := 0; i < len(pairs); i += 3 {
+			idx := int(pairs[i])<<8 | int(pairs[i+1])
+			if idx >= m {
+				return ErrInvalidSketch
+			}
+
+			regs[idx] = pairs[i+2]
+		}
+
+	default:
+		return ErrInvalidSketch
+	}
+
+	s.p, s.regs = p, regs
+
+	return nil
+}
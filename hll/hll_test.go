@@ -0,0 +1,73 @@
+package hll_test
+
+import (
+	"errors"
+	"math"
+	"strconv"
+	"testing"
+
+	"github.com/chutommy/url-shortener/hll"
+	"github.com/stretchr/testify/assert"
+)
+
+// add adds the items [from, to) to the sketch.
+func add(s *hll.Sketch, from, to int) {
+	for i := from; i < to; i++ {
+		s.Add(hll.Hash([]byte("visitor-" + strconv.Itoa(i))))
+	}
+}
+
+// assertClose asserts the estimate is within 5 % of the expected cardinality.
+func assertClose(t *testing.T, exp int, est uint64) {
+	t.Helper()
+
+	assert.True(t, math.Abs(float64(est)-float64(exp)) <= 0.05*float64(exp)+1,
+		"expected about %d; got %d", exp, est)
+}
+
+func TestSketch_Estimate(t *testing.T) {
+	for _, n := range []int{0, 1, 10, 100, 1000, 10000, 100000} {
+		t.Run(strconv.Itoa(n), func(t *testing.T) {
+			s := hll.New()
+			add(s, 0, n)
+
+			// repeated items are not counted
+			add(s, 0, n)
+
+			assertClose(t, n, s.Estimate())
+		})
+	}
+}
+
+func TestSketch_Merge(t *testing.T) {
+	a, b := hll.New(), hll.New()
+	add(a, 0, 6000)
+	add(b, 4000, 10000)
+
+	assert.Nil(t, a.Merge(b))
+	assertClose(t, 10000, a.Estimate())
+}
+
+func TestSketch_MarshalBinary(t *testing.T) {
+	for _, n := range []int{0, 50, 5000} {
+		t.Run(strconv.Itoa(n), func(t *testing.T) {
+			s := hll.New()
+			add(s, 0, n)
+
+			buf, err := s.MarshalBinary()
+			assert.Nil(t, err)
+
+			// small sketches are sparse
+			if n == 50 {
+				assert.Less(t, len(buf), 200)
+			}
+
+			var d hll.Sketch
+			assert.Nil(t, d.UnmarshalBinary(buf))
+			assert.Equal(t, s.Estimate(), d.Estimate())
+		})
+	}
+
+	var d hll.Sketch
+	assert.True(t, errors.Is(d.UnmarshalBinary([]byte{1, 12, 1, 0, 2, 0}), hll.ErrInvalidSketch))
+}
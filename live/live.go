@@ -0,0 +1,204 @@
+// Package live broadcasts the clicks to the subscribers of the live click
+// stream. The clicks are never queued for a slow subscriber, the clicks which
+// do not fit into its buffer are dropped and counted instead, so the redirects
+// never wait for the subscribers.
+package live
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	// ErrTooManySubscribers is returned if the maximal number of the subscribers is reached.
+	ErrTooManySubscribers = errors.New("too many subscribers of the live click stream")
+	// ErrClosed is returned if the broadcaster is closed or was never started.
+	ErrClosed = errors.New("live click stream is closed")
+)
+
+// Click is a click of the live stream. The details of the visitor which are
+// not stored are never streamed.
+type Click struct {
+	ShortcutID   string    `json:"shortcut_id"`
+	ShortURL     string    `json:"short_url"`
+	Tags         []string  `json:"tags,omitempty"`
+	Time         time.Time `json:"time"`
+	ReferrerHost string    `json:"referrer_host"`
+	Browser      string    `json:"browser"`
+	OS           string    `json:"os"`
+	Device       string    `json:"device"`
+	Country      string    `json:"country"`
+	Region       string    `json:"region"`
+	City         string    `json:"city"`
+	IsBot        bool      `json:"is_bot"`
+	BotReason    string    `json:"bot_reason,omitempty"`
+	IsRepeat     bool      `json:"is_repeat"`
+}
+
+// Filter selects the clicks of the shortcut with the ShortcutID and with the
+// Tag. The empty fields match all clicks.
+type Filter struct {
+	ShortcutID string
+	Tag        string
+}
+
+// match reports whether the click passes the filter.
+func (f Filter) match(c *Click) bool {
+	if f.ShortcutID != "" && !strings.EqualFold(f.ShortcutID, c.ShortcutID) {
+		return false
+	}
+
+	if f.Tag == "" {
+		return true
+	}
+
+	for _, t := range c.Tags {
+		if strings.EqualFold(f.Tag, t) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Broadcaster delivers the published clicks to the matching subscribers.
+// A nil Broadcaster publishes nothing and has no subscribers.
+type Broadcaster struct {
+	mu     sync.RWMutex
+	subs   map[*Subscription]struct{}
+	closed bool
+
+	buffer         int
+	maxSubscribers int
+}
+
+// New returns a broadcaster of at most maxSubscribers subscribers, each of
+// them buffering up to buffer clicks.
+func New(buffer, maxSubscribers int) *Broadcaster {
+	return &Broadcaster{
+		subs:           make(map[*Subscription]struct{}),
+		buffer:         buffer,
+		maxSubscribers: maxSubscribers,
+	}
+}
+
+// Subscribe adds a subscriber of the clicks matching the filter. The
+// subscription must be closed once it is not read anymore.
+func (b *Broadcaster) Subscribe(f Filter) (*Subscription, error) {
+	if b == nil {
+		return nil, ErrClosed
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return nil, ErrClosed
+	}
+
+	if len(b.subs) >= b.maxSubscribers {
+		return nil, ErrTooManySubscribers
+	}
+
+	s := &Subscription{
+		b:      b,
+		filter: f,
+		clicks: make(chan *Click, b.buffer),
+	}
+	b.subs[s] = struct{}{}
+
+	return s, nil
+}
+
+// Publish delivers the click to the matching subscribers without blocking.
+// It returns the number of the subscribers whose buffer was full, the click
+// is dropped for them.
+func (b *Broadcaster) Publish(c *Click) int {
+	if b == nil {
+		return 0
+	}
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	var dropped int
+
+	for s := range b.subs {
+		if !s.filter.match(c) {
+			continue
+		}
+
+		select {
+		case s.clicks <- c:
+		default:
+			s.dropped.Add(1)
+			dropped++
+		}
+	}
+
+	return dropped
+}
+
+// Len returns the number of the subscribers.
+func (b *Broadcaster) Len() int {
+	if b == nil {
+		return 0
+	}
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	return len(b.subs)
+}
+
+// Close ends all subscriptions and rejects the new ones.
+func (b *Broadcaster) Close() {
+	if b == nil {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.closed = true
+
+	for s := range b.subs {
+		delete(b.subs, s)
+		close(s.clicks)
+	}
+}
+
+// Subscription is a subscriber of the broadcaster.
+type Subscription struct {
+	b       *Broadcaster
+	filter  Filter
+	clicks  chan *Click
+	dropped atomic.Uint64
+}
+
+// Clicks returns the channel of the delivered clicks. It is closed once the
+// subscription or the broadcaster is closed.
+func (s *Subscription) Clicks() <-chan *Click {
+	return s.clicks
+}
+
+// Dropped returns the number of the clicks dropped since its last call,
+// because the buffer of the subscriber was full.
+func (s *Subscription) Dropped() uint64 {
+	return s.dropped.Swap(0)
+}
+
+// Close removes the subscriber from the broadcaster.
+func (s *Subscription) Close() {
+	s.b.mu.Lock()
+	defer s.b.mu.Unlock()
+
+	// the broadcaster closes the subscriptions it still holds
+	if _, ok := s.b.subs[s]; ok {
+		delete(s.b.subs, s)
+		close(s.clicks)
+	}
+}
@@ -0,0 +1,77 @@
+package live_test
+
+import (
+	"testing"
+
+	"github.com/chutommy/url-shortener/live"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBroadcaster_Filter(t *testing.T) {
+	b := live.New(10, 10)
+
+	all, err := b.Subscribe(live.Filter{})
+	assert.NoError(t, err)
+
+	byID, err := b.Subscribe(live.Filter{ShortcutID: "A"})
+	assert.NoError(t, err)
+
+	byTag, err := b.Subscribe(live.Filter{Tag: "launch"})
+	assert.NoError(t, err)
+
+	assert.Equal(t, 0, b.Publish(&live.Click{ShortcutID: "a", Tags: []string{"Launch"}}))
+	assert.Equal(t, 0, b.Publish(&live.Click{ShortcutID: "b"}))
+
+	assert.Len(t, all.Clicks(), 2)
+	assert.Len(t, byID.Clicks(), 1)
+	assert.Len(t, byTag.Clicks(), 1)
+}
+
+func TestBroadcaster_SlowSubscriber(t *testing.T) {
+	b := live.New(2, 10)
+
+	slow, err := b.Subscribe(live.Filter{})
+	assert.NoError(t, err)
+
+	for i, exp := range []int{0, 0, 1, 1} {
+		assert.Equal(t, exp, b.Publish(&live.Click{}), i)
+	}
+
+	assert.Len(t, slow.Clicks(), 2)
+	assert.Equal(t, uint64(2), slow.Dropped())
+	assert.Equal(t, uint64(0), slow.Dropped())
+
+	<-slow.Clicks()
+	assert.Equal(t, 0, b.Publish(&live.Click{}))
+}
+
+func TestBroadcaster_Limits(t *testing.T) {
+	b := live.New(1, 1)
+
+	s, err := b.Subscribe(live.Filter{})
+	assert.NoError(t, err)
+
+	_, err = b.Subscribe(live.Filter{})
+	assert.Equal(t, live.ErrTooManySubscribers, err)
+
+	s.Close()
+	s.Close()
+	assert.Equal(t, 0, b.Len())
+
+	s, err = b.Subscribe(live.Filter{})
+	assert.NoError(t, err)
+
+	b.Close()
+
+	_, ok := <-s.Clicks()
+	assert.False(t, ok)
+	s.Close()
+
+	_, err = b.Subscribe(live.Filter{})
+	assert.Equal(t, live.ErrClosed, err)
+
+	var nilB *live.Broadcaster
+	assert.Equal(t, 0, nilB.Publish(&live.Click{}))
+	_, err = nilB.Subscribe(live.Filter{})
+	assert.Equal(t, live.ErrClosed, err)
+}
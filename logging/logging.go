@@ -0,0 +1,47 @@
+// Package logging provides the structured logger of the service and carries
+// the request scoped loggers in the contexts.
+package logging
+
+import (
+	"context"
+	"io"
+	"log/slog"
+
+	"github.com/chutommy/url-shortener/config"
+)
+
+// LoggerKey is the context key of the request scoped logger. It is a plain
+// string, so the logger can be stored in and loaded from a *gin.Context.
+const LoggerKey = "logger"
+
+// New creates a logger which writes into w with the configured level and format.
+func New(w io.Writer, logCfg *config.Log) (*slog.Logger, error) {
+	lvl, err := logCfg.Leveler()
+	if err != nil {
+		return nil, err
+	}
+
+	format, err := logCfg.OutputFormat()
+	if err != nil {
+		return nil, err
+	}
+
+	opts := &slog.HandlerOptions{Level: lvl}
+	if format == config.LogFormatJSON {
+		return slog.New(slog.NewJSONHandler(w, opts)), nil
+	}
+
+	return slog.New(slog.NewTextHandler(w, opts)), nil
+}
+
+// FromContext returns the logger stored in the context. The default logger
+// is returned if there is none.
+func FromContext(ctx context.Context) *slog.Logger {
+	if ctx != nil {
+		if l, ok := ctx.Value(LoggerKey).(*slog.Logger); ok {
+			return l
+		}
+	}
+
+	return slog.Default()
+}
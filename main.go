@@ -2,13 +2,13 @@ package main
 
 import (
 	"context"
-	"fmt"
-	"log"
+	"log/slog"
 	"os"
 	"os/signal"
 	"syscall"
 
 	"github.com/chutommy/url-shortener/config"
+	"github.com/chutommy/url-shortener/logging"
 	"github.com/chutommy/url-shortener/server"
 	_ "github.com/lib/pq"
 )
@@ -17,23 +17,34 @@ func main() {
 	// get configuration
 	cfg, err := config.GetConfig("settings.json")
 	if err != nil {
-		log.Fatal(fmt.Errorf("failed to load config file: %w", err))
+		slog.Error("failed to load config file", slog.Any("error", err))
+		os.Exit(1)
 	}
 
+	// create logger
+	log, err := logging.New(os.Stdout, cfg.Log)
+	if err != nil {
+		slog.Error("failed to create logger", slog.Any("error", err))
+		os.Exit(1)
+	}
+
+	slog.SetDefault(log)
+
 	initCtx := context.Background()
 	// create server
-	srv := server.NewServer()
+	srv := server.NewServer(log)
 	err = srv.Set(initCtx, cfg)
 
 	if err != nil {
-		log.Fatal(fmt.Errorf("failed to set server: %w", err))
+		log.Error("failed to set server", slog.Any("error", err))
+		os.Exit(1)
 	}
 
 	// run server
 	go func() {
 		err = srv.Run()
 		if err != nil {
-			log.Println(err.Error())
+			log.Error("server failed", slog.Any("error", err))
 		}
 	}()
 
@@ -41,15 +52,15 @@ func main() {
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	s := <-quit
-	log.Printf("Shutting down server... (got signal: %v)\n\n", s)
+	log.Info("shutting down server", slog.String("signal", s.String()))
 
 	// stop server
 	if err = srv.Stop(); err != nil {
-		log.Println(err.Error())
+		log.Error("failed to stop server", slog.Any("error", err))
 	}
 
 	// close connections
 	if err = srv.Close(); err != nil {
-		log.Println(err.Error())
+		log.Error("failed to close connections", slog.Any("error", err))
 	}
 }
@@ -0,0 +1,16 @@
+package metrics
+
+// cacheLookups counts the lookups of the in-memory caches, the hit ratio of
+// a cache is the rate of its hits divided by the rate of all its lookups.
+var cacheLookups = Default.Counter("cache_lookups_total",
+	"Lookups of the in-memory caches by the cache and result (hit or miss).", "cache", "result")
+
+// ObserveCache records a lookup of the cache.
+func ObserveCache(cache string, hit bool) {
+	result := "miss"
+	if hit {
+		result = "hit"
+	}
+
+	cacheLookups.Inc(cache, result)
+}
@@ -0,0 +1,343 @@
+// Package metrics implements counters, gauges and histograms exposed in the
+// Prometheus text exposition format.
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ContentType is the content type of the text exposition format.
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// DefBuckets are the default buckets of the latency histograms in seconds.
+var DefBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// Default is the registry of the service's metrics.
+var Default = NewRegistry()
+
+// metric is a single registered metric family.
+type metric interface {
+	write(w *bufio.Writer)
+}
+
+// Registry holds the registered metrics in the order of the registration.
+type Registry struct {
+	mu      sync.Mutex
+	names   []string
+	metrics map[string]metric
+}
+
+// NewRegistry is a constructor of the Registry.
+func NewRegistry() *Registry {
+	return &Registry{
+		metrics: make(map[string]metric),
+	}
+}
+
+// register adds the metric. A metric with the same name is replaced.
+func (r *Registry) register(name string, m metric) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.metrics[name]; !ok {
+		r.names = append(r.names, name)
+	}
+
+	r.metrics[name] = m
+}
+
+// Write writes all metrics in the text exposition format.
+func (r *Registry) Write(w io.Writer) error {
+	r.mu.Lock()
+
+	ms := make([]metric, 0, len(r.names))
+	for _, name := range r.names {
+		ms = append(ms, r.metrics[name])
+	}
+
+	r.mu.Unlock()
+
+	bw := bufio.NewWriter(w)
+	for _, m := range ms {
+		m.write(bw)
+	}
+
+	return bw.Flush()
+}
+
+// Handler serves the metrics of the registry.
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", ContentType)
+		_ = r.Write(w)
+	})
+}
+
+// family holds the common parts of the labeled metrics.
+type family struct {
+	name   string
+	help   string
+	typ    string
+	labels []string
+}
+
+// header writes the HELP and TYPE lines of the family.
+func (f *family) header(w *bufio.Writer) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", f.name, escapeHelp(f.help), f.name, f.typ)
+}
+
+// key joins the label values into a map key.
+func (f *family) key(values []string) string {
+	if len(values) != len(f.labels) {
+		panic(fmt.Sprintf("metrics: %s expects %d label values, got %d", f.name, len(f.labels), len(values)))
+	}
+
+	return strings.Join(values, "\xff")
+}
+
+// labelPairs formats the labels of the values with the extra label pairs.
+func (f *family) labelPairs(key string, extra ...string) string {
+	var pairs []string
+
+	if len(f.labels) > 0 {
+		for i, v := range strings.Split(key, "\xff") {
+			pairs = append(pairs, f.labels[i]+`="`+escapeLabel(v)+`"`)
+		}
+	}
+
+	for i := 0; i+1 < len(extra); i += 2 {
+		pairs = append(pairs, extra[i]+`="`+escapeLabel(extra[i+1])+`"`)
+	}
+
+	if len(pairs) == 0 {
+		return ""
+	}
+
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+// CounterVec is a family of counters partitioned by labels.
+type CounterVec struct {
+	family
+	mu     sync.Mutex
+	values map[string]*float64
+}
+
+// Counter registers a new counter family with the given label names.
+func (r *Registry) Counter(name, help string, labels ...string) *CounterVec {
+	c := &CounterVec{
+		family: family{name: name, help: help, typ: "counter", labels: labels},
+		values: make(map[string]*float64),
+	}
+	r.register(name, c)
+
+	return c
+}
+
+// Add increases the counter of the label values by v.
+func (c *CounterVec) Add(v float64, values ...string) {
+	k := c.key(values)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	p, ok := c.values[k]
+	if !ok {
+		p = new(float64)
+		c.values[k] = p
+	}
+
+	*p += v
+}
+
+// Inc increments the counter of the label values.
+func (c *CounterVec) Inc(values ...string) {
+	c.Add(1, values...)
+}
+
+func (c *CounterVec) write(w *bufio.Writer) {
+	c.header(w)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, k := range sortedKeys(c.values) {
+		fmt.Fprintf(w, "%s%s %s\n", c.name, c.labelPairs(k), formatFloat(*c.values[k]))
+	}
+}
+
+// GaugeVec is a family of gauges partitioned by labels.
+type GaugeVec struct {
+	CounterVec
+}
+
+// Gauge registers a new gauge family with the given label names.
+func (r *Registry) Gauge(name, help string, labels ...string) *GaugeVec {
+	g := &GaugeVec{CounterVec{
+		family: family{name: name, help: help, typ: "gauge", labels: labels},
+		values: make(map[string]*float64),
+	}}
+	r.register(name, g)
+
+	return g
+}
+
+// Set sets the gauge of the label values.
+func (g *GaugeVec) Set(v float64, values ...string) {
+	k := g.key(values)
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	p, ok := g.values[k]
+	if !ok {
+		p = new(float64)
+		g.values[k] = p
+	}
+
+	*p = v
+}
+
+// Dec decrements the gauge of the label values.
+func (g *GaugeVec) Dec(values ...string) {
+	g.Add(-1, values...)
+}
+
+// funcMetric reads its value when the metrics are written.
+type funcMetric struct {
+	family
+	fn func() float64
+}
+
+// GaugeFunc registers a gauge whose value is read by fn.
+func (r *Registry) GaugeFunc(name, help string, fn func() float64) {
+	r.register(name, &funcMetric{family: family{name: name, help: help, typ: "gauge"}, fn: fn})
+}
+
+// CounterFunc registers a counter whose value is read by fn.
+func (r *Registry) CounterFunc(name, help string, fn func() float64) {
+	r.register(name, &funcMetric{family: family{name: name, help: help, typ: "counter"}, fn: fn})
+}
+
+func (f *funcMetric) write(w *bufio.Writer) {
+	f.header(w)
+	fmt.Fprintf(w, "%s %s\n", f.name, formatFloat(f.fn()))
+}
+
+// histogram holds the observations of a single label combination.
+type histogram struct {
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+// HistogramVec is a family of histograms partitioned by labels.
+type HistogramVec struct {
+	family
+	buckets []float64
+
+	mu     sync.Mutex
+	values map[string]*histogram
+}
+
+// Histogram registers a new histogram family with the given upper bounds of
+// the buckets and label names.
+func (r *Registry) Histogram(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	h := &HistogramVec{
+		family:  family{name: name, help: help, typ: "histogram", labels: labels},
+		buckets: buckets,
+		values:  make(map[string]*histogram),
+	}
+	r.register(name, h)
+
+	return h
+}
+
+// Observe adds the observation to the histogram of the label values.
+func (h *HistogramVec) Observe(v float64, values ...string) {
+	k := h.key(values)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	hist, ok := h.values[k]
+	if !ok {
+		hist = &histogram{counts: make([]uint64, len(h.buckets))}
+		h.values[k] = hist
+	}
+
+	for i, b := range h.buckets {
+		if v <= b {
+			hist.counts[i]++
+		}
+	}
+
+	hist.count++
+	hist.sum += v
+}
+
+func (h *HistogramVec) write(w *bufio.Writer) {
+	h.header(w)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for _, k := range sortedKeys(h.values) {
+		hist := h.values[k]
+
+		for i, b := range h.buckets {
+			fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, h.labelPairs(k, "le", formatFloat(b)), hist.counts[i])
+		}
+
+		fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, h.labelPairs(k, "le", "+Inf"), hist.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", h.name, h.labelPairs(k), formatFloat(hist.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", h.name, h.labelPairs(k), hist.count)
+	}
+}
+
+// sortedKeys returns the keys of the map in a stable order.
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
+
+// formatFloat formats the value as the exposition format expects.
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+var (
+	labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+)
+
+func escapeLabel(v string) string {
+	return labelEscaper.Replace(v)
+}
+
+func escapeHelp(v string) string {
+	return helpEscaper.Replace(v)
+}
@@ -0,0 +1,54 @@
+package metrics_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/chutommy/url-shortener/metrics"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegistry_Write(t *testing.T) {
+	r := metrics.NewRegistry()
+
+	c := r.Counter("requests_total", "Served requests.", "route", "status")
+	c.Inc("/v1/url/i/:record_short", "200")
+	c.Add(2, "/v1/url/i/:record_short", "200")
+	c.Inc(`/"quoted"`, "404")
+
+	g := r.Gauge("in_flight", "Requests in flight.")
+	g.Inc()
+	g.Inc()
+	g.Dec()
+
+	r.GaugeFunc("open_connections", "Open connections.", func() float64 {
+		return 4
+	})
+
+	h := r.Histogram("duration_seconds", "Latency.", []float64{0.1, 1}, "op")
+	h.Observe(0.05, "get")
+	h.Observe(0.5, "get")
+	h.Observe(2, "get")
+
+	var buf bytes.Buffer
+	assert.Nil(t, r.Write(&buf))
+
+	assert.Equal(t, `# HELP requests_total Served requests.
+# TYPE requests_total counter
+requests_total{route="/\"quoted\"",status="404"} 1
+requests_total{route="/v1/url/i/:record_short",status="200"} 3
+# HELP in_flight Requests in flight.
+# TYPE in_flight gauge
+in_flight 1
+# HELP open_connections Open connections.
+# TYPE open_connections gauge
+open_connections 4
+# HELP duration_seconds Latency.
+# TYPE duration_seconds histogram
+duration_seconds_bucket{op="get",le="0.1"} 1
+duration_seconds_bucket{op="get",le="1"} 2
+duration_seconds_bucket{op="get",le="+Inf"} 3
+duration_seconds_sum{op="get"} 2.55
+duration_seconds_count{op="get"} 3
+`, buf.String())
+}
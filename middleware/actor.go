@@ -0,0 +1,50 @@
+package middleware
+
+import (
+	"github.com/chutommy/url-shortener/data"
+	"github.com/gin-gonic/gin"
+)
+
+// Context keys which identify the actor of a request.
+const (
+	// AdminPrefixKey is the context key of the prefix of an admin_key which authorized the request.
+	AdminPrefixKey = "admin_prefix"
+	// LoginUserKey is the context key of the username of a successful admin login.
+	LoginUserKey = "login_user"
+)
+
+// Types of the actors.
+const (
+	ActorAdminKey  = "admin_key"
+	ActorSignature = "signature"
+	ActorSession   = "session"
+	ActorCert      = "cert"
+	ActorLogin     = "login"
+)
+
+// Actor returns the type and the identity of the authenticated actor of the request.
+func Actor(c *gin.Context) (string, string) {
+	if v, ok := c.Get(SessionKey); ok {
+		if claims, ok := v.(*data.SessionClaims); ok {
+			return ActorSession, claims.Username
+		}
+	}
+
+	if prefix := c.GetString(SignedPrefixKey); prefix != "" {
+		return ActorSignature, prefix
+	}
+
+	if prefix := c.GetString(AdminPrefixKey); prefix != "" {
+		return ActorAdminKey, prefix
+	}
+
+	if id := c.GetString(ClientCertKey); id != "" {
+		return ActorCert, id
+	}
+
+	if user := c.GetString(LoginUserKey); user != "" {
+		return ActorLogin, user
+	}
+
+	return "", ""
+}
@@ -4,13 +4,17 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"strings"
+	"time"
 
 	"github.com/chutommy/url-shortener/data"
 	"github.com/gin-gonic/gin"
 )
 
-// AdminLogin validates login data.
-func AdminLogin(s data.Service) gin.HandlerFunc {
+// AdminLogin validates login data. If the user has enabled two-factor authentication,
+// a valid otp form field is required as well. Failed attempts are counted per
+// client's IP address and username and lead to temporary lockouts.
+func AdminLogin(s data.Service, l *Limiter) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// load login
 		username, ok := c.GetPostForm("username")
@@ -33,8 +37,17 @@ func AdminLogin(s data.Service) gin.HandlerFunc {
 			return
 		}
 
+		// check lockouts
+		keys := []string{LockoutKey(KindIP, l.ClientIP(c)), LockoutKey(KindUsername, username)}
+		if wait := l.Check(keys...); wait > 0 {
+			abortLocked(c, wait)
+
+			return
+		}
+
 		// authentication
 		if err := s.AuthenticateAdmin(username, password); errors.Is(err, data.ErrUnauthorized) {
+			failAttempt(c, s, l, keys, authLogin)
 			c.JSON(http.StatusUnauthorized, gin.H{
 				"error": fmt.Errorf("authentication error: %w", err),
 			})
@@ -42,6 +55,7 @@ func AdminLogin(s data.Service) gin.HandlerFunc {
 
 			return
 		} else if err != nil {
+			l.Release(keys...)
 			s.LogError(c, err)
 			c.JSON(http.StatusInternalServerError, gin.H{
 				"error": data.ErrUnexpectedError,
@@ -51,16 +65,112 @@ func AdminLogin(s data.Service) gin.HandlerFunc {
 			return
 		}
 
-		c.Next()
+		// second factor
+		if !verifySecondFactor(c, s, l, keys, username) {
+			return
+		}
+
+		l.Succeed(keys...)
+		c.Set(LoginUserKey, username)
 	}
 }
 
-// ValidateAdminKey middleware checks if a request is authorized.
-func ValidateAdminKey(s data.Service) gin.HandlerFunc {
+// verifySecondFactor enforces the TOTP of the user if it is enabled. The otp form
+// field can hold either a TOTP code or a recovery code. It reports whether the request can continue.
+func verifySecondFactor(c *gin.Context, s data.Service, l *Limiter, keys []string, username string) bool {
+	enabled, err := s.TOTPEnabled(c, username)
+	if err != nil {
+		l.Release(keys...)
+		s.LogError(c, err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": data.ErrUnexpectedError,
+		})
+		c.Abort()
+
+		return false
+	}
+
+	if !enabled {
+		return true
+	}
+
+	// load code
+	code, ok := c.GetPostForm("otp")
+	if !ok {
+		l.Release(keys...)
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "missing otp form field, two-factor authentication is enabled",
+		})
+		c.Abort()
+
+		return false
+	}
+
+	// verify
+	if err = s.VerifyTOTP(c, username, code); errors.Is(err, data.ErrUnauthorized) {
+		failAttempt(c, s, l, keys, authTOTP)
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "invalid otp code",
+		})
+		c.Abort()
+
+		return false
+	} else if err != nil {
+		l.Release(keys...)
+		s.LogError(c, err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": data.ErrUnexpectedError,
+		})
+		c.Abort()
+
+		return false
+	}
+
+	c.Set(TOTPVerifiedKey, true)
+
+	return true
+}
+
+// LocalLoginDisabled middleware rejects all logins with the local credentials.
+func LocalLoginDisabled() gin.HandlerFunc {
 	return func(c *gin.Context) {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error": "local login is disabled, use /v1/oidc/login",
+		})
+		c.Abort()
+	}
+}
+
+// ValidateAdminKey middleware checks if a request is authorized either by an admin_key
+// query parameter, by a session token or an HMAC signature in the Authorization header
+// or by a verified client certificate mapped to scopes. Failed attempts are counted
+// per client's IP address and admin_key's prefix and lead to temporary lockouts.
+func ValidateAdminKey(s data.Service, l *Limiter, cs CertScopes, v *SignatureVerifier) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// signed request
+		if isSigned(c) {
+			validateSignature(c, s, l, v)
+
+			return
+		}
+
+		// session token
+		if token, ok := bearerToken(c); ok {
+			validateSession(c, s, l, token, true)
+
+			return
+		}
+
 		// load admin key
 		key := c.Query("admin_key")
 		if key == "" {
+			// client certificate
+			if cert, ok := verifiedCert(c); ok {
+				validateCert(c, cs, cert)
+
+				return
+			}
+
 			c.JSON(http.StatusUnauthorized, gin.H{
 				"error": "missing admin_key query parameter",
 			})
@@ -69,9 +179,20 @@ func ValidateAdminKey(s data.Service) gin.HandlerFunc {
 			return
 		}
 
+		// check lockouts
+		prefix := strings.SplitN(key, ".", 2)[0]
+
+		keys := []string{LockoutKey(KindIP, l.ClientIP(c)), LockoutKey(KindPrefix, prefix)}
+		if wait := l.Check(keys...); wait > 0 {
+			abortLocked(c, wait)
+
+			return
+		}
+
 		// validate admin key
 		err := s.ValidateAdminKey(c, key)
 		if errors.Is(err, data.ErrUnauthorized) {
+			failAttempt(c, s, l, keys, authAdminKey)
 			c.JSON(http.StatusUnauthorized, gin.H{
 				"error": "invalid admin_key query parameter",
 			})
@@ -79,6 +200,7 @@ func ValidateAdminKey(s data.Service) gin.HandlerFunc {
 
 			return
 		} else if err != nil {
+			l.Release(keys...)
 			s.LogError(c, err)
 			c.JSON(http.StatusInternalServerError, gin.H{
 				"error": data.ErrUnexpectedError,
@@ -88,6 +210,29 @@ func ValidateAdminKey(s data.Service) gin.HandlerFunc {
 			return
 		}
 
-		c.Next()
+		l.Succeed(keys...)
+		c.Set(AdminPrefixKey, prefix)
+	}
+}
+
+// failAttempt records a failed authentication attempt of the method. The started
+// lockouts are logged and announced to the client by the Retry-After header.
+func failAttempt(c *gin.Context, s data.Service, l *Limiter, keys []string, method string) {
+	authFailures.Inc(method)
+
+	var wait time.Duration
+
+	for _, lo := range l.Fail(keys...) {
+		if err := s.LogLockout(c, lo.Kind, lo.Subject, l.ClientIP(c), lo.Failures, lo.LockedUntil); err != nil {
+			s.LogError(c, err)
+		}
+
+		if d := time.Until(lo.LockedUntil); d > wait {
+			wait = d
+		}
+	}
+
+	if wait > 0 {
+		c.Header("Retry-After", retryAfter(wait))
 	}
 }
@@ -0,0 +1,86 @@
+package middleware
+
+import (
+	"crypto/x509"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ClientCertKey is the context key of the identity of a client certificate
+// which authorized the request.
+const ClientCertKey = "client_cert"
+
+// CertScopes maps identities of client certificates to the granted scopes.
+// See config.TLS for the format of the identities.
+type CertScopes map[string][]string
+
+// identify returns the identities of the certificate which are mapped to any
+// scope together with a union of their scopes.
+func (cs CertScopes) identify(cert *x509.Certificate) ([]string, []string) {
+	ids := []string{"cn:" + cert.Subject.CommonName}
+	for _, dns := range cert.DNSNames {
+		ids = append(ids, "dns:"+dns)
+	}
+
+	for _, u := range cert.URIs {
+		ids = append(ids, "uri:"+u.String())
+	}
+
+	for _, email := range cert.EmailAddresses {
+		ids = append(ids, "email:"+email)
+	}
+
+	// collect scopes
+	var mapped, scopes []string
+
+	seen := make(map[string]bool)
+
+	for _, id := range ids {
+		s, ok := cs[id]
+		if !ok {
+			continue
+		}
+
+		mapped = append(mapped, id)
+
+		for _, scope := range s {
+			if !seen[scope] {
+				seen[scope] = true
+
+				scopes = append(scopes, scope)
+			}
+		}
+	}
+
+	return mapped, scopes
+}
+
+// verifiedCert returns the verified client certificate of the request.
+func verifiedCert(c *gin.Context) (*x509.Certificate, bool) {
+	tls := c.Request.TLS
+	if tls == nil || len(tls.VerifiedChains) == 0 || len(tls.VerifiedChains[0]) == 0 {
+		return nil, false
+	}
+
+	return tls.VerifiedChains[0][0], true
+}
+
+// validateCert authorizes a request with the verified client certificate.
+func validateCert(c *gin.Context, cs CertScopes, cert *x509.Certificate) {
+	ids, scopes := cs.identify(cert)
+	if len(ids) == 0 {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error": "client certificate is not mapped to any scope",
+		})
+		c.Abort()
+
+		return
+	}
+
+	if !authorize(c, scopes) {
+		return
+	}
+
+	c.Set(ClientCertKey, ids[0])
+}
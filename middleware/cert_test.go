@@ -0,0 +1,65 @@
+package middleware
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCertScopes_identify(t *testing.T) {
+	cs := CertScopes{
+		"cn:billing":                 {ScopeRead},
+		"uri:spiffe://corp/billing":  {ScopeRead, ScopeWrite},
+		"dns:reports.corp.internal":  {ScopeRead},
+		"email:ops@corp.example.com": {ScopeWrite},
+	}
+
+	spiffe, _ := url.Parse("spiffe://corp/billing")
+
+	var identifyTests = []struct {
+		name   string
+		cert   *x509.Certificate
+		ids    []string
+		scopes []string
+	}{
+		{
+			name:   "common name",
+			cert:   &x509.Certificate{Subject: pkix.Name{CommonName: "billing"}},
+			ids:    []string{"cn:billing"},
+			scopes: []string{ScopeRead},
+		},
+		{
+			name: "merged sans",
+			cert: &x509.Certificate{
+				Subject: pkix.Name{CommonName: "billing"},
+				URIs:    []*url.URL{spiffe},
+			},
+			ids:    []string{"cn:billing", "uri:spiffe://corp/billing"},
+			scopes: []string{ScopeRead, ScopeWrite},
+		},
+		{
+			name: "dns and email",
+			cert: &x509.Certificate{
+				DNSNames:       []string{"reports.corp.internal"},
+				EmailAddresses: []string{"ops@corp.example.com"},
+			},
+			ids:    []string{"dns:reports.corp.internal", "email:ops@corp.example.com"},
+			scopes: []string{ScopeRead, ScopeWrite},
+		},
+		{
+			name: "unmapped",
+			cert: &x509.Certificate{Subject: pkix.Name{CommonName: "unknown"}},
+		},
+	}
+
+	for _, tc := range identifyTests {
+		t.Run(tc.name, func(t *testing.T) {
+			ids, scopes := cs.identify(tc.cert)
+			assert.Equal(t, tc.ids, ids)
+			assert.Equal(t, tc.scopes, scopes)
+		})
+	}
+}
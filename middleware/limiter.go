@@ -0,0 +1,431 @@
+package middleware
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/chutommy/url-shortener/config"
+	"github.com/gin-gonic/gin"
+)
+
+// pruneThreshold is the number of tracked keys after which stale entries are removed.
+const pruneThreshold = 10000
+
+// maxEntries caps the number of tracked keys, so a flood of distinct keys
+// can not exhaust the memory.
+const maxEntries = 100000
+
+// Kinds of the tracked lockout keys.
+const (
+	KindIP       = "ip"
+	KindUsername = "username"
+	KindPrefix   = "prefix"
+)
+
+// Lockout represents a temporarily blocked client or account.
+type Lockout struct {
+	Kind        string    `json:"kind"`
+	Subject     string    `json:"subject"`
+	Failures    int       `json:"failures"`
+	LockedUntil time.Time `json:"locked_until"`
+}
+
+// LockoutKey returns the key under which the failures of the subject are counted.
+func LockoutKey(kind, subject string) string {
+	return kind + ":" + subject
+}
+
+// attempts holds failure statistics of a single key.
+type attempts struct {
+	failures    int
+	pending     int
+	lastFailure time.Time
+	lockedUntil time.Time
+}
+
+// Limiter counts failed authentication attempts per key (IP address, username
+// or admin_key prefix) and locks the keys out with an exponential backoff.
+type Limiter struct {
+	mu      sync.Mutex
+	entries map[string]*attempts
+
+	free  int
+	base  time.Duration
+	max   time.Duration
+	reset time.Duration
+	now   func() time.Time
+
+	capacity int
+	proxies  []*net.IPNet
+}
+
+// NewLimiter is a constructor of the Limiter. A nil configuration results in
+// the default brute-force protection settings.
+func NewLimiter(cfg *config.BruteForce) *Limiter {
+	base, max, reset, err := cfg.Delays()
+	if err != nil {
+		base, max, reset, _ = (*config.BruteForce)(nil).Delays()
+	}
+
+	// untrusted proxies are ignored, the client is then the peer of the connection
+	proxies, _ := cfg.Proxies()
+
+	return &Limiter{
+		entries:  make(map[string]*attempts),
+		free:     cfg.Attempts(),
+		base:     base,
+		max:      max,
+		reset:    reset,
+		now:      time.Now,
+		capacity: maxEntries,
+		proxies:  proxies,
+	}
+}
+
+// ClientIP returns the IP address under which the failures of the client are
+// counted. It is the peer address of the connection unless the peer is a trusted
+// proxy, in which case the X-Forwarded-For header is walked from the right up to
+// the first address which is not a trusted proxy.
+func (l *Limiter) ClientIP(c *gin.Context) string {
+	ip, _, err := net.SplitHostPort(strings.TrimSpace(c.Request.RemoteAddr))
+	if err != nil {
+		ip = strings.TrimSpace(c.Request.RemoteAddr)
+	}
+
+	if !l.trusted(ip) {
+		return ip
+	}
+
+	hops := strings.Split(strings.Join(c.Request.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(hops[i])
+		if net.ParseIP(hop) == nil {
+			break
+		}
+
+		ip = hop
+		if !l.trusted(ip) {
+			break
+		}
+	}
+
+	return ip
+}
+
+// trusted reports whether the IP address belongs to a trusted proxy.
+func (l *Limiter) trusted(ip string) bool {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return false
+	}
+
+	for _, n := range l.proxies {
+		if n.Contains(parsed) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Check returns the longest remaining lockout of the given keys. Zero is
+// returned if none of the keys is locked, and an attempt is reserved for each
+// of the keys. The reservation has to be ended by Fail, Succeed or Release.
+// Attempts which could start a lockout are admitted one at a time, so parallel
+// requests can not bypass the backoff. A busy key is reported as locked for
+// the base delay.
+func (l *Limiter) Check(keys ...string) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+
+	var wait time.Duration
+
+	for _, k := range keys {
+		if a, ok := l.entries[k]; ok {
+			if d := a.lockedUntil.Sub(now); d > wait {
+				wait = d
+			}
+		}
+	}
+
+	if wait > 0 {
+		return wait
+	}
+
+	for _, k := range keys {
+		if a, ok := l.entries[k]; ok && l.busy(a, now) {
+			return l.base
+		}
+	}
+
+	// reserve the attempt
+	for _, k := range keys {
+		l.entry(k, now).pending++
+	}
+
+	return 0
+}
+
+// Fail records a failed attempt for each of the given keys and returns
+// the lockouts which were started by this failure. It ends the reservations
+// of the keys.
+func (l *Limiter) Fail(keys ...string) []Lockout {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+
+	var locked []Lockout
+
+	for _, k := range keys {
+		a := l.entry(k, now)
+		if a.pending > 0 {
+			a.pending--
+		}
+
+		a.failures++
+		a.lastFailure = now
+
+		if d := l.delay(a.failures); d > 0 {
+			a.lockedUntil = now.Add(d)
+			locked = append(locked, newLockout(k, a))
+		}
+	}
+
+	return locked
+}
+
+// Succeed forgets all failures of the given keys and ends their reservations.
+// Active lockouts are kept.
+func (l *Limiter) Succeed(keys ...string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+
+	for _, k := range keys {
+		a, ok := l.entries[k]
+		if !ok {
+			continue
+		}
+
+		if a.pending > 0 {
+			a.pending--
+		}
+
+		if !a.lockedUntil.After(now) {
+			a.failures = 0
+			if a.pending == 0 {
+				delete(l.entries, k)
+			}
+		}
+	}
+}
+
+// Release ends the reservations of the given keys without recording the outcome
+// of the attempt, e.g. if it could not be completed due to an internal error.
+func (l *Limiter) Release(keys ...string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+
+	for _, k := range keys {
+		a, ok := l.entries[k]
+		if !ok {
+			continue
+		}
+
+		if a.pending > 0 {
+			a.pending--
+		}
+
+		if a.pending == 0 && a.failures == 0 && !a.lockedUntil.After(now) {
+			delete(l.entries, k)
+		}
+	}
+}
+
+// Lockouts returns all currently active lockouts sorted by their expiration.
+func (l *Limiter) Lockouts() []Lockout {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.prune(now)
+
+	lockouts := []Lockout{}
+
+	for k, a := range l.entries {
+		if a.lockedUntil.After(now) {
+			lockouts = append(lockouts, newLockout(k, a))
+		}
+	}
+
+	sort.Slice(lockouts, func(i, j int) bool {
+		return lockouts[i].LockedUntil.Before(lockouts[j].LockedUntil)
+	})
+
+	return lockouts
+}
+
+// Clear removes the lockout and failure counter of the key. It reports
+// whether the key was tracked.
+func (l *Limiter) Clear(key string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	_, ok := l.entries[key]
+	delete(l.entries, key)
+
+	return ok
+}
+
+// delay returns the lockout duration after the given number of failures.
+func (l *Limiter) delay(failures int) time.Duration {
+	over := failures - l.free
+	if over <= 0 {
+		return 0
+	}
+
+	// avoid overflows of the exponential growth
+	if over > 62 {
+		return l.max
+	}
+
+	d := time.Duration(float64(l.base) * math.Pow(2, float64(over-1)))
+	if d > l.max || d <= 0 {
+		return l.max
+	}
+
+	return d
+}
+
+// entry returns the statistics of the key, a new entry is tracked if there is
+// none. Failures older than the reset period are forgotten.
+func (l *Limiter) entry(k string, now time.Time) *attempts {
+	a, ok := l.entries[k]
+	if ok {
+		if l.stale(a, now) {
+			a.failures = 0
+		}
+
+		return a
+	}
+
+	if len(l.entries) > pruneThreshold {
+		l.prune(now)
+	}
+
+	if len(l.entries) >= l.capacity {
+		l.evict(now)
+	}
+
+	a = &attempts{}
+	l.entries[k] = a
+
+	return a
+}
+
+// busy reports whether another attempt of the key must wait until the pending
+// ones are finished, because their failures could start a lockout.
+func (l *Limiter) busy(a *attempts, now time.Time) bool {
+	failures := a.failures
+	if l.stale(a, now) {
+		failures = 0
+	}
+
+	return a.pending > 0 && failures+a.pending >= l.free
+}
+
+// stale reports whether the failures of a key are older than the reset period.
+func (l *Limiter) stale(a *attempts, now time.Time) bool {
+	return !a.lockedUntil.After(now) && now.Sub(a.lastFailure) > l.reset
+}
+
+// expired reports whether the statistics of a key can be forgotten.
+func (l *Limiter) expired(a *attempts, now time.Time) bool {
+	return a.pending == 0 && l.stale(a, now)
+}
+
+// prune removes all expired entries.
+func (l *Limiter) prune(now time.Time) {
+	for k, a := range l.entries {
+		if l.expired(a, now) {
+			delete(l.entries, k)
+		}
+	}
+}
+
+// evict removes a single entry to make room for a new key. Entries which are not
+// locked are evicted first, starting with the least recent failure, then the
+// lockout which expires the soonest. Keys with pending attempts are evicted last.
+func (l *Limiter) evict(now time.Time) {
+	var (
+		victim string
+		oldest *attempts
+	)
+
+	for k, a := range l.entries {
+		if oldest == nil || evictBefore(a, oldest, now) {
+			victim, oldest = k, a
+		}
+	}
+
+	delete(l.entries, victim)
+}
+
+// evictBefore reports whether the entry a should be evicted before the entry b.
+func evictBefore(a, b *attempts, now time.Time) bool {
+	if (a.pending > 0) != (b.pending > 0) {
+		return b.pending > 0
+	}
+
+	aLocked, bLocked := a.lockedUntil.After(now), b.lockedUntil.After(now)
+	if aLocked != bLocked {
+		return bLocked
+	}
+
+	if aLocked {
+		return a.lockedUntil.Before(b.lockedUntil)
+	}
+
+	return a.lastFailure.Before(b.lastFailure)
+}
+
+// newLockout constructs a Lockout from a key and its statistics.
+func newLockout(key string, a *attempts) Lockout {
+	kind, subject := key, ""
+	if i := strings.Index(key, ":"); i >= 0 {
+		kind, subject = key[:i], key[i+1:]
+	}
+
+	return Lockout{
+		Kind:        kind,
+		Subject:     subject,
+		Failures:    a.failures,
+		LockedUntil: a.lockedUntil,
+	}
+}
+
+// abortLocked rejects the request of a locked out client.
+func abortLocked(c *gin.Context, wait time.Duration) {
+	c.Header("Retry-After", retryAfter(wait))
+	c.JSON(http.StatusTooManyRequests, gin.H{
+		"error": "too many failed authentication attempts, try again later",
+	})
+	c.Abort()
+}
+
+// retryAfter formats the duration as a Retry-After header value in seconds.
+func retryAfter(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
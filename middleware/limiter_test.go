@@ -0,0 +1,247 @@
+package middleware
+
+import (
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/chutommy/url-shortener/config"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestLimiter(now *time.Time) *Limiter {
+	l := NewLimiter(&config.BruteForce{
+		FreeAttempts: 2,
+		BaseDelay:    "1s",
+		MaxDelay:     "5s",
+		ResetAfter:   "1m",
+	})
+	l.now = func() time.Time {
+		return *now
+	}
+
+	return l
+}
+
+func TestLimiter_Backoff(t *testing.T) {
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	l := newTestLimiter(&now)
+	key := LockoutKey(KindIP, "127.0.0.1")
+
+	// free attempts
+	for i := 0; i < 2; i++ {
+		assert.Empty(t, l.Fail(key))
+		assert.Zero(t, l.Check(key))
+	}
+
+	// exponential backoff capped by the max delay
+	for _, want := range []time.Duration{1, 2, 4, 5, 5} {
+		lockouts := l.Fail(key)
+		if assert.Len(t, lockouts, 1) {
+			assert.Equal(t, KindIP, lockouts[0].Kind)
+			assert.Equal(t, "127.0.0.1", lockouts[0].Subject)
+		}
+
+		assert.Equal(t, want*time.Second, l.Check(key))
+	}
+
+	// lockout expires, counter is kept
+	now = now.Add(10 * time.Second)
+	assert.Zero(t, l.Check(key))
+	assert.Len(t, l.Fail(key), 1)
+
+	// counter is forgotten after the reset period
+	now = now.Add(2 * time.Minute)
+	assert.Empty(t, l.Fail(key))
+}
+
+func TestLimiter_SucceedAndClear(t *testing.T) {
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	l := newTestLimiter(&now)
+	ip, user := LockoutKey(KindIP, "127.0.0.1"), LockoutKey(KindUsername, "admin")
+
+	// success resets the counters
+	l.Fail(ip, user)
+	l.Fail(ip, user)
+	l.Succeed(ip, user)
+	assert.Empty(t, l.Fail(ip, user))
+
+	// lock out both keys
+	l.Fail(ip, user)
+	l.Fail(ip, user)
+	assert.Len(t, l.Lockouts(), 2)
+
+	// success does not lift a lockout
+	l.Succeed(ip)
+	assert.Len(t, l.Lockouts(), 2)
+
+	// clear
+	assert.True(t, l.Clear(ip))
+	assert.False(t, l.Clear(ip))
+	assert.Zero(t, l.Check(ip))
+	assert.NotZero(t, l.Check(user))
+	assert.Len(t, l.Lockouts(), 1)
+}
+
+func TestLimiter_Reservation(t *testing.T) {
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	l := newTestLimiter(&now)
+	key := LockoutKey(KindIP, "127.0.0.1")
+
+	// only the free attempts can be pending at once
+	assert.Zero(t, l.Check(key))
+	assert.Zero(t, l.Check(key))
+	assert.Equal(t, time.Second, l.Check(key))
+
+	// failures end the reservations
+	assert.Empty(t, l.Fail(key))
+	assert.Empty(t, l.Fail(key))
+
+	// attempts over the free ones are admitted one at a time
+	assert.Zero(t, l.Check(key))
+	assert.Equal(t, time.Second, l.Check(key))
+
+	// released attempt is not counted
+	l.Release(key)
+	assert.Zero(t, l.Check(key))
+	assert.Len(t, l.Fail(key), 1)
+	assert.Equal(t, time.Second, l.Check(key))
+
+	// success ends the reservation as well
+	now = now.Add(2 * time.Second)
+	assert.Zero(t, l.Check(key))
+	l.Succeed(key)
+	assert.Empty(t, l.entries)
+}
+
+func TestLimiter_ParallelAttempts(t *testing.T) {
+	l := NewLimiter(&config.BruteForce{
+		FreeAttempts: 2,
+		BaseDelay:    "1m",
+		MaxDelay:     "1h",
+	})
+	key := LockoutKey(KindIP, "127.0.0.1")
+
+	var (
+		wg       sync.WaitGroup
+		admitted int32
+	)
+
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			if l.Check(key) == 0 {
+				atomic.AddInt32(&admitted, 1)
+				l.Fail(key)
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	// free attempts and the single one which started the lockout
+	assert.True(t, admitted <= 3, admitted)
+	assert.NotZero(t, l.Check(key))
+}
+
+var clientIPTests = []struct {
+	name    string
+	proxies []string
+	remote  string
+	xff     []string
+	ip      string
+}{
+	{
+		name:   "no trusted proxies",
+		remote: "203.0.113.7:4321",
+		xff:    []string{"198.51.100.1"},
+		ip:     "203.0.113.7",
+	},
+	{
+		name:    "untrusted peer",
+		proxies: []string{"10.0.0.0/8"},
+		remote:  "203.0.113.7:4321",
+		xff:     []string{"198.51.100.1"},
+		ip:      "203.0.113.7",
+	},
+	{
+		name:    "trusted peer",
+		proxies: []string{"10.0.0.0/8"},
+		remote:  "10.0.0.2:4321",
+		xff:     []string{"198.51.100.1"},
+		ip:      "198.51.100.1",
+	},
+	{
+		name:    "spoofed hops are skipped",
+		proxies: []string{"10.0.0.0/8"},
+		remote:  "10.0.0.2:4321",
+		xff:     []string{"1.2.3.4, 198.51.100.1", "10.0.0.3"},
+		ip:      "198.51.100.1",
+	},
+	{
+		name:    "invalid hop",
+		proxies: []string{"10.0.0.0/8"},
+		remote:  "10.0.0.2:4321",
+		xff:     []string{"198.51.100.1, garbage"},
+		ip:      "10.0.0.2",
+	},
+	{
+		name:    "trusted peer without header",
+		proxies: []string{"10.0.0.2"},
+		remote:  "10.0.0.2:4321",
+		ip:      "10.0.0.2",
+	},
+}
+
+func TestLimiter_ClientIP(t *testing.T) {
+	for _, tc := range clientIPTests {
+		t.Run(tc.name, func(t *testing.T) {
+			l := NewLimiter(&config.BruteForce{TrustedProxies: tc.proxies})
+
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request = httptest.NewRequest("GET", "/", nil)
+			c.Request.RemoteAddr = tc.remote
+			c.Request.Header.Set("X-Real-IP", "192.0.2.99")
+
+			for _, v := range tc.xff {
+				c.Request.Header.Add("X-Forwarded-For", v)
+			}
+
+			assert.Equal(t, tc.ip, l.ClientIP(c))
+		})
+	}
+}
+
+func TestLimiter_MaxEntries(t *testing.T) {
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	l := newTestLimiter(&now)
+	l.capacity = 100
+	locked := LockoutKey(KindIP, "locked")
+
+	// a locked key survives the flood
+	l.Fail(locked)
+	l.Fail(locked)
+	l.Fail(locked)
+
+	for i := 0; i < 110; i++ {
+		now = now.Add(time.Millisecond)
+		l.Fail(LockoutKey(KindIP, strconv.Itoa(i)))
+	}
+
+	assert.Len(t, l.entries, 100)
+	assert.NotZero(t, l.Check(locked))
+
+	// the least recent failures are evicted first
+	_, ok := l.entries[LockoutKey(KindIP, "0")]
+	assert.False(t, ok)
+
+	_, ok = l.entries[LockoutKey(KindIP, strconv.Itoa(109))]
+	assert.True(t, ok)
+}
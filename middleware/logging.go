@@ -0,0 +1,149 @@
+package middleware
+
+import (
+	"bytes"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/chutommy/url-shortener/data"
+	"github.com/chutommy/url-shortener/logging"
+	"github.com/chutommy/url-shortener/report"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// RequestIDHeader is the header which carries the ID of a request.
+const RequestIDHeader = "X-Request-ID"
+
+// RequestIDKey is the context key of the request ID.
+const RequestIDKey = "request_id"
+
+const maxRequestIDLen = 128
+
+// RequestID middleware accepts the client's X-Request-ID header or generates a new ID.
+// The ID is echoed in the response header, added to every log line of the request,
+// to the reported errors and to the JSON bodies of the error responses.
+func RequestID(log *slog.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(RequestIDHeader)
+		if !validRequestID(id) {
+			id = uuid.New().String()
+		}
+
+		c.Set(RequestIDKey, id)
+		c.Set(logging.LoggerKey, log.With(slog.String("request_id", id)))
+		c.Set(report.RequestKey, &report.Request{
+			ID:       id,
+			Method:   c.Request.Method,
+			Route:    c.FullPath(),
+			ClientIP: c.ClientIP(),
+			Actor: func() string {
+				if typ, actor := Actor(c); typ != "" {
+					return typ + ":" + actor
+				}
+
+				return ""
+			},
+		})
+		c.Header(RequestIDHeader, id)
+
+		c.Writer = &requestIDWriter{ResponseWriter: c.Writer, id: id}
+		c.Next()
+	}
+}
+
+// validRequestID reports whether the client's request ID is safe to log and echo.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLen {
+		return false
+	}
+
+	for _, r := range id {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		case r == '-' || r == '_' || r == '.' || r == ':':
+		default:
+			return false
+		}
+	}
+
+	return true
+}
+
+// requestIDWriter adds the request ID to the JSON object of an error response.
+type requestIDWriter struct {
+	gin.ResponseWriter
+	id   string
+	done bool
+}
+
+// Write injects the request_id field into the first written JSON object of an error response.
+func (w *requestIDWriter) Write(b []byte) (int, error) {
+	if w.done || w.Status() < http.StatusBadRequest || len(b) < 2 || b[0] != '{' ||
+		!strings.HasPrefix(w.Header().Get("Content-Type"), "application/json") {
+		w.done = true
+
+		return w.ResponseWriter.Write(b)
+	}
+
+	w.done = true
+
+	var buf bytes.Buffer
+
+	buf.WriteString(`{"request_id":"` + w.id + `"`)
+
+	if rest := bytes.TrimSpace(b[1:]); len(rest) > 0 && rest[0] != '}' {
+		buf.WriteByte(',')
+	}
+
+	buf.Write(b[1:])
+
+	if _, err := w.ResponseWriter.Write(buf.Bytes()); err != nil {
+		return 0, err
+	}
+
+	return len(b), nil
+}
+
+// AccessLog middleware logs every served request with its status and latency.
+func AccessLog() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		lvl := slog.LevelInfo
+		if c.Writer.Status() >= http.StatusInternalServerError {
+			lvl = slog.LevelError
+		}
+
+		logging.FromContext(c).LogAttrs(c, lvl, "request served",
+			slog.String("method", c.Request.Method),
+			slog.String("path", c.Request.URL.Path),
+			slog.Int("status", c.Writer.Status()),
+			slog.Duration("latency", time.Since(start)),
+			slog.String("client_ip", c.ClientIP()),
+			slog.Int("size", c.Writer.Size()),
+		)
+	}
+}
+
+// Recovery middleware recovers from panics of the handlers, reports them with
+// the stack trace and responds with an internal server error.
+func Recovery(s data.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		defer func() {
+			if r := recover(); r != nil {
+				s.LogError(c, fmt.Errorf("handler panicked: %v", r))
+
+				c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+					"error": data.ErrUnexpectedError,
+				})
+			}
+		}()
+
+		c.Next()
+	}
+}
@@ -0,0 +1,72 @@
+package middleware
+
+import (
+	"io/ioutil"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequestID(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	r := gin.New()
+	r.Use(RequestID(slog.New(slog.NewTextHandler(ioutil.Discard, nil))))
+	r.GET("/ok", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"ok": true})
+	})
+	r.GET("/fail", func(c *gin.Context) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+	})
+
+	var requestIDTests = []struct {
+		name   string
+		path   string
+		header string
+		body   string
+	}{
+		{
+			name:   "success keeps body",
+			path:   "/ok",
+			header: "abc-123",
+			body:   `{"ok":true}`,
+		},
+		{
+			name:   "error gets request id",
+			path:   "/fail",
+			header: "abc-123",
+			body:   `{"request_id":"abc-123","error":"not found"}`,
+		},
+		{
+			name:   "invalid header is replaced",
+			path:   "/ok",
+			header: "bad id\n",
+			body:   `{"ok":true}`,
+		},
+	}
+
+	for _, tc := range requestIDTests {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tc.path, nil)
+			req.Header.Set(RequestIDHeader, tc.header)
+
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, tc.body, w.Body.String())
+
+			id := w.Header().Get(RequestIDHeader)
+			assert.True(t, validRequestID(id))
+
+			if validRequestID(tc.header) {
+				assert.Equal(t, tc.header, id)
+			} else {
+				assert.NotEqual(t, tc.header, id)
+			}
+		})
+	}
+}
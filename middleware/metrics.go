@@ -0,0 +1,52 @@
+package middleware
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/chutommy/url-shortener/metrics"
+	"github.com/gin-gonic/gin"
+)
+
+// unmatchedRoute labels the requests which did not match any route.
+const unmatchedRoute = "unmatched"
+
+var (
+	httpRequests = metrics.Default.Counter("http_requests_total",
+		"Served HTTP requests by method, route and status.", "method", "route", "status")
+	httpDuration = metrics.Default.Histogram("http_request_duration_seconds",
+		"Latency of the HTTP requests by method and route.", metrics.DefBuckets, "method", "route")
+	httpInFlight = metrics.Default.Gauge("http_requests_in_flight",
+		"HTTP requests which are being served.")
+	authFailures = metrics.Default.Counter("admin_auth_failures_total",
+		"Failed admin authentication attempts by the authentication method.", "method")
+)
+
+// Methods of the admin authentication.
+const (
+	authLogin     = "login"
+	authTOTP      = "totp"
+	authAdminKey  = "admin_key"
+	authSession   = "session"
+	authSignature = "signature"
+)
+
+// Metrics middleware records the rate, status and latency of the requests per route.
+func Metrics() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+
+		httpInFlight.Inc()
+		defer httpInFlight.Dec()
+
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = unmatchedRoute
+		}
+
+		httpRequests.Inc(c.Request.Method, route, strconv.Itoa(c.Writer.Status()))
+		httpDuration.Observe(time.Since(start).Seconds(), c.Request.Method, route)
+	}
+}
@@ -0,0 +1,49 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/chutommy/url-shortener/data"
+	"github.com/gin-gonic/gin"
+)
+
+// Scopes of the admin API.
+const (
+	// ScopeRead allows safe (GET, HEAD) requests.
+	ScopeRead = "read"
+	// ScopeWrite allows all other requests.
+	ScopeWrite = "write"
+)
+
+// roleScopes maps the session roles to the granted scopes.
+var roleScopes = map[string][]string{
+	data.RoleAdmin:  {ScopeRead, ScopeWrite},
+	data.RoleViewer: {ScopeRead},
+}
+
+// requiredScope returns the scope required by a request with the given method.
+func requiredScope(method string) string {
+	if method == http.MethodGet || method == http.MethodHead {
+		return ScopeRead
+	}
+
+	return ScopeWrite
+}
+
+// authorize checks that the granted scopes allow the request. Otherwise the request is aborted.
+func authorize(c *gin.Context, scopes []string) bool {
+	scope := requiredScope(c.Request.Method)
+
+	for _, s := range scopes {
+		if s == scope {
+			return true
+		}
+	}
+
+	c.JSON(http.StatusForbidden, gin.H{
+		"error": "insufficient scope, " + scope + " scope is required",
+	})
+	c.Abort()
+
+	return false
+}
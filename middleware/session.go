@@ -0,0 +1,87 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/chutommy/url-shortener/data"
+	"github.com/gin-gonic/gin"
+)
+
+// SessionKey is the context key of the *data.SessionClaims of a request
+// authorized by a session token.
+const SessionKey = "admin_session"
+
+// bearerToken loads a token from the Authorization header with the Bearer scheme.
+func bearerToken(c *gin.Context) (string, bool) {
+	auth := c.GetHeader("Authorization")
+
+	const scheme = "bearer "
+	if len(auth) <= len(scheme) || !strings.EqualFold(auth[:len(scheme)], scheme) {
+		return "", false
+	}
+
+	return strings.TrimSpace(auth[len(scheme):]), true
+}
+
+// SessionAuth middleware checks if a request is authorized by a session token
+// in the Authorization header. The scopes of the session's role are not
+// checked, so every session, even a read-only one, can end itself.
+func SessionAuth(s data.Service, l *Limiter) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token, ok := bearerToken(c)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"error": "missing session token in the Authorization header",
+			})
+			c.Abort()
+
+			return
+		}
+
+		validateSession(c, s, l, token, false)
+	}
+}
+
+// validateSession authorizes a request with the session access token. The
+// scopes of the session's role are checked if scoped is set.
+func validateSession(c *gin.Context, s data.Service, l *Limiter, token string, scoped bool) {
+	// check lockouts
+	keys := []string{LockoutKey(KindIP, l.ClientIP(c))}
+	if wait := l.Check(keys...); wait > 0 {
+		abortLocked(c, wait)
+
+		return
+	}
+
+	// validate session token
+	claims, err := s.ValidateSession(c, token)
+	if errors.Is(err, data.ErrUnauthorized) {
+		failAttempt(c, s, l, keys, authSession)
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "invalid or expired session token",
+		})
+		c.Abort()
+
+		return
+	} else if err != nil {
+		l.Release(keys...)
+		s.LogError(c, err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": data.ErrUnexpectedError,
+		})
+		c.Abort()
+
+		return
+	}
+
+	l.Succeed(keys...)
+
+	// check role
+	if scoped && !authorize(c, roleScopes[claims.Role]) {
+		return
+	}
+
+	c.Set(SessionKey, claims)
+}
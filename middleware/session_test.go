@@ -0,0 +1,73 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/chutommy/url-shortener/data"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeSessions validates the session tokens of the known roles.
+type fakeSessions struct {
+	data.Service
+}
+
+func (fakeSessions) ValidateSession(_ context.Context, token string) (*data.SessionClaims, error) {
+	switch token {
+	case data.RoleAdmin, data.RoleViewer:
+		return &data.SessionClaims{SessionID: "session-" + token, Username: "user", Role: token}, nil
+	default:
+		return nil, data.ErrUnauthorized
+	}
+}
+
+func (fakeSessions) LogLockout(context.Context, string, string, string, int, time.Time) error {
+	return nil
+}
+
+func (fakeSessions) LogError(context.Context, error) {}
+
+func TestSessionAuth(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	s := fakeSessions{}
+	l := NewLimiter(nil)
+
+	r := gin.New()
+	ok := func(c *gin.Context) { c.Status(http.StatusOK) }
+	r.POST("/logout", SessionAuth(s, l), ok)
+	r.POST("/write", ValidateAdminKey(s, l, nil, nil), ok)
+
+	tests := []struct {
+		name   string
+		path   string
+		token  string
+		status int
+	}{
+		{name: "viewer logs out", path: "/logout", token: data.RoleViewer, status: http.StatusOK},
+		{name: "admin logs out", path: "/logout", token: data.RoleAdmin, status: http.StatusOK},
+		{name: "invalid token", path: "/logout", token: "expired", status: http.StatusUnauthorized},
+		{name: "no token", path: "/logout", status: http.StatusUnauthorized},
+		{name: "viewer can not write", path: "/write", token: data.RoleViewer, status: http.StatusForbidden},
+		{name: "admin writes", path: "/write", token: data.RoleAdmin, status: http.StatusOK},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, tc.path, nil)
+			if tc.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tc.token)
+			}
+
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, tc.status, w.Code)
+		})
+	}
+}
@@ -0,0 +1,170 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/chutommy/url-shortener/client"
+	"github.com/chutommy/url-shortener/config"
+	"github.com/chutommy/url-shortener/data"
+	"github.com/gin-gonic/gin"
+)
+
+// maxSignedBodySize is the maximal size of a signed request's body.
+const maxSignedBodySize = 10 << 20
+
+// SignedPrefixKey is the context key of the admin_key prefix which signed the request.
+const SignedPrefixKey = "signed_prefix"
+
+// SignatureVerifier verifies HMAC signed requests. Each nonce is accepted only once
+// within the time the request's timestamp is considered fresh.
+type SignatureVerifier struct {
+	skew time.Duration
+
+	mu     sync.Mutex
+	nonces map[string]time.Time
+	now    func() time.Time
+}
+
+// NewSignatureVerifier is a constructor of the SignatureVerifier. A nil configuration
+// results in the default clock skew.
+func NewSignatureVerifier(cfg *config.Signing) *SignatureVerifier {
+	skew, err := cfg.ClockSkew()
+	if err != nil {
+		skew, _ = (*config.Signing)(nil).ClockSkew()
+	}
+
+	return &SignatureVerifier{
+		skew:   skew,
+		nonces: make(map[string]time.Time),
+		now:    time.Now,
+	}
+}
+
+// fresh reports whether the timestamp is within the tolerated clock skew.
+func (v *SignatureVerifier) fresh(ts int64) bool {
+	d := v.now().Sub(time.Unix(ts, 0))
+
+	return d <= v.skew && d >= -v.skew
+}
+
+// useNonce records the nonce of the prefix and reports whether it was not used before.
+func (v *SignatureVerifier) useNonce(prefix, nonce string) bool {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	now := v.now()
+	if len(v.nonces) > pruneThreshold {
+		for k, exp := range v.nonces {
+			if now.After(exp) {
+				delete(v.nonces, k)
+			}
+		}
+	}
+
+	key := prefix + ":" + nonce
+	if exp, ok := v.nonces[key]; ok && !now.After(exp) {
+		return false
+	}
+
+	// the timestamp can be fresh at most 2*skew
+	v.nonces[key] = now.Add(2 * v.skew)
+
+	return true
+}
+
+// isSigned reports whether the request carries the HMAC signature.
+func isSigned(c *gin.Context) bool {
+	return strings.HasPrefix(c.GetHeader("Authorization"), client.Scheme+" ")
+}
+
+// validateSignature authorizes a request signed by the signing secret of an admin_key.
+func validateSignature(c *gin.Context, s data.Service, l *Limiter, v *SignatureVerifier) {
+	cred, err := client.ParseAuthorization(c.GetHeader("Authorization"))
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": err.Error(),
+		})
+		c.Abort()
+
+		return
+	}
+
+	// check lockouts
+	keys := []string{LockoutKey(KindIP, l.ClientIP(c)), LockoutKey(KindPrefix, cred.Prefix)}
+	if wait := l.Check(keys...); wait > 0 {
+		abortLocked(c, wait)
+
+		return
+	}
+
+	// check timestamp
+	if !v.fresh(cred.Timestamp) {
+		l.Release(keys...)
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "request timestamp is outside of the allowed clock skew",
+		})
+		c.Abort()
+
+		return
+	}
+
+	// hash body
+	body, err := ioutil.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxSignedBodySize))
+	if err != nil {
+		l.Release(keys...)
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{
+			"error": "request body is too large",
+		})
+		c.Abort()
+
+		return
+	}
+
+	c.Request.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	// verify signature
+	secret, err := s.GetSigningSecret(c, cred.Prefix)
+	if err != nil && !errors.Is(err, data.ErrUnauthorized) {
+		l.Release(keys...)
+		s.LogError(c, err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": data.ErrUnexpectedError,
+		})
+		c.Abort()
+
+		return
+	}
+
+	sts := client.StringToSign(c.Request.Method, c.Request.URL.EscapedPath(), c.Request.URL.RawQuery,
+		cred.Timestamp, cred.Nonce, client.BodyHash(body))
+	if err != nil || !hmac.Equal([]byte(client.Signature([]byte(secret), sts)), []byte(cred.Signature)) {
+		failAttempt(c, s, l, keys, authSignature)
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "invalid request signature",
+		})
+		c.Abort()
+
+		return
+	}
+
+	// prevent replays
+	if !v.useNonce(cred.Prefix, cred.Nonce) {
+		l.Release(keys...)
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "request nonce was already used",
+		})
+		c.Abort()
+
+		return
+	}
+
+	l.Succeed(keys...)
+	c.Set(SignedPrefixKey, cred.Prefix)
+}
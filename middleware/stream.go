@@ -0,0 +1,39 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ResponseControllerKey is the request context key of the controller of the
+// server's response writer.
+const ResponseControllerKey = "response_controller"
+
+// ErrNoResponseController is returned if the request is not served by the ResponseControllers handler.
+var ErrNoResponseController = errors.New("response controller is not available")
+
+// ResponseControllers wraps the handler, so the handlers of the streamed
+// responses can extend the write deadline of the server. The writer of gin
+// does not expose the controller of the underlying writer.
+func ResponseControllers(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := context.WithValue(r.Context(), ResponseControllerKey, http.NewResponseController(w)) //nolint:staticcheck
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// ExtendWriteDeadline moves the write deadline of the response d from now, so
+// a streamed response is not cut by the write timeout of the server as long
+// as it makes progress.
+func ExtendWriteDeadline(c *gin.Context, d time.Duration) error {
+	rc, ok := c.Request.Context().Value(ResponseControllerKey).(*http.ResponseController)
+	if !ok {
+		return ErrNoResponseController
+	}
+
+	return rc.SetWriteDeadline(time.Now().Add(d))
+}
@@ -0,0 +1,67 @@
+package middleware
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExtendWriteDeadline(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	const timeout = 100 * time.Millisecond
+
+	r := gin.New()
+	r.GET("/stream", func(c *gin.Context) {
+		if c.Query("extend") != "" {
+			assert.NoError(t, ExtendWriteDeadline(c, time.Second))
+		}
+
+		c.Status(http.StatusOK)
+		c.Writer.Flush()
+		time.Sleep(3 * timeout)
+		_, _ = c.Writer.WriteString("done")
+	})
+
+	srv := httptest.NewUnstartedServer(ResponseControllers(r))
+	srv.Config.WriteTimeout = timeout
+	srv.Start()
+
+	defer srv.Close()
+
+	get := func(path string) (string, error) {
+		resp, err := http.Get(srv.URL + path)
+		if err != nil {
+			return "", err
+		}
+
+		defer func() {
+			_ = resp.Body.Close()
+		}()
+
+		body, err := ioutil.ReadAll(resp.Body)
+
+		return string(body), err
+	}
+
+	body, err := get("/stream?extend=1")
+	assert.NoError(t, err)
+	assert.Equal(t, "done", body)
+
+	body, _ = get("/stream")
+	assert.NotEqual(t, "done", body)
+}
+
+func TestExtendWriteDeadline_NoController(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodGet, "/", nil)
+
+	assert.Equal(t, ErrNoResponseController, ExtendWriteDeadline(c, time.Second))
+}
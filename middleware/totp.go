@@ -0,0 +1,26 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TOTPVerifiedKey is the context key which is set to true if the login was
+// confirmed by a valid second factor.
+const TOTPVerifiedKey = "totp_verified"
+
+// RequireTOTP middleware rejects logins which were not confirmed by a second factor.
+// It must be used after the AdminLogin middleware.
+func RequireTOTP() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !c.GetBool(TOTPVerifiedKey) {
+			c.JSON(http.StatusForbidden, gin.H{
+				"error": "two-factor authentication is required, enroll at /v1/login/totp/enroll",
+			})
+			c.Abort()
+
+			return
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package middleware
+
+import (
+	"log/slog"
+	"net/http"
+
+	"github.com/chutommy/url-shortener/logging"
+	"github.com/chutommy/url-shortener/tracing"
+	"github.com/gin-gonic/gin"
+)
+
+// Tracing middleware starts a server span of every request. The span continues
+// the trace of the W3C traceparent header if the client sent one.
+func Tracing() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		route := c.FullPath()
+		if route == "" {
+			route = unmatchedRoute
+		}
+
+		parent := tracing.Extract(c.Request.Header)
+
+		_, span := tracing.Default().StartWithParent(c, parent, c.Request.Method+" "+route, tracing.KindServer,
+			tracing.String("http.method", c.Request.Method),
+			tracing.String("http.route", route),
+			tracing.String("http.target", c.Request.URL.Path),
+			tracing.String("http.client_ip", c.ClientIP()),
+			tracing.String("http.request_id", c.GetString(RequestIDKey)),
+		)
+		if span == nil {
+			c.Next()
+
+			return
+		}
+
+		defer span.End()
+
+		c.Set(tracing.SpanKey, span)
+		c.Set(logging.LoggerKey, logging.FromContext(c).With(
+			slog.String("trace_id", span.Context().TraceID.String()),
+		))
+
+		c.Next()
+
+		status := c.Writer.Status()
+		span.SetAttrs(tracing.Int("http.status_code", status))
+
+		if status >= http.StatusInternalServerError {
+			span.SetError(&statusError{status: status})
+		}
+	}
+}
+
+// statusError describes a failed response.
+type statusError struct {
+	status int
+}
+
+func (e *statusError) Error() string {
+	return http.StatusText(e.status)
+}
+
+// Traced wraps the middleware into a span with the given name. The middleware
+// must not call c.Next, so the span does not include the following handlers.
+func Traced(name string, hf gin.HandlerFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		parent := tracing.FromContext(c)
+
+		_, span := tracing.Start(c, "middleware."+name)
+		if span == nil {
+			hf(c)
+
+			return
+		}
+
+		c.Set(tracing.SpanKey, span)
+		hf(c)
+		span.SetAttrs(tracing.Bool("aborted", c.IsAborted()))
+		span.End()
+		c.Set(tracing.SpanKey, parent)
+	}
+}
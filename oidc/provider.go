@@ -0,0 +1,338 @@
+// Package oidc implements the OpenID Connect authorization code flow with PKCE
+// used to log in admin users via an external identity provider.
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/chutommy/url-shortener/config"
+	"github.com/chutommy/url-shortener/tracing"
+)
+
+const (
+	discoveryPath = "/.well-known/openid-configuration"
+	httpTimeout   = 10 * time.Second
+	maxBodySize   = 1 << 20
+	randLen       = 32
+)
+
+var (
+	// ErrInvalidState is returned if the callback's state is unknown, expired or started by another browser.
+	ErrInvalidState = errors.New("invalid or expired oidc state")
+	// ErrInvalidToken is returned if the ID token can not be verified.
+	ErrInvalidToken = errors.New("invalid oidc id token")
+	// ErrNoRole is returned if the identity is not mapped to any admin role.
+	ErrNoRole = errors.New("identity is not mapped to any admin role")
+	// ErrProvider is returned if the identity provider responds unexpectedly.
+	ErrProvider = errors.New("unexpected identity provider response")
+)
+
+// discovery holds the used values of the provider's discovery document.
+type discovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// Identity is a verified identity of a logged user.
+type Identity struct {
+	Subject  string
+	Username string
+	Role     string
+}
+
+// Provider is a client of an OpenID Connect identity provider. The discovery
+// document is fetched on the first use and the keys are cached.
+type Provider struct {
+	cfg    *config.OIDC
+	client *http.Client
+	states *stateStore
+
+	mu   sync.Mutex
+	disc *discovery
+	keys *keySet
+}
+
+// NewProvider is a constructor of the Provider. It does not contact the identity provider.
+func NewProvider(cfg *config.OIDC) *Provider {
+	return &Provider{
+		cfg:    cfg,
+		client: &http.Client{Timeout: httpTimeout, Transport: &tracing.Transport{}},
+		states: newStateStore(),
+	}
+}
+
+// Begin starts a new login and returns the URL of the identity provider's
+// authorization endpoint to which the user should be redirected, and the
+// binding of the login, a secret which must be kept by the user's browser
+// and passed to Complete.
+func (p *Provider) Begin(ctx context.Context) (authURL, binding string, err error) {
+	disc, err := p.discover(ctx)
+	if err != nil {
+		return "", "", err
+	}
+
+	// generate login parameters
+	state, nonce, verifier := randString(), randString(), randString()
+	binding = randString()
+	p.states.put(pending{
+		state:    state,
+		binding:  binding,
+		nonce:    nonce,
+		verifier: verifier,
+	})
+
+	q := url.Values{}
+	q.Set("response_type", "code")
+	q.Set("client_id", p.cfg.ClientID)
+	q.Set("redirect_uri", p.cfg.RedirectURL)
+	q.Set("scope", strings.Join(p.scopes(), " "))
+	q.Set("state", state)
+	q.Set("nonce", nonce)
+	q.Set("code_challenge", challenge(verifier))
+	q.Set("code_challenge_method", "S256")
+
+	sep := "?"
+	if strings.Contains(disc.AuthorizationEndpoint, "?") {
+		sep = "&"
+	}
+
+	return disc.AuthorizationEndpoint + sep + q.Encode(), binding, nil
+}
+
+// Complete finishes the login of the given state started by the browser with
+// the binding. The authorization code is exchanged for an ID token, which is
+// verified and its claims are mapped to an admin role.
+func (p *Provider) Complete(ctx context.Context, state, binding, code string) (*Identity, error) {
+	pend, ok := p.states.take(state, binding)
+	if !ok {
+		return nil, ErrInvalidState
+	}
+
+	disc, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// exchange code
+	rawToken, err := p.exchange(ctx, disc, code, pend.verifier)
+	if err != nil {
+		return nil, err
+	}
+
+	// verify token
+	claims, err := p.verify(ctx, disc, rawToken, pend.nonce, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	return p.identity(claims)
+}
+
+// discover fetches and caches the discovery document of the issuer.
+func (p *Provider) discover(ctx context.Context) (*discovery, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.disc != nil {
+		return p.disc, nil
+	}
+
+	var disc discovery
+	if err := p.getJSON(ctx, strings.TrimSuffix(p.cfg.Issuer, "/")+discoveryPath, &disc); err != nil {
+		return nil, fmt.Errorf("oidc discovery failed: %w", err)
+	}
+
+	if disc.Issuer != p.cfg.Issuer || disc.AuthorizationEndpoint == "" ||
+		disc.TokenEndpoint == "" || disc.JWKSURI == "" {
+		return nil, fmt.Errorf("oidc discovery failed: %w", ErrProvider)
+	}
+
+	p.disc = &disc
+	p.keys = newKeySet(disc.JWKSURI, p.getJSON)
+
+	return p.disc, nil
+}
+
+// exchange exchanges the authorization code for an ID token.
+func (p *Provider) exchange(ctx context.Context, disc *discovery, code, verifier string) (string, error) {
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", p.cfg.RedirectURL)
+	form.Set("client_id", p.cfg.ClientID)
+	form.Set("code_verifier", verifier)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, disc.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", fmt.Errorf("failed to create token request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	if p.cfg.ClientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(p.cfg.ClientID), url.QueryEscape(p.cfg.ClientSecret))
+	}
+
+	var tok struct {
+		IDToken string `json:"id_token"`
+	}
+
+	if err = p.doJSON(req, &tok); err != nil {
+		return "", fmt.Errorf("token exchange failed: %w", err)
+	}
+
+	if tok.IDToken == "" {
+		return "", fmt.Errorf("token exchange failed: missing id_token: %w", ErrProvider)
+	}
+
+	return tok.IDToken, nil
+}
+
+// identity maps the verified claims to an admin identity.
+func (p *Provider) identity(claims map[string]interface{}) (*Identity, error) {
+	sub, _ := claims["sub"].(string)
+
+	usernameClaim := p.cfg.UsernameClaim
+	if usernameClaim == "" {
+		usernameClaim = "email"
+	}
+
+	username, _ := claims[usernameClaim].(string)
+	if username == "" {
+		username = sub
+	}
+
+	rolesClaim := p.cfg.RolesClaim
+	if rolesClaim == "" {
+		rolesClaim = "groups"
+	}
+
+	// pick the most privileged mapped role
+	var role string
+
+	for _, v := range claimValues(claims[rolesClaim]) {
+		switch p.cfg.RoleMapping[v] {
+		case "admin":
+			role = "admin"
+		case "viewer":
+			if role == "" {
+				role = "viewer"
+			}
+		}
+	}
+
+	if role == "" {
+		return nil, ErrNoRole
+	}
+
+	return &Identity{
+		Subject:  sub,
+		Username: username,
+		Role:     role,
+	}, nil
+}
+
+// scopes returns the requested scopes, openid is always included.
+func (p *Provider) scopes() []string {
+	scopes := []string{"openid"}
+
+	for _, s := range p.cfg.Scopes {
+		if s != "openid" {
+			scopes = append(scopes, s)
+		}
+	}
+
+	return scopes
+}
+
+// getJSON fetches and decodes a JSON document.
+func (p *Provider) getJSON(ctx context.Context, u string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("Accept", "application/json")
+
+	return p.doJSON(req, v)
+}
+
+// doJSON sends the request and decodes the JSON response.
+func (p *Provider) doJSON(req *http.Request, v interface{}) error {
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("request to %s failed: %w", req.URL.Host, err)
+	}
+
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxBodySize))
+	if err != nil {
+		return fmt.Errorf("failed to read response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%w: status %d", ErrProvider, resp.StatusCode)
+	}
+
+	if err = json.Unmarshal(body, v); err != nil {
+		return fmt.Errorf("%w: %v", ErrProvider, err)
+	}
+
+	return nil
+}
+
+// claimValues converts a string or a list claim into a slice of strings.
+func claimValues(v interface{}) []string {
+	switch t := v.(type) {
+	case string:
+		return []string{t}
+	case []interface{}:
+		values := make([]string, 0, len(t))
+
+		for _, e := range t {
+			if s, ok := e.(string); ok {
+				values = append(values, s)
+			}
+		}
+
+		return values
+	}
+
+	return nil
+}
+
+// challenge returns the S256 PKCE code challenge of the verifier.
+func challenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// randString returns a random URL-safe string.
+func randString() string {
+	b := make([]byte, randLen)
+	if _, err := rand.Read(b); err != nil {
+		panic(fmt.Sprintf("crypto/rand failure: %v", err))
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b)
+}
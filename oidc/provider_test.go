@@ -0,0 +1,276 @@
+package oidc_test
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/chutommy/url-shortener/config"
+	"github.com/chutommy/url-shortener/oidc"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	testClientID = "url-shortener"
+	testCode     = "authorization-code"
+	testKeyID    = "test-key"
+)
+
+// testIdP is a local stand-in identity provider.
+type testIdP struct {
+	srv    *httptest.Server
+	key    *rsa.PrivateKey
+	claims map[string]interface{}
+
+	// values of the last authorization request
+	nonce     string
+	challenge string
+}
+
+func newTestIdP(t *testing.T) *testIdP {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	idp := &testIdP{key: key}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, map[string]string{
+			"issuer":                 idp.srv.URL,
+			"authorization_endpoint": idp.srv.URL + "/authorize",
+			"token_endpoint":         idp.srv.URL + "/token",
+			"jwks_uri":               idp.srv.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, map[string]interface{}{
+			"keys": []map[string]string{{
+				"kty": "RSA",
+				"kid": testKeyID,
+				"use": "sig",
+				"n":   b64(key.PublicKey.N.Bytes()),
+				"e":   b64(big.NewInt(int64(key.PublicKey.E)).Bytes()),
+			}},
+		})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		sum := sha256.Sum256([]byte(r.PostFormValue("code_verifier")))
+		if r.PostFormValue("code") != testCode || b64(sum[:]) != idp.challenge {
+			w.WriteHeader(http.StatusBadRequest)
+
+			return
+		}
+
+		writeJSON(w, map[string]string{
+			"id_token": idp.sign(t, idp.claims),
+		})
+	})
+
+	idp.srv = httptest.NewServer(mux)
+
+	return idp
+}
+
+// authorize simulates the user's login at the authorization endpoint.
+func (idp *testIdP) authorize(t *testing.T, authURL string) string {
+	u, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	q := u.Query()
+	assert.Equal(t, "S256", q.Get("code_challenge_method"))
+	assert.Equal(t, testClientID, q.Get("client_id"))
+
+	idp.nonce = q.Get("nonce")
+	idp.challenge = q.Get("code_challenge")
+
+	return q.Get("state")
+}
+
+// sign creates an RS256 signed ID token.
+func (idp *testIdP) sign(t *testing.T, claims map[string]interface{}) string {
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "kid": testKeyID})
+
+	full := map[string]interface{}{
+		"iss":   idp.srv.URL,
+		"aud":   testClientID,
+		"sub":   "user-1",
+		"nonce": idp.nonce,
+		"iat":   time.Now().Unix(),
+		"exp":   time.Now().Add(time.Minute).Unix(),
+	}
+	for k, v := range claims {
+		full[k] = v
+	}
+
+	payload, _ := json.Marshal(full)
+	unsigned := b64(header) + "." + b64(payload)
+
+	sum := sha256.Sum256([]byte(unsigned))
+
+	sig, err := rsa.SignPKCS1v15(rand.Reader, idp.key, crypto.SHA256, sum[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return unsigned + "." + b64(sig)
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func b64(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+var completeTests = []struct {
+	name   string
+	claims map[string]interface{}
+	id     *oidc.Identity
+	err    error
+}{
+	{
+		name: "admin group",
+		claims: map[string]interface{}{
+			"email":  "jane@example.com",
+			"groups": []string{"staff", "shortener-admins"},
+		},
+		id: &oidc.Identity{Subject: "user-1", Username: "jane@example.com", Role: "admin"},
+	},
+	{
+		name: "viewer group",
+		claims: map[string]interface{}{
+			"email":  "joe@example.com",
+			"groups": []string{"marketing"},
+		},
+		id: &oidc.Identity{Subject: "user-1", Username: "joe@example.com", Role: "viewer"},
+	},
+	{
+		name: "unmapped group",
+		claims: map[string]interface{}{
+			"groups": []string{"staff"},
+		},
+		err: oidc.ErrNoRole,
+	},
+	{
+		name: "wrong audience",
+		claims: map[string]interface{}{
+			"aud":    "another-client",
+			"groups": []string{"shortener-admins"},
+		},
+		err: oidc.ErrInvalidToken,
+	},
+	{
+		name: "wrong nonce",
+		claims: map[string]interface{}{
+			"nonce":  "replayed",
+			"groups": []string{"shortener-admins"},
+		},
+		err: oidc.ErrInvalidToken,
+	},
+	{
+		name: "expired token",
+		claims: map[string]interface{}{
+			"exp":    time.Now().Add(-time.Hour).Unix(),
+			"groups": []string{"shortener-admins"},
+		},
+		err: oidc.ErrInvalidToken,
+	},
+}
+
+func TestProvider_Complete(t *testing.T) {
+	idp := newTestIdP(t)
+	defer idp.srv.Close()
+
+	p := oidc.NewProvider(&config.OIDC{
+		Issuer:      idp.srv.URL,
+		ClientID:    testClientID,
+		RedirectURL: "http://localhost:8080/v1/oidc/callback",
+		RoleMapping: map[string]string{
+			"shortener-admins": "admin",
+			"marketing":        "viewer",
+		},
+	})
+
+	for _, tc := range completeTests {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+
+			authURL, binding, err := p.Begin(ctx)
+			if !assert.Nil(t, err) {
+				return
+			}
+
+			state := idp.authorize(t, authURL)
+			idp.claims = tc.claims
+
+			id, err := p.Complete(ctx, state, binding, testCode)
+			if tc.err != nil {
+				assert.True(t, errors.Is(err, tc.err), err)
+
+				return
+			}
+
+			assert.Nil(t, err)
+			assert.Equal(t, tc.id, id)
+
+			// state can not be reused
+			_, err = p.Complete(ctx, state, binding, testCode)
+			assert.True(t, errors.Is(err, oidc.ErrInvalidState), err)
+		})
+	}
+}
+
+func TestProvider_CompleteUnknownState(t *testing.T) {
+	p := oidc.NewProvider(&config.OIDC{})
+
+	_, err := p.Complete(context.Background(), "unknown", "", testCode)
+	assert.True(t, errors.Is(err, oidc.ErrInvalidState), err)
+}
+
+func TestProvider_CompleteOtherBrowser(t *testing.T) {
+	idp := newTestIdP(t)
+	defer idp.srv.Close()
+
+	p := oidc.NewProvider(&config.OIDC{
+		Issuer:      idp.srv.URL,
+		ClientID:    testClientID,
+		RedirectURL: "http://localhost:8080/v1/oidc/callback",
+		RoleMapping: map[string]string{"shortener-admins": "admin"},
+	})
+
+	ctx := context.Background()
+
+	authURL, _, err := p.Begin(ctx)
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	// the login can not be finished by a browser without the binding
+	state := idp.authorize(t, authURL)
+	idp.claims = map[string]interface{}{"groups": []string{"shortener-admins"}}
+
+	_, otherBinding, err := p.Begin(ctx)
+	assert.Nil(t, err)
+
+	for _, binding := range []string{"", otherBinding} {
+		_, err = p.Complete(ctx, state, binding, testCode)
+		assert.True(t, errors.Is(err, oidc.ErrInvalidState), err)
+	}
+}
@@ -0,0 +1,91 @@
+package oidc
+
+import (
+	"container/list"
+	"crypto/subtle"
+	"sync"
+	"time"
+)
+
+const (
+	// stateTTL is the time within which the user must finish the login.
+	stateTTL = 10 * time.Minute
+	// maxStates is the maximum number of the unfinished logins, the oldest is dropped beyond it.
+	maxStates = 10000
+)
+
+// pending holds the secrets of an unfinished login. The binding is the secret
+// kept by the browser which started the login.
+type pending struct {
+	state    string
+	binding  string
+	nonce    string
+	verifier string
+	expires  time.Time
+}
+
+// stateStore keeps a bounded number of the unfinished logins in memory. Each
+// state can be used only once. The logins are kept in the order of their
+// expiry, so the expired ones are removed from the front without a full scan.
+type stateStore struct {
+	mu     sync.Mutex
+	states map[string]*list.Element
+	order  *list.List
+	max    int
+	now    func() time.Time
+}
+
+// newStateStore is a constructor of the stateStore.
+func newStateStore() *stateStore {
+	return &stateStore{
+		states: make(map[string]*list.Element),
+		order:  list.New(),
+		max:    maxStates,
+		now:    time.Now,
+	}
+}
+
+// put stores a new login, removes the expired ones and drops the oldest one if the store is full.
+func (s *stateStore) put(p pending) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	for e := s.order.Front(); e != nil; e = s.order.Front() {
+		if old := e.Value.(pending); !now.After(old.expires) && s.order.Len() < s.max {
+			break
+		}
+
+		s.remove(e)
+	}
+
+	p.expires = now.Add(stateTTL)
+	s.states[p.state] = s.order.PushBack(p)
+}
+
+// take removes and returns the login of the state if it has not expired and
+// it was started by the browser with the binding.
+func (s *stateStore) take(state, binding string) (pending, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, ok := s.states[state]
+	if !ok {
+		return pending{}, false
+	}
+
+	s.remove(e)
+
+	p := e.Value.(pending)
+	if s.now().After(p.expires) || subtle.ConstantTimeCompare([]byte(p.binding), []byte(binding)) != 1 {
+		return pending{}, false
+	}
+
+	return p, true
+}
+
+// remove removes the login of the element.
+func (s *stateStore) remove(e *list.Element) {
+	delete(s.states, e.Value.(pending).state)
+	s.order.Remove(e)
+}
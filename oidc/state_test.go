@@ -0,0 +1,65 @@
+package oidc
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStateStore(t *testing.T) {
+	now := time.Date(2024, time.March, 1, 12, 0, 0, 0, time.UTC)
+
+	s := newStateStore()
+	s.max = 3
+	s.now = func() time.Time { return now }
+
+	put := func(state string) {
+		s.put(pending{state: state, binding: "b-" + state, nonce: "n-" + state})
+	}
+
+	put("a")
+
+	now = now.Add(stateTTL / 2)
+	put("b")
+	put("c")
+
+	// the full store drops the oldest login
+	put("d")
+	assert.Equal(t, 3, s.order.Len())
+
+	_, ok := s.take("a", "b-a")
+	assert.False(t, ok)
+
+	// the login is bound to the browser which started it and used only once
+	_, ok = s.take("b", "b-c")
+	assert.False(t, ok)
+
+	_, ok = s.take("b", "b-b")
+	assert.False(t, ok)
+
+	p, ok := s.take("c", "b-c")
+	assert.True(t, ok)
+	assert.Equal(t, "n-c", p.nonce)
+
+	// the expired logins are removed by the next put
+	now = now.Add(stateTTL + time.Second)
+
+	_, ok = s.take("d", "b-d")
+	assert.False(t, ok)
+
+	for i := 0; i < 2; i++ {
+		put(strconv.Itoa(i))
+	}
+
+	now = now.Add(stateTTL + time.Second)
+	put("e")
+
+	assert.Equal(t, 1, s.order.Len())
+	assert.Len(t, s.states, 1)
+
+	_, ok = s.take("e", "b-e")
+	assert.True(t, ok)
+	assert.Equal(t, 0, s.order.Len())
+}
@@ -0,0 +1,272 @@
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/chutommy/url-shortener/metrics"
+)
+
+const (
+	// keysRefreshInterval limits how often are the keys refetched because of an unknown key ID.
+	keysRefreshInterval = time.Minute
+	// clockSkew is the tolerated difference between the clocks of the provider and the server.
+	clockSkew = time.Minute
+)
+
+// jwk is a single JSON Web Key of the provider.
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// jwksCache is the name of the keys cache in the metrics.
+const jwksCache = "oidc_jwks"
+
+// keySet caches the provider's signing keys. The keys are refetched when a token
+// is signed by an unknown key, which allows the provider to rotate its keys.
+type keySet struct {
+	uri   string
+	fetch func(context.Context, string, interface{}) error
+
+	mu      sync.Mutex
+	keys    map[string]crypto.PublicKey
+	fetched time.Time
+}
+
+// newKeySet is a constructor of the keySet.
+func newKeySet(uri string, fetch func(context.Context, string, interface{}) error) *keySet {
+	return &keySet{
+		uri:   uri,
+		fetch: fetch,
+	}
+}
+
+// key returns the public key with the given ID.
+func (ks *keySet) key(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	if k, ok := ks.keys[kid]; ok {
+		metrics.ObserveCache(jwksCache, true)
+
+		return k, nil
+	}
+
+	metrics.ObserveCache(jwksCache, false)
+
+	if ks.keys != nil && time.Since(ks.fetched) < keysRefreshInterval {
+		return nil, fmt.Errorf("%w: unknown key id", ErrInvalidToken)
+	}
+
+	// refetch keys
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+
+	if err := ks.fetch(ctx, ks.uri, &set); err != nil {
+		return nil, fmt.Errorf("failed to fetch jwks: %w", err)
+	}
+
+	ks.keys = make(map[string]crypto.PublicKey, len(set.Keys))
+	ks.fetched = time.Now()
+
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+
+		if pub, err := k.publicKey(); err == nil {
+			ks.keys[k.Kid] = pub
+		}
+	}
+
+	k, ok := ks.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown key id", ErrInvalidToken)
+	}
+
+	return k, nil
+}
+
+// publicKey decodes the RSA or P-256 EC public key.
+func (k *jwk) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+
+		e, err := decodeInt(k.E)
+		if err != nil || !e.IsInt64() {
+			return nil, fmt.Errorf("invalid rsa exponent")
+		}
+
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+
+	case "EC":
+		if k.Crv != "P-256" {
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+
+		x, err := decodeInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+
+		y, err := decodeInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+
+		return &ecdsa.PublicKey{Curve: elliptic.P256(), X: x, Y: y}, nil
+	}
+
+	return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+}
+
+// verify verifies the signature and the standard claims of the ID token and returns its claims.
+func (p *Provider) verify(ctx context.Context, disc *discovery, raw, nonce string, now time.Time) (map[string]interface{}, error) {
+	parts := strings.Split(raw, ".")
+	if len(parts) != 3 {
+		return nil, ErrInvalidToken
+	}
+
+	// decode header
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	// verify signature
+	key, err := p.keys.key(ctx, header.Kid)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = verifySignature(header.Alg, key, parts[0]+"."+parts[1], sig); err != nil {
+		return nil, err
+	}
+
+	// validate claims
+	var claims map[string]interface{}
+	if err = decodeSegment(parts[1], &claims); err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	if iss, _ := claims["iss"].(string); iss != disc.Issuer {
+		return nil, fmt.Errorf("%w: issuer mismatch", ErrInvalidToken)
+	}
+
+	aud := claimValues(claims["aud"])
+	if !contains(aud, p.cfg.ClientID) {
+		return nil, fmt.Errorf("%w: audience mismatch", ErrInvalidToken)
+	}
+
+	if azp, ok := claims["azp"].(string); len(aud) > 1 && (!ok || azp != p.cfg.ClientID) {
+		return nil, fmt.Errorf("%w: authorized party mismatch", ErrInvalidToken)
+	}
+
+	if n, _ := claims["nonce"].(string); n != nonce {
+		return nil, fmt.Errorf("%w: nonce mismatch", ErrInvalidToken)
+	}
+
+	exp, ok := claims["exp"].(float64)
+	if !ok || now.Add(-clockSkew).After(time.Unix(int64(exp), 0)) {
+		return nil, fmt.Errorf("%w: token expired", ErrInvalidToken)
+	}
+
+	if iat, ok := claims["iat"].(float64); ok && now.Add(clockSkew).Before(time.Unix(int64(iat), 0)) {
+		return nil, fmt.Errorf("%w: token issued in the future", ErrInvalidToken)
+	}
+
+	return claims, nil
+}
+
+// verifySignature verifies an RS256 or ES256 signature of the signed content.
+func verifySignature(alg string, key crypto.PublicKey, signed string, sig []byte) error {
+	sum := sha256.Sum256([]byte(signed))
+
+	switch alg {
+	case "RS256":
+		pub, ok := key.(*rsa.PublicKey)
+		if !ok || rsa.VerifyPKCS1v15(pub, crypto.SHA256, sum[:], sig) != nil {
+			return fmt.Errorf("%w: invalid signature", ErrInvalidToken)
+		}
+
+		return nil
+
+	case "ES256":
+		pub, ok := key.(*ecdsa.PublicKey)
+		if !ok || len(sig) != 64 {
+			return fmt.Errorf("%w: invalid signature", ErrInvalidToken)
+		}
+
+		r, s := new(big.Int).SetBytes(sig[:32]), new(big.Int).SetBytes(sig[32:])
+		if !ecdsa.Verify(pub, sum[:], r, s) {
+			return fmt.Errorf("%w: invalid signature", ErrInvalidToken)
+		}
+
+		return nil
+	}
+
+	return fmt.Errorf("%w: unsupported algorithm %q", ErrInvalidToken, alg)
+}
+
+// decodeSegment decodes a base64url encoded JSON segment of a token.
+func decodeSegment(seg string, v interface{}) error {
+	raw, err := base64.RawURLEncoding.DecodeString(seg)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(raw, v)
+}
+
+// decodeInt decodes a base64url encoded big-endian integer.
+func decodeInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil || len(b) == 0 {
+		return nil, fmt.Errorf("invalid key parameter")
+	}
+
+	return new(big.Int).SetBytes(b), nil
+}
+
+// contains reports whether the slice contains the value.
+func contains(values []string, v string) bool {
+	for _, s := range values {
+		if s == v {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,127 @@
+// Package report describes the unexpected errors of the service and delivers
+// them to the configured reporters.
+package report
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"regexp"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// RequestKey is the context key of the *Request. It is a plain string, so the
+// request can be stored in and loaded from a *gin.Context.
+const RequestKey = "report_request"
+
+const (
+	fingerprintLen = 16
+	maxStackDepth  = 32
+)
+
+// Request describes the request during which an error occurred.
+type Request struct {
+	ID       string
+	Method   string
+	Route    string
+	ClientIP string
+
+	// Actor resolves the authenticated actor at the time of the report.
+	Actor func() string
+}
+
+// Event is a single occurrence of an unexpected error.
+type Event struct {
+	Fingerprint string    `json:"fingerprint"`
+	Type        string    `json:"type"`
+	Message     string    `json:"message"`
+	RequestID   string    `json:"request_id,omitempty"`
+	Method      string    `json:"method,omitempty"`
+	Route       string    `json:"route,omitempty"`
+	Actor       string    `json:"actor,omitempty"`
+	ClientIP    string    `json:"client_ip,omitempty"`
+	Stack       string    `json:"stack"`
+	OccurredAt  time.Time `json:"occurred_at"`
+}
+
+// Reporter delivers the error events.
+type Reporter interface {
+	Report(context.Context, *Event) error
+}
+
+// NewEvent describes the error together with the request of the context and
+// the stack of the caller. Skip is the number of the caller's callers to omit.
+func NewEvent(ctx context.Context, err error, skip int) *Event {
+	e := &Event{
+		Type:       errorType(err),
+		Message:    err.Error(),
+		Stack:      stack(skip + 2),
+		OccurredAt: time.Now().UTC(),
+	}
+
+	if r, ok := ctx.Value(RequestKey).(*Request); ok {
+		e.RequestID = r.ID
+		e.Method = r.Method
+		e.Route = r.Route
+		e.ClientIP = r.ClientIP
+
+		if r.Actor != nil {
+			e.Actor = r.Actor()
+		}
+	}
+
+	e.Fingerprint = Fingerprint(e.Method, e.Route, e.Type, e.Message)
+
+	return e
+}
+
+// variableParts matches the parts of the messages which differ between
+// occurrences of the same error, such as ids, numbers and quoted values.
+var variableParts = regexp.MustCompile(
+	`[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}|"[^"]*"|'[^']*'|\d+`)
+
+// Fingerprint groups occurrences of the same error. The variable parts of the
+// message are ignored.
+func Fingerprint(method, route, errType, message string) string {
+	normalized := variableParts.ReplaceAllString(message, "?")
+
+	sum := sha256.Sum256([]byte(strings.Join([]string{method, route, errType, normalized}, "\n")))
+
+	return hex.EncodeToString(sum[:])[:fingerprintLen]
+}
+
+// errorType returns the type of the innermost wrapped error.
+func errorType(err error) string {
+	for {
+		next := errors.Unwrap(err)
+		if next == nil {
+			return fmt.Sprintf("%T", err)
+		}
+
+		err = next
+	}
+}
+
+// stack formats the stack trace of the caller.
+func stack(skip int) string {
+	pcs := make([]uintptr, maxStackDepth)
+	n := runtime.Callers(skip+1, pcs)
+
+	var b strings.Builder
+
+	frames := runtime.CallersFrames(pcs[:n])
+	for {
+		f, more := frames.Next()
+		fmt.Fprintf(&b, "%s\n\t%s:%d\n", f.Function, f.File, f.Line)
+
+		if !more {
+			break
+		}
+	}
+
+	return b.String()
+}
@@ -0,0 +1,69 @@
+package report_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/chutommy/url-shortener/report"
+	"github.com/stretchr/testify/assert"
+)
+
+var fingerprintTests = []struct {
+	name string
+	a, b string
+	same bool
+}{
+	{
+		name: "different ids",
+		a:    "record 0b7f0c8e-52a8-4c2a-9a59-1f0f4c1d5b6a was not updated",
+		b:    "record 7c5e1f77-5fd2-4f0e-8a5b-5a4c3d2e1f0a was not updated",
+		same: true,
+	},
+	{
+		name: "different numbers and quoted values",
+		a:    `pq: duplicate key "abc" after 3 attempts`,
+		b:    `pq: duplicate key "xyz" after 12 attempts`,
+		same: true,
+	},
+	{
+		name: "different messages",
+		a:    "connection refused",
+		b:    "connection reset by peer",
+		same: false,
+	},
+}
+
+func TestFingerprint(t *testing.T) {
+	for _, tc := range fingerprintTests {
+		t.Run(tc.name, func(t *testing.T) {
+			a := report.Fingerprint("GET", "/v1/admin/urls", "*errors.errorString", tc.a)
+			b := report.Fingerprint("GET", "/v1/admin/urls", "*errors.errorString", tc.b)
+			assert.Equal(t, tc.same, a == b)
+		})
+	}
+}
+
+func TestNewEvent(t *testing.T) {
+	req := &report.Request{
+		ID:     "req-1",
+		Method: "POST",
+		Route:  "/v1/admin/url",
+		Actor: func() string {
+			return "admin_key:AbCdEfGh"
+		},
+	}
+	ctx := context.WithValue(context.Background(), report.RequestKey, req) //nolint:staticcheck
+
+	err := fmt.Errorf("insert failure: %w", errors.New("connection refused"))
+	e := report.NewEvent(ctx, err, 0)
+
+	assert.Equal(t, "req-1", e.RequestID)
+	assert.Equal(t, "admin_key:AbCdEfGh", e.Actor)
+	assert.Equal(t, "*errors.errorString", e.Type)
+	assert.Equal(t, err.Error(), e.Message)
+	assert.Equal(t, report.Fingerprint("POST", "/v1/admin/url", e.Type, e.Message), e.Fingerprint)
+	assert.True(t, strings.HasPrefix(e.Stack, "github.com/chutommy/url-shortener/report_test.TestNewEvent"), e.Stack)
+}
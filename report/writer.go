@@ -0,0 +1,62 @@
+package report
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+// WriterReporter writes the events as JSON lines into a writer.
+type WriterReporter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// NewWriterReporter is a constructor of the WriterReporter.
+func NewWriterReporter(w io.Writer) *WriterReporter {
+	return &WriterReporter{w: w}
+}
+
+// Report writes the event as a single JSON line.
+func (r *WriterReporter) Report(_ context.Context, e *Event) error {
+	b, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("failed to encode error event: %w", err)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, err = r.w.Write(append(b, '\n')); err != nil {
+		return fmt.Errorf("failed to write error event: %w", err)
+	}
+
+	return nil
+}
+
+// FileReporter appends the events as JSON lines to a file.
+type FileReporter struct {
+	*WriterReporter
+	f *os.File
+}
+
+// NewFileReporter opens the file for appending and creates it if it does not exist.
+func NewFileReporter(path string) (*FileReporter, error) {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("can not open error report file: %w", err)
+	}
+
+	return &FileReporter{
+		WriterReporter: NewWriterReporter(f),
+		f:              f,
+	}, nil
+}
+
+// Close closes the file.
+func (r *FileReporter) Close() error {
+	return r.f.Close()
+}
@@ -2,13 +2,20 @@ package server
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"errors"
 	"fmt"
+	"io/ioutil"
+	"log/slog"
 	"net/http"
 	"time"
 
 	"github.com/chutommy/url-shortener/config"
 	"github.com/chutommy/url-shortener/controller"
+	"github.com/chutommy/url-shortener/health"
+	"github.com/chutommy/url-shortener/metrics"
+	"github.com/chutommy/url-shortener/tracing"
 )
 
 const (
@@ -27,36 +34,79 @@ type Server interface {
 
 // server implements Server interface.
 type server struct {
+	log        *slog.Logger
 	h          controller.Handler
 	srv        *http.Server
+	metricsSrv *http.Server
+	tracer     *tracing.Tracer
 	srvTimeOut time.Duration
+	drainDelay time.Duration
+	tls        *config.TLS
 }
 
-// NewServer is a constructor of the server.
-func NewServer() Server {
-	return &server{}
+// NewServer is a constructor of the server which logs with the given logger.
+func NewServer(log *slog.Logger) Server {
+	return &server{
+		log: log,
+	}
 }
 
 // Set prepares server to run. Set creates under the hood a new database connection
 // and server structure based on the given configuration + manage routing and endpoints.
 func (s *server) Set(ctx context.Context, cfg *config.Config) error { // set timeout
 	s.srvTimeOut, _ = time.ParseDuration(cfg.SrvTimeOut)
+	s.drainDelay, _ = cfg.Health.Drain()
+
+	// set tracer
+	tracer, err := tracing.New(cfg.Tracing)
+	if err != nil {
+		return fmt.Errorf("failed to set tracer: %w", err)
+	}
+
+	s.tracer = tracer
+	tracing.SetDefault(tracer)
+
+	if tracer != nil {
+		health.Default.Register("trace_exporter", tracer.Running)
+	}
 
 	// set handler
-	if err := s.setHandler(ctx, cfg); err != nil {
+	if err = s.setHandler(ctx, cfg); err != nil {
 		return fmt.Errorf("failed to set handler: %w", err)
 	}
 
 	// set server
-	s.setServer(cfg)
+	if err = s.setServer(cfg); err != nil {
+		return fmt.Errorf("failed to set server: %w", err)
+	}
 
 	return nil
 }
 
 // Run starts the server.
 func (s *server) Run() error {
+	// run metrics listener
+	if s.metricsSrv != nil {
+		go func() {
+			s.log.Info("metrics server is listening", slog.String("addr", s.metricsSrv.Addr))
+
+			if err := s.metricsSrv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				s.log.Error("metrics server failed", slog.Any("error", err))
+			}
+		}()
+	}
+
 	// run server
-	err := s.srv.ListenAndServe()
+	s.h.SetReady(true)
+	s.log.Info("server is listening", slog.String("addr", s.srv.Addr), slog.Bool("tls", s.tls != nil))
+
+	var err error
+	if s.tls != nil {
+		err = s.srv.ListenAndServeTLS(s.tls.CertFile, s.tls.KeyFile)
+	} else {
+		err = s.srv.ListenAndServe()
+	}
+
 	if err != nil && !errors.Is(err, http.ErrServerClosed) {
 		return fmt.Errorf("server can not be launched: %w", err)
 	}
@@ -66,15 +116,33 @@ func (s *server) Run() error {
 
 // Stop stops the server.
 func (s *server) Stop() error {
+	// fail readiness, so the load balancers stop routing new requests
+	s.h.SetReady(false)
+
+	if s.drainDelay > 0 {
+		s.log.Info("server is draining", slog.Duration("delay", s.drainDelay))
+		time.Sleep(s.drainDelay)
+	}
+
 	// stop server
+	s.log.Info("server is shutting down", slog.Duration("timeout", s.srvTimeOut))
+
 	ctx, cancel := context.WithTimeout(context.Background(), s.srvTimeOut)
 	defer cancel()
 
+	var errs []error
 	if err := s.srv.Shutdown(ctx); err != nil {
-		return fmt.Errorf("a forced shutdown failed: %w", err)
+		errs = append(errs, fmt.Errorf("a forced shutdown failed: %w", err))
 	}
 
-	return nil
+	// stop metrics listener, even if the server did not stop in time
+	if s.metricsSrv != nil {
+		if err := s.metricsSrv.Shutdown(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("a forced shutdown of the metrics server failed: %w", err))
+		}
+	}
+
+	return errors.Join(errs...)
 }
 
 // Close closes all open connections and services.
@@ -84,24 +152,71 @@ func (s *server) Close() error { // close handler
 		return fmt.Errorf("an unsuccessful handler's closure: %w", err)
 	}
 
+	// export remaining spans
+	health.Default.Unregister("trace_exporter")
+
+	ctx, cancel := context.WithTimeout(context.Background(), s.srvTimeOut)
+	defer cancel()
+
+	if err = s.tracer.Shutdown(ctx); err != nil {
+		return fmt.Errorf("an unsuccessful tracer's shutdown: %w", err)
+	}
+
 	return nil
 }
 
 // setHandler initializes handler's data service and sets it for the server.
 func (s *server) setHandler(ctx context.Context, cfg *config.Config) error {
 	// initialize handler
-	s.h = controller.NewHandler()
+	s.h = controller.NewHandler(s.log)
 
 	err := s.h.InitDataService(ctx, cfg.DB)
 	if err != nil {
 		return fmt.Errorf("can not init handler's data service: %w", err)
 	}
 
+	err = s.h.InitReporters(cfg.ErrorReporting)
+	if err != nil {
+		return fmt.Errorf("can not init handler's error reporters: %w", err)
+	}
+
+	err = s.h.InitGeoIP(cfg.GeoIP)
+	if err != nil {
+		return fmt.Errorf("can not init handler's geoip: %w", err)
+	}
+
+	err = s.h.InitLive(cfg.Live)
+	if err != nil {
+		return fmt.Errorf("can not init handler's live click stream: %w", err)
+	}
+
+	err = s.h.InitClicks(cfg.Analytics)
+	if err != nil {
+		return fmt.Errorf("can not init handler's click analytics: %w", err)
+	}
+
+	err = s.h.InitRollups(cfg.Rollup)
+	if err != nil {
+		return fmt.Errorf("can not init handler's rollups: %w", err)
+	}
+
+	s.h.InitLimiter(cfg.BruteForce)
+
+	err = s.h.InitSessions(cfg.Session)
+	if err != nil {
+		return fmt.Errorf("can not init handler's sessions: %w", err)
+	}
+
+	s.h.InitOIDC(cfg.OIDC)
+	s.h.InitCertScopes(cfg.TLS)
+	s.h.InitSignatures(cfg.Signing)
+	s.h.InitMetrics(cfg.Metrics)
+
 	return nil
 }
 
 // setServer constructs a server.
-func (s *server) setServer(cfg *config.Config) {
+func (s *server) setServer(cfg *config.Config) error {
 	// get handler with routing applied
 	r := s.h.GetHTTPHandler()
 
@@ -112,5 +227,66 @@ func (s *server) setServer(cfg *config.Config) {
 		ReadTimeout:       readTimeOut * time.Millisecond,
 		ReadHeaderTimeout: readHearTimeout * time.Millisecond,
 		WriteTimeout:      writeTimeout * time.Millisecond,
+		ErrorLog:          slog.NewLogLogger(s.log.Handler(), slog.LevelError),
 	}
+
+	// set separate metrics listener
+	if cfg.Metrics.Enabled() && cfg.Metrics.Listener() != "" {
+		path, _ := cfg.Metrics.Endpoint()
+
+		mux := http.NewServeMux()
+		mux.Handle(path, metrics.Default.Handler())
+
+		s.metricsSrv = &http.Server{
+			Addr:              cfg.Metrics.Listener(),
+			Handler:           mux,
+			ReadHeaderTimeout: readHearTimeout * time.Millisecond,
+			ErrorLog:          slog.NewLogLogger(s.log.Handler(), slog.LevelError),
+		}
+	}
+
+	// set tls
+	if cfg.TLS != nil {
+		tlsCfg, err := newTLSConfig(cfg.TLS)
+		if err != nil {
+			return err
+		}
+
+		s.srv.TLSConfig = tlsCfg
+		s.tls = cfg.TLS
+	}
+
+	return nil
+}
+
+// newTLSConfig creates a TLS configuration of the server which verifies
+// the client certificates against the configured CA bundle.
+func newTLSConfig(tlsCfg *config.TLS) (*tls.Config, error) {
+	c := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+	}
+
+	if tlsCfg.ClientCAFile == "" {
+		return c, nil
+	}
+
+	// load client CAs
+	pem, err := ioutil.ReadFile(tlsCfg.ClientCAFile)
+	if err != nil {
+		return nil, fmt.Errorf("can not read client CA bundle: %w", err)
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, errors.New("client CA bundle contains no valid certificate")
+	}
+
+	c.ClientCAs = pool
+	c.ClientAuth = tls.VerifyClientCertIfGiven
+
+	if tlsCfg.RequireClientCert {
+		c.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+
+	return c, nil
 }
@@ -0,0 +1,38 @@
+package tracing
+
+import (
+	"os"
+
+	"github.com/chutommy/url-shortener/config"
+)
+
+// New creates a tracer with the configured exporter. A nil tracer, which
+// starts no-op spans, is returned if the tracing is disabled.
+func New(trCfg *config.Tracing) (*Tracer, error) {
+	if !trCfg.Enabled() {
+		return nil, nil
+	}
+
+	var exp Exporter
+
+	switch trCfg.Exporter {
+	case config.TraceExporterOTLP:
+		exp = NewOTLPExporter(trCfg.OTLPEndpoint(), trCfg.Service())
+
+	case config.TraceExporterStdout:
+		exp = NewWriterExporter(os.Stdout)
+
+	case config.TraceExporterFile:
+		fe, err := NewFileExporter(trCfg.File)
+		if err != nil {
+			return nil, err
+		}
+
+		exp = fe
+
+	default:
+		return nil, config.ErrInvalidTracing
+	}
+
+	return NewTracer(exp, trCfg.Ratio()), nil
+}
@@ -0,0 +1,378 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	queueSize     = 2048
+	batchSize     = 512
+	flushInterval = 5 * time.Second
+	exportTimeout = 10 * time.Second
+)
+
+// Exporter delivers batches of the finished spans.
+type Exporter interface {
+	Export(context.Context, []*SpanData) error
+	Shutdown(context.Context) error
+}
+
+// batcher queues the finished spans and exports them in batches in the
+// background. Spans are dropped if the queue is full or the batcher is shut
+// down. The queue is never closed, so the spans ended during and after the
+// shutdown are safely dropped.
+type batcher struct {
+	exp   Exporter
+	queue chan *SpanData
+	stop  chan struct{}
+	done  chan struct{}
+	once  sync.Once
+}
+
+func newBatcher(exp Exporter) *batcher {
+	b := &batcher{
+		exp:   exp,
+		queue: make(chan *SpanData, queueSize),
+		stop:  make(chan struct{}),
+		done:  make(chan struct{}),
+	}
+
+	go b.run()
+
+	return b
+}
+
+// enqueue adds the span to the queue unless it is full or the batcher is shut down.
+func (b *batcher) enqueue(s *SpanData) {
+	select {
+	case <-b.stop:
+		return
+	default:
+	}
+
+	select {
+	case b.queue <- s:
+	default:
+	}
+}
+
+// run exports the queued spans when the batch is full or periodically.
+func (b *batcher) run() {
+	defer close(b.done)
+
+	ticker := time.NewTicker(flushInterval)
+	defer ticker.Stop()
+
+	batch := make([]*SpanData, 0, batchSize)
+
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), exportTimeout)
+		defer cancel()
+
+		if err := b.exp.Export(ctx, batch); err != nil {
+			slog.Warn("failed to export spans", slog.Any("error", err), slog.Int("spans", len(batch)))
+		}
+
+		batch = make([]*SpanData, 0, batchSize)
+	}
+
+	add := func(s *SpanData) {
+		batch = append(batch, s)
+		if len(batch) == batchSize {
+			flush()
+		}
+	}
+
+	for {
+		select {
+		case s := <-b.queue:
+			add(s)
+
+		case <-ticker.C:
+			flush()
+
+		case <-b.stop:
+			// export the spans queued before the shutdown
+			for {
+				select {
+				case s := <-b.queue:
+					add(s)
+				default:
+					flush()
+
+					return
+				}
+			}
+		}
+	}
+}
+
+// shutdown exports the queued spans and stops the exporter.
+func (b *batcher) shutdown(ctx context.Context) error {
+	b.once.Do(func() {
+		close(b.stop)
+	})
+
+	select {
+	case <-b.done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	return b.exp.Shutdown(ctx)
+}
+
+// jsonSpan is the JSON line representation of a span.
+type jsonSpan struct {
+	TraceID       string                 `json:"trace_id"`
+	SpanID        string                 `json:"span_id"`
+	ParentSpanID  string                 `json:"parent_span_id,omitempty"`
+	Name          string                 `json:"name"`
+	Kind          int                    `json:"kind"`
+	Start         time.Time              `json:"start"`
+	End           time.Time              `json:"end"`
+	DurationMS    float64                `json:"duration_ms"`
+	Attributes    map[string]interface{} `json:"attributes,omitempty"`
+	StatusCode    int                    `json:"status_code,omitempty"`
+	StatusMessage string                 `json:"status_message,omitempty"`
+}
+
+// WriterExporter writes the spans as JSON lines, it is usable without a collector.
+type WriterExporter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// NewWriterExporter is a constructor of the WriterExporter.
+func NewWriterExporter(w io.Writer) *WriterExporter {
+	return &WriterExporter{w: w}
+}
+
+// NewFileExporter appends the spans to the file which is created if it does not exist.
+func NewFileExporter(path string) (*WriterExporter, error) {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("can not open trace file: %w", err)
+	}
+
+	return NewWriterExporter(f), nil
+}
+
+// Export writes the spans.
+func (e *WriterExporter) Export(_ context.Context, spans []*SpanData) error {
+	var buf bytes.Buffer
+
+	enc := json.NewEncoder(&buf)
+
+	for _, s := range spans {
+		js := jsonSpan{
+			TraceID:       s.TraceID.String(),
+			SpanID:        s.SpanID.String(),
+			Name:          s.Name,
+			Kind:          s.Kind,
+			Start:         s.Start,
+			End:           s.End,
+			DurationMS:    float64(s.End.Sub(s.Start).Microseconds()) / 1000,
+			StatusCode:    s.StatusCode,
+			StatusMessage: s.StatusMessage,
+		}
+
+		if s.Parent.IsValid() {
+			js.ParentSpanID = s.Parent.String()
+		}
+
+		if len(s.Attrs) > 0 {
+			js.Attributes = make(map[string]interface{}, len(s.Attrs))
+			for _, a := range s.Attrs {
+				js.Attributes[a.Key] = a.Value
+			}
+		}
+
+		if err := enc.Encode(js); err != nil {
+			return fmt.Errorf("failed to encode span: %w", err)
+		}
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if _, err := e.w.Write(buf.Bytes()); err != nil {
+		return fmt.Errorf("failed to write spans: %w", err)
+	}
+
+	return nil
+}
+
+// Shutdown closes the underlying writer if it is a file.
+func (e *WriterExporter) Shutdown(context.Context) error {
+	if f, ok := e.w.(*os.File); ok && f != os.Stdout && f != os.Stderr {
+		return f.Close()
+	}
+
+	return nil
+}
+
+// OTLPExporter sends the spans to an OpenTelemetry collector by OTLP/HTTP with JSON encoding.
+type OTLPExporter struct {
+	endpoint    string
+	serviceName string
+	client      *http.Client
+}
+
+// NewOTLPExporter is a constructor of the OTLPExporter. The endpoint is the full
+// URL of the traces, such as http://localhost:4318/v1/traces.
+func NewOTLPExporter(endpoint, serviceName string) *OTLPExporter {
+	return &OTLPExporter{
+		endpoint:    endpoint,
+		serviceName: serviceName,
+		client:      &http.Client{Timeout: exportTimeout},
+	}
+}
+
+// OTLP/JSON representation of the export request.
+type (
+	otlpRequest struct {
+		ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+	}
+
+	otlpResourceSpans struct {
+		Resource   otlpResource     `json:"resource"`
+		ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+	}
+
+	otlpResource struct {
+		Attributes []otlpAttr `json:"attributes"`
+	}
+
+	otlpScopeSpans struct {
+		Scope otlpScope  `json:"scope"`
+		Spans []otlpSpan `json:"spans"`
+	}
+
+	otlpScope struct {
+		Name string `json:"name"`
+	}
+
+	otlpSpan struct {
+		TraceID           string     `json:"traceId"`
+		SpanID            string     `json:"spanId"`
+		TraceState        string     `json:"traceState,omitempty"`
+		ParentSpanID      string     `json:"parentSpanId,omitempty"`
+		Name              string     `json:"name"`
+		Kind              int        `json:"kind"`
+		StartTimeUnixNano string     `json:"startTimeUnixNano"`
+		EndTimeUnixNano   string     `json:"endTimeUnixNano"`
+		Attributes        []otlpAttr `json:"attributes,omitempty"`
+		Status            otlpStatus `json:"status"`
+	}
+
+	otlpStatus struct {
+		Code    int    `json:"code,omitempty"`
+		Message string `json:"message,omitempty"`
+	}
+
+	otlpAttr struct {
+		Key   string                 `json:"key"`
+		Value map[string]interface{} `json:"value"`
+	}
+)
+
+// otlpValue converts the attribute value into the OTLP AnyValue.
+func otlpValue(v interface{}) map[string]interface{} {
+	switch v := v.(type) {
+	case string:
+		return map[string]interface{}{"stringValue": v}
+	case int64:
+		return map[string]interface{}{"intValue": strconv.FormatInt(v, 10)}
+	case bool:
+		return map[string]interface{}{"boolValue": v}
+	case float64:
+		return map[string]interface{}{"doubleValue": v}
+	default:
+		return map[string]interface{}{"stringValue": fmt.Sprint(v)}
+	}
+}
+
+// Export sends the spans to the collector.
+func (e *OTLPExporter) Export(ctx context.Context, spans []*SpanData) error {
+	ss := make([]otlpSpan, 0, len(spans))
+
+	for _, s := range spans {
+		o := otlpSpan{
+			TraceID:           s.TraceID.String(),
+			SpanID:            s.SpanID.String(),
+			TraceState:        s.TraceState,
+			Name:              s.Name,
+			Kind:              s.Kind,
+			StartTimeUnixNano: strconv.FormatInt(s.Start.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(s.End.UnixNano(), 10),
+			Status:            otlpStatus{Code: s.StatusCode, Message: s.StatusMessage},
+		}
+
+		if s.Parent.IsValid() {
+			o.ParentSpanID = s.Parent.String()
+		}
+
+		for _, a := range s.Attrs {
+			o.Attributes = append(o.Attributes, otlpAttr{Key: a.Key, Value: otlpValue(a.Value)})
+		}
+
+		ss = append(ss, o)
+	}
+
+	body, err := json.Marshal(otlpRequest{ResourceSpans: []otlpResourceSpans{{
+		Resource: otlpResource{Attributes: []otlpAttr{
+			{Key: "service.name", Value: otlpValue(e.serviceName)},
+		}},
+		ScopeSpans: []otlpScopeSpans{{
+			Scope: otlpScope{Name: e.serviceName},
+			Spans: ss,
+		}},
+	}}})
+	if err != nil {
+		return fmt.Errorf("failed to encode spans: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create export request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to export spans: %w", err)
+	}
+
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("failed to export spans: collector responded with %s", resp.Status)
+	}
+
+	return nil
+}
+
+// Shutdown releases the idle connections to the collector.
+func (e *OTLPExporter) Shutdown(context.Context) error {
+	e.client.CloseIdleConnections()
+
+	return nil
+}
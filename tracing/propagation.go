@@ -0,0 +1,128 @@
+package tracing
+
+import (
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Headers of the W3C trace context.
+const (
+	TraceparentHeader = "traceparent"
+	TracestateHeader  = "tracestate"
+)
+
+const (
+	traceparentVersion = "00"
+	flagSampled        = 0x01
+)
+
+// Extract reads the W3C trace context of the headers. The returned span context
+// is invalid if there is no valid traceparent header.
+func Extract(h http.Header) SpanContext {
+	sc, ok := ParseTraceparent(h.Get(TraceparentHeader))
+	if !ok {
+		return SpanContext{}
+	}
+
+	sc.TraceState = h.Get(TracestateHeader)
+
+	return sc
+}
+
+// Inject writes the span context as the W3C trace context into the headers.
+func Inject(sc SpanContext, h http.Header) {
+	if !sc.TraceID.IsValid() || !sc.SpanID.IsValid() {
+		return
+	}
+
+	h.Set(TraceparentHeader, FormatTraceparent(sc))
+
+	if sc.TraceState != "" {
+		h.Set(TracestateHeader, sc.TraceState)
+	}
+}
+
+// ParseTraceparent parses a traceparent header value.
+func ParseTraceparent(v string) (SpanContext, bool) {
+	parts := strings.Split(strings.TrimSpace(v), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" ||
+		(parts[0] == traceparentVersion && len(parts) != 4) {
+		return SpanContext{}, false
+	}
+
+	var sc SpanContext
+
+	if !decodeHex(parts[1], sc.TraceID[:]) || !decodeHex(parts[2], sc.SpanID[:]) ||
+		!sc.TraceID.IsValid() || !sc.SpanID.IsValid() {
+		return SpanContext{}, false
+	}
+
+	var flags [1]byte
+	if !decodeHex(parts[3], flags[:]) {
+		return SpanContext{}, false
+	}
+
+	sc.Sampled = flags[0]&flagSampled != 0
+
+	return sc, true
+}
+
+// FormatTraceparent formats the span context as a traceparent header value.
+func FormatTraceparent(sc SpanContext) string {
+	var flags byte
+	if sc.Sampled {
+		flags = flagSampled
+	}
+
+	return fmt.Sprintf("%s-%s-%s-%02x", traceparentVersion, sc.TraceID, sc.SpanID, flags)
+}
+
+// decodeHex decodes the lowercase hex string into the destination of the exact length.
+func decodeHex(s string, dst []byte) bool {
+	if len(s) != hex.EncodedLen(len(dst)) || strings.ToLower(s) != s {
+		return false
+	}
+
+	_, err := hex.Decode(dst, []byte(s))
+
+	return err == nil
+}
+
+// Transport creates client spans of the outgoing requests and propagates
+// the trace context to the called services.
+type Transport struct {
+	Base http.RoundTripper
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *Transport) RoundTrip(r *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	_, span := Default().Start(r.Context(), "HTTP "+r.Method, KindClient,
+		String("http.method", r.Method),
+		String("http.url", r.URL.Redacted()),
+	)
+	defer span.End()
+
+	if span != nil {
+		// requests must not be modified by the round trippers
+		r = r.Clone(r.Context())
+		Inject(span.Context(), r.Header)
+	}
+
+	resp, err := base.RoundTrip(r)
+	if err != nil {
+		span.SetError(err)
+
+		return nil, err
+	}
+
+	span.SetAttrs(Int("http.status_code", resp.StatusCode))
+
+	return resp, nil
+}
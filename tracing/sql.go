@@ -0,0 +1,135 @@
+package tracing
+
+import (
+	"context"
+	"database/sql/driver"
+)
+
+const maxStatementLen = 2048
+
+// WrapConnector wraps the database connector, so every executed SQL statement
+// is recorded as a client span of the context's span. The span of a query ends
+// when the query returns, the iteration of its rows is not included.
+func WrapConnector(c driver.Connector, system string) driver.Connector {
+	return &connector{Connector: c, system: system}
+}
+
+type connector struct {
+	driver.Connector
+	system string
+}
+
+// Connect opens a traced connection.
+func (c *connector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := c.Connector.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return &tracedConn{Conn: conn, system: c.system}, nil
+}
+
+// tracedConn records the statements executed on the connection.
+type tracedConn struct {
+	driver.Conn
+	system string
+}
+
+// startStatement starts a span of the SQL statement.
+func (c *tracedConn) startStatement(ctx context.Context, op, query string) *Span {
+	if FromContext(ctx) == nil {
+		return nil
+	}
+
+	if len(query) > maxStatementLen {
+		query = query[:maxStatementLen]
+	}
+
+	_, span := Default().Start(ctx, "sql."+op, KindClient,
+		String("db.system", c.system),
+		String("db.statement", query),
+	)
+
+	return span
+}
+
+// ExecContext implements driver.ExecerContext.
+func (c *tracedConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	execer, ok := c.Conn.(driver.ExecerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+
+	span := c.startStatement(ctx, "exec", query)
+	defer span.End()
+
+	res, err := execer.ExecContext(ctx, query, args)
+	if err != nil && err != driver.ErrSkip { //nolint:errorlint
+		span.SetError(err)
+	}
+
+	return res, err
+}
+
+// QueryContext implements driver.QueryerContext.
+func (c *tracedConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	queryer, ok := c.Conn.(driver.QueryerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+
+	span := c.startStatement(ctx, "query", query)
+	defer span.End()
+
+	rows, err := queryer.QueryContext(ctx, query, args)
+	if err != nil && err != driver.ErrSkip { //nolint:errorlint
+		span.SetError(err)
+	}
+
+	return rows, err
+}
+
+// PrepareContext implements driver.ConnPrepareContext.
+func (c *tracedConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	if p, ok := c.Conn.(driver.ConnPrepareContext); ok {
+		return p.PrepareContext(ctx, query)
+	}
+
+	return c.Conn.Prepare(query)
+}
+
+// BeginTx implements driver.ConnBeginTx.
+func (c *tracedConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if b, ok := c.Conn.(driver.ConnBeginTx); ok {
+		return b.BeginTx(ctx, opts)
+	}
+
+	return c.Conn.Begin() //nolint:staticcheck
+}
+
+// Ping implements driver.Pinger.
+func (c *tracedConn) Ping(ctx context.Context) error {
+	if p, ok := c.Conn.(driver.Pinger); ok {
+		return p.Ping(ctx)
+	}
+
+	return nil
+}
+
+// ResetSession implements driver.SessionResetter.
+func (c *tracedConn) ResetSession(ctx context.Context) error {
+	if r, ok := c.Conn.(driver.SessionResetter); ok {
+		return r.ResetSession(ctx)
+	}
+
+	return nil
+}
+
+// CheckNamedValue implements driver.NamedValueChecker.
+func (c *tracedConn) CheckNamedValue(nv *driver.NamedValue) error {
+	if ch, ok := c.Conn.(driver.NamedValueChecker); ok {
+		return ch.CheckNamedValue(nv)
+	}
+
+	return driver.ErrSkip
+}
@@ -0,0 +1,265 @@
+// Package tracing records OpenTelemetry compatible spans, propagates the W3C
+// trace context and exports the finished spans by OTLP or as JSON lines.
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// SpanKey is the context key of the current span. It is a plain string, so
+// the span can be stored in and loaded from a *gin.Context.
+const SpanKey = "trace_span"
+
+// Kinds of the spans.
+const (
+	KindInternal = 1
+	KindServer   = 2
+	KindClient   = 3
+)
+
+// Status codes of the spans.
+const (
+	StatusUnset = 0
+	StatusOK    = 1
+	StatusError = 2
+)
+
+// TraceID identifies a trace.
+type TraceID [16]byte
+
+// SpanID identifies a span.
+type SpanID [8]byte
+
+// String returns the lowercase hex encoding of the ID.
+func (t TraceID) String() string { return hex.EncodeToString(t[:]) }
+
+// String returns the lowercase hex encoding of the ID.
+func (s SpanID) String() string { return hex.EncodeToString(s[:]) }
+
+// IsValid reports whether the ID is not all zeros.
+func (t TraceID) IsValid() bool { return t != TraceID{} }
+
+// IsValid reports whether the ID is not all zeros.
+func (s SpanID) IsValid() bool { return s != SpanID{} }
+
+// SpanContext is the propagated part of a span.
+type SpanContext struct {
+	TraceID    TraceID
+	SpanID     SpanID
+	Sampled    bool
+	TraceState string
+}
+
+// Attr is an attribute of a span.
+type Attr struct {
+	Key   string
+	Value interface{}
+}
+
+// String returns a string attribute.
+func String(key, value string) Attr { return Attr{Key: key, Value: value} }
+
+// Int returns an integer attribute.
+func Int(key string, value int) Attr { return Attr{Key: key, Value: int64(value)} }
+
+// Bool returns a boolean attribute.
+func Bool(key string, value bool) Attr { return Attr{Key: key, Value: value} }
+
+// SpanData is a finished span handed to the exporters.
+type SpanData struct {
+	SpanContext
+	Parent        SpanID
+	Name          string
+	Kind          int
+	Start         time.Time
+	End           time.Time
+	Attrs         []Attr
+	StatusCode    int
+	StatusMessage string
+}
+
+// Span is an operation in progress. A nil *Span is a valid no-op span.
+type Span struct {
+	mu     sync.Mutex
+	data   SpanData
+	tracer *Tracer
+	ended  bool
+}
+
+// Context returns the span context. The zero value is returned for a nil span.
+func (s *Span) Context() SpanContext {
+	if s == nil {
+		return SpanContext{}
+	}
+
+	return s.data.SpanContext
+}
+
+// SetAttrs adds the attributes to the span.
+func (s *Span) SetAttrs(attrs ...Attr) {
+	if s == nil {
+		return
+	}
+
+	s.mu.Lock()
+	s.data.Attrs = append(s.data.Attrs, attrs...)
+	s.mu.Unlock()
+}
+
+// SetError marks the span as failed by the error. Nil errors are ignored.
+func (s *Span) SetError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+
+	s.mu.Lock()
+	s.data.StatusCode = StatusError
+	s.data.StatusMessage = err.Error()
+	s.mu.Unlock()
+}
+
+// End finishes the span and hands it to the exporter if it is sampled.
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+
+		return
+	}
+
+	s.ended = true
+	s.data.End = time.Now()
+	data := s.data
+	s.mu.Unlock()
+
+	if data.Sampled && s.tracer.proc != nil {
+		s.tracer.proc.enqueue(&data)
+	}
+}
+
+// Tracer starts the spans. A nil *Tracer starts no-op spans.
+type Tracer struct {
+	ratio uint64
+	proc  *batcher
+}
+
+// NewTracer creates a tracer which samples the given ratio of the new traces
+// and exports them by the exporter.
+func NewTracer(exp Exporter, sampleRatio float64) *Tracer {
+	t := &Tracer{proc: newBatcher(exp)}
+
+	switch {
+	case sampleRatio >= 1:
+		t.ratio = ^uint64(0)
+	case sampleRatio > 0:
+		t.ratio = uint64(sampleRatio * float64(^uint64(0)))
+	}
+
+	return t
+}
+
+// Shutdown exports the remaining spans and stops the exporter.
+func (t *Tracer) Shutdown(ctx context.Context) error {
+	if t == nil {
+		return nil
+	}
+
+	return t.proc.shutdown(ctx)
+}
+
+// defaultTracer is the tracer of the package-level functions.
+var defaultTracer atomic.Value
+
+// SetDefault sets the tracer of the package-level functions.
+func SetDefault(t *Tracer) {
+	defaultTracer.Store(t)
+}
+
+// Default returns the tracer of the package-level functions.
+func Default() *Tracer {
+	t, _ := defaultTracer.Load().(*Tracer)
+
+	return t
+}
+
+// Start starts an internal span by the default tracer as a child of the context's span.
+func Start(ctx context.Context, name string, attrs ...Attr) (context.Context, *Span) {
+	return Default().Start(ctx, name, KindInternal, attrs...)
+}
+
+// Start starts a span as a child of the context's span. The returned context carries the new span.
+func (t *Tracer) Start(ctx context.Context, name string, kind int, attrs ...Attr) (context.Context, *Span) {
+	return t.StartWithParent(ctx, FromContext(ctx).Context(), name, kind, attrs...)
+}
+
+// StartWithParent starts a span as a child of the parent span context, which can be
+// a remote one. A new trace is started if the parent is not valid.
+func (t *Tracer) StartWithParent(ctx context.Context, parent SpanContext, name string, kind int,
+	attrs ...Attr) (context.Context, *Span) {
+	if t == nil {
+		return ctx, nil
+	}
+
+	sc := SpanContext{
+		TraceID:    parent.TraceID,
+		Sampled:    parent.Sampled,
+		TraceState: parent.TraceState,
+	}
+
+	// start a new trace
+	if !parent.TraceID.IsValid() {
+		_, _ = rand.Read(sc.TraceID[:])
+		sc.Sampled = binary.BigEndian.Uint64(sc.TraceID[8:]) < t.ratio || t.ratio == ^uint64(0)
+	}
+
+	_, _ = rand.Read(sc.SpanID[:])
+
+	s := &Span{
+		tracer: t,
+		data: SpanData{
+			SpanContext: sc,
+			Parent:      parent.SpanID,
+			Name:        name,
+			Kind:        kind,
+			Start:       time.Now(),
+			Attrs:       attrs,
+		},
+	}
+
+	return context.WithValue(ctx, SpanKey, s), s //nolint:staticcheck
+}
+
+// FromContext returns the current span of the context or nil if there is none.
+func FromContext(ctx context.Context) *Span {
+	if ctx == nil {
+		return nil
+	}
+
+	s, _ := ctx.Value(SpanKey).(*Span)
+
+	return s
+}
+
+// Running reports whether the exporter of the spans is running.
+func (t *Tracer) Running() bool {
+	if t == nil {
+		return true
+	}
+
+	select {
+	case <-t.proc.done:
+		return false
+	default:
+		return true
+	}
+}
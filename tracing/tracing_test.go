@@ -0,0 +1,128 @@
+package tracing_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/chutommy/url-shortener/tracing"
+	"github.com/stretchr/testify/assert"
+)
+
+var parseTraceparentTests = []struct {
+	name    string
+	v       string
+	ok      bool
+	sampled bool
+}{
+	{
+		name:    "sampled",
+		v:       "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		ok:      true,
+		sampled: true,
+	},
+	{
+		name: "not sampled",
+		v:    "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00",
+		ok:   true,
+	},
+	{
+		name: "zero trace id",
+		v:    "00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+	},
+	{
+		name: "uppercase",
+		v:    "00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01",
+	},
+	{
+		name: "forbidden version",
+		v:    "ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+	},
+	{
+		name: "missing flags",
+		v:    "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7",
+	},
+}
+
+func TestParseTraceparent(t *testing.T) {
+	for _, tc := range parseTraceparentTests {
+		t.Run(tc.name, func(t *testing.T) {
+			sc, ok := tracing.ParseTraceparent(tc.v)
+			assert.Equal(t, tc.ok, ok)
+
+			if ok {
+				assert.Equal(t, tc.sampled, sc.Sampled)
+				assert.Equal(t, tc.v, tracing.FormatTraceparent(sc))
+			}
+		})
+	}
+}
+
+func TestTracer_Start(t *testing.T) {
+	var buf bytes.Buffer
+
+	tracer := tracing.NewTracer(tracing.NewWriterExporter(&buf), 1)
+
+	// continue the remote trace
+	h := http.Header{}
+	h.Set(tracing.TraceparentHeader, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+
+	ctx, server := tracer.StartWithParent(context.Background(), tracing.Extract(h), "GET /", tracing.KindServer)
+	_, child := tracer.Start(ctx, "data.GetAllRecords", tracing.KindInternal)
+	child.End()
+	server.End()
+
+	assert.Nil(t, tracer.Shutdown(context.Background()))
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if !assert.Len(t, lines, 2) {
+		return
+	}
+
+	var spans []map[string]interface{}
+
+	for _, l := range lines {
+		var s map[string]interface{}
+		assert.Nil(t, json.Unmarshal([]byte(l), &s))
+
+		spans = append(spans, s)
+	}
+
+	assert.Equal(t, "data.GetAllRecords", spans[0]["name"])
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", spans[0]["trace_id"])
+	assert.Equal(t, spans[1]["span_id"], spans[0]["parent_span_id"])
+	assert.Equal(t, "00f067aa0ba902b7", spans[1]["parent_span_id"])
+}
+
+func TestTracer_StartNotSampled(t *testing.T) {
+	var buf bytes.Buffer
+
+	tracer := tracing.NewTracer(tracing.NewWriterExporter(&buf), 0)
+
+	_, span := tracer.Start(context.Background(), "GET /", tracing.KindServer)
+	span.End()
+
+	assert.Nil(t, tracer.Shutdown(context.Background()))
+	assert.Empty(t, buf.String())
+}
+
+func TestTracer_EndAfterShutdown(t *testing.T) {
+	var buf bytes.Buffer
+
+	tracer := tracing.NewTracer(tracing.NewWriterExporter(&buf), 1)
+
+	_, late := tracer.Start(context.Background(), "GET /slow", tracing.KindServer)
+
+	assert.Nil(t, tracer.Shutdown(context.Background()))
+	assert.Nil(t, tracer.Shutdown(context.Background()))
+
+	// the spans ended after the shutdown are dropped
+	assert.NotPanics(t, late.End)
+
+	_, span := tracer.Start(context.Background(), "data.GetAllRecords", tracing.KindInternal)
+	assert.NotPanics(t, span.End)
+	assert.Empty(t, buf.String())
+}
@@ -0,0 +1,135 @@
+// Package useragent classifies the User-Agent header of a visitor into the
+// browser, operating system and device class.
+package useragent
+
+import "strings"
+
+// Device classes.
+const (
+	DeviceDesktop = "desktop"
+	DeviceMobile  = "mobile"
+	DeviceTablet  = "tablet"
+	DeviceBot     = "bot"
+	DeviceUnknown = "unknown"
+)
+
+// Other is the browser or the operating system which is not recognized.
+const Other = "Other"
+
+// Agent is the classified user agent.
+type Agent struct {
+	Browser string `json:"browser"`
+	OS      string `json:"os"`
+	Device  string `json:"device"`
+}
+
+// token maps a substring of the user agent to a name. The first matching token wins.
+type token struct {
+	substr string
+	name   string
+}
+
+var (
+	botTokens = []string{
+		"bot", "crawl", "spider", "slurp", "facebookexternalhit", "preview", "curl/", "wget/",
+		"python-requests", "python-urllib", "go-http-client", "okhttp", "java/", "headlesschrome",
+		"whatsapp/", "mastodon/", "embedly", "iframely", "lighthouse",
+	}
+
+	browserTokens = []token{
+		{"edg/", "Edge"},
+		{"edga/", "Edge"},
+		{"edgios/", "Edge"},
+		{"opr/", "Opera"},
+		{"opera", "Opera"},
+		{"samsungbrowser/", "Samsung Internet"},
+		{"yabrowser/", "Yandex Browser"},
+		{"ucbrowser/", "UC Browser"},
+		{"firefox/", "Firefox"},
+		{"fxios/", "Firefox"},
+		{"crios/", "Chrome"},
+		{"chromium/", "Chromium"},
+		{"chrome/", "Chrome"},
+		{"msie ", "Internet Explorer"},
+		{"trident/", "Internet Explorer"},
+		{"safari/", "Safari"},
+	}
+
+	osTokens = []token{
+		{"windows phone", "Windows Phone"},
+		{"windows", "Windows"},
+		{"iphone", "iOS"},
+		{"ipad", "iOS"},
+		{"ipod", "iOS"},
+		{"android", "Android"},
+		{"cros", "ChromeOS"},
+		{"mac os x", "macOS"},
+		{"macintosh", "macOS"},
+		{"linux", "Linux"},
+		{"freebsd", "FreeBSD"},
+	}
+
+	tabletTokens  = []string{"ipad", "tablet", "kindle", "silk/", "playbook"}
+	mobileTokens  = []string{"mobi", "iphone", "ipod", "windows phone", "blackberry", "opera mini"}
+	desktopTokens = []string{"windows", "macintosh", "x11", "linux", "cros"}
+)
+
+// Parse classifies the user agent. An empty user agent results in an
+// unknown device with other browser and operating system.
+func Parse(ua string) Agent {
+	s := strings.ToLower(ua)
+
+	a := Agent{
+		Browser: match(s, browserTokens),
+		OS:      match(s, osTokens),
+		Device:  device(s),
+	}
+
+	// bots often mimic the browsers
+	if a.Device == DeviceBot {
+		a.Browser = Other
+	}
+
+	return a
+}
+
+// match returns the name of the first token found in s.
+func match(s string, tokens []token) string {
+	for _, t := range tokens {
+		if strings.Contains(s, t.substr) {
+			return t.name
+		}
+	}
+
+	return Other
+}
+
+// device returns the device class of the lowercase user agent.
+func device(s string) string {
+	switch {
+	case s == "":
+		return DeviceUnknown
+	case containsAny(s, botTokens):
+		return DeviceBot
+	case containsAny(s, tabletTokens),
+		strings.Contains(s, "android") && !strings.Contains(s, "mobi"):
+		return DeviceTablet
+	case containsAny(s, mobileTokens), strings.Contains(s, "android"):
+		return DeviceMobile
+	case containsAny(s, desktopTokens):
+		return DeviceDesktop
+	default:
+		return DeviceUnknown
+	}
+}
+
+// containsAny reports whether s contains any of the substrings.
+func containsAny(s string, substrs []string) bool {
+	for _, sub := range substrs {
+		if strings.Contains(s, sub) {
+			return true
+		}
+	}
+
+	return false
+}
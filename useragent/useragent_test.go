@@ -0,0 +1,78 @@
+package useragent_test
+
+import (
+	"testing"
+
+	"github.com/chutommy/url-shortener/useragent"
+	"github.com/stretchr/testify/assert"
+)
+
+var parseTests = []struct {
+	name string
+	ua   string
+	exp  useragent.Agent
+}{
+	{
+		name: "chrome on windows",
+		ua:   "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36",
+		exp:  useragent.Agent{Browser: "Chrome", OS: "Windows", Device: useragent.DeviceDesktop},
+	},
+	{
+		name: "edge on windows",
+		ua: "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) " +
+			"Chrome/120.0.0.0 Safari/537.36 Edg/120.0.2210.91",
+		exp: useragent.Agent{Browser: "Edge", OS: "Windows", Device: useragent.DeviceDesktop},
+	},
+	{
+		name: "safari on iphone",
+		ua: "Mozilla/5.0 (iPhone; CPU iPhone OS 17_1 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) " +
+			"Version/17.1 Mobile/15E148 Safari/604.1",
+		exp: useragent.Agent{Browser: "Safari", OS: "iOS", Device: useragent.DeviceMobile},
+	},
+	{
+		name: "safari on ipad",
+		ua: "Mozilla/5.0 (iPad; CPU OS 16_6 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) " +
+			"Version/16.6 Mobile/15E148 Safari/604.1",
+		exp: useragent.Agent{Browser: "Safari", OS: "iOS", Device: useragent.DeviceTablet},
+	},
+	{
+		name: "firefox on linux",
+		ua:   "Mozilla/5.0 (X11; Linux x86_64; rv:121.0) Gecko/20100101 Firefox/121.0",
+		exp:  useragent.Agent{Browser: "Firefox", OS: "Linux", Device: useragent.DeviceDesktop},
+	},
+	{
+		name: "chrome on android phone",
+		ua: "Mozilla/5.0 (Linux; Android 14; Pixel 8) AppleWebKit/537.36 (KHTML, like Gecko) " +
+			"Chrome/120.0.0.0 Mobile Safari/537.36",
+		exp: useragent.Agent{Browser: "Chrome", OS: "Android", Device: useragent.DeviceMobile},
+	},
+	{
+		name: "android tablet",
+		ua: "Mozilla/5.0 (Linux; Android 13; SM-X200) AppleWebKit/537.36 (KHTML, like Gecko) " +
+			"Chrome/120.0.0.0 Safari/537.36",
+		exp: useragent.Agent{Browser: "Chrome", OS: "Android", Device: useragent.DeviceTablet},
+	},
+	{
+		name: "googlebot",
+		ua:   "Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)",
+		exp:  useragent.Agent{Browser: useragent.Other, OS: useragent.Other, Device: useragent.DeviceBot},
+	},
+	{
+		name: "curl",
+		ua:   "curl/8.4.0",
+		exp:  useragent.Agent{Browser: useragent.Other, OS: useragent.Other, Device: useragent.DeviceBot},
+	},
+	{
+		name: "empty",
+		ua:   "",
+		exp:  useragent.Agent{Browser: useragent.Other, OS: useragent.Other, Device: useragent.DeviceUnknown},
+	},
+}
+
+func TestParse(t *testing.T) {
+	for _, tc := range parseTests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.exp, useragent.Parse(tc.ua))
+		})
+	}
+}